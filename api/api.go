@@ -5,25 +5,66 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	echo "github.com/labstack/echo/v4"
 	echomiddleware "github.com/labstack/echo/v4/middleware"
+	"github.com/reyhanfikridz/ecom-order-service/internal/audit"
 	"github.com/reyhanfikridz/ecom-order-service/internal/config"
+	"github.com/reyhanfikridz/ecom-order-service/internal/idempotency"
 	"github.com/reyhanfikridz/ecom-order-service/internal/middleware"
 	"github.com/reyhanfikridz/ecom-order-service/internal/model"
+	"github.com/reyhanfikridz/ecom-order-service/internal/outbox"
+	"github.com/reyhanfikridz/ecom-order-service/internal/policy"
+	"github.com/reyhanfikridz/ecom-order-service/internal/problem"
+	"github.com/reyhanfikridz/ecom-order-service/internal/product"
+	"github.com/reyhanfikridz/ecom-order-service/internal/repository"
+	"github.com/reyhanfikridz/ecom-order-service/internal/utils"
 	"github.com/reyhanfikridz/ecom-order-service/internal/validator"
+	"github.com/reyhanfikridz/ecom-order-service/internal/webhook"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// API contain context, map of mongodb collection, and echo router
+const (
+	// addOrderOperation idempotency operation of add order routes,
+	// shared by legacy and versioned route
+	addOrderOperation = "add-order"
+
+	// defaultListLimit default total orders in one page
+	defaultListLimit = 20
+
+	// maxListLimit max total orders in one page
+	maxListLimit = 100
+)
+
+// API contain context, map of mongodb collection,
+// order and audit entry repository, order event outbox and its publisher,
+// webhook repository, product service, idempotency key storage,
+// currency of new orders, token authorizer, API keys of internal services,
+// permission matrix policy, and echo router
 type API struct {
 	Ctx         context.Context
 	Collections map[string]*mongo.Collection
+	Orders      repository.OrderRepository
+	Audit       audit.Repository
+	Outbox      outbox.Store
+	Publisher   outbox.Publisher
+	Webhooks    webhook.Repository
+	Products    product.Service
+	Idempotency idempotency.Store
+	Currency    string
+	Authorizer  middleware.TokenAuthorizer
+	ServiceKeys middleware.ServiceKeys
+	Policy      *policy.Policy
 	Echo        *echo.Echo
 }
 
@@ -82,14 +123,142 @@ func (a *API) InitCollections(DBName string) error {
 	// put collection orders to map of collection
 	a.Collections["orders"] = DB.Collection("orders")
 
+	// create unique index of order number
+	err = model.CreateOrderIndexes(a.Ctx, a.Collections["orders"])
+	if err != nil {
+		return err
+	}
+
+	// set timestamps and version of orders created before they're recorded
+	_, err = model.MigrateOrderTimestamps(a.Ctx, a.Collections["orders"])
+	if err != nil {
+		return err
+	}
+
+	// rewrite prices of orders stored as double into exact amount,
+	// and set currency of orders created before currency recorded
+	_, err = model.MigrateOrderMoney(a.Ctx, a.Collections["orders"],
+		config.Currency)
+	if err != nil {
+		return err
+	}
+
+	// use orders collection as order repository
+	gen, err := utils.NewOrderNumberGenerator(config.OrderNumberFormat,
+		config.OrderNumberPrefix)
+	if err != nil {
+		return err
+	}
+	a.Orders = repository.NewMongoOrderRepository(a.Collections["orders"], gen)
+
+	// use order outbox collection, where order events written
+	// together with the order changes, as order event storage
+	a.Collections[model.OrderOutboxCollection] = DB.Collection(
+		model.OrderOutboxCollection)
+	err = model.CreateOrderOutboxIndexes(a.Ctx,
+		a.Collections[model.OrderOutboxCollection])
+	if err != nil {
+		return err
+	}
+	a.Outbox = outbox.NewMongoStore(a.Collections[model.OrderOutboxCollection])
+
+	// use order history collection as audit entry repository
+	a.Collections["order_history"] = DB.Collection("order_history")
+	auditRepository := audit.NewMongoRepository(a.Collections["order_history"])
+	err = auditRepository.CreateIndexes(a.Ctx)
+	if err != nil {
+		return err
+	}
+	a.Audit = auditRepository
+
+	// use webhook subscriptions and deliveries collection
+	// as webhook repository
+	a.Collections["webhook_subscriptions"] = DB.Collection(
+		"webhook_subscriptions")
+	a.Collections["webhook_deliveries"] = DB.Collection("webhook_deliveries")
+	webhookRepository := webhook.NewMongoRepository(
+		a.Collections["webhook_subscriptions"],
+		a.Collections["webhook_deliveries"])
+	err = webhookRepository.CreateIndexes(a.Ctx)
+	if err != nil {
+		return err
+	}
+	a.Webhooks = webhookRepository
+
+	// use idempotency keys collection as idempotency key storage,
+	// so the keys shared by every instance of the service
+	a.Collections["idempotency_keys"] = DB.Collection("idempotency_keys")
+	idempotencyStore := idempotency.NewMongoStore(
+		a.Collections["idempotency_keys"], config.IdempotencyTTL)
+	err = idempotencyStore.CreateIndexes(a.Ctx)
+	if err != nil {
+		return err
+	}
+	a.Idempotency = idempotencyStore
+
+	return nil
+}
+
+// InitServices initialize API client of other services,
+// order event publisher, currency of new orders,
+// token authorizer (by account service or local JWT verification),
+// and permission matrix policy (by policy file or the default)
+//
+// order events published through configured publisher
+// and to seller webhooks
+func (a *API) InitServices() error {
+	publisher, err := outbox.NewPublisher(config.OutboxPublisher,
+		config.OutboxFile)
+	if err != nil {
+		return err
+	}
+	a.Publisher = outbox.MultiPublisher{
+		publisher, webhook.NewDispatcher(a.Webhooks),
+	}
+
+	a.Products = product.NewHTTPService(config.ProductServiceURL)
+	a.Currency = config.Currency
+
+	a.Authorizer, err = middleware.NewTokenAuthorizer(a.Ctx, config.AuthMode,
+		middleware.AccountServiceConfig{
+			URL:              config.AccountServiceURL,
+			Timeout:          config.AuthTimeout,
+			CacheTTL:         config.AuthCacheTTL,
+			CacheSize:        config.AuthCacheSize,
+			FailureThreshold: config.AuthFailureThreshold,
+			OpenTimeout:      config.AuthOpenTimeout,
+		}, middleware.JWTConfig{
+			Secret:   config.JWTSecret,
+			JWKS:     config.JWTJWKS,
+			Issuer:   config.JWTIssuer,
+			Audience: config.JWTAudience,
+			Leeway:   config.JWTLeeway,
+		})
+	if err != nil {
+		return err
+	}
+
+	a.ServiceKeys, err = middleware.ParseServiceKeys(config.ServiceKeys)
+	if err != nil {
+		return err
+	}
+
+	a.Policy, err = policy.Load(config.PolicyFile)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
 // InitRouter initialize echo router for API
 func (a *API) InitRouter() {
 	a.Echo = echo.New()
+	a.Echo.HTTPErrorHandler = problem.HTTPErrorHandler
 
-	// add middleware CORS and logger to all route
+	// add middleware request ID, CORS, and logger to all route,
+	// request ID returned in X-Request-ID header and error response
+	a.Echo.Use(echomiddleware.RequestID())
 	a.Echo.Use(echomiddleware.CORSWithConfig(echomiddleware.CORSConfig{
 		AllowOrigins: []string{
 			config.FrontendURL, config.AccountServiceURL, config.ProductServiceURL,
@@ -97,65 +266,216 @@ func (a *API) InitRouter() {
 	}))
 	a.Echo.Use(echomiddleware.Logger())
 
-	// create main router group (prefix: "/api") with middleware authorization
-	mainRouter := a.Echo.Group("/api", middleware.AuthorizationMiddleware)
+	// create versioned router group (prefix: "/api/v1")
+	// with middleware authorization
+	v1Router := a.Echo.Group("/api/v1",
+		middleware.AuthorizationMiddleware(a.Authorizer, a.ServiceKeys))
+
+	//// route add order, can be retried safely with Idempotency-Key header
+	v1Router.POST("/orders", a.AddOrderHandler,
+		middleware.IdempotencyMiddleware(a.Idempotency, addOrderOperation))
+
+	//// route get orders
+	v1Router.GET("/orders", a.GetOrdersHandler)
+
+	//// route get order
+	v1Router.GET("/orders/:order_number", a.GetOrderHandler)
+
+	//// route update order
+	v1Router.PUT("/orders/:order_number", a.UpdateOrderHandler)
+
+	//// route patch order by JSON Merge Patch or JSON Patch
+	v1Router.PATCH("/orders/:order_number", a.PatchOrderHandler)
+
+	//// route delete order
+	v1Router.DELETE("/orders/:order_number", a.DeleteOrderHandler)
+
+	//// route restore deleted order
+	v1Router.POST("/orders/:order_number/restore", a.RestoreOrderHandler)
+
+	//// route get order history
+	v1Router.GET("/orders/:order_number/history", a.GetOrderHistoryHandler)
+
+	//// route add order item
+	v1Router.POST("/orders/:order_number/items", a.AddOrderItemHandler)
+
+	//// route update order item
+	v1Router.PUT("/orders/:order_number/items/:product_id",
+		a.UpdateOrderItemHandler)
+
+	//// route delete order item
+	v1Router.DELETE("/orders/:order_number/items/:product_id",
+		a.DeleteOrderItemHandler)
+
+	//// route add webhook subscription
+	v1Router.POST("/webhooks", a.AddWebhookHandler)
 
-	//// route add order
-	mainRouter.POST("/order/", a.AddOrderHandler)
+	//// route get webhook subscriptions
+	v1Router.GET("/webhooks", a.GetWebhooksHandler)
+
+	//// route delete webhook subscription
+	v1Router.DELETE("/webhooks/:id", a.DeleteWebhookHandler)
+
+	//// route get webhook deliveries, including dead letters
+	v1Router.GET("/webhooks/deliveries", a.GetWebhookDeliveriesHandler)
+
+	//// route retry dead webhook delivery
+	v1Router.POST("/webhooks/deliveries/:delivery_id/retry",
+		a.RetryWebhookDeliveryHandler)
+
+	// create legacy router group (prefix: "/api") with middleware
+	// authorization, order number passed by query param, deprecated
+	// in favor of versioned routes, so only routes existed before
+	// versioned routes registered
+	mainRouter := a.Echo.Group("/api",
+		middleware.AuthorizationMiddleware(a.Authorizer, a.ServiceKeys))
+	deprecatedOrders := middleware.DeprecationMiddleware("/api/v1/orders")
+
+	//// route add order, can be retried safely with Idempotency-Key header
+	mainRouter.POST("/order/", a.AddOrderHandler, deprecatedOrders,
+		middleware.IdempotencyMiddleware(a.Idempotency, addOrderOperation))
 
 	//// route get orders
-	mainRouter.GET("/orders/", a.GetOrdersHandler)
+	mainRouter.GET("/orders/", a.GetOrdersHandler, deprecatedOrders)
 
 	//// route update order
-	mainRouter.PUT("/order/", a.UpdateOrderHandler)
+	mainRouter.PUT("/order/", a.UpdateOrderHandler, deprecatedOrders)
 
 	//// route delete order
-	mainRouter.DELETE("/order/", a.DeleteOrderHandler)
+	mainRouter.DELETE("/order/", a.DeleteOrderHandler, deprecatedOrders)
 }
 
 // GetOrdersHandler route handler for get orders (Method: GET, User: all)
+//
+// orders can be paginated by limit and offset or after (last order ID),
+// and sorted by sort (created_at, updated_at, total_price, qty, status,
+// order_number, prefix with "-" for descending)
+//
+// page ETag returned, so client can get 304 by If-None-Match
+// if orders in the page not changed
+//
+// buyer only get their own orders, seller only get orders
+// containing their product, admin get all orders
+// and can include deleted orders by include_deleted
 func (a *API) GetOrdersHandler(c echo.Context) error {
 	// get user data
 	tmpU := c.Get("user")
-	_, ok := tmpU.(middleware.User)
+	u, ok := tmpU.(middleware.User)
 	if !ok {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"message": "user data invalid",
-		})
+		return problem.Internal(errUserInvalid)
 	}
 
 	// get filter
-	filter := bson.M{}
+	filter := repository.OrderFilter{}
 
 	//// get filter buyerID
 	buyerID, err := strconv.Atoi(c.QueryParam("buyer_id"))
 	if err == nil {
-		filter["buyer_id"] = buyerID
+		filter.BuyerID = buyerID
 	}
 
 	//// get filter status
-	if c.QueryParam("status") != "" {
-		filter["status"] = c.QueryParam("status")
-	}
+	filter.Status = c.QueryParam("status")
 
 	//// get filter productUserID
 	productUserID, err := strconv.Atoi(c.QueryParam("product_user_id"))
 	if err == nil {
-		filter["product_user_id"] = productUserID
+		filter.ProductUserID = productUserID
+	}
+
+	//// get filter include deleted orders
+	if c.QueryParam("include_deleted") != "" {
+		filter.IncludeDeleted, err = strconv.ParseBool(
+			c.QueryParam("include_deleted"))
+		if err != nil {
+			return problem.New(http.StatusBadRequest, problem.CodeBadRequest,
+				"include_deleted invalid")
+		}
 	}
 
-	// get orders from orders collection
-	orders, err := model.GetOrders(a.Ctx, a.Collections["orders"],
-		filter)
+	//// restrict filter to orders user can access
+	filter, err = a.Policy.ScopeOrderFilter(u, filter)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"message": fmt.Sprintf(
-				"There's an error when getting the orders data => %s",
-				err),
-		})
+		return problem.New(http.StatusForbidden, problem.CodeForbidden,
+			"user doesn't have authority to get other user orders")
 	}
 
-	return c.JSON(http.StatusOK, orders)
+	// get sorting and pagination options
+	opts, err := getListOptions(c)
+	if err != nil {
+		return problem.Newf(http.StatusBadRequest, problem.CodeBadRequest,
+			"Pagination/sort param invalid => %s", err)
+	}
+
+	// get orders from order repository
+	page, err := a.Orders.List(a.Ctx, filter, opts)
+	if err != nil {
+		if err == repository.ErrInvalidCursor {
+			return problem.New(http.StatusBadRequest, problem.CodeBadRequest,
+				"Pagination/sort param invalid => after invalid")
+		}
+
+		return problem.Internal(fmt.Errorf(
+			"There's an error when getting the orders data => %w",
+			err))
+	}
+
+	etag := getOrderPageETag(page)
+	c.Response().Header().Set("ETag", etag)
+	if isNotModified(c, etag) {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	return c.JSON(http.StatusOK, page)
+}
+
+// GetOrderHandler route handler for get order (Method: GET, User: all)
+//
+// order ETag returned, so client can get 304 by If-None-Match
+// if the order not changed
+//
+// buyer and seller can only get their order, admin can get any order
+func (a *API) GetOrderHandler(c echo.Context) error {
+	// get user data
+	tmpU := c.Get("user")
+	u, ok := tmpU.(middleware.User)
+	if !ok {
+		return problem.Internal(errUserInvalid)
+	}
+
+	// set filter (for now only order number)
+	filter := repository.OrderFilter{}
+	if getParam(c, "order_number") == "" {
+		return paramRequiredError("order_number")
+	}
+	filter.OrderNumber = getParam(c, "order_number")
+
+	// get order from order repository
+	o, err := a.Orders.Get(a.Ctx, filter)
+	if err != nil {
+		if err == repository.ErrOrderNotFound {
+			return errOrderNotFound
+		}
+
+		return problem.Internal(fmt.Errorf(
+			"There's an error when getting order data => %w",
+			err))
+	}
+
+	// check user authority to access the order
+	err = a.Policy.CanAccessOrder(u, o)
+	if err != nil {
+		return problem.New(http.StatusForbidden, problem.CodeForbidden,
+			err.Error())
+	}
+
+	etag := getOrderETag(o)
+	c.Response().Header().Set("ETag", etag)
+	if isNotModified(c, etag) {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	return c.JSON(http.StatusOK, o)
 }
 
 // AddOrderHandler route handler for add order (Method: POST, User: buyer)
@@ -164,124 +484,826 @@ func (a *API) AddOrderHandler(c echo.Context) error {
 	tmpU := c.Get("user")
 	u, ok := tmpU.(middleware.User)
 	if !ok {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"message": "user data invalid",
-		})
+		return problem.Internal(errUserInvalid)
 	}
 
-	// check user role is buyer
-	if u.Role != "buyer" {
-		return c.JSON(http.StatusForbidden, map[string]string{
-			"message": "user doesn't have authority to access this API",
-		})
+	// check user authority to add order
+	if a.Policy.CanAddOrder(u) != nil {
+		return problem.New(http.StatusForbidden, problem.CodeForbidden,
+			"user doesn't have authority to access this API")
 	}
 
 	// set order that need to be inserted to database
 	var o model.Order
 	err := c.Bind(&o)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"message": fmt.Sprintf("Order data not completed/invalid => %s", err),
-		})
+		return bindError("Order data", err)
 	}
 	o.BuyerID = u.ID
+	o.ReservedAt = nil
+	o.DeletedAt = nil
+	o.DeletedBy = nil
+
+	// new order must be in cart, items stock reserved when checked out
+	if o.Status != "" && o.Status != model.OrderStatusInCart {
+		return invalidDataError("Order data", validator.FieldError{
+			Field:   "status",
+			Code:    validator.CodeInvalid,
+			Message: fmt.Sprintf("must be '%s'", model.OrderStatusInCart),
+		})
+	}
+
+	// new order priced in currency of product service prices
+	if o.Currency == "" {
+		o.Currency = a.Currency
+	}
+	if o.Currency != a.Currency {
+		return invalidDataError("Order data", validator.FieldError{
+			Field:   "currency",
+			Code:    validator.CodeInvalid,
+			Message: fmt.Sprintf("must be '%s'", a.Currency),
+		})
+	}
+
+	// validate order before getting product data of its items,
+	// so invalid order doesn't call product service for every item
+	err = validator.IsOrderRequestValid(o)
+	if err != nil {
+		return invalidDataError("Order data", err)
+	}
+
+	// fill order items with product data from product service
+	for i := range o.Items {
+		o.Items[i], err = a.snapshotOrderItem(c, o.Items[i])
+		if err != nil {
+			return productError(fmt.Errorf("items[%d] => %w", i, err))
+		}
+	}
+
+	// calculate order total, client-supplied total must be the same
+	qty, totalPrice := o.Qty, o.TotalPrice
+	o.CalculateTotal()
+	if qty != 0 && qty != o.Qty {
+		return problem.Newf(http.StatusUnprocessableEntity, codeOrderTotalInvalid,
+			"Order total invalid => qty %d, expected %d",
+			qty, o.Qty)
+	}
+	if totalPrice != 0 && totalPrice != o.TotalPrice {
+		return problem.Newf(http.StatusUnprocessableEntity, codeOrderTotalInvalid,
+			"Order total invalid => total_price %v, expected %v",
+			totalPrice, o.TotalPrice)
+	}
 
 	// validate order data
 	err = validator.IsOrderValid(o)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"message": fmt.Sprintf("Order data not completed/invalid => %s", err),
-		})
+		return invalidDataError("Order data", err)
 	}
 
 	// insert order to database
-	o, err = model.InsertOrder(a.Ctx, a.Collections["orders"], o)
+	o, err = a.Orders.Insert(a.withAudit(a.Ctx, u), o)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"message": fmt.Sprintf(
-				"There's an error when inserting order data => %s",
-				err),
-		})
+		return problem.Internal(fmt.Errorf(
+			"There's an error when inserting order data => %w",
+			err))
 	}
 
+	c.Response().Header().Set(echo.HeaderLocation, "/api/v1/orders/"+o.OrderNumber)
+	c.Response().Header().Set("ETag", getOrderETag(o))
 	return c.JSON(http.StatusCreated, o)
 }
 
 // UpdateOrderHandler route handler for update order (Method: PUT, User: all)
+//
+// buyer can only update their own order, seller can only update status
+// of order containing their product, and admin can update any order
+//
+// order only updated if its version is the same as If-Match header
+// or version field, if set
 func (a *API) UpdateOrderHandler(c echo.Context) error {
 	// get user data
 	tmpU := c.Get("user")
-	_, ok := tmpU.(middleware.User)
+	u, ok := tmpU.(middleware.User)
 	if !ok {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"message": "user data invalid",
-		})
+		return problem.Internal(errUserInvalid)
 	}
 
 	// set order that need to be updated to database
 	var o model.Order
 	err := c.Bind(&o)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"message": fmt.Sprintf("Order data not completed/invalid => %s", err),
-		})
+		return bindError("Order data", err)
+	}
+	o.ReservedAt = nil
+	o.DeletedAt = nil
+	o.DeletedBy = nil
+
+	// get order version expected by client
+	expectedVersion, err := getExpectedVersion(c, o.Version)
+	if err != nil {
+		return invalidDataError("Order data", err)
 	}
 
 	// set filter (for now only order number)
-	filter := bson.M{}
-	if c.QueryParam("order_number") == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"message": "order_number empty/not found",
-		})
+	filter := repository.OrderFilter{}
+	if getParam(c, "order_number") == "" {
+		return paramRequiredError("order_number")
 	}
-	filter["order_number"] = c.QueryParam("order_number")
+	filter.OrderNumber = getParam(c, "order_number")
 
-	// update order in database
-	err = model.UpdateOrder(a.Ctx, a.Collections["orders"], filter, o)
+	// validate fields that need to be updated,
+	// items validated after filled with product data below
+	oFields := o
+	oFields.Items, oFields.Qty, oFields.TotalPrice = nil, 0, 0
+	err = validator.IsOrderUpdateValid(oFields)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"message": fmt.Sprintf(
-				"There's an error when updating order data => %s",
-				err),
+		return invalidDataError("Order data", err)
+	}
+
+	// get order that need to be updated
+	current, err := a.Orders.Get(a.Ctx, filter)
+	if err != nil {
+		if err == repository.ErrOrderNotFound {
+			return errOrderNotFound
+		}
+
+		return problem.Internal(fmt.Errorf(
+			"There's an error when getting order data => %w",
+			err))
+	}
+
+	// check user authority to access the order
+	err = a.Policy.CanAccessOrder(u, current)
+	if err != nil {
+		return problem.New(http.StatusForbidden, problem.CodeForbidden,
+			err.Error())
+	}
+
+	// check order not changed since the version expected by client,
+	// then only update if the order still in the version checked below
+	if expectedVersion != 0 && expectedVersion != current.Version {
+		return orderVersionConflict(current, expectedVersion)
+	}
+	filter.Version = current.Version
+
+	// check order status transition if status need to be updated
+	if o.Status != "" {
+		if !model.IsOrderStatusTransitionAllowed(current.Status, o.Status) {
+			return orderStatusTransitionConflict(current.Status, o.Status)
+		}
+
+		// only update if status still the same as checked above,
+		// so concurrent status change can't skip the transition check
+		filter.Status = current.Status
+	}
+
+	// check user authority to update the fields and status of the order
+	err = a.Policy.CanUpdateOrder(u, current, o)
+	if err != nil {
+		return problem.New(http.StatusForbidden, problem.CodeForbidden,
+			err.Error())
+	}
+
+	// check and fill items with product data if items need to be updated,
+	// so price of the items can't be set by client
+	if o.Items != nil {
+		o.Items, err = a.getChangedOrderItems(c, u, current, o.Items)
+		if err != nil {
+			return err
+		}
+		o.CalculateTotal()
+
+		err = validator.IsOrderUpdateValid(o)
+		if err != nil {
+			return invalidDataError("Order data", err)
+		}
+	}
+
+	// update order in database
+	return a.saveOrderChange(c, u, current, o,
+		func(ctx context.Context, o model.Order, changed []string) error {
+			return a.Orders.Update(ctx, filter, o)
+		},
+		func(after model.Order) error {
+			// legacy route keep its response for existing clients
+			if !isVersionedRoute(c) {
+				return c.JSON(http.StatusOK, map[string]string{
+					"message": "Update order success!",
+				})
+			}
+
+			return c.JSON(http.StatusOK, after)
 		})
+}
+
+// saveOrderChange save change of current order into o by user
+// with save function, then respond with the changed order
+//
+// save function must change the order with the given context,
+// so the change recorded to audit trail together with the change
+//
+// items stock reserved when order checked out, so o saved
+// with reservation time, and released when order cancelled, so o saved
+// with stock release pending until the stock released, fields set
+// by saveOrderChange given to save function
+func (a *API) saveOrderChange(c echo.Context, u middleware.User,
+	current model.Order, o model.Order,
+	save func(ctx context.Context, o model.Order, fields []string) error,
+	respond func(after model.Order) error) error {
+	// reserve items stock when order checked out
+	fields := []string{}
+	token := getProductServiceToken(c, u)
+	reserve := current.Status == model.OrderStatusInCart &&
+		o.Status == model.OrderStatusCheckedOut
+	if reserve {
+		err := a.reserveOrderStock(token, current)
+		if err != nil {
+			return stockError(err)
+		}
+
+		reservedAt := time.Now().UTC()
+		o.ReservedAt = &reservedAt
+		fields = append(fields, "reserved_at")
 	}
 
-	return c.JSON(http.StatusOK, map[string]string{
-		"message": "Update order success!",
-	})
+	// release reserved items stock when order cancelled
+	release := o.Status == model.OrderStatusCancelled &&
+		current.Status != model.OrderStatusCancelled &&
+		current.ReservedAt != nil
+	if release {
+		o.StockReleasePending = true
+		fields = append(fields, "stock_release_pending")
+	}
+
+	// save order in database
+	err := save(a.withAudit(a.Ctx, u), o, fields)
+	if err != nil {
+		// roll back reserved stock because order not checked out
+		if reserve {
+			releaseErr := a.releaseItemsStock(token, current.Items)
+			if releaseErr != nil {
+				log.Printf("There's an error when releasing stock of order %s "+
+					"=> %s", current.OrderNumber, releaseErr)
+			}
+		}
+
+		if err == repository.ErrVersionConflict {
+			return a.orderChangedConflict(current)
+		}
+		if o.Status != "" && o.Status != current.Status &&
+			err == repository.ErrNoDataUpdated {
+			return orderStatusTransitionConflict(current.Status, o.Status)
+		}
+		if err == repository.ErrNoDataUpdated {
+			return errOrderNotFound
+		}
+
+		return problem.Internal(fmt.Errorf(
+			"There's an error when updating order data => %w",
+			err))
+	}
+
+	after, err := a.getChangedOrder(current.OrderNumber)
+	if err == nil {
+		c.Response().Header().Set("ETag", getOrderETag(after))
+	} else {
+		after = o
+	}
+
+	// release reserved items stock when order cancelled,
+	// retried by reservation expiry job if failed
+	if release {
+		err = a.releaseOrderStock(a.Ctx, token, current)
+		if err != nil {
+			log.Printf("There's an error when releasing stock of order %s, "+
+				"release retried later => %s", current.OrderNumber, err)
+		}
+	}
+
+	return respond(after)
 }
 
 // DeleteOrderHandler route handler for delete order (Method: DELETE, User: all)
+//
+// buyer can only delete their own order and admin can delete any order
+//
+// checked out order with items stock reserved can't be deleted,
+// it must be cancelled first so its items stock released
 func (a *API) DeleteOrderHandler(c echo.Context) error {
 	// get user data
 	tmpU := c.Get("user")
-	_, ok := tmpU.(middleware.User)
+	u, ok := tmpU.(middleware.User)
 	if !ok {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"message": "user data invalid",
-		})
+		return problem.Internal(errUserInvalid)
 	}
 
 	// set filter (for now only order number)
-	filter := bson.M{}
-	if c.QueryParam("order_number") == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"message": "order_number empty/not found",
-		})
+	filter := repository.OrderFilter{}
+	if getParam(c, "order_number") == "" {
+		return paramRequiredError("order_number")
 	}
-	filter["order_number"] = c.QueryParam("order_number")
+	filter.OrderNumber = getParam(c, "order_number")
 
-	// update order in database
-	err := model.DeleteOrder(a.Ctx, a.Collections["orders"], filter)
+	// get order that need to be deleted
+	o, err := a.Orders.Get(a.Ctx, filter)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"message": fmt.Sprintf(
-				"There's an error when deleting order data => %s",
-				err),
-		})
+		if err == repository.ErrOrderNotFound {
+			return errOrderNotFound
+		}
+
+		return problem.Internal(fmt.Errorf(
+			"There's an error when getting order data => %w",
+			err))
+	}
+
+	// check user authority to delete the order
+	err = a.Policy.CanDeleteOrder(u, o)
+	if err != nil {
+		return problem.New(http.StatusForbidden, problem.CodeForbidden,
+			err.Error())
+	}
+
+	// check order items stock not reserved
+	if o.Status == model.OrderStatusCheckedOut && o.ReservedAt != nil {
+		return problem.Newf(http.StatusConflict, codeOrderStockReserved,
+			"Order with items stock reserved can't be deleted, "+
+				"change its status to '%s' first", model.OrderStatusCancelled)
+	}
+
+	// mark order as deleted in database only if order status
+	// still the same as checked above, the order purged
+	// after deleted order retention time
+	filter.Status = o.Status
+	err = a.Orders.Delete(a.withAudit(a.Ctx, u), filter, getOrderActor(u))
+	if err != nil {
+		if err == repository.ErrNoDataDeleted {
+			return a.orderChangedConflict(o)
+		}
+
+		return problem.Internal(fmt.Errorf(
+			"There's an error when deleting order data => %w",
+			err))
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{
 		"message": "Delete order success!",
 	})
 }
+
+// RestoreOrderHandler route handler for restore deleted order
+// (Method: POST, User: admin)
+func (a *API) RestoreOrderHandler(c echo.Context) error {
+	// get user data
+	tmpU := c.Get("user")
+	u, ok := tmpU.(middleware.User)
+	if !ok {
+		return problem.Internal(errUserInvalid)
+	}
+
+	// set filter (for now only order number)
+	filter := repository.OrderFilter{IncludeDeleted: true}
+	if getParam(c, "order_number") == "" {
+		return paramRequiredError("order_number")
+	}
+	filter.OrderNumber = getParam(c, "order_number")
+
+	// get order that need to be restored
+	o, err := a.Orders.Get(a.Ctx, filter)
+	if err != nil {
+		if err == repository.ErrOrderNotFound {
+			return errOrderNotFound
+		}
+
+		return problem.Internal(fmt.Errorf(
+			"There's an error when getting order data => %w",
+			err))
+	}
+
+	// check user authority to restore the order
+	err = a.Policy.CanRestoreOrder(u, o)
+	if err != nil {
+		return problem.New(http.StatusForbidden, problem.CodeForbidden,
+			err.Error())
+	}
+
+	// check order is deleted
+	if o.DeletedAt == nil {
+		return problem.New(http.StatusConflict, codeOrderNotDeleted,
+			"Order is not deleted")
+	}
+
+	// restore order in database
+	err = a.Orders.Restore(a.withAudit(a.Ctx, u), filter)
+	if err != nil {
+		if err == repository.ErrNoDataUpdated {
+			return problem.New(http.StatusConflict, codeOrderNotDeleted,
+				"Order is not deleted")
+		}
+
+		return problem.Internal(fmt.Errorf(
+			"There's an error when restoring order data => %w",
+			err))
+	}
+	after, err := a.getChangedOrder(o.OrderNumber)
+	if err == nil {
+		o = after
+	}
+	o.DeletedAt = nil
+	o.DeletedBy = nil
+
+	c.Response().Header().Set("ETag", getOrderETag(o))
+	return c.JSON(http.StatusOK, o)
+}
+
+// GetOrderHistoryHandler route handler for get order change history
+// (Method: GET, User: all)
+//
+// buyer and seller can only get history of their order,
+// admin can get history of any order, including deleted order
+func (a *API) GetOrderHistoryHandler(c echo.Context) error {
+	// get user data
+	tmpU := c.Get("user")
+	u, ok := tmpU.(middleware.User)
+	if !ok {
+		return problem.Internal(errUserInvalid)
+	}
+
+	// get order number
+	orderNumber := getParam(c, "order_number")
+	if orderNumber == "" {
+		return paramRequiredError("order_number")
+	}
+
+	// check user authority to access the order,
+	// deleted order history only for user allowed to access deleted orders
+	o, err := a.Orders.Get(a.Ctx, repository.OrderFilter{OrderNumber: orderNumber})
+	if err != nil && err != repository.ErrOrderNotFound {
+		return problem.Internal(fmt.Errorf(
+			"There's an error when getting order data => %w",
+			err))
+	}
+	if err == repository.ErrOrderNotFound && a.Policy.CanAccessDeletedOrders(u) != nil {
+		return errOrderNotFound
+	}
+	if err == nil && a.Policy.CanAccessOrder(u, o) != nil {
+		return problem.New(http.StatusForbidden, problem.CodeForbidden,
+			policy.ErrForbidden.Error())
+	}
+
+	// get order audit entries
+	entries, err := a.Audit.List(a.Ctx, orderNumber)
+	if err != nil {
+		return problem.Internal(fmt.Errorf(
+			"There's an error when getting order history => %w",
+			err))
+	}
+	if len(entries) == 0 {
+		return errOrderNotFound
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"order_number": orderNumber,
+		"items":        entries,
+	})
+}
+
+// AddOrderItemHandler route handler for add item
+// to existing order (Method: POST, User: all)
+func (a *API) AddOrderItemHandler(c echo.Context) error {
+	// get user data
+	tmpU := c.Get("user")
+	u, ok := tmpU.(middleware.User)
+	if !ok {
+		return problem.Internal(errUserInvalid)
+	}
+
+	// set item that need to be added to order
+	var item model.OrderItem
+	err := c.Bind(&item)
+	if err != nil {
+		return bindError("Order item data", err)
+	}
+
+	// fill item with product data from product service
+	item, err = a.snapshotOrderItem(c, item)
+	if err != nil {
+		return productError(err)
+	}
+
+	// validate item data
+	err = validator.IsOrderItemValid(item)
+	if err != nil {
+		return invalidDataError("Order item data", err)
+	}
+
+	// change order items
+	return a.changeOrderItems(c, u, func(o *model.Order) error {
+		o.AddItem(item)
+		return nil
+	})
+}
+
+// UpdateOrderItemHandler route handler for update item qty
+// in existing order (Method: PUT, User: all)
+func (a *API) UpdateOrderItemHandler(c echo.Context) error {
+	// get user data
+	tmpU := c.Get("user")
+	u, ok := tmpU.(middleware.User)
+	if !ok {
+		return problem.Internal(errUserInvalid)
+	}
+
+	// get product ID of the item
+	productID, err := strconv.Atoi(getParam(c, "product_id"))
+	if err != nil {
+		return paramRequiredError("product_id")
+	}
+
+	// set item that need to be updated
+	var item model.OrderItem
+	err = c.Bind(&item)
+	if err != nil {
+		return bindError("Order item data", err)
+	}
+	switch {
+	case item.Qty == 0:
+		return invalidDataError("Order item data", validator.FieldError{
+			Field:   "qty",
+			Code:    validator.CodeRequired,
+			Message: "empty/not found",
+		})
+	case item.Qty < 0 || item.Qty > validator.MaxItemQty:
+		return invalidDataError("Order item data", validator.FieldError{
+			Field:   "qty",
+			Code:    validator.CodeOutOfRange,
+			Message: fmt.Sprintf("must be between 1 and %d", validator.MaxItemQty),
+		})
+	}
+
+	// change order items
+	return a.changeOrderItems(c, u, func(o *model.Order) error {
+		return o.UpdateItemQty(productID, item.Qty)
+	})
+}
+
+// DeleteOrderItemHandler route handler for delete item
+// from existing order (Method: DELETE, User: all)
+func (a *API) DeleteOrderItemHandler(c echo.Context) error {
+	// get user data
+	tmpU := c.Get("user")
+	u, ok := tmpU.(middleware.User)
+	if !ok {
+		return problem.Internal(errUserInvalid)
+	}
+
+	// get product ID of the item
+	productID, err := strconv.Atoi(getParam(c, "product_id"))
+	if err != nil {
+		return paramRequiredError("product_id")
+	}
+
+	// change order items
+	return a.changeOrderItems(c, u, func(o *model.Order) error {
+		return o.RemoveItem(productID)
+	})
+}
+
+// changeOrderItems get order by order_number param,
+// change its items with change function, and save the new items
+//
+// only buyer of the order and admin can change order items,
+// items only changed if order version is the same as If-Match header, if set
+func (a *API) changeOrderItems(c echo.Context, u middleware.User,
+	change func(o *model.Order) error) error {
+	// get order version expected by client
+	expectedVersion, err := getExpectedVersion(c, 0)
+	if err != nil {
+		return invalidDataError("Order item data", err)
+	}
+
+	// set filter (for now only order number)
+	filter := repository.OrderFilter{}
+	if getParam(c, "order_number") == "" {
+		return paramRequiredError("order_number")
+	}
+	filter.OrderNumber = getParam(c, "order_number")
+
+	// get order
+	o, err := a.Orders.Get(a.Ctx, filter)
+	if err != nil {
+		if err == repository.ErrOrderNotFound {
+			return errOrderNotFound
+		}
+
+		return problem.Internal(fmt.Errorf(
+			"There's an error when getting order data => %w",
+			err))
+	}
+
+	// check user authority to change order items
+	err = a.Policy.CanChangeOrderItems(u, o)
+	if err != nil {
+		return problem.New(http.StatusForbidden, problem.CodeForbidden,
+			err.Error())
+	}
+
+	// check order not changed since the version expected by client
+	if expectedVersion != 0 && expectedVersion != o.Version {
+		return orderVersionConflict(o, expectedVersion)
+	}
+
+	// check order still in cart, items can't be changed
+	// after items stock reserved
+	if o.Status != model.OrderStatusInCart {
+		return problem.Newf(http.StatusConflict, codeOrderItemsLocked,
+			"Order items can only be changed "+
+				"when order status is '%s'", model.OrderStatusInCart)
+	}
+
+	// change order items
+	before := o
+	before.Items = append([]model.OrderItem{}, o.Items...)
+	err = change(&o)
+	if err != nil {
+		return problem.Newf(http.StatusNotFound, codeOrderItemNotFound,
+			"Order item not found => %s", err)
+	}
+	if o.Items == nil {
+		o.Items = []model.OrderItem{}
+	}
+
+	// update order items in database only if order still in cart
+	// and not changed since it's got
+	filter.Status = model.OrderStatusInCart
+	filter.Version = o.Version
+	err = a.Orders.Update(a.withAudit(a.Ctx, u), filter,
+		model.Order{Items: o.Items})
+	if err != nil {
+		if err == repository.ErrVersionConflict {
+			return a.orderChangedConflict(before)
+		}
+		if err == repository.ErrNoDataUpdated {
+			return problem.Newf(http.StatusConflict, codeOrderItemsLocked,
+				"Order items can only be changed "+
+					"when order status is '%s'", model.OrderStatusInCart)
+		}
+
+		return problem.Internal(fmt.Errorf(
+			"There's an error when updating order data => %w",
+			err))
+	}
+	after, err := a.getChangedOrder(o.OrderNumber)
+	if err == nil {
+		o = after
+	}
+
+	c.Response().Header().Set("ETag", getOrderETag(o))
+	return c.JSON(http.StatusOK, o)
+}
+
+// isVersionedRoute check request routed to versioned route
+// (prefix: "/api/v1")
+func isVersionedRoute(c echo.Context) bool {
+	return strings.HasPrefix(c.Path(), "/api/v1/")
+}
+
+// getParam get param from request path, or from query param
+// for legacy route without the param in its path
+func getParam(c echo.Context, name string) string {
+	value := c.Param(name)
+	if value != "" {
+		return value
+	}
+
+	return c.QueryParam(name)
+}
+
+// getListOptions get sorting and pagination options
+// from query params limit, offset, after, and sort
+func getListOptions(c echo.Context) (repository.ListOptions, error) {
+	opts := repository.ListOptions{Limit: defaultListLimit}
+
+	// get limit
+	if c.QueryParam("limit") != "" {
+		limit, err := strconv.ParseInt(c.QueryParam("limit"), 10, 64)
+		if err != nil || limit <= 0 || limit > maxListLimit {
+			return opts, fmt.Errorf("limit must be between 1 and %d",
+				maxListLimit)
+		}
+		opts.Limit = limit
+	}
+
+	// get offset
+	if c.QueryParam("offset") != "" {
+		offset, err := strconv.ParseInt(c.QueryParam("offset"), 10, 64)
+		if err != nil || offset < 0 {
+			return opts, fmt.Errorf("offset invalid")
+		}
+		opts.Offset = offset
+	}
+
+	// get cursor
+	if c.QueryParam("after") != "" {
+		after, err := primitive.ObjectIDFromHex(c.QueryParam("after"))
+		if err != nil {
+			return opts, fmt.Errorf("after invalid")
+		}
+		opts.After = after
+	}
+
+	// get sort
+	if !repository.IsSortValid(c.QueryParam("sort")) {
+		return opts, fmt.Errorf("sort '%s' invalid", c.QueryParam("sort"))
+	}
+	opts.Sort = c.QueryParam("sort")
+
+	return opts, nil
+}
+
+// orderStatusTransitionConflict error for order status
+// that not allowed to be changed into requested status
+func orderStatusTransitionConflict(currentStatus string,
+	requestedStatus string) error {
+	return problem.Newf(http.StatusConflict, codeOrderStatusTransitionInvalid,
+		"Order status can't be changed from '%s' to '%s'",
+		currentStatus, requestedStatus).
+		With("current_status", currentStatus).
+		With("requested_status", requestedStatus)
+}
+
+// getProductServiceToken get token used to access product service
+// on behalf of user, internal service authorized by API key
+// has no bearer token, so it uses token of this service
+func getProductServiceToken(c echo.Context, u middleware.User) string {
+	token := middleware.GetTokenFromHeader(c.Request().Header)
+	if u.Role == middleware.RoleService || token == "" {
+		return config.ProductServiceToken
+	}
+
+	return token
+}
+
+// snapshotOrderItem get product of order item from product service
+// using user token, then fill the item with the product data
+func (a *API) snapshotOrderItem(c echo.Context,
+	item model.OrderItem) (model.OrderItem, error) {
+	// item without product ID is rejected by validator
+	if item.ProductID == 0 {
+		return item, nil
+	}
+
+	token := middleware.GetTokenFromHeader(c.Request().Header)
+	p, err := a.Products.GetProduct(a.Ctx, token, item.ProductID)
+	if err != nil {
+		if err == product.ErrProductNotFound {
+			return item, fmt.Errorf("%w => product_id %d", err, item.ProductID)
+		}
+		return item, err
+	}
+
+	return product.SnapshotOrderItem(item, p)
+}
+
+// productError error when filling order item
+// with product data
+func productError(err error) error {
+	if errors.Is(err, product.ErrProductNotFound) ||
+		errors.Is(err, product.ErrProductMismatch) {
+		return problem.Newf(http.StatusUnprocessableEntity, codeProductInvalid,
+			"Order item product invalid => %s", err)
+	}
+
+	return problem.Internal(fmt.Errorf(
+		"There's an error when getting the product data => %w",
+		err))
+}
+
+// withAudit get copy of ctx where order changed with it recorded
+// as changed by user to audit trail, in the same transaction as the change
+func (a *API) withAudit(ctx context.Context,
+	u middleware.User) context.Context {
+	return model.WithOrderChangeRecorder(ctx,
+		func(ctx context.Context, before *model.Order,
+			after *model.Order) error {
+			e, err := audit.NewEntry(audit.GetAction(before, after), u,
+				before, after)
+			if err != nil {
+				return err
+			}
+
+			return a.Audit.Insert(ctx, e)
+		})
+}
+
+// getChangedOrder get order after changed, including deleted order
+func (a *API) getChangedOrder(orderNumber string) (model.Order, error) {
+	return a.Orders.Get(a.Ctx, repository.OrderFilter{
+		OrderNumber:    orderNumber,
+		IncludeDeleted: true,
+	})
+}
+
+// getOrderActor get user as actor of order change
+func getOrderActor(u middleware.User) model.OrderActor {
+	return model.OrderActor{ID: u.ID, FullName: u.FullName, Role: u.Role}
+}
@@ -7,68 +7,44 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
-	"log"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/reyhanfikridz/ecom-order-service/internal/audit"
 	"github.com/reyhanfikridz/ecom-order-service/internal/config"
 	"github.com/reyhanfikridz/ecom-order-service/internal/middleware"
 	"github.com/reyhanfikridz/ecom-order-service/internal/model"
-	"go.mongodb.org/mongo-driver/bson"
+	"github.com/reyhanfikridz/ecom-order-service/internal/money"
+	"github.com/reyhanfikridz/ecom-order-service/internal/outbox"
+	"github.com/reyhanfikridz/ecom-order-service/internal/policy"
+	"github.com/reyhanfikridz/ecom-order-service/internal/problem"
+	"github.com/reyhanfikridz/ecom-order-service/internal/product"
+	"github.com/reyhanfikridz/ecom-order-service/internal/repository"
+	"github.com/reyhanfikridz/ecom-order-service/internal/validator"
+	"github.com/reyhanfikridz/ecom-order-service/internal/webhook"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-// TestMain do some test before and after all testing in the package
-func TestMain(m *testing.M) {
-	ctx := context.Background()
-
-	// init all config before can be used
-	err := config.InitConfig()
-	if err != nil {
-		log.Fatalf("There's an error when initialize config => %s", err)
-	}
-
-	// get testing app
-	u := middleware.User{}
-	a, err := GetTestingAPI(u)
-	if err != nil {
-		log.Fatalf("There's an error when initialize "+
-			"testing API => %s", err)
-	}
-
-	// remove all data in all collection before run all test
-	for _, collection := range a.Collections {
-		_, err = collection.DeleteMany(ctx, bson.D{})
-		if err != nil {
-			log.Fatalf("There's an error when truncating "+
-				"mongodb collections before run all test => %s", err)
-		}
-	}
-
-	// run all testing
-	m.Run()
-
-	// remove all data in all collection before run all test
-	for _, collection := range a.Collections {
-		_, err = collection.DeleteMany(ctx, bson.D{})
-		if err != nil {
-			log.Fatalf("There's an error when truncating "+
-				"mongodb collections after run all test => %s", err)
-		}
-	}
-}
-
 // TestInitCollections test InitCollections
 func TestInitCollections(t *testing.T) {
 	a := API{}
 
-	err := a.InitCollections(config.DBName)
+	// this test need live mongodb, so skip it
+	// if database config not available
+	err := config.InitConfig(nil)
+	if err != nil {
+		t.Skipf("Skip test, config not available => %s", err)
+	}
+
+	err = a.InitCollections(config.DBName)
 	if err != nil {
 		t.Errorf("Expected initialize connection to database collections success,"+
 			" but connection failed => %s", err)
@@ -90,63 +66,71 @@ func TestGetOrdersHandler(t *testing.T) {
 	// create orders
 	orders := []model.Order{
 		{
-			Status:             "in-cart",
-			Qty:                2,
-			TotalPrice:         2000001,
-			BuyerID:            1,
-			BuyerFullName:      "George Marcus",
-			BuyerAddress:       "Buyer Street",
-			ProductID:          1,
-			ProductSKU:         "testsku",
-			ProductName:        "product name",
-			ProductPrice:       1000000.50,
-			ProductWeight:      1.5,
-			ProductDescription: "product description",
-			ProductStock:       100,
-			ProductUserID:      10,
-			ProductImagesPath:  []string{"product 1.1.jpg", "product 1.2.jpg"},
-		},
-		{
-			Status:             "done",
-			Qty:                2,
-			TotalPrice:         4000001,
-			BuyerID:            1,
-			BuyerFullName:      "George Marcus",
-			BuyerAddress:       "Buyer Street",
-			ProductID:          2,
-			ProductSKU:         "testsku2",
-			ProductName:        "product name 2",
-			ProductPrice:       2000000.50,
-			ProductWeight:      2.5,
-			ProductDescription: "product description 2",
-			ProductStock:       200,
-			ProductUserID:      20,
-			ProductImagesPath:  []string{"product 2.1.jpg", "product 2.2.jpg", "product 2.3.jpg"},
-		},
-		{
-			Status:             "in-cart",
-			Qty:                2,
-			TotalPrice:         6000001,
-			BuyerID:            2,
-			BuyerFullName:      "Linda",
-			BuyerAddress:       "Buyer Street 2",
-			ProductID:          3,
-			ProductSKU:         "testsku 3",
-			ProductName:        "product name 3",
-			ProductPrice:       3000000.50,
-			ProductWeight:      3.5,
-			ProductDescription: "product description 3",
-			ProductStock:       300,
-			ProductUserID:      30,
-			ProductImagesPath:  []string{},
+			Status:        "in-cart",
+			BuyerID:       1,
+			BuyerFullName: "George Marcus",
+			BuyerAddress:  "Buyer Street",
+			Items: []model.OrderItem{
+				{
+					ProductID:          1,
+					ProductSKU:         "testsku",
+					ProductName:        "product name",
+					ProductPrice:       money.MustParse("1000000.50"),
+					ProductWeight:      1.5,
+					ProductDescription: "product description",
+					ProductStock:       100,
+					ProductUserID:      10,
+					ProductImagesPath:  []string{"product 1.1.jpg", "product 1.2.jpg"},
+					Qty:                2,
+				},
+			},
+		},
+		{
+			Status:        "done",
+			BuyerID:       1,
+			BuyerFullName: "George Marcus",
+			BuyerAddress:  "Buyer Street",
+			Items: []model.OrderItem{
+				{
+					ProductID:          2,
+					ProductSKU:         "testsku2",
+					ProductName:        "product name 2",
+					ProductPrice:       money.MustParse("2000000.50"),
+					ProductWeight:      2.5,
+					ProductDescription: "product description 2",
+					ProductStock:       200,
+					ProductUserID:      20,
+					ProductImagesPath:  []string{"product 2.1.jpg", "product 2.2.jpg", "product 2.3.jpg"},
+					Qty:                2,
+				},
+			},
+		},
+		{
+			Status:        "in-cart",
+			BuyerID:       2,
+			BuyerFullName: "Linda",
+			BuyerAddress:  "Buyer Street 2",
+			Items: []model.OrderItem{
+				{
+					ProductID:          3,
+					ProductSKU:         "testsku 3",
+					ProductName:        "product name 3",
+					ProductPrice:       money.MustParse("3000000.50"),
+					ProductWeight:      3.5,
+					ProductDescription: "product description 3",
+					ProductStock:       300,
+					ProductUserID:      30,
+					ProductImagesPath:  []string{},
+					Qty:                2,
+				},
+			},
 		},
 	}
 
 	// loop orders
 	for i := range orders {
 		// insert order into database collection
-		orders[i], err = model.InsertOrder(ctx, a.Collections["orders"],
-			orders[i])
+		orders[i], err = a.Orders.Insert(ctx, orders[i])
 		if err != nil {
 			t.Fatalf("There's an error when insert order => %s",
 				err)
@@ -155,51 +139,145 @@ func TestGetOrdersHandler(t *testing.T) {
 
 	// create testing table
 	testTable := []struct {
-		TestName        string
-		Filter          map[string]string
-		User            middleware.User
-		ExpectedStatus  int
-		ExpectedResults []model.Order
+		TestName           string
+		Filter             map[string]string
+		User               middleware.User
+		ExpectedStatus     int
+		ExpectedResults    []model.Order
+		ExpectedTotal      int64
+		ExpectedNextCursor string
 	}{
 		{
-			TestName:        "Test Get All Order",
+			TestName:        "Test Get All Order Admin",
 			Filter:          nil,
-			User:            middleware.User{Role: "buyer"},
+			User:            middleware.User{ID: 100, Role: "admin"},
 			ExpectedStatus:  http.StatusOK,
 			ExpectedResults: orders,
+			ExpectedTotal:   3,
+		},
+		{
+			TestName:        "Test Get All Order Admin By Buyer ID <2>",
+			Filter:          map[string]string{"buyer_id": "2"},
+			User:            middleware.User{ID: 100, Role: "admin"},
+			ExpectedStatus:  http.StatusOK,
+			ExpectedResults: []model.Order{orders[2]},
+			ExpectedTotal:   1,
+		},
+		{
+			TestName:        "Test Get All Order Buyer <1>",
+			Filter:          nil,
+			User:            middleware.User{ID: 1, Role: "buyer"},
+			ExpectedStatus:  http.StatusOK,
+			ExpectedResults: []model.Order{orders[0], orders[1]},
+			ExpectedTotal:   2,
 		},
 		{
-			TestName:        "Test Get All Order By Buyer ID <1>",
+			TestName:        "Test Get All Order Buyer <1> By Buyer ID <1>",
 			Filter:          map[string]string{"buyer_id": "1"},
-			User:            middleware.User{Role: "buyer"},
+			User:            middleware.User{ID: 1, Role: "buyer"},
 			ExpectedStatus:  http.StatusOK,
 			ExpectedResults: []model.Order{orders[0], orders[1]},
+			ExpectedTotal:   2,
 		},
 		{
-			TestName:        "Test Get All Order By Buyer ID <1> and Status <in-cart>",
-			Filter:          map[string]string{"buyer_id": "1", "status": "in-cart"},
-			User:            middleware.User{Role: "buyer"},
+			TestName:        "Test Get All Order Buyer <1> By Status <in-cart>",
+			Filter:          map[string]string{"status": "in-cart"},
+			User:            middleware.User{ID: 1, Role: "buyer"},
 			ExpectedStatus:  http.StatusOK,
 			ExpectedResults: []model.Order{orders[0]},
+			ExpectedTotal:   1,
 		},
 		{
-			TestName:        "Test Get All Order By Product User ID <30>",
+			TestName:        "Test Get All Order Buyer <1> By Product User ID <30>",
 			Filter:          map[string]string{"product_user_id": "30"},
-			User:            middleware.User{Role: "buyer"},
+			User:            middleware.User{ID: 1, Role: "buyer"},
+			ExpectedStatus:  http.StatusOK,
+			ExpectedResults: []model.Order{},
+			ExpectedTotal:   0,
+		},
+		{
+			TestName:       "Test Get All Order Buyer <1> By Other Buyer ID",
+			Filter:         map[string]string{"buyer_id": "2"},
+			User:           middleware.User{ID: 1, Role: "buyer"},
+			ExpectedStatus: http.StatusForbidden,
+		},
+		{
+			TestName:       "Test Get All Order Buyer <1> Include Deleted",
+			Filter:         map[string]string{"include_deleted": "true"},
+			User:           middleware.User{ID: 1, Role: "buyer"},
+			ExpectedStatus: http.StatusForbidden,
+		},
+		{
+			TestName:        "Test Get All Order Admin Include Deleted",
+			Filter:          map[string]string{"include_deleted": "true"},
+			User:            middleware.User{ID: 100, Role: "admin"},
+			ExpectedStatus:  http.StatusOK,
+			ExpectedResults: orders,
+			ExpectedTotal:   3,
+		},
+		{
+			TestName:        "Test Get All Order Seller <30>",
+			Filter:          nil,
+			User:            middleware.User{ID: 30, Role: "seller"},
+			ExpectedStatus:  http.StatusOK,
+			ExpectedResults: []model.Order{orders[2]},
+			ExpectedTotal:   1,
+		},
+		{
+			TestName:       "Test Get All Order Seller <30> By Other Product User ID",
+			Filter:         map[string]string{"product_user_id": "10"},
+			User:           middleware.User{ID: 30, Role: "seller"},
+			ExpectedStatus: http.StatusForbidden,
+		},
+		{
+			TestName:       "Test Get All Order Unknown Role",
+			Filter:         nil,
+			User:           middleware.User{ID: 1, Role: "guest"},
+			ExpectedStatus: http.StatusForbidden,
+		},
+		{
+			TestName:           "Test Get Order First Page Sort By Total Price Descending",
+			Filter:             map[string]string{"limit": "2", "sort": "-total_price"},
+			User:               middleware.User{ID: 100, Role: "admin"},
+			ExpectedStatus:     http.StatusOK,
+			ExpectedResults:    []model.Order{orders[2], orders[1]},
+			ExpectedTotal:      3,
+			ExpectedNextCursor: orders[1].ID.Hex(),
+		},
+		{
+			TestName: "Test Get Order Next Page Sort By Total Price Descending",
+			Filter: map[string]string{
+				"limit": "2", "sort": "-total_price", "after": orders[1].ID.Hex(),
+			},
+			User:            middleware.User{ID: 100, Role: "admin"},
+			ExpectedStatus:  http.StatusOK,
+			ExpectedResults: []model.Order{orders[0]},
+			ExpectedTotal:   3,
+		},
+		{
+			TestName:        "Test Get Order With Offset",
+			Filter:          map[string]string{"limit": "1", "offset": "2"},
+			User:            middleware.User{ID: 100, Role: "admin"},
 			ExpectedStatus:  http.StatusOK,
 			ExpectedResults: []model.Order{orders[2]},
+			ExpectedTotal:   3,
+		},
+		{
+			TestName:       "Test Get Order Invalid Sort",
+			Filter:         map[string]string{"sort": "buyer_address"},
+			User:           middleware.User{ID: 1, Role: "buyer"},
+			ExpectedStatus: http.StatusBadRequest,
+		},
+		{
+			TestName:       "Test Get Order Invalid Limit",
+			Filter:         map[string]string{"limit": "1000"},
+			User:           middleware.User{ID: 1, Role: "buyer"},
+			ExpectedStatus: http.StatusBadRequest,
 		},
 	}
 
 	// loop test in test table
 	for _, test := range testTable {
-		// get testing API for get products
-		a, err = GetTestingAPI(test.User)
-		if err != nil {
-			t.Errorf("There's an error when getting testing API => %s",
-				err)
-		}
-
 		// get url params
 		params := url.Values{}
 		for key, value := range test.Filter {
@@ -218,7 +296,7 @@ func TestGetOrdersHandler(t *testing.T) {
 		response := httptest.NewRecorder()
 		echoCtx := a.Echo.NewContext(req, response)
 		echoCtx.Set("user", test.User)
-		err = a.GetOrdersHandler(echoCtx)
+		err = callHandler(a.GetOrdersHandler, echoCtx)
 		if err != nil {
 			t.Errorf("[%s] Expected API call success, but got error => %s",
 				test.TestName, err)
@@ -229,7 +307,7 @@ func TestGetOrdersHandler(t *testing.T) {
 			t.Errorf("[%s] Expected status %d got %d",
 				test.TestName, test.ExpectedStatus, response.Code)
 
-			var resp map[string]string
+			var resp map[string]interface{}
 			err = json.NewDecoder(response.Body).Decode(&resp)
 			if err != nil {
 				t.Errorf("[%s] There's an error when unmarshal body response => %s",
@@ -239,14 +317,25 @@ func TestGetOrdersHandler(t *testing.T) {
 			t.Error(resp)
 
 		} else if response.Code == test.ExpectedStatus &&
-			response.Code != http.StatusForbidden {
+			response.Code == http.StatusOK {
 			// get response data (product)
-			var results []model.Order
-			err = json.NewDecoder(response.Body).Decode(&results)
+			var page repository.OrderPage
+			err = json.NewDecoder(response.Body).Decode(&page)
 			if err != nil {
 				t.Errorf("[%s] There's an error when unmarshal body response => %s",
 					test.TestName, err)
 			}
+			results := page.Items
+
+			// check response total and next page cursor
+			if test.ExpectedTotal != page.Total {
+				t.Errorf("[%s] Expected total %d, but got %d",
+					test.TestName, test.ExpectedTotal, page.Total)
+			}
+			if test.ExpectedNextCursor != page.NextCursor {
+				t.Errorf("[%s] Expected next cursor '%s', but got '%s'",
+					test.TestName, test.ExpectedNextCursor, page.NextCursor)
+			}
 
 			// check response data length
 			if len(test.ExpectedResults) != len(results) {
@@ -258,43 +347,8 @@ func TestGetOrdersHandler(t *testing.T) {
 			for _, expectedResult := range test.ExpectedResults {
 				resultExist := false
 				for _, result := range results {
-					if expectedResult.ID == result.ID &&
-						expectedResult.OrderNumber == result.OrderNumber &&
-						expectedResult.Status == result.Status &&
-						expectedResult.Qty == result.Qty &&
-						expectedResult.TotalPrice == result.TotalPrice &&
-						expectedResult.BuyerID == result.BuyerID &&
-						expectedResult.BuyerFullName == result.BuyerFullName &&
-						expectedResult.BuyerAddress == result.BuyerAddress &&
-						expectedResult.ProductID == result.ProductID &&
-						expectedResult.ProductSKU == result.ProductSKU &&
-						expectedResult.ProductName == result.ProductName &&
-						expectedResult.ProductPrice == result.ProductPrice &&
-						expectedResult.ProductWeight == result.ProductWeight &&
-						expectedResult.ProductDescription == result.ProductDescription &&
-						expectedResult.ProductStock == result.ProductStock &&
-						expectedResult.ProductUserID == result.ProductUserID {
-
-						if len(expectedResult.ProductImagesPath) ==
-							len(result.ProductImagesPath) {
-							totalImageExist := 0
-							for _, expectedProductImagePath := range expectedResult.ProductImagesPath {
-								imageExist := false
-								for _, resultProductImagePath := range result.ProductImagesPath {
-									if expectedProductImagePath == resultProductImagePath {
-										imageExist = true
-									}
-								}
-								if imageExist {
-									totalImageExist++
-								}
-							}
-							if len(expectedResult.ProductImagesPath) ==
-								totalImageExist {
-								resultExist = true
-							}
-						}
-
+					if isOrderEqual(expectedResult, result) {
+						resultExist = true
 					}
 				}
 
@@ -307,12 +361,125 @@ func TestGetOrdersHandler(t *testing.T) {
 		}
 	}
 
-	// remove all data in all collection after test
-	for _, collection := range a.Collections {
-		_, err = collection.DeleteMany(ctx, bson.D{})
+}
+
+// TestGetOrderHandler test GetOrderHandler
+func TestGetOrderHandler(t *testing.T) {
+	a, err := GetTestingAPI(middleware.User{ID: 1, Role: "buyer"})
+	if err != nil {
+		t.Fatalf("There's an error when getting testing API => %s", err)
+	}
+
+	o, err := a.Orders.Insert(a.Ctx, model.Order{
+		Status:  "in-cart",
+		BuyerID: 1,
+		Items: []model.OrderItem{
+			{ProductID: 2, ProductName: "Product 2",
+				ProductPrice: money.MustParse("500"), ProductWeight: 1,
+				ProductUserID: 20, Qty: 1},
+		},
+	})
+	if err != nil {
+		t.Fatalf("There's an error when creating testing data => %s", err)
+	}
+
+	// initialize testing table
+	testTable := []struct {
+		TestName       string
+		OrderNumber    string
+		PathParam      bool
+		IfNoneMatch    string
+		User           middleware.User
+		ExpectedStatus int
+	}{
+		{
+			TestName:       "Test Get Order Buyer Path Param",
+			OrderNumber:    o.OrderNumber,
+			PathParam:      true,
+			User:           middleware.User{ID: 1, Role: "buyer"},
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			TestName:       "Test Get Order Seller Query Param",
+			OrderNumber:    o.OrderNumber,
+			User:           middleware.User{ID: 20, Role: "seller"},
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			TestName:       "Test Get Order Not Modified",
+			OrderNumber:    o.OrderNumber,
+			PathParam:      true,
+			IfNoneMatch:    getOrderETag(o),
+			User:           middleware.User{ID: 1, Role: "buyer"},
+			ExpectedStatus: http.StatusNotModified,
+		},
+		{
+			TestName:       "Test Get Order Forbidden Other Buyer",
+			OrderNumber:    o.OrderNumber,
+			PathParam:      true,
+			User:           middleware.User{ID: 2, Role: "buyer"},
+			ExpectedStatus: http.StatusForbidden,
+		},
+		{
+			TestName:       "Test Get Order Not Found",
+			OrderNumber:    "unknown",
+			PathParam:      true,
+			User:           middleware.User{ID: 100, Role: "admin"},
+			ExpectedStatus: http.StatusNotFound,
+		},
+		{
+			TestName:       "Test Get Order Bad Request",
+			User:           middleware.User{ID: 100, Role: "admin"},
+			ExpectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	// loop test in test table
+	for _, test := range testTable {
+		req := httptest.NewRequest("GET", "/", nil)
+		if !test.PathParam && test.OrderNumber != "" {
+			req.URL.RawQuery = url.Values{
+				"order_number": []string{test.OrderNumber},
+			}.Encode()
+		}
+		if test.IfNoneMatch != "" {
+			req.Header.Set("If-None-Match", test.IfNoneMatch)
+		}
+
+		response := httptest.NewRecorder()
+		echoCtx := a.Echo.NewContext(req, response)
+		if test.PathParam {
+			echoCtx.SetParamNames("order_number")
+			echoCtx.SetParamValues(test.OrderNumber)
+		}
+		echoCtx.Set("user", test.User)
+		err = callHandler(a.GetOrderHandler, echoCtx)
+		if err != nil {
+			t.Errorf("[%s] Expected API call success, but got error => %s",
+				test.TestName, err)
+		}
+
+		// check response
+		if response.Code != test.ExpectedStatus {
+			t.Errorf("[%s] Expected status %d got %d => %s", test.TestName,
+				test.ExpectedStatus, response.Code, response.Body.String())
+			continue
+		}
+		if response.Code != http.StatusOK {
+			continue
+		}
+
+		var result model.Order
+		err = json.NewDecoder(response.Body).Decode(&result)
 		if err != nil {
-			log.Fatalf("There's an error when truncating "+
-				"mongodb collections after run all test => %s", err)
+			t.Errorf("[%s] There's an error when unmarshal body response => %s",
+				test.TestName, err)
+		}
+		if result.OrderNumber != o.OrderNumber ||
+			response.Header().Get("ETag") != getOrderETag(o) {
+			t.Errorf("[%s] Expected order %s with ETag %s, but got %s "+
+				"with ETag %s", test.TestName, o.OrderNumber, getOrderETag(o),
+				result.OrderNumber, response.Header().Get("ETag"))
 		}
 	}
 }
@@ -322,57 +489,197 @@ func TestAddOrderHandler(t *testing.T) {
 	// initialize testing table
 	testTable := []struct {
 		TestName       string
-		FormData       map[string]string
+		Order          model.Order
 		User           middleware.User
 		ExpectedOrder  model.Order
 		ExpectedStatus int
 	}{
 		{
 			TestName: "Test Add Order Success",
-			FormData: map[string]string{
-				"status":              "in-cart",
-				"qty":                 "2",
-				"total_price":         "2000000.50",
-				"buyer_full_name":     "George Marcus",
-				"buyer_address":       "Buyer Street",
-				"product_name":        "Product 1",
-				"product_price":       "1000000.50",
-				"product_weight":      "1.5",
-				"product_description": "Product description",
-				"product_stock":       "100",
+			Order: model.Order{
+				Status:        "in-cart",
+				BuyerFullName: "George Marcus",
+				BuyerAddress:  "Buyer Street",
+				Items: []model.OrderItem{
+					{
+						ProductID:          1,
+						ProductName:        "Product 1",
+						ProductPrice:       money.MustParse("1000000.50"),
+						ProductWeight:      1.5,
+						ProductDescription: "Product description",
+						ProductStock:       100,
+						Qty:                2,
+					},
+					{
+						ProductID:     2,
+						ProductName:   "Product 2",
+						ProductPrice:  money.MustParse("500"),
+						ProductWeight: 1,
+						Qty:           1,
+					},
+				},
 			},
 			User: middleware.User{
 				ID:   1,
 				Role: "buyer",
 			},
 			ExpectedOrder: model.Order{
-				Status:             "in-cart",
-				Qty:                2,
-				BuyerID:            1,
-				BuyerFullName:      "George Marcus",
-				BuyerAddress:       "Buyer Street",
-				TotalPrice:         2000000.50,
-				ProductName:        "Product 1",
-				ProductPrice:       1000000.50,
-				ProductWeight:      1.5,
-				ProductDescription: "Product description",
-				ProductStock:       100,
+				Status:        "in-cart",
+				Currency:      "IDR",
+				Qty:           3,
+				BuyerID:       1,
+				BuyerFullName: "George Marcus",
+				BuyerAddress:  "Buyer Street",
+				TotalPrice:    money.MustParse("2000501"),
+				Items: []model.OrderItem{
+					{
+						ProductID:          1,
+						ProductSKU:         "sku1",
+						ProductName:        "Product 1",
+						ProductPrice:       money.MustParse("1000000.50"),
+						ProductWeight:      1.5,
+						ProductDescription: "Product description",
+						ProductStock:       100,
+						ProductUserID:      10,
+						ProductImagesPath:  []string{"product 1.1.jpg"},
+						Qty:                2,
+						Subtotal:           money.MustParse("2000001"),
+					},
+					{
+						ProductID:     2,
+						ProductSKU:    "sku2",
+						ProductName:   "Product 2",
+						ProductPrice:  money.MustParse("500"),
+						ProductWeight: 1,
+						ProductStock:  50,
+						ProductUserID: 20,
+						Qty:           1,
+						Subtotal:      money.MustParse("500"),
+					},
+				},
+			},
+			ExpectedStatus: http.StatusCreated,
+		},
+		{
+			TestName: "Test Add Order Without Product Detail Success",
+			Order: model.Order{
+				Status:        "in-cart",
+				BuyerFullName: "George Marcus",
+				BuyerAddress:  "Buyer Street",
+				Items: []model.OrderItem{
+					{
+						ProductID: 2,
+						Qty:       4,
+					},
+				},
+			},
+			User: middleware.User{
+				ID:   1,
+				Role: "buyer",
+			},
+			ExpectedOrder: model.Order{
+				Status:        "in-cart",
+				Currency:      "IDR",
+				Qty:           4,
+				BuyerID:       1,
+				BuyerFullName: "George Marcus",
+				BuyerAddress:  "Buyer Street",
+				TotalPrice:    money.MustParse("2000"),
+				Items: []model.OrderItem{
+					{
+						ProductID:     2,
+						ProductSKU:    "sku2",
+						ProductName:   "Product 2",
+						ProductPrice:  money.MustParse("500"),
+						ProductWeight: 1,
+						ProductStock:  50,
+						ProductUserID: 20,
+						Qty:           4,
+						Subtotal:      money.MustParse("2000"),
+					},
+				},
 			},
 			ExpectedStatus: http.StatusCreated,
 		},
+		{
+			TestName: "Test Add Order Product Price Mismatch",
+			Order: model.Order{
+				Status:        "in-cart",
+				BuyerFullName: "George Marcus",
+				BuyerAddress:  "Buyer Street",
+				Items: []model.OrderItem{
+					{
+						ProductID:     1,
+						ProductName:   "Product 1",
+						ProductPrice:  money.MustParse("0.01"),
+						ProductWeight: 1.5,
+						Qty:           1,
+					},
+				},
+			},
+			User: middleware.User{
+				ID:   1,
+				Role: "buyer",
+			},
+			ExpectedOrder:  model.Order{},
+			ExpectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			TestName: "Test Add Order Total Price Mismatch",
+			Order: model.Order{
+				Status:        "in-cart",
+				BuyerFullName: "George Marcus",
+				BuyerAddress:  "Buyer Street",
+				TotalPrice:    money.MustParse("0.01"),
+				Items: []model.OrderItem{
+					{
+						ProductID: 1,
+						Qty:       1,
+					},
+				},
+			},
+			User: middleware.User{
+				ID:   1,
+				Role: "buyer",
+			},
+			ExpectedOrder:  model.Order{},
+			ExpectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			TestName: "Test Add Order Product Not Found",
+			Order: model.Order{
+				Status:        "in-cart",
+				BuyerFullName: "George Marcus",
+				BuyerAddress:  "Buyer Street",
+				Items: []model.OrderItem{
+					{
+						ProductID: 99,
+						Qty:       1,
+					},
+				},
+			},
+			User: middleware.User{
+				ID:   1,
+				Role: "buyer",
+			},
+			ExpectedOrder:  model.Order{},
+			ExpectedStatus: http.StatusUnprocessableEntity,
+		},
 		{
 			TestName: "Test Add Order Forbidden",
-			FormData: map[string]string{
-				"status":              "in-cart",
-				"qty":                 "2",
-				"total_price":         "2000000.50",
-				"buyer_full_name":     "George Marcus",
-				"buyer_address":       "Buyer Street",
-				"product_name":        "Product 1",
-				"product_price":       "1000000.50",
-				"product_weight":      "1.5",
-				"product_description": "Product description",
-				"product_stock":       "100",
+			Order: model.Order{
+				Status:        "in-cart",
+				BuyerFullName: "George Marcus",
+				BuyerAddress:  "Buyer Street",
+				Items: []model.OrderItem{
+					{
+						ProductID:     1,
+						ProductName:   "Product 1",
+						ProductPrice:  money.MustParse("1000000.50"),
+						ProductWeight: 1.5,
+						Qty:           2,
+					},
+				},
 			},
 			User: middleware.User{
 				ID:   1,
@@ -383,16 +690,72 @@ func TestAddOrderHandler(t *testing.T) {
 		},
 		{
 			TestName: "Test Add Order Bad Request",
-			FormData: map[string]string{
-				"status":              "",
-				"qty":                 "2",
-				"total_price":         "2000000.50",
-				"buyer_full_name":     "George Marcus",
-				"buyer_address":       "Buyer Street",
-				"product_name":        "Product 1",
-				"product_price":       "1000000.50",
-				"product_weight":      "1.5",
-				"product_description": "Product description",
+			Order: model.Order{
+				Status:        "",
+				BuyerFullName: "George Marcus",
+				BuyerAddress:  "Buyer Street",
+				Items: []model.OrderItem{
+					{
+						ProductID:     1,
+						ProductName:   "Product 1",
+						ProductPrice:  money.MustParse("1000000.50"),
+						ProductWeight: 1.5,
+						Qty:           2,
+					},
+				},
+			},
+			User: middleware.User{
+				ID:   1,
+				Role: "buyer",
+			},
+			ExpectedOrder:  model.Order{},
+			ExpectedStatus: http.StatusBadRequest,
+		},
+		{
+			TestName: "Test Add Order Checked Out",
+			Order: model.Order{
+				Status:        "checked-out",
+				BuyerFullName: "George Marcus",
+				BuyerAddress:  "Buyer Street",
+				Items: []model.OrderItem{
+					{
+						ProductID: 1,
+						Qty:       2,
+					},
+				},
+			},
+			User: middleware.User{
+				ID:   1,
+				Role: "buyer",
+			},
+			ExpectedOrder:  model.Order{},
+			ExpectedStatus: http.StatusBadRequest,
+		},
+		{
+			TestName: "Test Add Order Currency Invalid",
+			Order: model.Order{
+				Status:   "in-cart",
+				Currency: "USD",
+				Items: []model.OrderItem{
+					{
+						ProductID: 1,
+						Qty:       2,
+					},
+				},
+			},
+			User: middleware.User{
+				ID:   1,
+				Role: "buyer",
+			},
+			ExpectedOrder:  model.Order{},
+			ExpectedStatus: http.StatusBadRequest,
+		},
+		{
+			TestName: "Test Add Order Without Items",
+			Order: model.Order{
+				Status:        "in-cart",
+				BuyerFullName: "George Marcus",
+				BuyerAddress:  "Buyer Street",
 			},
 			User: middleware.User{
 				ID:   1,
@@ -412,39 +775,21 @@ func TestAddOrderHandler(t *testing.T) {
 				test.TestName, err.Error())
 		}
 
-		// transform form data to bytes buffer
-		var bFormData bytes.Buffer
-		w := multipart.NewWriter(&bFormData)
-		for key, r := range test.FormData {
-			fw, err := w.CreateFormField(key)
-			if err != nil {
-				t.Errorf("[%s] There's an error when creating "+
-					"bytes buffer form data => %s",
-					test.TestName, err.Error())
-			}
-
-			_, err = io.Copy(fw, strings.NewReader(r))
-			if err != nil {
-				t.Errorf("[%s] There's an error when creating "+
-					"bytes buffer form data => %s",
-					test.TestName, err.Error())
-			}
+		// transform order to json body
+		body, err := json.Marshal(test.Order)
+		if err != nil {
+			t.Errorf("[%s] There's an error when marshal order to json => %s",
+				test.TestName, err.Error())
 		}
-		w.Close()
 
 		// create and run request
-		req := httptest.NewRequest("POST", "/", &bFormData)
-		req.Header.Set("Content-Type", w.FormDataContentType())
-		if err != nil {
-			t.Errorf("[%s] There's an error when creating "+
-				"request API add orders => %s",
-				test.TestName, err)
-		}
+		req := httptest.NewRequest("POST", "/", bytes.NewReader(body))
+		req.Header.Set("Content-Type", echo.MIMEApplicationJSON)
 
 		response := httptest.NewRecorder()
 		echoCtx := a.Echo.NewContext(req, response)
 		echoCtx.Set("user", test.User)
-		err = a.AddOrderHandler(echoCtx)
+		err = callHandler(a.AddOrderHandler, echoCtx)
 		if err != nil {
 			t.Errorf("[%s] Expected API call success, but got error => %s",
 				test.TestName, err)
@@ -455,7 +800,7 @@ func TestAddOrderHandler(t *testing.T) {
 			t.Errorf("[%s] Expected status %d got %d",
 				test.TestName, test.ExpectedStatus, response.Code)
 
-			var resp map[string]string
+			var resp map[string]interface{}
 			err = json.NewDecoder(response.Body).Decode(&resp)
 			if err != nil {
 				t.Errorf("[%s] There's an error when unmarshal body response => %s",
@@ -465,83 +810,1082 @@ func TestAddOrderHandler(t *testing.T) {
 			t.Error(resp)
 		} else {
 			if test.ExpectedStatus == http.StatusCreated {
-				// get response data (product)
-				var respPInfo model.Order
-				err = json.NewDecoder(response.Body).Decode(&respPInfo)
+				// get response data (order)
+				var respO model.Order
+				err = json.NewDecoder(response.Body).Decode(&respO)
 				if err != nil {
 					t.Errorf("[%s] There's an error when unmarshal body response => %s",
 						test.TestName, err.Error())
 				}
 
 				// check result
-				if respPInfo.ID == primitive.NilObjectID {
+				if respO.ID == primitive.NilObjectID {
 					t.Errorf("[%s] Expected id not nil, but got nil", test.TestName)
 				}
-				if test.ExpectedOrder.ProductName != respPInfo.ProductName {
-					t.Errorf("[%s] Expected ProductName '%s', but got ProductName '%s'",
-						test.TestName, test.ExpectedOrder.ProductName,
-						respPInfo.ProductName)
+				if respO.OrderNumber == "" {
+					t.Errorf("[%s] Expected order number not empty, but got empty",
+						test.TestName)
 				}
-				if test.ExpectedOrder.ProductPrice != respPInfo.ProductPrice {
-					t.Errorf("[%s] Expected ProductPrice %f, but got ProductPrice %f",
-						test.TestName, test.ExpectedOrder.ProductPrice,
-						respPInfo.ProductPrice)
-				}
-				if test.ExpectedOrder.ProductWeight != respPInfo.ProductWeight {
-					t.Errorf("[%s] Expected ProductWeight %f, but got ProductWeight %f",
-						test.TestName, test.ExpectedOrder.ProductWeight,
-						respPInfo.ProductWeight)
-				}
-				if test.ExpectedOrder.ProductDescription != respPInfo.ProductDescription {
-					t.Errorf("[%s] Expected ProductDescription '%s', "+
-						"but got ProductDescription '%s'",
-						test.TestName, test.ExpectedOrder.ProductDescription,
-						respPInfo.ProductDescription)
-				}
-				if test.ExpectedOrder.ProductStock != respPInfo.ProductStock {
-					t.Errorf("[%s] Expected ProductStock %d, but got ProductStock %d",
-						test.TestName, test.ExpectedOrder.ProductStock,
-						respPInfo.ProductStock)
-				}
-				if test.ExpectedOrder.BuyerID != respPInfo.BuyerID {
-					t.Errorf("[%s] Expected BuyerID %d, but got BuyerID %d",
-						test.TestName, test.ExpectedOrder.BuyerID,
-						respPInfo.BuyerID)
-				}
-				if test.ExpectedOrder.BuyerFullName != respPInfo.BuyerFullName {
-					t.Errorf("[%s] Expected BuyerFullName %s, but got BuyerFullName %s",
-						test.TestName, test.ExpectedOrder.BuyerFullName,
-						respPInfo.BuyerFullName)
-				}
-				if test.ExpectedOrder.BuyerAddress != respPInfo.BuyerAddress {
-					t.Errorf("[%s] Expected BuyerAddress %s, but got BuyerAddress %s",
-						test.TestName, test.ExpectedOrder.BuyerAddress,
-						respPInfo.BuyerAddress)
+				test.ExpectedOrder.ID = respO.ID
+				test.ExpectedOrder.OrderNumber = respO.OrderNumber
+				if !isOrderEqual(test.ExpectedOrder, respO) {
+					t.Errorf("[%s] Expected order %v, but got %v",
+						test.TestName, test.ExpectedOrder, respO)
 				}
 			}
 		}
 	}
 
-	// remove all data in all collection after test
-	a, err := GetTestingAPI(middleware.User{})
-	if err != nil {
-		t.Errorf("There's an error when getting testing API => %s",
-			err.Error())
-	}
+}
 
-	for _, collection := range a.Collections {
-		_, err = collection.DeleteMany(a.Ctx, bson.D{})
-		if err != nil {
-			log.Fatalf("There's an error when truncating "+
-				"mongodb collections after run all test => %s", err)
-		}
+// TestAddOrderHandlerInvalidItems test AddOrderHandler validate order items
+// before getting their product data from product service
+func TestAddOrderHandlerInvalidItems(t *testing.T) {
+	tooManyItems := []model.OrderItem{}
+	for i := 1; i <= validator.MaxItems+1; i++ {
+		tooManyItems = append(tooManyItems, model.OrderItem{ProductID: i, Qty: 1})
 	}
-}
 
-// TestUpdateOrderHandler test UpdateOrderHandler
+	// initialize testing table
+	testTable := []struct {
+		TestName       string
+		Items          []model.OrderItem
+		ExpectedFields []string
+	}{
+		{
+			TestName: "Test Add Order Duplicated Items",
+			Items: []model.OrderItem{
+				{ProductID: 1, Qty: 1},
+				{ProductID: 1, Qty: 2},
+			},
+			ExpectedFields: []string{"items[1].product_id"},
+		},
+		{
+			TestName:       "Test Add Order Item Qty Invalid",
+			Items:          []model.OrderItem{{ProductID: 1, Qty: 0}},
+			ExpectedFields: []string{"items[0].qty"},
+		},
+		{
+			TestName:       "Test Add Order Item Without Product ID",
+			Items:          []model.OrderItem{{Qty: 1}},
+			ExpectedFields: []string{"items[0].product_id"},
+		},
+		{
+			TestName:       "Test Add Order Too Many Items",
+			Items:          tooManyItems,
+			ExpectedFields: []string{"items"},
+		},
+	}
+
+	// loop test in test table
+	for _, test := range testTable {
+		u := middleware.User{ID: 1, Role: "buyer"}
+		a, err := GetTestingAPI(u)
+		if err != nil {
+			t.Errorf("[%s] There's an error when getting testing API => %s",
+				test.TestName, err.Error())
+		}
+		products := &countingProductService{Service: a.Products}
+		a.Products = products
+
+		body, err := json.Marshal(model.Order{
+			Status: "in-cart",
+			Items:  test.Items,
+		})
+		if err != nil {
+			t.Errorf("[%s] There's an error when marshal order to json => %s",
+				test.TestName, err.Error())
+		}
+
+		req := httptest.NewRequest("POST", "/", bytes.NewReader(body))
+		req.Header.Set("Content-Type", echo.MIMEApplicationJSON)
+
+		response := httptest.NewRecorder()
+		echoCtx := a.Echo.NewContext(req, response)
+		echoCtx.Set("user", u)
+		err = callHandler(a.AddOrderHandler, echoCtx)
+		if err != nil {
+			t.Errorf("[%s] Expected API call success, but got error => %s",
+				test.TestName, err)
+		}
+
+		// check response and product service not called
+		if response.Code != http.StatusBadRequest {
+			t.Errorf("[%s] Expected status %d got %d => %s", test.TestName,
+				http.StatusBadRequest, response.Code, response.Body.String())
+		}
+		if products.GetProductCalls != 0 {
+			t.Errorf("[%s] Expected product service not called, "+
+				"but called %d times", test.TestName, products.GetProductCalls)
+		}
+
+		var p problem.Problem
+		err = json.Unmarshal(response.Body.Bytes(), &p)
+		if err != nil {
+			t.Errorf("[%s] There's an error when unmarshal body response => %s",
+				test.TestName, err)
+			continue
+		}
+		fields := []string{}
+		for _, e := range p.Errors {
+			fields = append(fields, e.Field)
+		}
+		if strings.Join(fields, ",") != strings.Join(test.ExpectedFields, ",") {
+			t.Errorf("[%s] Expected invalid fields %v, but got %v",
+				test.TestName, test.ExpectedFields, fields)
+		}
+	}
+}
+
+// TestUpdateOrderHandler test UpdateOrderHandler
+//
+// Required for test: model.InsertOrder
+func TestUpdateOrderHandler(t *testing.T) {
+	// insert testing data
+	a, err := GetTestingAPI(middleware.User{
+		ID:   1,
+		Role: "buyer",
+	})
+	if err != nil {
+		t.Fatalf("There's an error when getting testing API => %s",
+			err.Error())
+	}
+	oCreate := model.Order{
+		Status:        "in-cart",
+		BuyerID:       1,
+		BuyerFullName: "George Marcus",
+		BuyerAddress:  "Buyer Street",
+		Items: []model.OrderItem{
+			{
+				ProductID:          1,
+				ProductName:        "Product 1",
+				ProductPrice:       money.MustParse("1000000.50"),
+				ProductWeight:      1.5,
+				ProductDescription: "Product description",
+				ProductStock:       100,
+				ProductUserID:      10,
+				Qty:                2,
+			},
+		},
+	}
+
+	oCreate, err = a.Orders.Insert(a.Ctx, oCreate)
+	if err != nil {
+		t.Fatalf("There's an error when creating testing data for testing "+
+			"update data => %s", err.Error())
+	}
+
+	oDone := oCreate
+	oDone.ID = primitive.NilObjectID
+	oDone.Status = "done"
+	oDone, err = a.Orders.Insert(a.Ctx, oDone)
+	if err != nil {
+		t.Fatalf("There's an error when creating testing data for testing "+
+			"update data => %s", err.Error())
+	}
+
+	// initialize testing table
+	testTable := []struct {
+		TestName       string
+		Filter         map[string]string
+		FormData       map[string]string
+		User           middleware.User
+		ExpectedStatus int
+	}{
+		{
+			TestName: "Test Update Order Success",
+			Filter: map[string]string{
+				"order_number": oCreate.OrderNumber,
+			},
+			FormData: map[string]string{
+				"status":        "checked-out",
+				"buyer_address": "Buyer Street 2",
+			},
+			User: middleware.User{
+				ID:   1,
+				Role: "buyer",
+			},
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			TestName: "Test Update Order Forbidden Buyer Paid",
+			Filter: map[string]string{
+				"order_number": oCreate.OrderNumber,
+			},
+			FormData: map[string]string{
+				"status": "paid",
+			},
+			User: middleware.User{
+				ID:   1,
+				Role: "buyer",
+			},
+			ExpectedStatus: http.StatusForbidden,
+		},
+		{
+			TestName: "Test Update Order Status Transition Invalid",
+			Filter: map[string]string{
+				"order_number": oDone.OrderNumber,
+			},
+			FormData: map[string]string{
+				"status": "in-cart",
+			},
+			User: middleware.User{
+				ID:   1,
+				Role: "buyer",
+			},
+			ExpectedStatus: http.StatusConflict,
+		},
+		{
+			TestName: "Test Update Order Status Unknown",
+			Filter: map[string]string{
+				"order_number": oCreate.OrderNumber,
+			},
+			FormData: map[string]string{
+				"status": "waiting-for-payment",
+			},
+			User: middleware.User{
+				ID:   1,
+				Role: "buyer",
+			},
+			ExpectedStatus: http.StatusBadRequest,
+		},
+		{
+			TestName: "Test Update Order Bad Request",
+			Filter:   map[string]string{},
+			FormData: map[string]string{
+				"status":        "",
+				"buyer_address": "Buyer Street 2",
+			},
+			User: middleware.User{
+				ID:   1,
+				Role: "buyer",
+			},
+			ExpectedStatus: http.StatusBadRequest,
+		},
+		{
+			TestName: "Test Update Order Forbidden Other Buyer",
+			Filter: map[string]string{
+				"order_number": oCreate.OrderNumber,
+			},
+			FormData: map[string]string{
+				"status": "cancelled",
+			},
+			User: middleware.User{
+				ID:   2,
+				Role: "buyer",
+			},
+			ExpectedStatus: http.StatusForbidden,
+		},
+		{
+			TestName: "Test Update Order Forbidden Seller Status",
+			Filter: map[string]string{
+				"order_number": oCreate.OrderNumber,
+			},
+			FormData: map[string]string{
+				"status": "paid",
+			},
+			User: middleware.User{
+				ID:   10,
+				Role: "seller",
+			},
+			ExpectedStatus: http.StatusForbidden,
+		},
+		{
+			TestName: "Test Update Order Forbidden Seller Field",
+			Filter: map[string]string{
+				"order_number": oCreate.OrderNumber,
+			},
+			FormData: map[string]string{
+				"buyer_address": "Seller Street",
+			},
+			User: middleware.User{
+				ID:   10,
+				Role: "seller",
+			},
+			ExpectedStatus: http.StatusForbidden,
+		},
+		{
+			TestName: "Test Update Order Admin Success",
+			Filter: map[string]string{
+				"order_number": oCreate.OrderNumber,
+			},
+			FormData: map[string]string{
+				"status": "paid",
+			},
+			User: middleware.User{
+				ID:   100,
+				Role: "admin",
+			},
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			TestName: "Test Update Order Seller Success",
+			Filter: map[string]string{
+				"order_number": oCreate.OrderNumber,
+			},
+			FormData: map[string]string{
+				"status": "shipped",
+			},
+			User: middleware.User{
+				ID:   10,
+				Role: "seller",
+			},
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			TestName: "Test Update Order Forbidden Other Seller",
+			Filter: map[string]string{
+				"order_number": oCreate.OrderNumber,
+			},
+			FormData: map[string]string{
+				"status": "delivered",
+			},
+			User: middleware.User{
+				ID:   20,
+				Role: "seller",
+			},
+			ExpectedStatus: http.StatusForbidden,
+		},
+		{
+			TestName: "Test Update Order Not Found",
+			Filter: map[string]string{
+				"order_number": "this order number not exist",
+			},
+			FormData: map[string]string{
+				"status": "cancelled",
+			},
+			User: middleware.User{
+				ID:   1,
+				Role: "buyer",
+			},
+			ExpectedStatus: http.StatusNotFound,
+		},
+	}
+
+	// loop test in test table
+	for _, test := range testTable {
+		// transform form data to bytes buffer
+		var bFormData bytes.Buffer
+		w := multipart.NewWriter(&bFormData)
+		for key, r := range test.FormData {
+			fw, err := w.CreateFormField(key)
+			if err != nil {
+				t.Errorf("[%s] There's an error when creating "+
+					"bytes buffer form data => %s",
+					test.TestName, err.Error())
+			}
+
+			_, err = io.Copy(fw, strings.NewReader(r))
+			if err != nil {
+				t.Errorf("[%s] There's an error when creating "+
+					"bytes buffer form data => %s",
+					test.TestName, err.Error())
+			}
+		}
+		w.Close()
+
+		// get url params
+		params := url.Values{}
+		for key, value := range test.Filter {
+			params.Add(key, value)
+		}
+
+		// create and run request
+		req := httptest.NewRequest("PUT", "/", &bFormData)
+		req.URL.RawQuery = params.Encode()
+		req.Header.Set("Content-Type", w.FormDataContentType())
+		if err != nil {
+			t.Errorf("[%s] There's an error when creating "+
+				"request API add orders => %s",
+				test.TestName, err)
+		}
+
+		response := httptest.NewRecorder()
+		echoCtx := a.Echo.NewContext(req, response)
+		echoCtx.Set("user", test.User)
+		err = callHandler(a.UpdateOrderHandler, echoCtx)
+		if err != nil {
+			t.Errorf("[%s] Expected API call success, but got error => %s",
+				test.TestName, err)
+		}
+
+		// check response
+		if response.Code != test.ExpectedStatus {
+			t.Errorf("[%s] Expected status %d got %d",
+				test.TestName, test.ExpectedStatus, response.Code)
+
+			var resp map[string]interface{}
+			err = json.NewDecoder(response.Body).Decode(&resp)
+			if err != nil {
+				t.Errorf("[%s] There's an error when unmarshal body response => %s",
+					test.TestName, err.Error())
+			}
+
+			t.Error(resp)
+		}
+	}
+
+}
+
+// TestUpdateOrderHandlerResponse test UpdateOrderHandler respond
+// with the updated order on versioned route and with message
+// on legacy route
+func TestUpdateOrderHandlerResponse(t *testing.T) {
+	buyer := middleware.User{ID: 1, Role: "buyer"}
+	a, err := GetTestingAPI(buyer)
+	if err != nil {
+		t.Fatalf("There's an error when getting testing API => %s", err)
+	}
+
+	o, err := a.Orders.Insert(a.Ctx, model.Order{
+		Status:  "in-cart",
+		BuyerID: 1,
+		Items: []model.OrderItem{
+			{ProductID: 2, ProductName: "Product 2",
+				ProductPrice: money.MustParse("500"), Qty: 1},
+		},
+	})
+	if err != nil {
+		t.Fatalf("There's an error when creating testing data => %s", err)
+	}
+
+	// initialize testing table, run in order
+	testTable := []struct {
+		TestName        string
+		Path            string
+		BuyerAddress    string
+		ExpectedETag    string
+		ExpectedMessage string
+	}{
+		{
+			TestName:     "Test Update Order Versioned Route",
+			Path:         "/api/v1/orders/:order_number",
+			BuyerAddress: "Buyer Street 2",
+			ExpectedETag: `"2"`,
+		},
+		{
+			TestName:        "Test Update Order Legacy Route",
+			Path:            "/api/order/",
+			BuyerAddress:    "Buyer Street 3",
+			ExpectedETag:    `"3"`,
+			ExpectedMessage: "Update order success!",
+		},
+	}
+
+	// test for each testing table
+	for _, test := range testTable {
+		req := httptest.NewRequest("PUT", "/?order_number="+o.OrderNumber,
+			strings.NewReader(`{"buyer_address": "`+test.BuyerAddress+`"}`))
+		req.Header.Set("Content-Type", echo.MIMEApplicationJSON)
+
+		response := httptest.NewRecorder()
+		echoCtx := a.Echo.NewContext(req, response)
+		echoCtx.SetPath(test.Path)
+		echoCtx.Set("user", buyer)
+		err = callHandler(a.UpdateOrderHandler, echoCtx)
+		if err != nil {
+			t.Errorf("[%s] Expected API call success, but got error => %s",
+				test.TestName, err)
+		}
+
+		if response.Code != http.StatusOK {
+			t.Errorf("[%s] Expected status %d got %d => %s", test.TestName,
+				http.StatusOK, response.Code, response.Body.String())
+		}
+		if response.Header().Get("ETag") != test.ExpectedETag {
+			t.Errorf("[%s] Expected ETag %s got %s", test.TestName,
+				test.ExpectedETag, response.Header().Get("ETag"))
+		}
+
+		var result map[string]interface{}
+		err = json.Unmarshal(response.Body.Bytes(), &result)
+		if err != nil {
+			t.Fatalf("[%s] There's an error when unmarshal body response => %s",
+				test.TestName, err)
+		}
+		if test.ExpectedMessage != "" {
+			if result["message"] != test.ExpectedMessage {
+				t.Errorf("[%s] Expected message %s, but got %v",
+					test.TestName, test.ExpectedMessage, result)
+			}
+			continue
+		}
+		if result["order_number"] != o.OrderNumber ||
+			result["buyer_address"] != test.BuyerAddress {
+			t.Errorf("[%s] Expected updated order %s, but got %v",
+				test.TestName, o.OrderNumber, result)
+		}
+	}
+}
+
+// TestInitRouter test InitRouter only register routes existed before
+// versioned routes on legacy router
+func TestInitRouter(t *testing.T) {
+	a, err := GetTestingAPI(middleware.User{})
+	if err != nil {
+		t.Fatalf("There's an error when getting testing API => %s", err)
+	}
+	a.InitRouter()
+
+	routes := map[string]bool{}
+	for _, r := range a.Echo.Routes() {
+		routes[r.Method+" "+r.Path] = true
+	}
+
+	// initialize testing table
+	testTable := []struct {
+		TestName           string
+		Route              string
+		ExpectedRegistered bool
+	}{
+		{
+			TestName:           "Test Versioned Route Restore Order",
+			Route:              "POST /api/v1/orders/:order_number/restore",
+			ExpectedRegistered: true,
+		},
+		{
+			TestName:           "Test Legacy Route Update Order",
+			Route:              "PUT /api/order/",
+			ExpectedRegistered: true,
+		},
+		{
+			TestName: "Test Legacy Route Patch Order",
+			Route:    "PATCH /api/order/",
+		},
+		{
+			TestName: "Test Legacy Route Restore Order",
+			Route:    "POST /api/order/restore/",
+		},
+		{
+			TestName: "Test Legacy Route Order History",
+			Route:    "GET /api/order/history/",
+		},
+		{
+			TestName: "Test Legacy Route Add Order Item",
+			Route:    "POST /api/order/item/",
+		},
+		{
+			TestName: "Test Legacy Route Add Webhook",
+			Route:    "POST /api/webhook/",
+		},
+	}
+
+	// test for each testing table
+	for _, test := range testTable {
+		if routes[test.Route] != test.ExpectedRegistered {
+			t.Errorf("[%s] Expected route %s registered %t, but got %t",
+				test.TestName, test.Route, test.ExpectedRegistered,
+				routes[test.Route])
+		}
+	}
+}
+
+// TestUpdateOrderHandlerItems test UpdateOrderHandler update order items
+// filled with product data instead of item data sent by client
+func TestUpdateOrderHandlerItems(t *testing.T) {
+	buyer := middleware.User{ID: 1, Role: "buyer"}
+	a, err := GetTestingAPI(buyer)
+	if err != nil {
+		t.Fatalf("There's an error when getting testing API => %s", err)
+	}
+
+	o, err := a.Orders.Insert(a.Ctx, model.Order{
+		Status:  "in-cart",
+		BuyerID: 1,
+		Items: []model.OrderItem{
+			{ProductID: 2, ProductName: "Product 2",
+				ProductPrice: money.MustParse("500"), ProductWeight: 1,
+				ProductUserID: 20, Qty: 1},
+		},
+	})
+	if err != nil {
+		t.Fatalf("There's an error when creating testing data => %s", err)
+	}
+
+	// initialize testing table, run in order
+	testTable := []struct {
+		TestName       string
+		Body           string
+		ExpectedStatus int
+	}{
+		{
+			TestName: "Test Update Order Items Duplicated",
+			Body: `{"items": [{"product_id": 1, "qty": 1}, ` +
+				`{"product_id": 1, "qty": 2}]}`,
+			ExpectedStatus: http.StatusBadRequest,
+		},
+		{
+			TestName: "Test Update Order Items Price By Buyer",
+			Body: `{"items": [{"product_id": 2, "product_name": "Product 2", ` +
+				`"product_price": "1", "product_weight": 1, ` +
+				`"product_user_id": 20, "qty": 1}]}`,
+			ExpectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			TestName: "Test Update Order New Item Price By Buyer",
+			Body: `{"items": [{"product_id": 1, "product_price": "1", ` +
+				`"qty": 2}]}`,
+			ExpectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			TestName:       "Test Update Order Items Success",
+			Body:           `{"items": [{"product_id": 1, "qty": 2}]}`,
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			TestName:       "Test Update Order Item Qty Only",
+			Body:           `{"items": [{"product_id": 1, "qty": 3}]}`,
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			TestName: "Test Update Order Item Qty With Same Detail",
+			Body: `{"items": [{"product_id": 1, "product_sku": "sku1", ` +
+				`"product_price": "1000000.50", "qty": 2}]}`,
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			TestName: "Test Update Order Item Price Changed By Buyer",
+			Body: `{"items": [{"product_id": 1, "product_price": "1", ` +
+				`"qty": 2}]}`,
+			ExpectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			TestName:       "Test Update Order Checked Out",
+			Body:           `{"status": "checked-out"}`,
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			TestName:       "Test Update Order Items After Checked Out",
+			Body:           `{"items": [{"product_id": 1, "qty": 3}]}`,
+			ExpectedStatus: http.StatusConflict,
+		},
+	}
+
+	// test for each testing table
+	for _, test := range testTable {
+		req := httptest.NewRequest("PUT", "/?order_number="+o.OrderNumber,
+			strings.NewReader(test.Body))
+		req.Header.Set("Content-Type", echo.MIMEApplicationJSON)
+
+		response := httptest.NewRecorder()
+		echoCtx := a.Echo.NewContext(req, response)
+		echoCtx.Set("user", buyer)
+		err = callHandler(a.UpdateOrderHandler, echoCtx)
+		if err != nil {
+			t.Errorf("[%s] Expected API call success, but got error => %s",
+				test.TestName, err)
+		}
+
+		if response.Code != test.ExpectedStatus {
+			t.Errorf("[%s] Expected status %d got %d => %s",
+				test.TestName, test.ExpectedStatus, response.Code,
+				response.Body.String())
+		}
+	}
+
+	// check new item price and total price from product service
+	result, err := a.Orders.Get(a.Ctx,
+		repository.OrderFilter{OrderNumber: o.OrderNumber})
+	if err != nil {
+		t.Fatalf("There's an error when getting order => %s", err)
+	}
+	if len(result.Items) != 1 ||
+		result.Items[0].ProductPrice != money.MustParse("1000000.50") ||
+		result.TotalPrice != money.MustParse("2000001") {
+		t.Errorf("Expected order items price from product service, "+
+			"but got %+v", result)
+	}
+}
+
+// TestDeleteOrderHandler test DeleteOrderHandler
+//
+// Required for test: model.InsertOrder
+func TestDeleteOrderHandler(t *testing.T) {
+	// insert testing data
+	a, err := GetTestingAPI(middleware.User{
+		ID:   1,
+		Role: "buyer",
+	})
+	if err != nil {
+		t.Fatalf("There's an error when getting testing API => %s",
+			err.Error())
+	}
+	oCreate := model.Order{
+		Status:        "in-cart",
+		BuyerID:       1,
+		BuyerFullName: "George Marcus",
+		BuyerAddress:  "Buyer Street",
+		Items: []model.OrderItem{
+			{
+				ProductID:          1,
+				ProductName:        "Product 1",
+				ProductPrice:       money.MustParse("1000000.50"),
+				ProductWeight:      1.5,
+				ProductDescription: "Product description",
+				ProductStock:       100,
+				Qty:                2,
+			},
+		},
+	}
+
+	oCreate, err = a.Orders.Insert(a.Ctx, oCreate)
+	if err != nil {
+		t.Fatalf("There's an error when creating testing data for testing "+
+			"update data => %s", err.Error())
+	}
+
+	oReserved := oCreate
+	oReserved.ID = primitive.NilObjectID
+	oReserved.Status = "checked-out"
+	reservedAt := time.Now().UTC()
+	oReserved.ReservedAt = &reservedAt
+	oReserved, err = a.Orders.Insert(a.Ctx, oReserved)
+	if err != nil {
+		t.Fatalf("There's an error when creating testing data for testing "+
+			"update data => %s", err.Error())
+	}
+
+	// initialize testing table
+	testTable := []struct {
+		TestName       string
+		Filter         map[string]string
+		User           middleware.User
+		ExpectedStatus int
+	}{
+		{
+			TestName: "Test Delete Order Forbidden Other Buyer",
+			Filter: map[string]string{
+				"order_number": oCreate.OrderNumber,
+			},
+			User: middleware.User{
+				ID:   2,
+				Role: "buyer",
+			},
+			ExpectedStatus: http.StatusForbidden,
+		},
+		{
+			TestName: "Test Delete Order Forbidden Seller",
+			Filter: map[string]string{
+				"order_number": oCreate.OrderNumber,
+			},
+			User: middleware.User{
+				ID:   10,
+				Role: "seller",
+			},
+			ExpectedStatus: http.StatusForbidden,
+		},
+		{
+			TestName: "Test Delete Order Stock Reserved",
+			Filter: map[string]string{
+				"order_number": oReserved.OrderNumber,
+			},
+			User: middleware.User{
+				ID:   1,
+				Role: "buyer",
+			},
+			ExpectedStatus: http.StatusConflict,
+		},
+		{
+			TestName: "Test Delete Order Success",
+			Filter: map[string]string{
+				"order_number": oCreate.OrderNumber,
+			},
+			User: middleware.User{
+				ID:   1,
+				Role: "buyer",
+			},
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			TestName: "Test Delete Order Not Found",
+			Filter: map[string]string{
+				"order_number": oCreate.OrderNumber,
+			},
+			User: middleware.User{
+				ID:   1,
+				Role: "buyer",
+			},
+			ExpectedStatus: http.StatusNotFound,
+		},
+		{
+			TestName: "Test Delete Order Bad Request",
+			Filter:   map[string]string{},
+			User: middleware.User{
+				ID:   1,
+				Role: "buyer",
+			},
+			ExpectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	// loop test in test table
+	for _, test := range testTable {
+		// get url params
+		params := url.Values{}
+		for key, value := range test.Filter {
+			params.Add(key, value)
+		}
+
+		// create and run request
+		req := httptest.NewRequest("DELETE", "/", nil)
+		req.URL.RawQuery = params.Encode()
+		if err != nil {
+			t.Errorf("[%s] There's an error when creating "+
+				"request API add orders => %s",
+				test.TestName, err)
+		}
+
+		response := httptest.NewRecorder()
+		echoCtx := a.Echo.NewContext(req, response)
+		echoCtx.Set("user", test.User)
+		err = callHandler(a.DeleteOrderHandler, echoCtx)
+		if err != nil {
+			t.Errorf("[%s] Expected API call success, but got error => %s",
+				test.TestName, err)
+		}
+
+		// check response
+		if response.Code != test.ExpectedStatus {
+			t.Errorf("[%s] Expected status %d got %d",
+				test.TestName, test.ExpectedStatus, response.Code)
+
+			var resp map[string]interface{}
+			err = json.NewDecoder(response.Body).Decode(&resp)
+			if err != nil {
+				t.Errorf("[%s] There's an error when unmarshal body response => %s",
+					test.TestName, err.Error())
+			}
+
+			t.Error(resp)
+		}
+	}
+
+}
+
+// TestAddOrderItemHandler test AddOrderItemHandler
+//
+// Required for test: model.InsertOrder, model.GetOrder
+func TestAddOrderItemHandler(t *testing.T) {
+	// insert testing data
+	a, err := GetTestingAPI(middleware.User{
+		ID:   1,
+		Role: "buyer",
+	})
+	if err != nil {
+		t.Fatalf("There's an error when getting testing API => %s",
+			err.Error())
+	}
+	oCreate := model.Order{
+		Status:        "in-cart",
+		BuyerID:       1,
+		BuyerFullName: "George Marcus",
+		BuyerAddress:  "Buyer Street",
+		Items: []model.OrderItem{
+			{
+				ProductID:     1,
+				ProductName:   "Product 1",
+				ProductPrice:  money.MustParse("1000000.50"),
+				ProductWeight: 1.5,
+				Qty:           2,
+			},
+		},
+	}
+
+	oCreate, err = a.Orders.Insert(a.Ctx, oCreate)
+	if err != nil {
+		t.Fatalf("There's an error when creating testing data for testing "+
+			"add order item => %s", err.Error())
+	}
+
+	// initialize testing table
+	testTable := []struct {
+		TestName           string
+		Filter             map[string]string
+		Item               model.OrderItem
+		User               middleware.User
+		ExpectedStatus     int
+		ExpectedTotalItem  int
+		ExpectedTotalPrice money.Amount
+	}{
+		{
+			TestName: "Test Add New Order Item Success",
+			Filter: map[string]string{
+				"order_number": oCreate.OrderNumber,
+			},
+			Item: model.OrderItem{
+				ProductID:     2,
+				ProductName:   "Product 2",
+				ProductPrice:  money.MustParse("500"),
+				ProductWeight: 1,
+				Qty:           3,
+			},
+			User: middleware.User{
+				ID:   1,
+				Role: "buyer",
+			},
+			ExpectedStatus:     http.StatusOK,
+			ExpectedTotalItem:  2,
+			ExpectedTotalPrice: money.MustParse("2001501"),
+		},
+		{
+			TestName: "Test Add Existing Order Item Success",
+			Filter: map[string]string{
+				"order_number": oCreate.OrderNumber,
+			},
+			Item: model.OrderItem{
+				ProductID: 1,
+				Qty:       1,
+			},
+			User: middleware.User{
+				ID:   1,
+				Role: "buyer",
+			},
+			ExpectedStatus:     http.StatusOK,
+			ExpectedTotalItem:  2,
+			ExpectedTotalPrice: money.MustParse("3001501.5"),
+		},
+		{
+			TestName: "Test Add Order Item Bad Request",
+			Filter: map[string]string{
+				"order_number": oCreate.OrderNumber,
+			},
+			Item: model.OrderItem{
+				ProductID:   0,
+				ProductName: "Product 3",
+				Qty:         1,
+			},
+			User: middleware.User{
+				ID:   1,
+				Role: "buyer",
+			},
+			ExpectedStatus: http.StatusBadRequest,
+		},
+		{
+			TestName: "Test Add Order Item Product Mismatch",
+			Filter: map[string]string{
+				"order_number": oCreate.OrderNumber,
+			},
+			Item: model.OrderItem{
+				ProductID:   2,
+				ProductName: "Product 3",
+				Qty:         1,
+			},
+			User: middleware.User{
+				ID:   1,
+				Role: "buyer",
+			},
+			ExpectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			TestName: "Test Add Order Item Product Not Found",
+			Filter: map[string]string{
+				"order_number": oCreate.OrderNumber,
+			},
+			Item: model.OrderItem{
+				ProductID: 99,
+				Qty:       1,
+			},
+			User: middleware.User{
+				ID:   1,
+				Role: "buyer",
+			},
+			ExpectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			TestName: "Test Add Order Item Forbidden Other Buyer",
+			Filter: map[string]string{
+				"order_number": oCreate.OrderNumber,
+			},
+			Item: model.OrderItem{
+				ProductID:     2,
+				ProductName:   "Product 2",
+				ProductPrice:  money.MustParse("500"),
+				ProductWeight: 1,
+				Qty:           3,
+			},
+			User: middleware.User{
+				ID:   2,
+				Role: "buyer",
+			},
+			ExpectedStatus: http.StatusForbidden,
+		},
+		{
+			TestName: "Test Add Order Item Order Not Found",
+			Filter: map[string]string{
+				"order_number": "this order number not exist",
+			},
+			Item: model.OrderItem{
+				ProductID:     2,
+				ProductName:   "Product 2",
+				ProductPrice:  money.MustParse("500"),
+				ProductWeight: 1,
+				Qty:           3,
+			},
+			User: middleware.User{
+				ID:   1,
+				Role: "buyer",
+			},
+			ExpectedStatus: http.StatusNotFound,
+		},
+	}
+
+	// loop test in test table
+	for _, test := range testTable {
+		// transform item to json body
+		body, err := json.Marshal(test.Item)
+		if err != nil {
+			t.Errorf("[%s] There's an error when marshal item to json => %s",
+				test.TestName, err.Error())
+		}
+
+		// get url params
+		params := url.Values{}
+		for key, value := range test.Filter {
+			params.Add(key, value)
+		}
+
+		// create and run request
+		req := httptest.NewRequest("POST", "/", bytes.NewReader(body))
+		req.URL.RawQuery = params.Encode()
+		req.Header.Set("Content-Type", echo.MIMEApplicationJSON)
+
+		response := httptest.NewRecorder()
+		echoCtx := a.Echo.NewContext(req, response)
+		echoCtx.Set("user", test.User)
+		err = callHandler(a.AddOrderItemHandler, echoCtx)
+		if err != nil {
+			t.Errorf("[%s] Expected API call success, but got error => %s",
+				test.TestName, err)
+		}
+
+		// check response
+		if response.Code != test.ExpectedStatus {
+			t.Errorf("[%s] Expected status %d got %d",
+				test.TestName, test.ExpectedStatus, response.Code)
+		} else if response.Code == http.StatusOK {
+			result, err := a.Orders.Get(a.Ctx,
+				repository.OrderFilter{OrderNumber: oCreate.OrderNumber})
+			if err != nil {
+				t.Errorf("[%s] There's an error when getting order => %s",
+					test.TestName, err)
+			}
+			if len(result.Items) != test.ExpectedTotalItem {
+				t.Errorf("[%s] Expected total item %d, but got %d",
+					test.TestName, test.ExpectedTotalItem, len(result.Items))
+			}
+			if result.TotalPrice != test.ExpectedTotalPrice {
+				t.Errorf("[%s] Expected TotalPrice %s, but got %s",
+					test.TestName, test.ExpectedTotalPrice, result.TotalPrice)
+			}
+		}
+	}
+
+}
+
+// TestUpdateOrderItemHandler test UpdateOrderItemHandler
 //
-// Required for test: model.InsertOrder
-func TestUpdateOrderHandler(t *testing.T) {
+// Required for test: model.InsertOrder, model.GetOrder
+func TestUpdateOrderItemHandler(t *testing.T) {
 	// insert testing data
 	a, err := GetTestingAPI(middleware.User{
 		ID:   1,
@@ -552,56 +1896,60 @@ func TestUpdateOrderHandler(t *testing.T) {
 			err.Error())
 	}
 	oCreate := model.Order{
-		Status:             "in-cart",
-		Qty:                2,
-		BuyerID:            1,
-		BuyerFullName:      "George Marcus",
-		BuyerAddress:       "Buyer Street",
-		TotalPrice:         2000000.50,
-		ProductName:        "Product 1",
-		ProductPrice:       1000000.50,
-		ProductWeight:      1.5,
-		ProductDescription: "Product description",
-		ProductStock:       100,
-	}
-
-	oCreate, err = model.InsertOrder(a.Ctx, a.Collections["orders"], oCreate)
+		Status:        "in-cart",
+		BuyerID:       1,
+		BuyerFullName: "George Marcus",
+		BuyerAddress:  "Buyer Street",
+		Items: []model.OrderItem{
+			{
+				ProductID:     1,
+				ProductName:   "Product 1",
+				ProductPrice:  money.MustParse("1000"),
+				ProductWeight: 1.5,
+				Qty:           2,
+			},
+		},
+	}
+
+	oCreate, err = a.Orders.Insert(a.Ctx, oCreate)
 	if err != nil {
 		t.Fatalf("There's an error when creating testing data for testing "+
-			"update data => %s", err.Error())
+			"update order item => %s", err.Error())
 	}
 
 	// initialize testing table
 	testTable := []struct {
-		TestName       string
-		Filter         map[string]string
-		FormData       map[string]string
-		User           middleware.User
-		ExpectedStatus int
+		TestName           string
+		Filter             map[string]string
+		FormData           map[string]string
+		User               middleware.User
+		ExpectedStatus     int
+		ExpectedTotalPrice money.Amount
 	}{
 		{
-			TestName: "Test Update Order Success",
+			TestName: "Test Update Order Item Success",
 			Filter: map[string]string{
 				"order_number": oCreate.OrderNumber,
+				"product_id":   "1",
 			},
 			FormData: map[string]string{
-				"status":      "waiting-for-payment",
-				"qty":         "3",
-				"total_price": "3000001.00",
+				"qty": "5",
 			},
 			User: middleware.User{
 				ID:   1,
 				Role: "buyer",
 			},
-			ExpectedStatus: http.StatusOK,
+			ExpectedStatus:     http.StatusOK,
+			ExpectedTotalPrice: money.MustParse("5000"),
 		},
 		{
-			TestName: "Test Update Order Bad Request",
-			Filter:   map[string]string{},
+			TestName: "Test Update Order Item Bad Request",
+			Filter: map[string]string{
+				"order_number": oCreate.OrderNumber,
+				"product_id":   "1",
+			},
 			FormData: map[string]string{
-				"status":      "",
-				"qty":         "3",
-				"total_price": "3000001.00",
+				"qty": "0",
 			},
 			User: middleware.User{
 				ID:   1,
@@ -609,17 +1957,25 @@ func TestUpdateOrderHandler(t *testing.T) {
 			},
 			ExpectedStatus: http.StatusBadRequest,
 		},
+		{
+			TestName: "Test Update Order Item Not Found",
+			Filter: map[string]string{
+				"order_number": oCreate.OrderNumber,
+				"product_id":   "2",
+			},
+			FormData: map[string]string{
+				"qty": "1",
+			},
+			User: middleware.User{
+				ID:   1,
+				Role: "buyer",
+			},
+			ExpectedStatus: http.StatusNotFound,
+		},
 	}
 
 	// loop test in test table
 	for _, test := range testTable {
-		// initialize testing API
-		a, err := GetTestingAPI(test.User)
-		if err != nil {
-			t.Errorf("[%s] There's an error when getting testing API => %s",
-				test.TestName, err.Error())
-		}
-
 		// transform form data to bytes buffer
 		var bFormData bytes.Buffer
 		w := multipart.NewWriter(&bFormData)
@@ -650,16 +2006,11 @@ func TestUpdateOrderHandler(t *testing.T) {
 		req := httptest.NewRequest("PUT", "/", &bFormData)
 		req.URL.RawQuery = params.Encode()
 		req.Header.Set("Content-Type", w.FormDataContentType())
-		if err != nil {
-			t.Errorf("[%s] There's an error when creating "+
-				"request API add orders => %s",
-				test.TestName, err)
-		}
 
 		response := httptest.NewRecorder()
 		echoCtx := a.Echo.NewContext(req, response)
 		echoCtx.Set("user", test.User)
-		err = a.UpdateOrderHandler(echoCtx)
+		err = callHandler(a.UpdateOrderItemHandler, echoCtx)
 		if err != nil {
 			t.Errorf("[%s] Expected API call success, but got error => %s",
 				test.TestName, err)
@@ -669,38 +2020,26 @@ func TestUpdateOrderHandler(t *testing.T) {
 		if response.Code != test.ExpectedStatus {
 			t.Errorf("[%s] Expected status %d got %d",
 				test.TestName, test.ExpectedStatus, response.Code)
-
-			var resp map[string]string
-			err = json.NewDecoder(response.Body).Decode(&resp)
+		} else if response.Code == http.StatusOK {
+			result, err := a.Orders.Get(a.Ctx,
+				repository.OrderFilter{OrderNumber: oCreate.OrderNumber})
 			if err != nil {
-				t.Errorf("[%s] There's an error when unmarshal body response => %s",
-					test.TestName, err.Error())
+				t.Errorf("[%s] There's an error when getting order => %s",
+					test.TestName, err)
+			}
+			if result.TotalPrice != test.ExpectedTotalPrice {
+				t.Errorf("[%s] Expected TotalPrice %s, but got %s",
+					test.TestName, test.ExpectedTotalPrice, result.TotalPrice)
 			}
-
-			t.Error(resp)
 		}
 	}
 
-	// remove all data in all collection after test
-	a, err = GetTestingAPI(middleware.User{})
-	if err != nil {
-		t.Errorf("There's an error when getting testing API => %s",
-			err.Error())
-	}
-
-	for _, collection := range a.Collections {
-		_, err = collection.DeleteMany(a.Ctx, bson.D{})
-		if err != nil {
-			log.Fatalf("There's an error when truncating "+
-				"mongodb collections after run all test => %s", err)
-		}
-	}
 }
 
-// TestDeleteOrderHandler test DeleteOrderHandler
+// TestDeleteOrderItemHandler test DeleteOrderItemHandler
 //
-// Required for test: model.InsertOrder
-func TestDeleteOrderHandler(t *testing.T) {
+// Required for test: model.InsertOrder, model.GetOrder
+func TestDeleteOrderItemHandler(t *testing.T) {
 	// insert testing data
 	a, err := GetTestingAPI(middleware.User{
 		ID:   1,
@@ -711,46 +2050,72 @@ func TestDeleteOrderHandler(t *testing.T) {
 			err.Error())
 	}
 	oCreate := model.Order{
-		Status:             "in-cart",
-		Qty:                2,
-		BuyerID:            1,
-		BuyerFullName:      "George Marcus",
-		BuyerAddress:       "Buyer Street",
-		TotalPrice:         2000000.50,
-		ProductName:        "Product 1",
-		ProductPrice:       1000000.50,
-		ProductWeight:      1.5,
-		ProductDescription: "Product description",
-		ProductStock:       100,
-	}
-
-	oCreate, err = model.InsertOrder(a.Ctx, a.Collections["orders"], oCreate)
+		Status:        "in-cart",
+		BuyerID:       1,
+		BuyerFullName: "George Marcus",
+		BuyerAddress:  "Buyer Street",
+		Items: []model.OrderItem{
+			{
+				ProductID:     1,
+				ProductName:   "Product 1",
+				ProductPrice:  money.MustParse("1000"),
+				ProductWeight: 1.5,
+				Qty:           2,
+			},
+			{
+				ProductID:     2,
+				ProductName:   "Product 2",
+				ProductPrice:  money.MustParse("500"),
+				ProductWeight: 1,
+				Qty:           1,
+			},
+		},
+	}
+
+	oCreate, err = a.Orders.Insert(a.Ctx, oCreate)
 	if err != nil {
 		t.Fatalf("There's an error when creating testing data for testing "+
-			"update data => %s", err.Error())
+			"delete order item => %s", err.Error())
 	}
 
 	// initialize testing table
 	testTable := []struct {
-		TestName       string
-		Filter         map[string]string
-		User           middleware.User
-		ExpectedStatus int
+		TestName           string
+		Filter             map[string]string
+		User               middleware.User
+		ExpectedStatus     int
+		ExpectedTotalPrice money.Amount
 	}{
 		{
-			TestName: "Test Delete Order Success",
+			TestName: "Test Delete Order Item Success",
 			Filter: map[string]string{
 				"order_number": oCreate.OrderNumber,
+				"product_id":   "1",
 			},
 			User: middleware.User{
 				ID:   1,
 				Role: "buyer",
 			},
-			ExpectedStatus: http.StatusOK,
+			ExpectedStatus:     http.StatusOK,
+			ExpectedTotalPrice: money.MustParse("500"),
 		},
 		{
-			TestName: "Test Delete Order Bad Request",
-			Filter:   map[string]string{},
+			TestName: "Test Delete Order Item Not Found",
+			Filter: map[string]string{
+				"order_number": oCreate.OrderNumber,
+				"product_id":   "1",
+			},
+			User: middleware.User{
+				ID:   1,
+				Role: "buyer",
+			},
+			ExpectedStatus: http.StatusNotFound,
+		},
+		{
+			TestName: "Test Delete Order Item Bad Request",
+			Filter: map[string]string{
+				"order_number": oCreate.OrderNumber,
+			},
 			User: middleware.User{
 				ID:   1,
 				Role: "buyer",
@@ -761,13 +2126,6 @@ func TestDeleteOrderHandler(t *testing.T) {
 
 	// loop test in test table
 	for _, test := range testTable {
-		// initialize testing API
-		a, err := GetTestingAPI(test.User)
-		if err != nil {
-			t.Errorf("[%s] There's an error when getting testing API => %s",
-				test.TestName, err.Error())
-		}
-
 		// get url params
 		params := url.Values{}
 		for key, value := range test.Filter {
@@ -777,16 +2135,158 @@ func TestDeleteOrderHandler(t *testing.T) {
 		// create and run request
 		req := httptest.NewRequest("DELETE", "/", nil)
 		req.URL.RawQuery = params.Encode()
+
+		response := httptest.NewRecorder()
+		echoCtx := a.Echo.NewContext(req, response)
+		echoCtx.Set("user", test.User)
+		err = callHandler(a.DeleteOrderItemHandler, echoCtx)
 		if err != nil {
-			t.Errorf("[%s] There's an error when creating "+
-				"request API add orders => %s",
+			t.Errorf("[%s] Expected API call success, but got error => %s",
 				test.TestName, err)
 		}
 
+		// check response
+		if response.Code != test.ExpectedStatus {
+			t.Errorf("[%s] Expected status %d got %d",
+				test.TestName, test.ExpectedStatus, response.Code)
+		} else if response.Code == http.StatusOK {
+			result, err := a.Orders.Get(a.Ctx,
+				repository.OrderFilter{OrderNumber: oCreate.OrderNumber})
+			if err != nil {
+				t.Errorf("[%s] There's an error when getting order => %s",
+					test.TestName, err)
+			}
+			if result.TotalPrice != test.ExpectedTotalPrice {
+				t.Errorf("[%s] Expected TotalPrice %s, but got %s",
+					test.TestName, test.ExpectedTotalPrice, result.TotalPrice)
+			}
+		}
+	}
+
+}
+
+// TestGetOrderHistoryHandler test GetOrderHistoryHandler
+func TestGetOrderHistoryHandler(t *testing.T) {
+	buyer := middleware.User{ID: 1, Role: "buyer"}
+	a, err := GetTestingAPI(buyer)
+	if err != nil {
+		t.Fatalf("There's an error when getting testing API => %s", err)
+	}
+
+	// create order then change and delete it
+	body, err := json.Marshal(model.Order{
+		Status: "in-cart",
+		Items:  []model.OrderItem{{ProductID: 1, Qty: 2}},
+	})
+	if err != nil {
+		t.Fatalf("There's an error when marshal order to json => %s", err)
+	}
+	req := httptest.NewRequest("POST", "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", echo.MIMEApplicationJSON)
+	response := httptest.NewRecorder()
+	echoCtx := a.Echo.NewContext(req, response)
+	echoCtx.Set("user", buyer)
+	err = callHandler(a.AddOrderHandler, echoCtx)
+	if err != nil || response.Code != http.StatusCreated {
+		t.Fatalf("Expected add order success, but got status %d => %v",
+			response.Code, err)
+	}
+
+	var o model.Order
+	err = json.NewDecoder(response.Body).Decode(&o)
+	if err != nil {
+		t.Fatalf("There's an error when unmarshal body response => %s", err)
+	}
+
+	response = updateOrderStatus(a, buyer, o.OrderNumber, "checked-out")
+	if response.Code != http.StatusOK {
+		t.Fatalf("Expected update order success, but got status %d",
+			response.Code)
+	}
+
+	deletedO, err := a.Orders.Insert(a.Ctx, model.Order{
+		Status:  "in-cart",
+		BuyerID: 1,
+		Items:   []model.OrderItem{{ProductID: 1, Qty: 1}},
+	})
+	if err != nil {
+		t.Fatalf("There's an error when creating testing data => %s", err)
+	}
+	req = httptest.NewRequest("DELETE", "/?order_number="+deletedO.OrderNumber, nil)
+	response = httptest.NewRecorder()
+	echoCtx = a.Echo.NewContext(req, response)
+	echoCtx.Set("user", buyer)
+	err = callHandler(a.DeleteOrderHandler, echoCtx)
+	if err != nil || response.Code != http.StatusOK {
+		t.Fatalf("Expected delete order success, but got status %d => %v",
+			response.Code, err)
+	}
+
+	// initialize testing table
+	testTable := []struct {
+		TestName        string
+		OrderNumber     string
+		User            middleware.User
+		ExpectedStatus  int
+		ExpectedActions []string
+	}{
+		{
+			TestName:        "Test Get Order History Buyer",
+			OrderNumber:     o.OrderNumber,
+			User:            buyer,
+			ExpectedStatus:  http.StatusOK,
+			ExpectedActions: []string{"create", "status-change"},
+		},
+		{
+			TestName:        "Test Get Order History Seller",
+			OrderNumber:     o.OrderNumber,
+			User:            middleware.User{ID: 10, Role: "seller"},
+			ExpectedStatus:  http.StatusOK,
+			ExpectedActions: []string{"create", "status-change"},
+		},
+		{
+			TestName:       "Test Get Order History Other Buyer",
+			OrderNumber:    o.OrderNumber,
+			User:           middleware.User{ID: 2, Role: "buyer"},
+			ExpectedStatus: http.StatusForbidden,
+		},
+		{
+			TestName:        "Test Get Deleted Order History Admin",
+			OrderNumber:     deletedO.OrderNumber,
+			User:            middleware.User{ID: 100, Role: "admin"},
+			ExpectedStatus:  http.StatusOK,
+			ExpectedActions: []string{"delete"},
+		},
+		{
+			TestName:       "Test Get Deleted Order History Buyer",
+			OrderNumber:    deletedO.OrderNumber,
+			User:           buyer,
+			ExpectedStatus: http.StatusNotFound,
+		},
+		{
+			TestName:       "Test Get Order History Not Found",
+			OrderNumber:    "this order number not exist",
+			User:           middleware.User{ID: 100, Role: "admin"},
+			ExpectedStatus: http.StatusNotFound,
+		},
+		{
+			TestName:       "Test Get Order History Bad Request",
+			OrderNumber:    "",
+			User:           buyer,
+			ExpectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	// loop test in test table
+	for _, test := range testTable {
+		params := url.Values{}
+		params.Add("order_number", test.OrderNumber)
+		req := httptest.NewRequest("GET", "/?"+params.Encode(), nil)
+
 		response := httptest.NewRecorder()
 		echoCtx := a.Echo.NewContext(req, response)
 		echoCtx.Set("user", test.User)
-		err = a.DeleteOrderHandler(echoCtx)
+		err = callHandler(a.GetOrderHistoryHandler, echoCtx)
 		if err != nil {
 			t.Errorf("[%s] Expected API call success, but got error => %s",
 				test.TestName, err)
@@ -796,44 +2296,272 @@ func TestDeleteOrderHandler(t *testing.T) {
 		if response.Code != test.ExpectedStatus {
 			t.Errorf("[%s] Expected status %d got %d",
 				test.TestName, test.ExpectedStatus, response.Code)
+			continue
+		}
+		if response.Code != http.StatusOK {
+			continue
+		}
 
-			var resp map[string]string
-			err = json.NewDecoder(response.Body).Decode(&resp)
-			if err != nil {
-				t.Errorf("[%s] There's an error when unmarshal body response => %s",
-					test.TestName, err.Error())
-			}
+		var history struct {
+			Items []audit.Entry `json:"items"`
+		}
+		err = json.NewDecoder(response.Body).Decode(&history)
+		if err != nil {
+			t.Errorf("[%s] There's an error when unmarshal body response => %s",
+				test.TestName, err)
+		}
 
-			t.Error(resp)
+		actions := []string{}
+		for _, e := range history.Items {
+			actions = append(actions, e.Action)
+			if e.Actor.ID != buyer.ID || e.Actor.Role != buyer.Role {
+				t.Errorf("[%s] Expected actor buyer 1, but got %+v",
+					test.TestName, e.Actor)
+			}
+		}
+		if strings.Join(actions, ",") != strings.Join(test.ExpectedActions, ",") {
+			t.Errorf("[%s] Expected actions %v, but got %v",
+				test.TestName, test.ExpectedActions, actions)
 		}
 	}
+}
 
-	// remove all data in all collection after test
-	a, err = GetTestingAPI(middleware.User{})
+// TestServiceUpdateOrderHandler test internal service update order status
+// by its scopes, and the service recorded as audit actor
+func TestServiceUpdateOrderHandler(t *testing.T) {
+	a, err := GetTestingAPI(middleware.User{ID: 1, Role: "buyer"})
 	if err != nil {
-		t.Errorf("There's an error when getting testing API => %s",
-			err.Error())
+		t.Fatalf("There's an error when getting testing API => %s", err)
 	}
 
-	for _, collection := range a.Collections {
-		_, err = collection.DeleteMany(a.Ctx, bson.D{})
-		if err != nil {
-			log.Fatalf("There's an error when truncating "+
-				"mongodb collections after run all test => %s", err)
+	o, err := a.Orders.Insert(a.Ctx, model.Order{
+		Status:  "paid",
+		BuyerID: 1,
+		Items:   []model.OrderItem{{ProductID: 1, Qty: 1, ProductUserID: 10}},
+	})
+	if err != nil {
+		t.Fatalf("There's an error when creating testing data => %s", err)
+	}
+
+	reportingService := middleware.User{
+		FullName: "account-service",
+		Role:     middleware.RoleService,
+		Service:  "account-service",
+		Scopes:   []string{policy.ScopeOrdersRead},
+	}
+	shippingService := middleware.User{
+		FullName: "product-service",
+		Role:     middleware.RoleService,
+		Service:  "product-service",
+		Scopes:   []string{policy.ScopeOrdersRead, policy.ScopeOrdersStatus},
+	}
+
+	// service without status scope can't update order
+	response := updateOrderStatus(a, reportingService, o.OrderNumber, "shipped")
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Expected service without status scope get status %d, "+
+			"but got %d", http.StatusForbidden, response.Code)
+	}
+
+	// service with status scope can update order status
+	response = updateOrderStatus(a, shippingService, o.OrderNumber, "shipped")
+	if response.Code != http.StatusOK {
+		t.Fatalf("Expected service with status scope get status %d, "+
+			"but got %d", http.StatusOK, response.Code)
+	}
+
+	entries, err := a.Audit.List(a.Ctx, o.OrderNumber)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("Expected 1 audit entry, but got %d => %v", len(entries), err)
+	}
+	actor := entries[0].Actor
+	if actor.Role != middleware.RoleService ||
+		actor.Service != "product-service" || actor.ID != 0 {
+		t.Errorf("Expected audit actor service product-service, but got %+v",
+			actor)
+	}
+}
+
+// TestServiceUpdateOrderHandlerStock test internal service checking out
+// and cancelling order reserve and release items stock using token
+// of this service, because service has no bearer token
+func TestServiceUpdateOrderHandlerStock(t *testing.T) {
+	a, err := GetTestingAPI(middleware.User{ID: 1, Role: "buyer"})
+	if err != nil {
+		t.Fatalf("There's an error when getting testing API => %s", err)
+	}
+	products := &countingProductService{Service: a.Products}
+	a.Products = products
+
+	productServiceToken := config.ProductServiceToken
+	config.ProductServiceToken = "order-service-token"
+	defer func() { config.ProductServiceToken = productServiceToken }()
+
+	o, err := a.Orders.Insert(a.Ctx, model.Order{
+		Status:  "in-cart",
+		BuyerID: 1,
+		Items:   []model.OrderItem{{ProductID: 1, Qty: 1, ProductUserID: 10}},
+	})
+	if err != nil {
+		t.Fatalf("There's an error when creating testing data => %s", err)
+	}
+
+	paymentService := middleware.User{
+		FullName: "payment-service",
+		Role:     middleware.RoleService,
+		Service:  "payment-service",
+		Scopes:   []string{policy.ScopeOrdersRead, policy.ScopeOrdersStatus},
+	}
+	for _, status := range []string{"checked-out", "cancelled"} {
+		response := updateOrderStatus(a, paymentService, o.OrderNumber, status)
+		if response.Code != http.StatusOK {
+			t.Fatalf("Expected service update order to %s get status %d, "+
+				"but got %d => %s", status, http.StatusOK, response.Code,
+				response.Body.String())
 		}
 	}
+
+	expectedTokens := []string{"order-service-token", "order-service-token"}
+	if strings.Join(products.StockTokens, ",") !=
+		strings.Join(expectedTokens, ",") {
+		t.Errorf("Expected stock reserved and released with tokens %v, "+
+			"but got %v", expectedTokens, products.StockTokens)
+	}
 }
 
 // GetTestingAPI get API for testing
+//
+// the API use in-memory order repository, so no database needed
 func GetTestingAPI(u middleware.User) (API, error) {
 	a := API{}
+	a.Ctx = context.Background()
 	a.Echo = echo.New()
+	a.Echo.HTTPErrorHandler = problem.HTTPErrorHandler
+	orders := repository.NewMemoryOrderRepository()
+	a.Orders = orders
+	a.Outbox = orders.Outbox
+	a.Publisher = outbox.NewMemoryPublisher()
+	a.Audit = audit.NewMemoryRepository()
+	a.Webhooks = webhook.NewMemoryRepository()
+	a.Currency = "IDR"
+	a.Policy = policy.Default()
+	a.Products = product.NewMemoryService(
+		product.Product{
+			ID:          1,
+			SKU:         "sku1",
+			Name:        "Product 1",
+			Price:       money.MustParse("1000000.50"),
+			Weight:      1.5,
+			Description: "Product description",
+			Stock:       100,
+			UserID:      10,
+			ImagesPath:  []string{"product 1.1.jpg"},
+		},
+		product.Product{
+			ID:     2,
+			SKU:    "sku2",
+			Name:   "Product 2",
+			Price:  money.MustParse("500"),
+			Weight: 1,
+			Stock:  50,
+			UserID: 20,
+		},
+	)
 
-	// init mongodb database collections
-	err := a.InitCollections(config.DBNameForAPITest)
-	if err != nil {
-		return a, err
+	return a, nil
+}
+
+// callHandler call route handler like the router,
+// error returned by the handler responded by API HTTP error handler
+//
+// return error if the handler failed with internal error
+func callHandler(handler echo.HandlerFunc, c echo.Context) error {
+	err := handler(c)
+	if err == nil {
+		return nil
 	}
+	c.Error(err)
 
-	return a, nil
+	var p *problem.Problem
+	if !errors.As(err, &p) || p.Status >= http.StatusInternalServerError {
+		return err
+	}
+
+	return nil
+}
+
+// countingProductService product service counting its GetProduct calls
+// and recording token of its stock changes, stock release failed
+// with ReleaseErr if it's set
+type countingProductService struct {
+	product.Service
+	GetProductCalls int
+	StockTokens     []string
+	ReleaseErr      error
+}
+
+// GetProduct count the call and get product from the product service
+func (s *countingProductService) GetProduct(ctx context.Context,
+	token string, id int) (product.Product, error) {
+	s.GetProductCalls++
+	return s.Service.GetProduct(ctx, token, id)
+}
+
+// ReserveStock record the token and reserve stock in the product service
+func (s *countingProductService) ReserveStock(ctx context.Context,
+	token string, id int, qty int) error {
+	s.StockTokens = append(s.StockTokens, token)
+	return s.Service.ReserveStock(ctx, token, id, qty)
+}
+
+// ReleaseStock record the token and release stock in the product service
+func (s *countingProductService) ReleaseStock(ctx context.Context,
+	token string, id int, qty int) error {
+	s.StockTokens = append(s.StockTokens, token)
+	if s.ReleaseErr != nil {
+		return s.ReleaseErr
+	}
+	return s.Service.ReleaseStock(ctx, token, id, qty)
+}
+
+// isOrderEqual check if two order have the same value
+func isOrderEqual(expected model.Order, result model.Order) bool {
+	if expected.ID != result.ID ||
+		expected.OrderNumber != result.OrderNumber ||
+		expected.Status != result.Status ||
+		expected.Qty != result.Qty ||
+		expected.TotalPrice != result.TotalPrice ||
+		expected.Currency != result.Currency ||
+		expected.BuyerID != result.BuyerID ||
+		expected.BuyerFullName != result.BuyerFullName ||
+		expected.BuyerAddress != result.BuyerAddress ||
+		len(expected.Items) != len(result.Items) {
+		return false
+	}
+
+	for i := range expected.Items {
+		if expected.Items[i].ProductID != result.Items[i].ProductID ||
+			expected.Items[i].ProductSKU != result.Items[i].ProductSKU ||
+			expected.Items[i].ProductName != result.Items[i].ProductName ||
+			expected.Items[i].ProductPrice != result.Items[i].ProductPrice ||
+			expected.Items[i].ProductWeight != result.Items[i].ProductWeight ||
+			expected.Items[i].ProductDescription != result.Items[i].ProductDescription ||
+			expected.Items[i].ProductStock != result.Items[i].ProductStock ||
+			expected.Items[i].ProductUserID != result.Items[i].ProductUserID ||
+			expected.Items[i].Qty != result.Items[i].Qty ||
+			expected.Items[i].Subtotal != result.Items[i].Subtotal ||
+			len(expected.Items[i].ProductImagesPath) !=
+				len(result.Items[i].ProductImagesPath) {
+			return false
+		}
+
+		for j := range expected.Items[i].ProductImagesPath {
+			if expected.Items[i].ProductImagesPath[j] !=
+				result.Items[i].ProductImagesPath[j] {
+				return false
+			}
+		}
+	}
+
+	return true
 }
@@ -0,0 +1,80 @@
+/*
+Package api containing API initialization and API route handler
+*/
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/reyhanfikridz/ecom-order-service/internal/problem"
+	"github.com/reyhanfikridz/ecom-order-service/internal/validator"
+)
+
+// error codes of order service problem, in addition to problem package codes
+const (
+	codeOrderNotFound                = "order_not_found"
+	codeOrderVersionConflict         = "order_version_conflict"
+	codeOrderStatusTransitionInvalid = "order_status_transition_invalid"
+	codeOrderItemsLocked             = "order_items_locked"
+	codeOrderItemNotFound            = "order_item_not_found"
+	codeOrderNotDeleted              = "order_not_deleted"
+	codeOrderStockReserved           = "order_stock_reserved"
+	codeOrderTotalInvalid            = "order_total_invalid"
+	codeOrderPatchTestFailed         = "order_patch_test_failed"
+	codeProductInvalid               = "product_invalid"
+	codeStockUnavailable             = "stock_unavailable"
+	codeWebhookNotFound              = "webhook_not_found"
+	codeWebhookDeliveryNotFound      = "webhook_delivery_not_found"
+	codeWebhookDeliveryNotDead       = "webhook_delivery_not_dead"
+)
+
+// errOrderNotFound problem of order not found
+var errOrderNotFound = problem.New(http.StatusNotFound, codeOrderNotFound,
+	"Order not found")
+
+// invalidDataError error for request data not completed/invalid,
+// with every invalid field if err is validator field error(s)
+func invalidDataError(data string, err error) error {
+	p := problem.Newf(http.StatusBadRequest, problem.CodeValidationFailed,
+		"%s not completed/invalid => %s", data, err)
+
+	var fieldErrs validator.Errors
+	var fieldErr validator.FieldError
+	if errors.As(err, &fieldErrs) {
+		for _, fieldErr := range fieldErrs {
+			p.WithErrors(problemFieldError(fieldErr))
+		}
+	} else if errors.As(err, &fieldErr) {
+		p.WithErrors(problemFieldError(fieldErr))
+	}
+
+	return p
+}
+
+// problemFieldError convert validator field error into problem field error
+func problemFieldError(fieldErr validator.FieldError) problem.FieldError {
+	return problem.FieldError{
+		Field:   fieldErr.Field,
+		Code:    fieldErr.Code,
+		Message: fieldErr.Message,
+	}
+}
+
+// paramRequiredError error for required param empty/not found
+func paramRequiredError(name string) error {
+	return problem.Newf(http.StatusBadRequest, problem.CodeValidationFailed,
+		"%s empty/not found", name).
+		WithErrors(problem.FieldError{
+			Field:   name,
+			Code:    validator.CodeRequired,
+			Message: "empty/not found",
+		})
+}
+
+// bindError error for request body or param can't be bound
+func bindError(data string, err error) error {
+	return problem.New(http.StatusBadRequest, problem.CodeBadRequest,
+		fmt.Sprintf("%s not completed/invalid => %s", data, err))
+}
@@ -0,0 +1,172 @@
+/*
+Package api containing API initialization and API route handler
+*/
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	echo "github.com/labstack/echo/v4"
+	"github.com/reyhanfikridz/ecom-order-service/internal/middleware"
+	"github.com/reyhanfikridz/ecom-order-service/internal/model"
+	"github.com/reyhanfikridz/ecom-order-service/internal/problem"
+)
+
+// TestErrorResponse test route handler error responded as problem
+func TestErrorResponse(t *testing.T) {
+	buyer := middleware.User{ID: 1, Role: "buyer"}
+	a, err := GetTestingAPI(buyer)
+	if err != nil {
+		t.Fatalf("There's an error when getting testing API => %s", err)
+	}
+
+	o, err := a.Orders.Insert(a.Ctx, model.Order{Status: "paid", BuyerID: 1})
+	if err != nil {
+		t.Fatalf("There's an error when creating testing data => %s", err)
+	}
+
+	// initialize testing table
+	testTable := []struct {
+		TestName       string
+		Method         string
+		OrderNumber    string
+		Body           string
+		Handler        func(a *API) echo.HandlerFunc
+		ExpectedStatus int
+		ExpectedCode   string
+		ExpectedFields []problem.FieldError
+	}{
+		{
+			TestName: "Test Error Response Validation Failed",
+			Method:   "POST",
+			Body:     `{"status": "in-cart", "items": [{"product_id": 1}]}`,
+			Handler: func(a *API) echo.HandlerFunc {
+				return a.AddOrderHandler
+			},
+			ExpectedStatus: http.StatusBadRequest,
+			ExpectedCode:   problem.CodeValidationFailed,
+			ExpectedFields: []problem.FieldError{
+				{Field: "items[0].qty", Code: "required",
+					Message: "empty/not found"},
+			},
+		},
+		{
+			TestName: "Test Error Response Multiple Fields Invalid",
+			Method:   "POST",
+			Body: `{"status": "in-cart", "buyer_full_name": "` +
+				strings.Repeat("a", 101) + `", ` +
+				`"items": [{"product_id": 1, "qty": -1}]}`,
+			Handler: func(a *API) echo.HandlerFunc {
+				return a.AddOrderHandler
+			},
+			ExpectedStatus: http.StatusBadRequest,
+			ExpectedCode:   problem.CodeValidationFailed,
+			ExpectedFields: []problem.FieldError{
+				{Field: "buyer_full_name", Code: "too_long",
+					Message: "must not be longer than 100 characters"},
+				{Field: "items[0].qty", Code: "out_of_range",
+					Message: "must be between 1 and 1000"},
+			},
+		},
+		{
+			TestName:    "Test Error Response Update Fields Invalid",
+			Method:      "PUT",
+			OrderNumber: o.OrderNumber,
+			Body: `{"status": "unknown", "buyer_full_name": "` +
+				strings.Repeat("a", 101) + `"}`,
+			Handler: func(a *API) echo.HandlerFunc {
+				return a.UpdateOrderHandler
+			},
+			ExpectedStatus: http.StatusBadRequest,
+			ExpectedCode:   problem.CodeValidationFailed,
+			ExpectedFields: []problem.FieldError{
+				{Field: "status", Code: "invalid",
+					Message: "'unknown' invalid"},
+				{Field: "buyer_full_name", Code: "too_long",
+					Message: "must not be longer than 100 characters"},
+			},
+		},
+		{
+			TestName:    "Test Error Response Order Not Found",
+			Method:      "GET",
+			OrderNumber: "unknown",
+			Handler: func(a *API) echo.HandlerFunc {
+				return a.GetOrderHandler
+			},
+			ExpectedStatus: http.StatusNotFound,
+			ExpectedCode:   codeOrderNotFound,
+		},
+		{
+			TestName:    "Test Error Response Status Transition",
+			Method:      "PUT",
+			OrderNumber: o.OrderNumber,
+			Body:        `{"status": "in-cart"}`,
+			Handler: func(a *API) echo.HandlerFunc {
+				return a.UpdateOrderHandler
+			},
+			ExpectedStatus: http.StatusConflict,
+			ExpectedCode:   codeOrderStatusTransitionInvalid,
+		},
+	}
+
+	// test for each testing table
+	for _, test := range testTable {
+		req := httptest.NewRequest(test.Method, "/",
+			strings.NewReader(test.Body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+		response := httptest.NewRecorder()
+		echoCtx := a.Echo.NewContext(req, response)
+		echoCtx.Response().Header().Set(echo.HeaderXRequestID, "request-1")
+		if test.OrderNumber != "" {
+			echoCtx.SetParamNames("order_number")
+			echoCtx.SetParamValues(test.OrderNumber)
+		}
+		echoCtx.Set("user", buyer)
+		err = callHandler(test.Handler(&a), echoCtx)
+		if err != nil {
+			t.Errorf("[%s] Expected API call success, but got error => %s",
+				test.TestName, err)
+		}
+
+		if response.Code != test.ExpectedStatus {
+			t.Errorf("[%s] Expected status %d got %d => %s", test.TestName,
+				test.ExpectedStatus, response.Code, response.Body.String())
+		}
+		if response.Header().Get(echo.HeaderContentType) !=
+			problem.MIMEProblemJSON {
+			t.Errorf("[%s] Expected Content-Type %s got %s", test.TestName,
+				problem.MIMEProblemJSON,
+				response.Header().Get(echo.HeaderContentType))
+		}
+
+		var p problem.Problem
+		err = json.Unmarshal(response.Body.Bytes(), &p)
+		if err != nil {
+			t.Errorf("[%s] There's an error when unmarshal body response => %s",
+				test.TestName, err)
+			continue
+		}
+		if p.Code != test.ExpectedCode || p.Status != test.ExpectedStatus ||
+			p.RequestID != "request-1" {
+			t.Errorf("[%s] Expected problem code %s status %d request ID "+
+				"request-1, but got %+v", test.TestName, test.ExpectedCode,
+				test.ExpectedStatus, p)
+		}
+		if len(p.Errors) != len(test.ExpectedFields) {
+			t.Errorf("[%s] Expected field errors %v, but got %v", test.TestName,
+				test.ExpectedFields, p.Errors)
+			continue
+		}
+		for i := range p.Errors {
+			if p.Errors[i] != test.ExpectedFields[i] {
+				t.Errorf("[%s] Expected field errors %v, but got %v",
+					test.TestName, test.ExpectedFields, p.Errors)
+			}
+		}
+	}
+}
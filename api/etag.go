@@ -0,0 +1,104 @@
+/*
+Package api containing API initialization and API route handler
+*/
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	echo "github.com/labstack/echo/v4"
+	"github.com/reyhanfikridz/ecom-order-service/internal/model"
+	"github.com/reyhanfikridz/ecom-order-service/internal/problem"
+	"github.com/reyhanfikridz/ecom-order-service/internal/repository"
+)
+
+// getOrderETag get entity tag of order, which is the order version
+func getOrderETag(o model.Order) string {
+	return fmt.Sprintf("\"%d\"", o.Version)
+}
+
+// getOrderPageETag get weak entity tag of page of orders
+// from order numbers and versions in the page
+func getOrderPageETag(page repository.OrderPage) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d;%s", page.Total, page.NextCursor)
+	for _, o := range page.Items {
+		fmt.Fprintf(h, ";%s:%d", o.OrderNumber, o.Version)
+	}
+
+	return fmt.Sprintf("W/\"%s\"", hex.EncodeToString(h.Sum(nil))[:32])
+}
+
+// isNotModified check if entity tag match one of If-None-Match header tags,
+// so client cached response is still valid
+func isNotModified(c echo.Context, etag string) bool {
+	ifNoneMatch := c.Request().Header.Get("If-None-Match")
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" ||
+			strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getExpectedVersion get order version expected by client from If-Match
+// header, or from version field in body if the header not set,
+// 0 means any version
+func getExpectedVersion(c echo.Context, bodyVersion int) (int, error) {
+	ifMatch := strings.TrimSpace(c.Request().Header.Get("If-Match"))
+	if ifMatch == "" {
+		if bodyVersion < 0 {
+			return 0, fmt.Errorf("version invalid")
+		}
+		return bodyVersion, nil
+	}
+	if ifMatch == "*" {
+		return 0, nil
+	}
+
+	version, err := strconv.Atoi(strings.Trim(
+		strings.TrimPrefix(ifMatch, "W/"), "\""))
+	if err != nil || version <= 0 {
+		return 0, fmt.Errorf("If-Match '%s' invalid", ifMatch)
+	}
+	if bodyVersion != 0 && bodyVersion != version {
+		return 0, fmt.Errorf("If-Match '%s' and version %d not the same",
+			ifMatch, bodyVersion)
+	}
+
+	return version, nil
+}
+
+// orderVersionConflict error for order changed
+// since the version expected by client
+func orderVersionConflict(current model.Order, expectedVersion int) error {
+	return problem.Newf(http.StatusConflict, codeOrderVersionConflict,
+		"Order has been changed since version %d, "+
+			"get the order again and retry", expectedVersion).
+		With("current_version", current.Version).
+		With("expected_version", expectedVersion)
+}
+
+// orderChangedConflict error for order changed by another request
+// after it's got for changing
+func (a *API) orderChangedConflict(got model.Order) error {
+	current, err := a.Orders.Get(a.Ctx,
+		repository.OrderFilter{OrderNumber: got.OrderNumber})
+	if err != nil {
+		current = got
+	}
+
+	return orderVersionConflict(current, got.Version)
+}
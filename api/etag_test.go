@@ -0,0 +1,216 @@
+/*
+Package api containing API initialization and API route handler
+*/
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	echo "github.com/labstack/echo/v4"
+	"github.com/reyhanfikridz/ecom-order-service/internal/middleware"
+	"github.com/reyhanfikridz/ecom-order-service/internal/model"
+	"github.com/reyhanfikridz/ecom-order-service/internal/money"
+	"github.com/reyhanfikridz/ecom-order-service/internal/repository"
+)
+
+// TestUpdateOrderHandlerVersion test UpdateOrderHandler
+// and changeOrderItems with expected order version
+func TestUpdateOrderHandlerVersion(t *testing.T) {
+	buyer := middleware.User{ID: 1, Role: "buyer"}
+	a, err := GetTestingAPI(buyer)
+	if err != nil {
+		t.Fatalf("There's an error when getting testing API => %s", err)
+	}
+
+	o, err := a.Orders.Insert(a.Ctx, model.Order{
+		Status:  "in-cart",
+		BuyerID: 1,
+		Items: []model.OrderItem{
+			{ProductID: 2, ProductName: "Product 2",
+				ProductPrice: money.MustParse("500"), Qty: 1},
+		},
+	})
+	if err != nil {
+		t.Fatalf("There's an error when creating testing data => %s", err)
+	}
+	if o.Version != 1 || o.CreatedAt.IsZero() || !o.UpdatedAt.Equal(o.CreatedAt) {
+		t.Fatalf("Expected new order version 1 with timestamps, but got "+
+			"version %d created at %v updated at %v", o.Version, o.CreatedAt,
+			o.UpdatedAt)
+	}
+
+	// initialize testing table, run in order
+	testTable := []struct {
+		TestName       string
+		IfMatch        string
+		Body           string
+		ExpectedStatus int
+		ExpectedETag   string
+	}{
+		{
+			TestName:       "Test Update Order If-Match Success",
+			IfMatch:        `"1"`,
+			Body:           `{"buyer_address": "Buyer Street 2"}`,
+			ExpectedStatus: http.StatusOK,
+			ExpectedETag:   `"2"`,
+		},
+		{
+			TestName:       "Test Update Order If-Match Stale",
+			IfMatch:        `"1"`,
+			Body:           `{"buyer_address": "Buyer Street 3"}`,
+			ExpectedStatus: http.StatusConflict,
+		},
+		{
+			TestName:       "Test Update Order Version Field Stale",
+			Body:           `{"buyer_address": "Buyer Street 3", "version": 1}`,
+			ExpectedStatus: http.StatusConflict,
+		},
+		{
+			TestName:       "Test Update Order Version Field Success",
+			Body:           `{"buyer_address": "Buyer Street 3", "version": 2}`,
+			ExpectedStatus: http.StatusOK,
+			ExpectedETag:   `"3"`,
+		},
+		{
+			TestName:       "Test Update Order If-Match Any",
+			IfMatch:        "*",
+			Body:           `{"buyer_address": "Buyer Street 4"}`,
+			ExpectedStatus: http.StatusOK,
+			ExpectedETag:   `"4"`,
+		},
+		{
+			TestName:       "Test Update Order If-Match Invalid",
+			IfMatch:        `"abc"`,
+			Body:           `{"buyer_address": "Buyer Street 5"}`,
+			ExpectedStatus: http.StatusBadRequest,
+		},
+		{
+			TestName:       "Test Update Order If-Match Not The Same As Version",
+			IfMatch:        `"4"`,
+			Body:           `{"buyer_address": "Buyer Street 5", "version": 3}`,
+			ExpectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	// test for each testing table
+	for _, test := range testTable {
+		req := httptest.NewRequest("PUT", "/?order_number="+o.OrderNumber,
+			strings.NewReader(test.Body))
+		req.Header.Set("Content-Type", echo.MIMEApplicationJSON)
+		if test.IfMatch != "" {
+			req.Header.Set("If-Match", test.IfMatch)
+		}
+
+		response := httptest.NewRecorder()
+		echoCtx := a.Echo.NewContext(req, response)
+		echoCtx.Set("user", buyer)
+		err = callHandler(a.UpdateOrderHandler, echoCtx)
+		if err != nil {
+			t.Errorf("[%s] Expected API call success, but got error => %s",
+				test.TestName, err)
+		}
+
+		if response.Code != test.ExpectedStatus {
+			t.Errorf("[%s] Expected status %d got %d => %s",
+				test.TestName, test.ExpectedStatus, response.Code,
+				response.Body.String())
+		}
+		if response.Header().Get("ETag") != test.ExpectedETag {
+			t.Errorf("[%s] Expected ETag %s got %s", test.TestName,
+				test.ExpectedETag, response.Header().Get("ETag"))
+		}
+	}
+
+	// check order updated with the last version
+	result, err := a.Orders.Get(a.Ctx,
+		repository.OrderFilter{OrderNumber: o.OrderNumber})
+	if err != nil {
+		t.Fatalf("There's an error when getting order => %s", err)
+	}
+	if result.Version != 4 || result.BuyerAddress != "Buyer Street 4" ||
+		!result.CreatedAt.Equal(o.CreatedAt) ||
+		result.UpdatedAt.Before(o.UpdatedAt) {
+		t.Errorf("Expected order version 4 with buyer address "+
+			"'Buyer Street 4', but got %+v", result)
+	}
+
+	// test change order items with stale version
+	req := httptest.NewRequest("DELETE",
+		"/?order_number="+o.OrderNumber+"&product_id=2", nil)
+	req.Header.Set("If-Match", `"3"`)
+
+	response := httptest.NewRecorder()
+	echoCtx := a.Echo.NewContext(req, response)
+	echoCtx.Set("user", buyer)
+	err = callHandler(a.DeleteOrderItemHandler, echoCtx)
+	if err != nil {
+		t.Errorf("Expected API call success, but got error => %s", err)
+	}
+	if response.Code != http.StatusConflict {
+		t.Errorf("Expected status %d got %d => %s", http.StatusConflict,
+			response.Code, response.Body.String())
+	}
+}
+
+// TestGetOrdersHandlerETag test GetOrdersHandler ETag and If-None-Match
+func TestGetOrdersHandlerETag(t *testing.T) {
+	buyer := middleware.User{ID: 1, Role: "buyer"}
+	a, err := GetTestingAPI(buyer)
+	if err != nil {
+		t.Fatalf("There's an error when getting testing API => %s", err)
+	}
+
+	o, err := a.Orders.Insert(a.Ctx, model.Order{Status: "in-cart", BuyerID: 1})
+	if err != nil {
+		t.Fatalf("There's an error when creating testing data => %s", err)
+	}
+
+	getOrders := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+
+		response := httptest.NewRecorder()
+		echoCtx := a.Echo.NewContext(req, response)
+		echoCtx.Set("user", buyer)
+		err := callHandler(a.GetOrdersHandler, echoCtx)
+		if err != nil {
+			t.Errorf("Expected API call success, but got error => %s", err)
+		}
+
+		return response
+	}
+
+	// get orders and its ETag
+	response := getOrders("")
+	etag := response.Header().Get("ETag")
+	if response.Code != http.StatusOK || etag == "" {
+		t.Fatalf("Expected status %d with ETag, but got %d with ETag '%s'",
+			http.StatusOK, response.Code, etag)
+	}
+
+	// test orders not changed
+	response = getOrders(etag)
+	if response.Code != http.StatusNotModified {
+		t.Errorf("Expected status %d got %d", http.StatusNotModified,
+			response.Code)
+	}
+
+	// test orders changed
+	err = a.Orders.Update(a.Ctx,
+		repository.OrderFilter{OrderNumber: o.OrderNumber},
+		model.Order{BuyerAddress: "Buyer Street"})
+	if err != nil {
+		t.Fatalf("There's an error when updating testing data => %s", err)
+	}
+
+	response = getOrders(etag)
+	if response.Code != http.StatusOK || response.Header().Get("ETag") == etag {
+		t.Errorf("Expected status %d with new ETag, but got %d with ETag '%s'",
+			http.StatusOK, response.Code, response.Header().Get("ETag"))
+	}
+}
@@ -0,0 +1,327 @@
+/*
+Package api containing API initialization and API route handler
+*/
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	echo "github.com/labstack/echo/v4"
+	"github.com/reyhanfikridz/ecom-order-service/internal/middleware"
+	"github.com/reyhanfikridz/ecom-order-service/internal/model"
+	"github.com/reyhanfikridz/ecom-order-service/internal/patch"
+	"github.com/reyhanfikridz/ecom-order-service/internal/problem"
+	"github.com/reyhanfikridz/ecom-order-service/internal/product"
+	"github.com/reyhanfikridz/ecom-order-service/internal/repository"
+	"github.com/reyhanfikridz/ecom-order-service/internal/validator"
+)
+
+// media types of order patch document
+const (
+	mimeMergePatch = "application/merge-patch+json"
+	mimeJSONPatch  = "application/json-patch+json"
+)
+
+// PatchOrderHandler route handler for patch order (Method: PATCH, User: all)
+//
+// order patched by JSON Merge Patch (application/merge-patch+json
+// or application/json) where null clear the field,
+// or by JSON Patch (application/json-patch+json)
+//
+// each role can only patch fields in its whitelist,
+// and order only patched if its version is the same as If-Match header
+// or version field of merge patch, if set
+func (a *API) PatchOrderHandler(c echo.Context) error {
+	// get user data
+	tmpU := c.Get("user")
+	u, ok := tmpU.(middleware.User)
+	if !ok {
+		return problem.Internal(errUserInvalid)
+	}
+
+	// set filter (for now only order number)
+	filter := repository.OrderFilter{}
+	if getParam(c, "order_number") == "" {
+		return paramRequiredError("order_number")
+	}
+	filter.OrderNumber = getParam(c, "order_number")
+
+	// get patch document
+	mediaType, _, err := mime.ParseMediaType(
+		c.Request().Header.Get(echo.HeaderContentType))
+	if err != nil || (mediaType != mimeMergePatch &&
+		mediaType != echo.MIMEApplicationJSON && mediaType != mimeJSONPatch) {
+		return problem.Newf(http.StatusUnsupportedMediaType,
+			problem.CodeUnsupportedMediaType, "Content-Type must be %s, %s, or %s",
+			mimeMergePatch, echo.MIMEApplicationJSON, mimeJSONPatch)
+	}
+
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return problem.Newf(http.StatusBadRequest, problem.CodeBadRequest,
+			"Order patch invalid => %s", err)
+	}
+
+	// get order version expected by client
+	bodyVersion := 0
+	if mediaType != mimeJSONPatch {
+		body, bodyVersion, err = getMergePatchVersion(body)
+		if err != nil {
+			return problem.Newf(http.StatusBadRequest, problem.CodeBadRequest,
+				"Order patch invalid => %s", err)
+		}
+	}
+
+	expectedVersion, err := getExpectedVersion(c, bodyVersion)
+	if err != nil {
+		return problem.Newf(http.StatusBadRequest, problem.CodeBadRequest,
+			"Order patch invalid => %s", err)
+	}
+
+	// get order that need to be patched
+	current, err := a.Orders.Get(a.Ctx, filter)
+	if err != nil {
+		if err == repository.ErrOrderNotFound {
+			return errOrderNotFound
+		}
+
+		return problem.Internal(fmt.Errorf(
+			"There's an error when getting order data => %w",
+			err))
+	}
+
+	// check user authority to access the order
+	err = a.Policy.CanAccessOrder(u, current)
+	if err != nil {
+		return problem.New(http.StatusForbidden, problem.CodeForbidden,
+			err.Error())
+	}
+
+	// check order not changed since the version expected by client,
+	// then only patch if the order still in the version checked below
+	if expectedVersion != 0 && expectedVersion != current.Version {
+		return orderVersionConflict(current, expectedVersion)
+	}
+	filter.Version = current.Version
+
+	// apply patch to the order
+	doc, err := json.Marshal(current)
+	if err != nil {
+		return problem.Internal(fmt.Errorf(
+			"There's an error when getting order data => %w",
+			err))
+	}
+
+	var patched []byte
+	if mediaType == mimeJSONPatch {
+		var ops []patch.Operation
+		ops, err = patch.DecodeJSONPatch(body)
+		if err == nil {
+			patched, err = patch.JSONPatch(doc, ops)
+		}
+	} else {
+		patched, err = patch.MergePatch(doc, body)
+	}
+	if err != nil {
+		return patchError(err)
+	}
+
+	// get patched fields, nothing saved if there's none
+	fields, err := patch.ChangedFields(doc, patched)
+	if err != nil {
+		return patchError(err)
+	}
+	if len(fields) == 0 {
+		c.Response().Header().Set("ETag", getOrderETag(current))
+		return c.JSON(http.StatusOK, current)
+	}
+
+	var o model.Order
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&o)
+	if err != nil {
+		return bindError("Order data", err)
+	}
+
+	// check user authority to patch the fields
+	err = a.Policy.CanPatchOrder(u, current, o, fields)
+	if err != nil {
+		return problem.New(http.StatusForbidden, problem.CodeForbidden,
+			err.Error())
+	}
+
+	// check and fill patched fields value
+	for _, field := range fields {
+		switch field {
+		case "status":
+			if o.Status == "" {
+				return invalidDataError("Order data", validator.FieldError{
+					Field:   "status",
+					Code:    validator.CodeRequired,
+					Message: "empty/not found",
+				})
+			}
+
+		case "buyer_id":
+			if o.BuyerID <= 0 {
+				return invalidDataError("Order data", validator.FieldError{
+					Field:   "buyer_id",
+					Code:    validator.CodeRequired,
+					Message: "empty/not found",
+				})
+			}
+
+		case "items":
+			o.Items, err = a.getChangedOrderItems(c, u, current, o.Items)
+			if err != nil {
+				return err
+			}
+			o.CalculateTotal()
+		}
+	}
+
+	// validate patched order
+	err = validator.IsOrderUpdateValid(o)
+	if err != nil {
+		return invalidDataError("Order data", err)
+	}
+
+	// check order status transition if status patched
+	for _, field := range fields {
+		if field != "status" {
+			continue
+		}
+		if !model.IsOrderStatusTransitionAllowed(current.Status, o.Status) {
+			return orderStatusTransitionConflict(current.Status, o.Status)
+		}
+
+		// only patch if status still the same as checked above
+		filter.Status = current.Status
+	}
+
+	// patch order in database
+	return a.saveOrderChange(c, u, current, o,
+		func(ctx context.Context, o model.Order, changed []string) error {
+			return a.Orders.Patch(ctx, filter, o, append(fields, changed...))
+		},
+		func(after model.Order) error {
+			return c.JSON(http.StatusOK, after)
+		})
+}
+
+// getChangedOrderItems get items that replace items of current order
+// as a whole (by PUT or PATCH), validated before filled with product data
+//
+// items can't be changed after items stock reserved
+func (a *API) getChangedOrderItems(c echo.Context, u middleware.User,
+	current model.Order, items []model.OrderItem) ([]model.OrderItem, error) {
+	if current.Status != model.OrderStatusInCart {
+		return nil, problem.Newf(http.StatusConflict, codeOrderItemsLocked,
+			"Order items can only be changed "+
+				"when order status is '%s'", model.OrderStatusInCart)
+	}
+
+	err := validator.IsOrderItemsRequestValid(items)
+	if err != nil {
+		return nil, invalidDataError("Order data", err)
+	}
+
+	items, err = a.getPatchedOrderItems(c, u, current, items)
+	if err != nil {
+		return nil, productError(err)
+	}
+
+	return items, nil
+}
+
+// getPatchedOrderItems get patched items of current order
+// after checked and filled with product data
+//
+// only admin can change product detail of item already in the order,
+// new item filled with product data from product service
+func (a *API) getPatchedOrderItems(c echo.Context, u middleware.User,
+	current model.Order, items []model.OrderItem) ([]model.OrderItem, error) {
+	currentItems := map[int]model.OrderItem{}
+	for _, item := range current.Items {
+		currentItems[item.ProductID] = item
+	}
+
+	result := make([]model.OrderItem, 0, len(items))
+	for i, item := range items {
+		currentItem, ok := currentItems[item.ProductID]
+		switch {
+		case a.Policy.CanEditOrderItemDetail(u, current) == nil:
+
+		case !ok:
+			// fill new item with product data from product service
+			var err error
+			item, err = a.snapshotOrderItem(c, item)
+			if err != nil {
+				return nil, fmt.Errorf("items[%d] => %w", i, err)
+			}
+
+		default:
+			// only qty of item already in the order can be changed,
+			// product detail supplied by client must be the same
+			// as the one already in the order, subtotal recalculated
+			var err error
+			item.Subtotal = 0
+			item, err = product.SnapshotOrderItem(item,
+				product.GetOrderItemProduct(currentItem))
+			if err != nil {
+				return nil, fmt.Errorf("items[%d] => %w", i, err)
+			}
+		}
+		result = append(result, item)
+	}
+
+	return result, nil
+}
+
+// getMergePatchVersion get version field of merge patch as order version
+// expected by client, return the merge patch without the version field
+func getMergePatchVersion(body []byte) ([]byte, int, error) {
+	fields := map[string]json.RawMessage{}
+	err := json.Unmarshal(body, &fields)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	versionValue, ok := fields["version"]
+	if !ok {
+		return body, 0, nil
+	}
+
+	version := 0
+	err = json.Unmarshal(versionValue, &version)
+	if err != nil || version <= 0 {
+		return nil, 0, fmt.Errorf("version invalid")
+	}
+	delete(fields, "version")
+
+	body, err = json.Marshal(fields)
+	return body, version, err
+}
+
+// patchError error when applying patch to order
+func patchError(err error) error {
+	switch {
+	case errors.Is(err, patch.ErrInvalidPatch):
+		return problem.Newf(http.StatusBadRequest, problem.CodeBadRequest,
+			"Order patch invalid => %s", err)
+	case errors.Is(err, patch.ErrTestFailed):
+		return problem.Newf(http.StatusConflict, codeOrderPatchTestFailed,
+			"Order patch test failed => %s", err)
+	}
+
+	return problem.Newf(http.StatusUnprocessableEntity,
+		problem.CodeUnprocessableEntity, "Order patch can't be applied => %s", err)
+}
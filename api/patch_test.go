@@ -0,0 +1,329 @@
+/*
+Package api containing API initialization and API route handler
+*/
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	echo "github.com/labstack/echo/v4"
+	"github.com/reyhanfikridz/ecom-order-service/internal/middleware"
+	"github.com/reyhanfikridz/ecom-order-service/internal/model"
+	"github.com/reyhanfikridz/ecom-order-service/internal/money"
+	"github.com/reyhanfikridz/ecom-order-service/internal/repository"
+)
+
+// TestPatchOrderHandler test PatchOrderHandler
+func TestPatchOrderHandler(t *testing.T) {
+	buyer := middleware.User{ID: 1, Role: "buyer"}
+	seller := middleware.User{ID: 20, Role: "seller"}
+	otherSeller := middleware.User{ID: 30, Role: "seller"}
+	admin := middleware.User{ID: 100, Role: "admin"}
+	a, err := GetTestingAPI(buyer)
+	if err != nil {
+		t.Fatalf("There's an error when getting testing API => %s", err)
+	}
+
+	inCart, err := a.Orders.Insert(a.Ctx, model.Order{
+		Status:        "in-cart",
+		BuyerID:       1,
+		BuyerFullName: "Buyer 1",
+		BuyerAddress:  "Buyer Street 1",
+		Items: []model.OrderItem{
+			{
+				ProductID:         1,
+				ProductSKU:        "sku1",
+				ProductName:       "Product 1",
+				ProductPrice:      money.MustParse("1000000.50"),
+				ProductWeight:     1.5,
+				ProductUserID:     10,
+				ProductImagesPath: []string{"product 1.1.jpg"},
+				Qty:               2,
+				Subtotal:          money.MustParse("2000001"),
+			},
+		},
+		Qty:        2,
+		TotalPrice: money.MustParse("2000001"),
+	})
+	if err != nil {
+		t.Fatalf("There's an error when creating testing data => %s", err)
+	}
+
+	paid, err := a.Orders.Insert(a.Ctx, model.Order{
+		Status:  "paid",
+		BuyerID: 1,
+		Items: []model.OrderItem{
+			{ProductID: 2, ProductName: "Product 2",
+				ProductPrice: money.MustParse("500"), ProductWeight: 1,
+				ProductUserID: 20, Qty: 1, Subtotal: money.MustParse("500")},
+		},
+		Qty:        1,
+		TotalPrice: money.MustParse("500"),
+	})
+	if err != nil {
+		t.Fatalf("There's an error when creating testing data => %s", err)
+	}
+
+	// initialize testing table, run in order
+	testTable := []struct {
+		TestName       string
+		User           middleware.User
+		OrderNumber    string
+		ContentType    string
+		IfMatch        string
+		Body           string
+		ExpectedStatus int
+		ExpectedETag   string
+	}{
+		{
+			TestName:       "Test Merge Patch Clear Buyer Address",
+			User:           buyer,
+			OrderNumber:    inCart.OrderNumber,
+			ContentType:    mimeMergePatch,
+			Body:           `{"buyer_address": null}`,
+			ExpectedStatus: http.StatusOK,
+			ExpectedETag:   `"2"`,
+		},
+		{
+			TestName:       "Test Merge Patch Nothing Changed",
+			User:           buyer,
+			OrderNumber:    inCart.OrderNumber,
+			ContentType:    echo.MIMEApplicationJSON,
+			Body:           `{"buyer_full_name": "Buyer 1"}`,
+			ExpectedStatus: http.StatusOK,
+			ExpectedETag:   `"2"`,
+		},
+		{
+			TestName:       "Test Merge Patch Buyer ID By Buyer",
+			User:           buyer,
+			OrderNumber:    inCart.OrderNumber,
+			ContentType:    mimeMergePatch,
+			Body:           `{"buyer_id": 2}`,
+			ExpectedStatus: http.StatusForbidden,
+		},
+		{
+			TestName:       "Test Merge Patch Unknown Field",
+			User:           buyer,
+			OrderNumber:    inCart.OrderNumber,
+			ContentType:    mimeMergePatch,
+			Body:           `{"discount": 10}`,
+			ExpectedStatus: http.StatusBadRequest,
+		},
+		{
+			TestName:       "Test Merge Patch Stale Version Field",
+			User:           buyer,
+			OrderNumber:    inCart.OrderNumber,
+			ContentType:    mimeMergePatch,
+			Body:           `{"buyer_full_name": "Buyer One", "version": 1}`,
+			ExpectedStatus: http.StatusConflict,
+		},
+		{
+			TestName:       "Test JSON Patch Item Qty",
+			User:           buyer,
+			OrderNumber:    inCart.OrderNumber,
+			ContentType:    mimeJSONPatch,
+			IfMatch:        `"2"`,
+			Body:           `[{"op": "replace", "path": "/items/0/qty", "value": 3}]`,
+			ExpectedStatus: http.StatusOK,
+			ExpectedETag:   `"3"`,
+		},
+		{
+			TestName:       "Test Merge Patch Item Qty Only",
+			User:           buyer,
+			OrderNumber:    inCart.OrderNumber,
+			ContentType:    mimeMergePatch,
+			Body:           `{"items": [{"product_id": 1, "qty": 4}]}`,
+			ExpectedStatus: http.StatusOK,
+			ExpectedETag:   `"4"`,
+		},
+		{
+			TestName:    "Test Merge Patch Item Qty With Same Detail",
+			User:        buyer,
+			OrderNumber: inCart.OrderNumber,
+			ContentType: mimeMergePatch,
+			Body: `{"items": [{"product_id": 1, "product_name": "Product 1", ` +
+				`"product_price": "1000000.50", "qty": 3}]}`,
+			ExpectedStatus: http.StatusOK,
+			ExpectedETag:   `"5"`,
+		},
+		{
+			TestName:    "Test Merge Patch Item Name By Buyer",
+			User:        buyer,
+			OrderNumber: inCart.OrderNumber,
+			ContentType: mimeMergePatch,
+			Body: `{"items": [{"product_id": 1, "product_name": "Product X", ` +
+				`"qty": 3}]}`,
+			ExpectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			TestName:    "Test JSON Patch Item Price By Buyer",
+			User:        buyer,
+			OrderNumber: inCart.OrderNumber,
+			ContentType: mimeJSONPatch,
+			Body: `[{"op": "replace", "path": "/items/0/product_price", ` +
+				`"value": 1}]`,
+			ExpectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			TestName:    "Test JSON Patch Add Item",
+			User:        buyer,
+			OrderNumber: inCart.OrderNumber,
+			ContentType: mimeJSONPatch,
+			Body: `[{"op": "add", "path": "/items/-", ` +
+				`"value": {"product_id": 2, "qty": 1}}]`,
+			ExpectedStatus: http.StatusOK,
+			ExpectedETag:   `"6"`,
+		},
+		{
+			TestName:    "Test JSON Patch Test Failed",
+			User:        buyer,
+			OrderNumber: inCart.OrderNumber,
+			ContentType: mimeJSONPatch,
+			Body: `[{"op": "test", "path": "/buyer_full_name", "value": "Buyer 2"},` +
+				`{"op": "replace", "path": "/buyer_full_name", "value": "Buyer 3"}]`,
+			ExpectedStatus: http.StatusConflict,
+		},
+		{
+			TestName:       "Test JSON Patch Path Not Found",
+			User:           buyer,
+			OrderNumber:    inCart.OrderNumber,
+			ContentType:    mimeJSONPatch,
+			Body:           `[{"op": "remove", "path": "/items/5"}]`,
+			ExpectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			TestName:       "Test JSON Patch Invalid",
+			User:           buyer,
+			OrderNumber:    inCart.OrderNumber,
+			ContentType:    mimeJSONPatch,
+			Body:           `{"op": "remove", "path": "/items/0"}`,
+			ExpectedStatus: http.StatusBadRequest,
+		},
+		{
+			TestName:       "Test Patch Unsupported Media Type",
+			User:           buyer,
+			OrderNumber:    inCart.OrderNumber,
+			ContentType:    echo.MIMEApplicationForm,
+			Body:           `buyer_address=Buyer+Street+2`,
+			ExpectedStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			TestName:       "Test Merge Patch Stale If-Match",
+			User:           buyer,
+			OrderNumber:    inCart.OrderNumber,
+			ContentType:    mimeMergePatch,
+			IfMatch:        `"2"`,
+			Body:           `{"buyer_address": "Buyer Street 2"}`,
+			ExpectedStatus: http.StatusConflict,
+		},
+		{
+			TestName:       "Test Merge Patch Status By Seller Not In Order",
+			User:           otherSeller,
+			OrderNumber:    inCart.OrderNumber,
+			ContentType:    mimeMergePatch,
+			Body:           `{"status": "cancelled"}`,
+			ExpectedStatus: http.StatusForbidden,
+		},
+		{
+			TestName:       "Test Merge Patch Status By Seller",
+			User:           seller,
+			OrderNumber:    paid.OrderNumber,
+			ContentType:    mimeMergePatch,
+			Body:           `{"status": "shipped"}`,
+			ExpectedStatus: http.StatusOK,
+			ExpectedETag:   `"2"`,
+		},
+		{
+			TestName:       "Test Merge Patch Status Transition Invalid",
+			User:           admin,
+			OrderNumber:    paid.OrderNumber,
+			ContentType:    mimeMergePatch,
+			Body:           `{"status": "in-cart"}`,
+			ExpectedStatus: http.StatusConflict,
+		},
+		{
+			TestName:       "Test Merge Patch Items Not In Cart",
+			User:           admin,
+			OrderNumber:    paid.OrderNumber,
+			ContentType:    mimeMergePatch,
+			Body:           `{"items": []}`,
+			ExpectedStatus: http.StatusConflict,
+		},
+		{
+			TestName:    "Test JSON Patch Clear Product Images By Admin",
+			User:        admin,
+			OrderNumber: inCart.OrderNumber,
+			ContentType: mimeJSONPatch,
+			Body: `[{"op": "replace", "path": "/items/0/product_images_path", ` +
+				`"value": []}]`,
+			ExpectedStatus: http.StatusOK,
+			ExpectedETag:   `"7"`,
+		},
+		{
+			TestName:       "Test Merge Patch Order Not Found",
+			User:           admin,
+			OrderNumber:    "unknown",
+			ContentType:    mimeMergePatch,
+			Body:           `{"buyer_address": "Buyer Street 2"}`,
+			ExpectedStatus: http.StatusNotFound,
+		},
+	}
+
+	// test for each testing table
+	for _, test := range testTable {
+		req := httptest.NewRequest("PATCH", "/?order_number="+test.OrderNumber,
+			strings.NewReader(test.Body))
+		req.Header.Set("Content-Type", test.ContentType)
+		if test.IfMatch != "" {
+			req.Header.Set("If-Match", test.IfMatch)
+		}
+
+		response := httptest.NewRecorder()
+		echoCtx := a.Echo.NewContext(req, response)
+		echoCtx.Set("user", test.User)
+		err = callHandler(a.PatchOrderHandler, echoCtx)
+		if err != nil {
+			t.Errorf("[%s] Expected API call success, but got error => %s",
+				test.TestName, err)
+		}
+
+		if response.Code != test.ExpectedStatus {
+			t.Errorf("[%s] Expected status %d got %d => %s",
+				test.TestName, test.ExpectedStatus, response.Code,
+				response.Body.String())
+		}
+		if response.Header().Get("ETag") != test.ExpectedETag {
+			t.Errorf("[%s] Expected ETag %s got %s", test.TestName,
+				test.ExpectedETag, response.Header().Get("ETag"))
+		}
+	}
+
+	// check patched orders
+	result, err := a.Orders.Get(a.Ctx,
+		repository.OrderFilter{OrderNumber: inCart.OrderNumber})
+	if err != nil {
+		t.Fatalf("There's an error when getting order => %s", err)
+	}
+	if result.BuyerAddress != "" || result.BuyerFullName != "Buyer 1" ||
+		len(result.Items) != 2 || result.Items[0].Qty != 3 ||
+		len(result.Items[0].ProductImagesPath) != 0 ||
+		result.Items[1].ProductName != "Product 2" ||
+		result.Qty != 4 || result.TotalPrice != money.MustParse("3000501.50") {
+		t.Errorf("Expected order patched, but got %+v", result)
+	}
+
+	result, err = a.Orders.Get(a.Ctx,
+		repository.OrderFilter{OrderNumber: paid.OrderNumber})
+	if err != nil {
+		t.Fatalf("There's an error when getting order => %s", err)
+	}
+	if result.Status != "shipped" ||
+		!reflect.DeepEqual(result.Items, paid.Items) {
+		t.Errorf("Expected order status patched into shipped, but got %+v",
+			result)
+	}
+}
@@ -0,0 +1,216 @@
+/*
+Package api containing API initialization and API route handler
+*/
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/reyhanfikridz/ecom-order-service/internal/audit"
+	"github.com/reyhanfikridz/ecom-order-service/internal/config"
+	"github.com/reyhanfikridz/ecom-order-service/internal/model"
+	"github.com/reyhanfikridz/ecom-order-service/internal/problem"
+	"github.com/reyhanfikridz/ecom-order-service/internal/product"
+	"github.com/reyhanfikridz/ecom-order-service/internal/repository"
+)
+
+// reservationExpiryInterval interval of checking expired stock reservation
+const reservationExpiryInterval = time.Minute
+
+// reserveOrderStock reserve stock of all order items in product service,
+// if one of them failed then all reserved items stock released back
+func (a *API) reserveOrderStock(token string, o model.Order) error {
+	for i, item := range o.Items {
+		err := a.Products.ReserveStock(a.Ctx, token, item.ProductID, item.Qty)
+		if err != nil {
+			// roll back stock already reserved
+			rollbackErr := a.releaseItemsStock(token, o.Items[:i])
+			if rollbackErr != nil {
+				log.Printf("There's an error when releasing stock of order %s "+
+					"=> %s", o.OrderNumber, rollbackErr)
+			}
+
+			return fmt.Errorf("items[%d] => %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// releaseItemsStock release stock of order items in product service
+//
+// all items stock are released even if one of them failed,
+// the first error is returned
+func (a *API) releaseItemsStock(token string, items []model.OrderItem) error {
+	var result error
+	for i, item := range items {
+		err := a.Products.ReleaseStock(a.Ctx, token, item.ProductID, item.Qty)
+		if err != nil && result == nil {
+			result = fmt.Errorf("items[%d] => %w", i, err)
+		}
+	}
+
+	return result
+}
+
+// releaseOrderStock release items stock of cancelled order
+// with stock release pending, release marked as done before
+// the stock released so it's released only once, and marked
+// as pending again if failed
+func (a *API) releaseOrderStock(ctx context.Context, token string,
+	o model.Order) error {
+	filter := repository.OrderFilter{
+		OrderNumber:    o.OrderNumber,
+		IncludeDeleted: true,
+	}
+
+	// claim the release, skip if already released by others
+	filter.StockReleasePending = true
+	err := a.Orders.SetStockReleasePending(ctx, filter, false)
+	if err == repository.ErrNoDataUpdated {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	err = a.releaseItemsStock(token, o.Items)
+	if err != nil {
+		filter.StockReleasePending = false
+		pendingErr := a.Orders.SetStockReleasePending(ctx, filter, true)
+		if pendingErr != nil {
+			log.Printf("There's an error when marking stock release of "+
+				"order %s pending => %s", o.OrderNumber, pendingErr)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// stockError error when reserving order items stock
+func stockError(err error) error {
+	if errors.Is(err, product.ErrStockInsufficient) ||
+		errors.Is(err, product.ErrProductNotFound) {
+		return problem.Newf(http.StatusConflict, codeStockUnavailable,
+			"Order items stock can't be reserved => %s", err)
+	}
+
+	return problem.Internal(fmt.Errorf(
+		"There's an error when reserving order items stock => %w",
+		err))
+}
+
+// CancelExpiredReservations cancel checked out orders with stock reserved
+// before the time and release their items stock, including deleted orders,
+// return total orders cancelled
+func (a *API) CancelExpiredReservations(ctx context.Context,
+	before time.Time) (int, error) {
+	// get checked out orders with expired reservation
+	filter := repository.OrderFilter{
+		Status:         model.OrderStatusCheckedOut,
+		ReservedBefore: before,
+		IncludeDeleted: true,
+	}
+	page, err := a.Orders.List(ctx, filter, repository.ListOptions{})
+	if err != nil {
+		return 0, err
+	}
+
+	// cancelled orders recorded to audit trail as changed by the service
+	auditCtx := a.withAudit(ctx, audit.SystemUser)
+	total := 0
+	for _, o := range page.Items {
+		// cancel order only if it's still checked out,
+		// so the stock released only once
+		err = a.Orders.Update(auditCtx, repository.OrderFilter{
+			OrderNumber:    o.OrderNumber,
+			Status:         model.OrderStatusCheckedOut,
+			IncludeDeleted: true,
+		}, model.Order{
+			Status:              model.OrderStatusCancelled,
+			StockReleasePending: true,
+		})
+		if err == repository.ErrNoDataUpdated {
+			continue
+		}
+		if err != nil {
+			return total, err
+		}
+		total++
+
+		// release order items stock, retried later if failed
+		err = a.releaseOrderStock(ctx, config.ProductServiceToken, o)
+		if err != nil {
+			log.Printf("There's an error when releasing stock of order %s, "+
+				"release retried later => %s", o.OrderNumber, err)
+		}
+	}
+
+	return total, nil
+}
+
+// ReleasePendingStock release items stock of cancelled orders
+// with stock release pending, i.e. previous release failed,
+// including deleted orders, return total orders released
+func (a *API) ReleasePendingStock(ctx context.Context) (int, error) {
+	page, err := a.Orders.List(ctx, repository.OrderFilter{
+		StockReleasePending: true,
+		IncludeDeleted:      true,
+	}, repository.ListOptions{})
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, o := range page.Items {
+		err = a.releaseOrderStock(ctx, config.ProductServiceToken, o)
+		if err != nil {
+			log.Printf("There's an error when releasing stock of order %s, "+
+				"release retried later => %s", o.OrderNumber, err)
+			continue
+		}
+		total++
+	}
+
+	return total, nil
+}
+
+// RunReservationExpiry periodically cancel checked out orders
+// with stock reserved longer than ttl and retry pending stock release
+// until ctx done
+func (a *API) RunReservationExpiry(ctx context.Context, ttl time.Duration) {
+	ticker := time.NewTicker(reservationExpiryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			total, err := a.CancelExpiredReservations(ctx, now.Add(-ttl))
+			if err != nil {
+				log.Printf("There's an error when cancelling expired "+
+					"reservation => %s", err)
+			}
+			if total > 0 {
+				log.Printf("%d order with expired reservation cancelled", total)
+			}
+
+			total, err = a.ReleasePendingStock(ctx)
+			if err != nil {
+				log.Printf("There's an error when releasing pending "+
+					"stock => %s", err)
+			}
+			if total > 0 {
+				log.Printf("%d order pending stock released", total)
+			}
+		}
+	}
+}
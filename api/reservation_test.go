@@ -0,0 +1,411 @@
+/*
+Package api containing API initialization and API route handler
+*/
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/reyhanfikridz/ecom-order-service/internal/middleware"
+	"github.com/reyhanfikridz/ecom-order-service/internal/model"
+	"github.com/reyhanfikridz/ecom-order-service/internal/money"
+	"github.com/reyhanfikridz/ecom-order-service/internal/product"
+	"github.com/reyhanfikridz/ecom-order-service/internal/product/producttest"
+	"github.com/reyhanfikridz/ecom-order-service/internal/repository"
+)
+
+// TestOrderStockReservation test items stock reserved when order
+// checked out and released when order cancelled
+func TestOrderStockReservation(t *testing.T) {
+	// get testing API connected to product service stub
+	server := producttest.NewServer(
+		product.Product{ID: 1, Name: "Product 1",
+			Price: money.MustParse("1000"), Weight: 1, Stock: 3},
+		product.Product{ID: 2, Name: "Product 2",
+			Price: money.MustParse("500"), Weight: 1, Stock: 1},
+	)
+	defer server.Close()
+
+	u := middleware.User{ID: 1, Role: "buyer"}
+	a, err := GetTestingAPI(u)
+	if err != nil {
+		t.Fatalf("There's an error when getting testing API => %s", err)
+	}
+	a.Products = server.Service()
+
+	o, err := a.Orders.Insert(a.Ctx, model.Order{
+		Status:  "in-cart",
+		BuyerID: 1,
+		Items: []model.OrderItem{
+			{ProductID: 1, ProductName: "Product 1",
+				ProductPrice: money.MustParse("1000"), Qty: 2},
+			{ProductID: 2, ProductName: "Product 2",
+				ProductPrice: money.MustParse("500"), Qty: 2},
+		},
+	})
+	if err != nil {
+		t.Fatalf("There's an error when creating testing data => %s", err)
+	}
+
+	// initialize testing table, run in order
+	testTable := []struct {
+		TestName       string
+		Status         string
+		ProductStock   map[int]int
+		ExpectedStatus int
+		ExpectedStock  map[int]int
+		ExpectedOrder  string
+	}{
+		{
+			TestName:       "Test Checkout Stock Insufficient",
+			Status:         "checked-out",
+			ExpectedStatus: http.StatusConflict,
+			ExpectedStock:  map[int]int{1: 3, 2: 1},
+			ExpectedOrder:  "in-cart",
+		},
+		{
+			TestName:       "Test Checkout Success",
+			Status:         "checked-out",
+			ProductStock:   map[int]int{2: 5},
+			ExpectedStatus: http.StatusOK,
+			ExpectedStock:  map[int]int{1: 1, 2: 3},
+			ExpectedOrder:  "checked-out",
+		},
+		{
+			TestName:       "Test Cancel Success",
+			Status:         "cancelled",
+			ExpectedStatus: http.StatusOK,
+			ExpectedStock:  map[int]int{1: 3, 2: 5},
+			ExpectedOrder:  "cancelled",
+		},
+		{
+			TestName:       "Test Cancel Again",
+			Status:         "cancelled",
+			ExpectedStatus: http.StatusOK,
+			ExpectedStock:  map[int]int{1: 3, 2: 5},
+			ExpectedOrder:  "cancelled",
+		},
+	}
+
+	// test for each testing table
+	for _, test := range testTable {
+		for id, stock := range test.ProductStock {
+			p, _ := server.Products.GetProduct(a.Ctx, "", id)
+			p.Stock = stock
+			server.Products.SetProduct(p)
+		}
+
+		response := updateOrderStatus(a, u, o.OrderNumber, test.Status)
+		if response.Code != test.ExpectedStatus {
+			t.Errorf("[%s] Expected status %d got %d => %s",
+				test.TestName, test.ExpectedStatus, response.Code,
+				response.Body.String())
+		}
+
+		for id, expectedStock := range test.ExpectedStock {
+			p, _ := server.Products.GetProduct(a.Ctx, "", id)
+			if p.Stock != expectedStock {
+				t.Errorf("[%s] Expected product %d stock %d, but got %d",
+					test.TestName, id, expectedStock, p.Stock)
+			}
+		}
+
+		result, err := a.Orders.Get(a.Ctx,
+			repository.OrderFilter{OrderNumber: o.OrderNumber})
+		if err != nil {
+			t.Fatalf("[%s] There's an error when getting order => %s",
+				test.TestName, err)
+		}
+		if result.Status != test.ExpectedOrder {
+			t.Errorf("[%s] Expected order status %s, but got %s",
+				test.TestName, test.ExpectedOrder, result.Status)
+		}
+		if result.Status == "checked-out" && result.ReservedAt == nil {
+			t.Errorf("[%s] Expected reserved_at not empty, but got empty",
+				test.TestName)
+		}
+	}
+}
+
+// TestChangeOrderItemsAfterCheckout test order items
+// can't be changed after order checked out
+func TestChangeOrderItemsAfterCheckout(t *testing.T) {
+	u := middleware.User{ID: 1, Role: "buyer"}
+	a, err := GetTestingAPI(u)
+	if err != nil {
+		t.Fatalf("There's an error when getting testing API => %s", err)
+	}
+
+	o, err := a.Orders.Insert(a.Ctx, model.Order{
+		Status:  "in-cart",
+		BuyerID: 1,
+		Items: []model.OrderItem{
+			{ProductID: 1, ProductName: "Product 1",
+				ProductPrice: money.MustParse("1000"), Qty: 2},
+		},
+	})
+	if err != nil {
+		t.Fatalf("There's an error when creating testing data => %s", err)
+	}
+
+	response := updateOrderStatus(a, u, o.OrderNumber, "checked-out")
+	if response.Code != http.StatusOK {
+		t.Fatalf("Expected checkout status %d got %d => %s",
+			http.StatusOK, response.Code, response.Body.String())
+	}
+
+	// try to remove item from checked out order
+	params := url.Values{}
+	params.Add("order_number", o.OrderNumber)
+	params.Add("product_id", "1")
+	req := httptest.NewRequest("DELETE", "/?"+params.Encode(), nil)
+
+	response = httptest.NewRecorder()
+	echoCtx := a.Echo.NewContext(req, response)
+	echoCtx.Set("user", u)
+	err = callHandler(a.DeleteOrderItemHandler, echoCtx)
+	if err != nil {
+		t.Errorf("Expected API call success, but got error => %s", err)
+	}
+	if response.Code != http.StatusConflict {
+		t.Errorf("Expected status %d got %d", http.StatusConflict, response.Code)
+	}
+}
+
+// TestCancelExpiredReservations test CancelExpiredReservations
+func TestCancelExpiredReservations(t *testing.T) {
+	u := middleware.User{ID: 1, Role: "buyer"}
+	a, err := GetTestingAPI(u)
+	if err != nil {
+		t.Fatalf("There's an error when getting testing API => %s", err)
+	}
+
+	o, err := a.Orders.Insert(a.Ctx, model.Order{
+		Status:  "in-cart",
+		BuyerID: 1,
+		Items: []model.OrderItem{
+			{ProductID: 2, ProductName: "Product 2",
+				ProductPrice: money.MustParse("500"), Qty: 5},
+		},
+	})
+	if err != nil {
+		t.Fatalf("There's an error when creating testing data => %s", err)
+	}
+
+	response := updateOrderStatus(a, u, o.OrderNumber, "checked-out")
+	if response.Code != http.StatusOK {
+		t.Fatalf("Expected checkout status %d got %d => %s",
+			http.StatusOK, response.Code, response.Body.String())
+	}
+
+	// deleted order with stock reserved also cancelled
+	deleted, err := a.Orders.Insert(a.Ctx, model.Order{
+		Status:  "in-cart",
+		BuyerID: 1,
+		Items: []model.OrderItem{
+			{ProductID: 2, ProductName: "Product 2",
+				ProductPrice: money.MustParse("500"), Qty: 5},
+		},
+	})
+	if err != nil {
+		t.Fatalf("There's an error when creating testing data => %s", err)
+	}
+
+	response = updateOrderStatus(a, u, deleted.OrderNumber, "checked-out")
+	if response.Code != http.StatusOK {
+		t.Fatalf("Expected checkout status %d got %d => %s",
+			http.StatusOK, response.Code, response.Body.String())
+	}
+	err = a.Orders.Delete(a.Ctx,
+		repository.OrderFilter{OrderNumber: deleted.OrderNumber},
+		model.OrderActor{ID: 1, Role: "buyer"})
+	if err != nil {
+		t.Fatalf("There's an error when deleting testing data => %s", err)
+	}
+
+	// initialize testing table, run in order
+	testTable := []struct {
+		TestName      string
+		Before        time.Time
+		ExpectedTotal int
+		ExpectedOrder string
+		ExpectedStock int
+	}{
+		{
+			TestName:      "Test Reservation Not Expired",
+			Before:        time.Now().Add(-time.Minute),
+			ExpectedTotal: 0,
+			ExpectedOrder: "checked-out",
+			ExpectedStock: 40,
+		},
+		{
+			TestName:      "Test Reservation Expired",
+			Before:        time.Now().Add(time.Minute),
+			ExpectedTotal: 2,
+			ExpectedOrder: "cancelled",
+			ExpectedStock: 50,
+		},
+		{
+			TestName:      "Test Reservation Already Cancelled",
+			Before:        time.Now().Add(time.Minute),
+			ExpectedTotal: 0,
+			ExpectedOrder: "cancelled",
+			ExpectedStock: 50,
+		},
+	}
+
+	// test for each testing table
+	for _, test := range testTable {
+		total, err := a.CancelExpiredReservations(a.Ctx, test.Before)
+		if err != nil {
+			t.Errorf("[%s] Expected error nil, but got %s", test.TestName, err)
+		}
+		if total != test.ExpectedTotal {
+			t.Errorf("[%s] Expected total %d, but got %d",
+				test.TestName, test.ExpectedTotal, total)
+		}
+
+		for _, orderNumber := range []string{o.OrderNumber,
+			deleted.OrderNumber} {
+			result, err := a.Orders.Get(a.Ctx, repository.OrderFilter{
+				OrderNumber:    orderNumber,
+				IncludeDeleted: true,
+			})
+			if err != nil {
+				t.Fatalf("[%s] There's an error when getting order => %s",
+					test.TestName, err)
+			}
+			if result.Status != test.ExpectedOrder {
+				t.Errorf("[%s] Expected order %s status %s, but got %s",
+					test.TestName, orderNumber, test.ExpectedOrder,
+					result.Status)
+			}
+		}
+
+		p, _ := a.Products.GetProduct(a.Ctx, "", 2)
+		if p.Stock != test.ExpectedStock {
+			t.Errorf("[%s] Expected product stock %d, but got %d",
+				test.TestName, test.ExpectedStock, p.Stock)
+		}
+	}
+}
+
+// TestReleasePendingStock test failed stock release of cancelled order
+// retried by ReleasePendingStock
+func TestReleasePendingStock(t *testing.T) {
+	u := middleware.User{ID: 1, Role: "buyer"}
+	a, err := GetTestingAPI(u)
+	if err != nil {
+		t.Fatalf("There's an error when getting testing API => %s", err)
+	}
+	products := &countingProductService{Service: a.Products}
+	a.Products = products
+
+	o, err := a.Orders.Insert(a.Ctx, model.Order{
+		Status:  "in-cart",
+		BuyerID: 1,
+		Items: []model.OrderItem{
+			{ProductID: 2, ProductName: "Product 2",
+				ProductPrice: money.MustParse("500"), Qty: 5},
+		},
+	})
+	if err != nil {
+		t.Fatalf("There's an error when creating testing data => %s", err)
+	}
+
+	// order cancelled even if its stock release failed
+	products.ReleaseErr = errors.New("product service down")
+	for _, status := range []string{"checked-out", "cancelled"} {
+		response := updateOrderStatus(a, u, o.OrderNumber, status)
+		if response.Code != http.StatusOK {
+			t.Fatalf("Expected update order to %s status %d got %d => %s",
+				status, http.StatusOK, response.Code, response.Body.String())
+		}
+	}
+
+	// initialize testing table, run in order
+	testTable := []struct {
+		TestName        string
+		ReleaseErr      error
+		ExpectedTotal   int
+		ExpectedPending bool
+		ExpectedStock   int
+	}{
+		{
+			TestName:        "Test Release Failed Again",
+			ReleaseErr:      errors.New("product service down"),
+			ExpectedTotal:   0,
+			ExpectedPending: true,
+			ExpectedStock:   45,
+		},
+		{
+			TestName:        "Test Release Success",
+			ExpectedTotal:   1,
+			ExpectedPending: false,
+			ExpectedStock:   50,
+		},
+		{
+			TestName:        "Test Release Already Done",
+			ExpectedTotal:   0,
+			ExpectedPending: false,
+			ExpectedStock:   50,
+		},
+	}
+
+	// test for each testing table
+	for _, test := range testTable {
+		products.ReleaseErr = test.ReleaseErr
+		total, err := a.ReleasePendingStock(a.Ctx)
+		if err != nil {
+			t.Errorf("[%s] Expected error nil, but got %s", test.TestName, err)
+		}
+		if total != test.ExpectedTotal {
+			t.Errorf("[%s] Expected total %d, but got %d",
+				test.TestName, test.ExpectedTotal, total)
+		}
+
+		result, err := a.Orders.Get(a.Ctx,
+			repository.OrderFilter{OrderNumber: o.OrderNumber})
+		if err != nil {
+			t.Fatalf("[%s] There's an error when getting order => %s",
+				test.TestName, err)
+		}
+		if result.Status != "cancelled" ||
+			result.StockReleasePending != test.ExpectedPending {
+			t.Errorf("[%s] Expected order cancelled with stock release "+
+				"pending %t, but got %s %t", test.TestName,
+				test.ExpectedPending, result.Status, result.StockReleasePending)
+		}
+
+		p, _ := a.Products.GetProduct(a.Ctx, "", 2)
+		if p.Stock != test.ExpectedStock {
+			t.Errorf("[%s] Expected product stock %d, but got %d",
+				test.TestName, test.ExpectedStock, p.Stock)
+		}
+	}
+}
+
+// updateOrderStatus run update order handler to change order status
+func updateOrderStatus(a API, u middleware.User, orderNumber string,
+	status string) *httptest.ResponseRecorder {
+	form := url.Values{}
+	form.Add("status", status)
+
+	req := httptest.NewRequest("PUT", "/?order_number="+orderNumber,
+		strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", echo.MIMEApplicationForm)
+
+	response := httptest.NewRecorder()
+	echoCtx := a.Echo.NewContext(req, response)
+	echoCtx.Set("user", u)
+	callHandler(a.UpdateOrderHandler, echoCtx)
+
+	return response
+}
@@ -0,0 +1,74 @@
+/*
+Package api containing API initialization and API route handler
+*/
+package api
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/reyhanfikridz/ecom-order-service/internal/audit"
+	"github.com/reyhanfikridz/ecom-order-service/internal/repository"
+)
+
+// deletedOrderPurgeInterval interval of checking deleted orders
+// that need to be purged
+const deletedOrderPurgeInterval = time.Hour
+
+// PurgeDeletedOrders permanently delete orders deleted before the time,
+// return total orders purged
+func (a *API) PurgeDeletedOrders(ctx context.Context,
+	before time.Time) (int, error) {
+	// get orders deleted before the time
+	filter := repository.OrderFilter{DeletedBefore: before}
+	page, err := a.Orders.List(ctx, filter, repository.ListOptions{})
+	if err != nil {
+		return 0, err
+	}
+
+	// purged orders recorded to audit trail as changed by the service
+	auditCtx := a.withAudit(ctx, audit.SystemUser)
+	total := 0
+	for _, o := range page.Items {
+		// purge order only if it's still deleted before the time,
+		// so restored order is kept
+		purged, err := a.Orders.Purge(auditCtx, repository.OrderFilter{
+			OrderNumber:   o.OrderNumber,
+			DeletedBefore: before,
+		})
+		if err != nil {
+			return total, err
+		}
+		if purged == 0 {
+			continue
+		}
+		total++
+	}
+
+	return total, nil
+}
+
+// RunDeletedOrderPurge periodically purge orders deleted
+// longer than retention until ctx done
+func (a *API) RunDeletedOrderPurge(ctx context.Context,
+	retention time.Duration) {
+	ticker := time.NewTicker(deletedOrderPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			total, err := a.PurgeDeletedOrders(ctx, now.Add(-retention))
+			if err != nil {
+				log.Printf("There's an error when purging deleted "+
+					"orders => %s", err)
+			}
+			if total > 0 {
+				log.Printf("%d deleted order purged", total)
+			}
+		}
+	}
+}
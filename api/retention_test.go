@@ -0,0 +1,229 @@
+/*
+Package api containing API initialization and API route handler
+*/
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/reyhanfikridz/ecom-order-service/internal/audit"
+	"github.com/reyhanfikridz/ecom-order-service/internal/middleware"
+	"github.com/reyhanfikridz/ecom-order-service/internal/model"
+	"github.com/reyhanfikridz/ecom-order-service/internal/money"
+	"github.com/reyhanfikridz/ecom-order-service/internal/repository"
+)
+
+// TestRestoreOrderHandler test RestoreOrderHandler
+func TestRestoreOrderHandler(t *testing.T) {
+	buyer := middleware.User{ID: 1, Role: "buyer"}
+	admin := middleware.User{ID: 100, Role: "admin"}
+	a, err := GetTestingAPI(buyer)
+	if err != nil {
+		t.Fatalf("There's an error when getting testing API => %s", err)
+	}
+
+	o, err := a.Orders.Insert(a.Ctx, model.Order{
+		Status:  "in-cart",
+		BuyerID: 1,
+		Items: []model.OrderItem{
+			{ProductID: 2, ProductName: "Product 2",
+				ProductPrice: money.MustParse("500"), Qty: 1},
+		},
+	})
+	if err != nil {
+		t.Fatalf("There's an error when creating testing data => %s", err)
+	}
+
+	response := deleteOrder(a, buyer, o.OrderNumber)
+	if response.Code != http.StatusOK {
+		t.Fatalf("Expected delete status %d got %d => %s",
+			http.StatusOK, response.Code, response.Body.String())
+	}
+
+	// initialize testing table, run in order
+	testTable := []struct {
+		TestName       string
+		OrderNumber    string
+		User           middleware.User
+		ExpectedStatus int
+	}{
+		{
+			TestName:       "Test Restore Order Forbidden Buyer",
+			OrderNumber:    o.OrderNumber,
+			User:           buyer,
+			ExpectedStatus: http.StatusForbidden,
+		},
+		{
+			TestName:       "Test Restore Order Success",
+			OrderNumber:    o.OrderNumber,
+			User:           admin,
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			TestName:       "Test Restore Order Not Deleted",
+			OrderNumber:    o.OrderNumber,
+			User:           admin,
+			ExpectedStatus: http.StatusConflict,
+		},
+		{
+			TestName:       "Test Restore Order Not Found",
+			OrderNumber:    "NOTEXIST",
+			User:           admin,
+			ExpectedStatus: http.StatusNotFound,
+		},
+		{
+			TestName:       "Test Restore Order Bad Request",
+			OrderNumber:    "",
+			User:           admin,
+			ExpectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	// test for each testing table
+	for _, test := range testTable {
+		req := httptest.NewRequest("POST", "/?order_number="+test.OrderNumber,
+			nil)
+
+		response := httptest.NewRecorder()
+		echoCtx := a.Echo.NewContext(req, response)
+		echoCtx.Set("user", test.User)
+		err = callHandler(a.RestoreOrderHandler, echoCtx)
+		if err != nil {
+			t.Errorf("[%s] Expected API call success, but got error => %s",
+				test.TestName, err)
+		}
+
+		if response.Code != test.ExpectedStatus {
+			t.Errorf("[%s] Expected status %d got %d => %s",
+				test.TestName, test.ExpectedStatus, response.Code,
+				response.Body.String())
+		}
+	}
+
+	// check restored order can be get again and the history recorded
+	result, err := a.Orders.Get(a.Ctx,
+		repository.OrderFilter{OrderNumber: o.OrderNumber})
+	if err != nil {
+		t.Fatalf("Expected restored order found, but got error => %s", err)
+	}
+	if result.DeletedAt != nil {
+		t.Errorf("Expected restored order not deleted, but got deleted at %v",
+			result.DeletedAt)
+	}
+
+	entries, err := a.Audit.List(a.Ctx, o.OrderNumber)
+	if err != nil {
+		t.Fatalf("There's an error when getting order history => %s", err)
+	}
+	if len(entries) != 2 || entries[0].Action != audit.ActionDelete ||
+		entries[1].Action != audit.ActionRestore {
+		t.Errorf("Expected order history delete and restore, but got %+v",
+			entries)
+	}
+}
+
+// TestPurgeDeletedOrders test PurgeDeletedOrders
+func TestPurgeDeletedOrders(t *testing.T) {
+	u := middleware.User{ID: 1, Role: "buyer"}
+	a, err := GetTestingAPI(u)
+	if err != nil {
+		t.Fatalf("There's an error when getting testing API => %s", err)
+	}
+
+	orderNumbers := []string{}
+	for i := 0; i < 2; i++ {
+		o, err := a.Orders.Insert(a.Ctx, model.Order{
+			Status:  "in-cart",
+			BuyerID: 1,
+			Items: []model.OrderItem{
+				{ProductID: 2, ProductName: "Product 2",
+					ProductPrice: money.MustParse("500"), Qty: 1},
+			},
+		})
+		if err != nil {
+			t.Fatalf("There's an error when creating testing data => %s", err)
+		}
+		orderNumbers = append(orderNumbers, o.OrderNumber)
+	}
+
+	// delete only the first order
+	response := deleteOrder(a, u, orderNumbers[0])
+	if response.Code != http.StatusOK {
+		t.Fatalf("Expected delete status %d got %d => %s",
+			http.StatusOK, response.Code, response.Body.String())
+	}
+
+	// initialize testing table, run in order
+	testTable := []struct {
+		TestName      string
+		Before        time.Time
+		ExpectedTotal int
+		ExpectedLeft  int64
+	}{
+		{
+			TestName:      "Test Retention Not Passed",
+			Before:        time.Now().Add(-time.Minute),
+			ExpectedTotal: 0,
+			ExpectedLeft:  2,
+		},
+		{
+			TestName:      "Test Retention Passed",
+			Before:        time.Now().Add(time.Minute),
+			ExpectedTotal: 1,
+			ExpectedLeft:  1,
+		},
+		{
+			TestName:      "Test Already Purged",
+			Before:        time.Now().Add(time.Minute),
+			ExpectedTotal: 0,
+			ExpectedLeft:  1,
+		},
+	}
+
+	// test for each testing table
+	for _, test := range testTable {
+		total, err := a.PurgeDeletedOrders(a.Ctx, test.Before)
+		if err != nil {
+			t.Errorf("[%s] Expected error nil, but got %s", test.TestName, err)
+		}
+		if total != test.ExpectedTotal {
+			t.Errorf("[%s] Expected total %d, but got %d",
+				test.TestName, test.ExpectedTotal, total)
+		}
+
+		page, err := a.Orders.List(a.Ctx,
+			repository.OrderFilter{IncludeDeleted: true},
+			repository.ListOptions{})
+		if err != nil {
+			t.Fatalf("[%s] There's an error when getting orders => %s",
+				test.TestName, err)
+		}
+		if page.Total != test.ExpectedLeft {
+			t.Errorf("[%s] Expected total orders left %d, but got %d",
+				test.TestName, test.ExpectedLeft, page.Total)
+		}
+	}
+
+	// check the not deleted order is kept
+	_, err = a.Orders.Get(a.Ctx,
+		repository.OrderFilter{OrderNumber: orderNumbers[1]})
+	if err != nil {
+		t.Errorf("Expected not deleted order kept, but got error => %s", err)
+	}
+}
+
+// deleteOrder run delete order handler to delete order
+func deleteOrder(a API, u middleware.User,
+	orderNumber string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("DELETE", "/?order_number="+orderNumber, nil)
+
+	response := httptest.NewRecorder()
+	echoCtx := a.Echo.NewContext(req, response)
+	echoCtx.Set("user", u)
+	callHandler(a.DeleteOrderHandler, echoCtx)
+
+	return response
+}
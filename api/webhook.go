@@ -0,0 +1,269 @@
+/*
+Package api containing API initialization and API route handler
+*/
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	echo "github.com/labstack/echo/v4"
+	"github.com/reyhanfikridz/ecom-order-service/internal/middleware"
+	"github.com/reyhanfikridz/ecom-order-service/internal/policy"
+	"github.com/reyhanfikridz/ecom-order-service/internal/problem"
+	"github.com/reyhanfikridz/ecom-order-service/internal/webhook"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// errUserInvalid returned when user data in context invalid
+	errUserInvalid = errors.New("user data invalid")
+
+	// errWebhookIDInvalid returned when webhook subscription ID invalid
+	errWebhookIDInvalid = errors.New("webhook id invalid")
+)
+
+// AddWebhookHandler route handler for subscribe to events of orders
+// containing seller product (Method: POST, User: seller)
+//
+// secret generated if it's empty, the secret only shown in this response
+func (a *API) AddWebhookHandler(c echo.Context) error {
+	// get user data
+	tmpU := c.Get("user")
+	u, ok := tmpU.(middleware.User)
+	if !ok {
+		return problem.Internal(errUserInvalid)
+	}
+
+	// check user authority to add webhook
+	err := a.Policy.CanAddWebhook(u)
+	if err != nil {
+		return problem.New(http.StatusForbidden, problem.CodeForbidden,
+			"user doesn't have authority to access this API")
+	}
+
+	// set subscription that need to be inserted to database
+	var s webhook.Subscription
+	err = c.Bind(&s)
+	if err != nil {
+		return bindError("Webhook data", err)
+	}
+	s.ID = primitive.NilObjectID
+	s.SellerID = u.ID
+	s.CreatedAt = time.Now().UTC()
+	if s.EventTypes == nil {
+		s.EventTypes = []string{}
+	}
+
+	if s.Secret == "" {
+		s.Secret, err = webhook.NewSecret()
+		if err != nil {
+			return problem.Internal(fmt.Errorf(
+				"There's an error when generating webhook secret => %w",
+				err))
+		}
+	}
+
+	// validate subscription data
+	err = webhook.ValidateSubscription(s)
+	if err != nil {
+		return invalidDataError("Webhook data", err)
+	}
+
+	// insert subscription to database
+	s, err = a.Webhooks.InsertSubscription(a.Ctx, s)
+	if err != nil {
+		return problem.Internal(fmt.Errorf(
+			"There's an error when inserting webhook data => %w",
+			err))
+	}
+
+	return c.JSON(http.StatusCreated, s)
+}
+
+// GetWebhooksHandler route handler for get webhook subscriptions
+// (Method: GET, User: seller, admin)
+//
+// seller only get their own subscriptions, admin get all subscriptions
+// or subscriptions of seller_id
+func (a *API) GetWebhooksHandler(c echo.Context) error {
+	// get user data
+	tmpU := c.Get("user")
+	u, ok := tmpU.(middleware.User)
+	if !ok {
+		return problem.Internal(errUserInvalid)
+	}
+
+	// get seller ID of subscriptions
+	sellerID, _ := strconv.Atoi(c.QueryParam("seller_id"))
+	sellerID, err := a.Policy.ScopeWebhookSellerID(u, sellerID)
+	if err != nil {
+		return problem.New(http.StatusForbidden, problem.CodeForbidden,
+			"user doesn't have authority to access this API")
+	}
+
+	// get subscriptions from database
+	subscriptions, err := a.Webhooks.ListSubscriptions(a.Ctx, sellerID)
+	if err != nil {
+		return problem.Internal(fmt.Errorf(
+			"There's an error when getting webhook data => %w",
+			err))
+	}
+	for i := range subscriptions {
+		subscriptions[i].Secret = ""
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"items": subscriptions,
+	})
+}
+
+// DeleteWebhookHandler route handler for unsubscribe webhook
+// (Method: DELETE, User: seller, admin)
+func (a *API) DeleteWebhookHandler(c echo.Context) error {
+	// get subscription that need to be deleted
+	s, err := a.getUserWebhook(c)
+	if err != nil {
+		return webhookError(err)
+	}
+
+	// delete subscription in database
+	err = a.Webhooks.DeleteSubscription(a.Ctx, s.ID)
+	if err != nil {
+		return webhookError(err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Delete webhook success!",
+	})
+}
+
+// GetWebhookDeliveriesHandler route handler for get delivery log
+// of webhook subscription (Method: GET, User: seller, admin)
+//
+// deliveries can be filtered by status (pending, succeeded, or dead),
+// status dead get the dead letters of the subscription
+func (a *API) GetWebhookDeliveriesHandler(c echo.Context) error {
+	// get subscription of the deliveries
+	s, err := a.getUserWebhook(c)
+	if err != nil {
+		return webhookError(err)
+	}
+
+	// get filter status
+	status := c.QueryParam("status")
+	if status != "" && status != webhook.DeliveryStatusPending &&
+		status != webhook.DeliveryStatusSending &&
+		status != webhook.DeliveryStatusSucceeded &&
+		status != webhook.DeliveryStatusDead {
+		return problem.Newf(http.StatusBadRequest, problem.CodeBadRequest,
+			"status '%s' invalid", status)
+	}
+
+	// get deliveries from database
+	deliveries, err := a.Webhooks.ListDeliveries(a.Ctx, s.ID, status)
+	if err != nil {
+		return webhookError(err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"subscription_id": s.ID,
+		"items":           deliveries,
+	})
+}
+
+// RetryWebhookDeliveryHandler route handler for retry dead delivery
+// of webhook subscription (Method: POST, User: seller, admin)
+func (a *API) RetryWebhookDeliveryHandler(c echo.Context) error {
+	// get subscription of the delivery
+	s, err := a.getUserWebhook(c)
+	if err != nil {
+		return webhookError(err)
+	}
+
+	// get delivery that need to be retried
+	deliveryID, err := primitive.ObjectIDFromHex(getParam(c, "delivery_id"))
+	if err != nil {
+		return paramRequiredError("delivery_id")
+	}
+
+	d, err := a.Webhooks.GetDelivery(a.Ctx, deliveryID)
+	if err == nil && d.SubscriptionID != s.ID {
+		err = webhook.ErrDeliveryNotFound
+	}
+	if err != nil {
+		return webhookError(err)
+	}
+
+	// only dead delivery can be retried
+	if d.Status != webhook.DeliveryStatusDead {
+		return problem.Newf(http.StatusConflict, codeWebhookDeliveryNotDead,
+			"Webhook delivery can only be retried "+
+				"when delivery status is '%s'", webhook.DeliveryStatusDead)
+	}
+
+	// move delivery back to pending
+	d, err = webhook.RetryDelivery(a.Ctx, a.Webhooks, d)
+	if err != nil {
+		return webhookError(err)
+	}
+
+	return c.JSON(http.StatusOK, d)
+}
+
+// getUserWebhook get webhook subscription by id param
+// that user can manage
+func (a *API) getUserWebhook(c echo.Context) (webhook.Subscription, error) {
+	// get user data
+	tmpU := c.Get("user")
+	u, ok := tmpU.(middleware.User)
+	if !ok {
+		return webhook.Subscription{}, errUserInvalid
+	}
+
+	// get subscription
+	id, err := primitive.ObjectIDFromHex(getParam(c, "id"))
+	if err != nil {
+		return webhook.Subscription{}, errWebhookIDInvalid
+	}
+
+	s, err := a.Webhooks.GetSubscription(a.Ctx, id)
+	if err != nil {
+		return s, err
+	}
+
+	// check user authority to manage the subscription
+	err = a.Policy.CanManageWebhook(u, s)
+	if err != nil {
+		return s, err
+	}
+
+	return s, nil
+}
+
+// webhookError error when getting
+// or changing webhook subscription and its deliveries
+func webhookError(err error) error {
+	switch err {
+	case errUserInvalid:
+		return problem.Internal(errUserInvalid)
+	case errWebhookIDInvalid:
+		return paramRequiredError("id")
+	case policy.ErrForbidden:
+		return problem.New(http.StatusForbidden, problem.CodeForbidden,
+			"user doesn't have authority to access this webhook")
+	case webhook.ErrSubscriptionNotFound:
+		return problem.New(http.StatusNotFound, codeWebhookNotFound,
+			"Webhook not found")
+	case webhook.ErrDeliveryNotFound:
+		return problem.New(http.StatusNotFound, codeWebhookDeliveryNotFound,
+			"Webhook delivery not found")
+	}
+
+	return problem.Internal(fmt.Errorf(
+		"There's an error when getting webhook data => %w",
+		err))
+}
@@ -0,0 +1,446 @@
+/*
+Package api containing API initialization and API route handler
+*/
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	echo "github.com/labstack/echo/v4"
+	"github.com/reyhanfikridz/ecom-order-service/internal/middleware"
+	"github.com/reyhanfikridz/ecom-order-service/internal/model"
+	"github.com/reyhanfikridz/ecom-order-service/internal/money"
+	"github.com/reyhanfikridz/ecom-order-service/internal/outbox"
+	"github.com/reyhanfikridz/ecom-order-service/internal/webhook"
+)
+
+// TestAddWebhookHandler test AddWebhookHandler and GetWebhooksHandler
+func TestAddWebhookHandler(t *testing.T) {
+	seller := middleware.User{ID: 10, Role: "seller"}
+	a, err := GetTestingAPI(seller)
+	if err != nil {
+		t.Fatalf("There's an error when getting testing API => %s", err)
+	}
+
+	// initialize testing table
+	testTable := []struct {
+		TestName       string
+		User           middleware.User
+		Body           string
+		ExpectedStatus int
+	}{
+		{
+			TestName:       "Test Add Webhook Success",
+			User:           seller,
+			Body:           `{"url": "https://seller.example.com/hook"}`,
+			ExpectedStatus: http.StatusCreated,
+		},
+		{
+			TestName: "Test Add Webhook Event Types Success",
+			User:     seller,
+			Body: `{"url": "https://seller.example.com/hook", ` +
+				`"event_types": ["OrderCancelled"]}`,
+			ExpectedStatus: http.StatusCreated,
+		},
+		{
+			TestName:       "Test Add Webhook URL Invalid",
+			User:           seller,
+			Body:           `{"url": "ftp://seller.example.com/hook"}`,
+			ExpectedStatus: http.StatusBadRequest,
+		},
+		{
+			TestName: "Test Add Webhook Event Type Invalid",
+			User:     seller,
+			Body: `{"url": "https://seller.example.com/hook", ` +
+				`"event_types": ["OrderShipped"]}`,
+			ExpectedStatus: http.StatusBadRequest,
+		},
+		{
+			TestName:       "Test Add Webhook Forbidden Buyer",
+			User:           middleware.User{ID: 1, Role: "buyer"},
+			Body:           `{"url": "https://buyer.example.com/hook"}`,
+			ExpectedStatus: http.StatusForbidden,
+		},
+	}
+
+	// test for each testing table
+	for _, test := range testTable {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(test.Body))
+		req.Header.Set("Content-Type", echo.MIMEApplicationJSON)
+
+		response := httptest.NewRecorder()
+		echoCtx := a.Echo.NewContext(req, response)
+		echoCtx.Set("user", test.User)
+		err = callHandler(a.AddWebhookHandler, echoCtx)
+		if err != nil {
+			t.Errorf("[%s] Expected API call success, but got error => %s",
+				test.TestName, err)
+		}
+
+		if response.Code != test.ExpectedStatus {
+			t.Errorf("[%s] Expected status %d got %d => %s",
+				test.TestName, test.ExpectedStatus, response.Code,
+				response.Body.String())
+			continue
+		}
+
+		if response.Code == http.StatusCreated {
+			s := webhook.Subscription{}
+			err = json.Unmarshal(response.Body.Bytes(), &s)
+			if err != nil {
+				t.Errorf("[%s] There's an error when unmarshal response => %s",
+					test.TestName, err)
+			}
+			if s.ID.IsZero() || s.SellerID != seller.ID || s.Secret == "" {
+				t.Errorf("[%s] Expected subscription of seller %d with secret, "+
+					"but got %+v", test.TestName, seller.ID, s)
+			}
+		}
+	}
+
+	// check subscriptions of each user
+	getTestTable := []struct {
+		TestName       string
+		User           middleware.User
+		ExpectedStatus int
+		ExpectedTotal  int
+	}{
+		{
+			TestName:       "Test Get Webhooks Seller",
+			User:           seller,
+			ExpectedStatus: http.StatusOK,
+			ExpectedTotal:  2,
+		},
+		{
+			TestName:       "Test Get Webhooks Other Seller",
+			User:           middleware.User{ID: 20, Role: "seller"},
+			ExpectedStatus: http.StatusOK,
+			ExpectedTotal:  0,
+		},
+		{
+			TestName:       "Test Get Webhooks Admin",
+			User:           middleware.User{ID: 100, Role: "admin"},
+			ExpectedStatus: http.StatusOK,
+			ExpectedTotal:  2,
+		},
+		{
+			TestName:       "Test Get Webhooks Forbidden Buyer",
+			User:           middleware.User{ID: 1, Role: "buyer"},
+			ExpectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, test := range getTestTable {
+		req := httptest.NewRequest("GET", "/", nil)
+
+		response := httptest.NewRecorder()
+		echoCtx := a.Echo.NewContext(req, response)
+		echoCtx.Set("user", test.User)
+		err = callHandler(a.GetWebhooksHandler, echoCtx)
+		if err != nil {
+			t.Errorf("[%s] Expected API call success, but got error => %s",
+				test.TestName, err)
+		}
+
+		if response.Code != test.ExpectedStatus {
+			t.Errorf("[%s] Expected status %d got %d => %s",
+				test.TestName, test.ExpectedStatus, response.Code,
+				response.Body.String())
+			continue
+		}
+
+		if response.Code == http.StatusOK {
+			result := struct {
+				Items []webhook.Subscription `json:"items"`
+			}{}
+			err = json.Unmarshal(response.Body.Bytes(), &result)
+			if err != nil {
+				t.Errorf("[%s] There's an error when unmarshal response => %s",
+					test.TestName, err)
+			}
+			if len(result.Items) != test.ExpectedTotal {
+				t.Errorf("[%s] Expected %d webhooks, but got %d",
+					test.TestName, test.ExpectedTotal, len(result.Items))
+			}
+			for _, s := range result.Items {
+				if s.Secret != "" {
+					t.Errorf("[%s] Expected webhook secret hidden",
+						test.TestName)
+				}
+			}
+		}
+	}
+}
+
+// TestWebhookDelivery test order events delivered to seller webhook,
+// GetWebhookDeliveriesHandler, RetryWebhookDeliveryHandler,
+// and DeleteWebhookHandler
+func TestWebhookDelivery(t *testing.T) {
+	seller := middleware.User{ID: 10, Role: "seller"}
+	otherSeller := middleware.User{ID: 20, Role: "seller"}
+	a, err := GetTestingAPI(seller)
+	if err != nil {
+		t.Fatalf("There's an error when getting testing API => %s", err)
+	}
+	a.Publisher = outbox.MultiPublisher{
+		outbox.NewMemoryPublisher(), webhook.NewDispatcher(a.Webhooks),
+	}
+
+	// create receiver of the webhook verifying the signature
+	secret := "testsecret"
+	received := []model.OrderEvent{}
+	receiver := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			payload, _ := io.ReadAll(r.Body)
+			if !webhook.Verify(secret, r.Header.Get(webhook.HeaderTimestamp),
+				payload, r.Header.Get(webhook.HeaderSignature)) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			e := model.OrderEvent{}
+			_ = json.Unmarshal(payload, &e)
+			received = append(received, e)
+		}))
+	defer receiver.Close()
+
+	s, err := a.Webhooks.InsertSubscription(a.Ctx, webhook.Subscription{
+		SellerID:   seller.ID,
+		URL:        receiver.URL,
+		Secret:     secret,
+		EventTypes: []string{},
+	})
+	if err != nil {
+		t.Fatalf("There's an error when creating testing data => %s", err)
+	}
+
+	// create order containing seller product, then relay and deliver events
+	o, err := a.Orders.Insert(a.Ctx, model.Order{
+		Status:  "in-cart",
+		BuyerID: 1,
+		Items: []model.OrderItem{
+			{ProductID: 1, ProductName: "Product 1",
+				ProductPrice:  money.MustParse("1000"),
+				ProductUserID: seller.ID, Qty: 1},
+		},
+	})
+	if err != nil {
+		t.Fatalf("There's an error when creating testing data => %s", err)
+	}
+
+	_, err = outbox.NewRelay(a.Outbox, a.Publisher).RelayOnce(a.Ctx)
+	if err != nil {
+		t.Fatalf("There's an error when relaying order events => %s", err)
+	}
+	deliverer := webhook.NewDeliverer(a.Webhooks)
+	deliverer.Client = receiver.Client()
+	total, err := deliverer.DeliverDue(a.Ctx)
+	if err != nil {
+		t.Fatalf("There's an error when delivering webhooks => %s", err)
+	}
+	if total != 1 || len(received) != 1 ||
+		received[0].Type != model.EventOrderCreated ||
+		received[0].OrderNumber != o.OrderNumber {
+		t.Errorf("Expected event %s of order %s delivered, but got %+v",
+			model.EventOrderCreated, o.OrderNumber, received)
+	}
+
+	// get delivery log
+	deliveries := []webhook.Delivery{}
+	getTestTable := []struct {
+		TestName       string
+		User           middleware.User
+		Query          string
+		ExpectedStatus int
+	}{
+		{
+			TestName:       "Test Get Webhook Deliveries Success",
+			User:           seller,
+			Query:          "?id=" + s.ID.Hex(),
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			TestName:       "Test Get Webhook Deliveries Status Invalid",
+			User:           seller,
+			Query:          "?id=" + s.ID.Hex() + "&status=unknown",
+			ExpectedStatus: http.StatusBadRequest,
+		},
+		{
+			TestName:       "Test Get Webhook Deliveries ID Invalid",
+			User:           seller,
+			Query:          "?id=invalid",
+			ExpectedStatus: http.StatusBadRequest,
+		},
+		{
+			TestName:       "Test Get Webhook Deliveries Forbidden Other Seller",
+			User:           otherSeller,
+			Query:          "?id=" + s.ID.Hex(),
+			ExpectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, test := range getTestTable {
+		req := httptest.NewRequest("GET", "/"+test.Query, nil)
+
+		response := httptest.NewRecorder()
+		echoCtx := a.Echo.NewContext(req, response)
+		echoCtx.Set("user", test.User)
+		err = callHandler(a.GetWebhookDeliveriesHandler, echoCtx)
+		if err != nil {
+			t.Errorf("[%s] Expected API call success, but got error => %s",
+				test.TestName, err)
+		}
+
+		if response.Code != test.ExpectedStatus {
+			t.Errorf("[%s] Expected status %d got %d => %s",
+				test.TestName, test.ExpectedStatus, response.Code,
+				response.Body.String())
+			continue
+		}
+
+		if response.Code == http.StatusOK {
+			result := struct {
+				Items []webhook.Delivery `json:"items"`
+			}{}
+			err = json.Unmarshal(response.Body.Bytes(), &result)
+			if err != nil {
+				t.Errorf("[%s] There's an error when unmarshal response => %s",
+					test.TestName, err)
+			}
+			if len(result.Items) != 1 ||
+				result.Items[0].Status != webhook.DeliveryStatusSucceeded {
+				t.Errorf("[%s] Expected 1 succeeded delivery, but got %+v",
+					test.TestName, result.Items)
+			}
+			deliveries = result.Items
+		}
+	}
+	if len(deliveries) != 1 {
+		t.Fatalf("Expected 1 delivery, but got %d", len(deliveries))
+	}
+
+	// move delivery to dead letters, then retry it
+	d := deliveries[0]
+	d.Status = webhook.DeliveryStatusDead
+	err = a.Webhooks.UpdateDelivery(a.Ctx, d)
+	if err != nil {
+		t.Fatalf("There's an error when updating testing data => %s", err)
+	}
+
+	retryTestTable := []struct {
+		TestName       string
+		User           middleware.User
+		Query          string
+		ExpectedStatus int
+	}{
+		{
+			TestName:       "Test Retry Webhook Delivery Forbidden Other Seller",
+			User:           otherSeller,
+			Query:          "?id=" + s.ID.Hex() + "&delivery_id=" + d.ID.Hex(),
+			ExpectedStatus: http.StatusForbidden,
+		},
+		{
+			TestName:       "Test Retry Webhook Delivery Success",
+			User:           seller,
+			Query:          "?id=" + s.ID.Hex() + "&delivery_id=" + d.ID.Hex(),
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			TestName:       "Test Retry Webhook Delivery Not Dead",
+			User:           seller,
+			Query:          "?id=" + s.ID.Hex() + "&delivery_id=" + d.ID.Hex(),
+			ExpectedStatus: http.StatusConflict,
+		},
+		{
+			TestName: "Test Retry Webhook Delivery Not Found",
+			User:     seller,
+			Query: "?id=" + s.ID.Hex() + "&delivery_id=" +
+				s.ID.Hex(),
+			ExpectedStatus: http.StatusNotFound,
+		},
+		{
+			TestName:       "Test Retry Webhook Delivery ID Invalid",
+			User:           seller,
+			Query:          "?id=" + s.ID.Hex(),
+			ExpectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range retryTestTable {
+		req := httptest.NewRequest("POST", "/"+test.Query, nil)
+
+		response := httptest.NewRecorder()
+		echoCtx := a.Echo.NewContext(req, response)
+		echoCtx.Set("user", test.User)
+		err = callHandler(a.RetryWebhookDeliveryHandler, echoCtx)
+		if err != nil {
+			t.Errorf("[%s] Expected API call success, but got error => %s",
+				test.TestName, err)
+		}
+
+		if response.Code != test.ExpectedStatus {
+			t.Errorf("[%s] Expected status %d got %d => %s",
+				test.TestName, test.ExpectedStatus, response.Code,
+				response.Body.String())
+		}
+	}
+
+	// check retried delivery sent again
+	total, err = deliverer.DeliverDue(a.Ctx)
+	if err != nil {
+		t.Fatalf("There's an error when delivering webhooks => %s", err)
+	}
+	if total != 1 || len(received) != 2 {
+		t.Errorf("Expected retried delivery sent again, but got %d received",
+			len(received))
+	}
+
+	// delete webhook
+	deleteTestTable := []struct {
+		TestName       string
+		User           middleware.User
+		ExpectedStatus int
+	}{
+		{
+			TestName:       "Test Delete Webhook Forbidden Other Seller",
+			User:           otherSeller,
+			ExpectedStatus: http.StatusForbidden,
+		},
+		{
+			TestName:       "Test Delete Webhook Success",
+			User:           seller,
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			TestName:       "Test Delete Webhook Not Found",
+			User:           seller,
+			ExpectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range deleteTestTable {
+		req := httptest.NewRequest("DELETE", "/?id="+s.ID.Hex(),
+			bytes.NewReader(nil))
+
+		response := httptest.NewRecorder()
+		echoCtx := a.Echo.NewContext(req, response)
+		echoCtx.Set("user", test.User)
+		err = callHandler(a.DeleteWebhookHandler, echoCtx)
+		if err != nil {
+			t.Errorf("[%s] Expected API call success, but got error => %s",
+				test.TestName, err)
+		}
+
+		if response.Code != test.ExpectedStatus {
+			t.Errorf("[%s] Expected status %d got %d => %s",
+				test.TestName, test.ExpectedStatus, response.Code,
+				response.Body.String())
+		}
+	}
+}
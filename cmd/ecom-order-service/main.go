@@ -4,30 +4,55 @@ Package main the executeable file
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
+	"os"
 
 	"github.com/reyhanfikridz/ecom-order-service/api"
 	"github.com/reyhanfikridz/ecom-order-service/internal/config"
+	"github.com/reyhanfikridz/ecom-order-service/internal/outbox"
+	"github.com/reyhanfikridz/ecom-order-service/internal/webhook"
 )
 
 // main
 func main() {
-	// init API
-	a, err := InitAPI()
+	// init API, config set by command-line flags too
+	a, err := InitAPI(os.Args[1:])
+	if err == flag.ErrHelp {
+		return
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	// cancel checked out orders with expired items stock reservation
+	go a.RunReservationExpiry(context.Background(), config.ReservationTTL)
+
+	// purge orders deleted longer than retention time
+	go a.RunDeletedOrderPurge(context.Background(),
+		config.DeletedOrderRetention)
+
+	// publish order events written to outbox
+	go outbox.NewRelay(a.Outbox, a.Publisher).Run(context.Background(),
+		config.OutboxRelayInterval)
+
+	// send order events to seller webhooks
+	go webhook.NewDeliverer(a.Webhooks).Run(context.Background(),
+		config.WebhookDeliveryInterval)
+
 	// serve server
-	log.Fatal(a.Echo.Start(":8030"))
+	log.Fatal(a.Echo.Start(fmt.Sprintf(":%d", config.Port)))
 }
 
-// InitAPI initialize API
-func InitAPI() (api.API, error) {
+// InitAPI initialize API with command-line flags args
+func InitAPI(args []string) (api.API, error) {
 	a := api.API{}
+	a.Ctx = context.Background()
 
 	// init all config before can be used
-	err := config.InitConfig()
+	err := config.InitConfig(args)
 	if err != nil {
 		return a, err
 	}
@@ -38,6 +63,12 @@ func InitAPI() (api.API, error) {
 		return a, err
 	}
 
+	// init other services client
+	err = a.InitServices()
+	if err != nil {
+		return a, err
+	}
+
 	// init router
 	a.InitRouter()
 
@@ -7,7 +7,7 @@ import "testing"
 
 // TestInitAPI test InitAPI
 func TestInitAPI(t *testing.T) {
-	_, err := InitAPI()
+	_, err := InitAPI(nil)
 	if err != nil {
 		t.Errorf("There's an error when initialize API => " + err.Error())
 	}
@@ -0,0 +1,219 @@
+/*
+Package audit containing immutable audit trail of order changes
+*/
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/reyhanfikridz/ecom-order-service/internal/middleware"
+	"github.com/reyhanfikridz/ecom-order-service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// audit entry actions
+const (
+	ActionCreate       = "create"
+	ActionUpdate       = "update"
+	ActionStatusChange = "status-change"
+	ActionDelete       = "delete"
+	ActionRestore      = "restore"
+	ActionPurge        = "purge"
+)
+
+// SystemUser actor of changes made by the service itself,
+// e.g. cancelling order with expired reservation
+var SystemUser = middleware.User{FullName: "system", Role: "system"}
+
+// Entry one immutable record of order change
+type Entry struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	OrderNumber string             `bson:"order_number" json:"order_number"`
+	Action      string             `bson:"action" json:"action"`
+	Actor       Actor              `bson:"actor" json:"actor"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	Changes     []Change           `bson:"changes" json:"changes"`
+}
+
+// Actor user who made the change, or internal service
+// authorized by API key (role service) with its name in Service
+type Actor struct {
+	ID       int    `bson:"id" json:"id"`
+	FullName string `bson:"full_name" json:"full_name"`
+	Role     string `bson:"role" json:"role"`
+	Service  string `bson:"service,omitempty" json:"service,omitempty"`
+}
+
+// Change value of one order field before and after changed,
+// null value means the field not exist
+type Change struct {
+	Field  string `bson:"field" json:"field"`
+	Before Value  `bson:"before" json:"before"`
+	After  Value  `bson:"after" json:"after"`
+}
+
+// Value JSON value of order field, stored as JSON string in mongodb
+// so it's read back the same as it's written
+type Value json.RawMessage
+
+// MarshalJSON get value as raw JSON
+func (v Value) MarshalJSON() ([]byte, error) {
+	if len(v) == 0 {
+		return []byte("null"), nil
+	}
+
+	return v, nil
+}
+
+// UnmarshalJSON set value from raw JSON
+func (v *Value) UnmarshalJSON(b []byte) error {
+	*v = append((*v)[:0], b...)
+	return nil
+}
+
+// MarshalBSONValue get value as bson string
+func (v Value) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	return bson.MarshalValue(string(v))
+}
+
+// UnmarshalBSONValue set value from bson string
+func (v *Value) UnmarshalBSONValue(t bsontype.Type, b []byte) error {
+	var s string
+	err := bson.RawValue{Type: t, Value: b}.Unmarshal(&s)
+	if err != nil {
+		return err
+	}
+	*v = Value(s)
+
+	return nil
+}
+
+// Repository storage of audit entries, entries can't be changed or deleted
+type Repository interface {
+	// Insert insert new audit entry
+	Insert(ctx context.Context, e Entry) error
+
+	// List get all audit entries of order sorted by time
+	List(ctx context.Context, orderNumber string) ([]Entry, error)
+}
+
+// NewEntry create audit entry of order changed by user,
+// before is nil for created order and after is nil for deleted order
+func NewEntry(action string, u middleware.User, before *model.Order,
+	after *model.Order) (Entry, error) {
+	e := Entry{
+		Action: action,
+		Actor: Actor{
+			ID:       u.ID,
+			FullName: u.FullName,
+			Role:     u.Role,
+			Service:  u.Service,
+		},
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if after != nil {
+		e.OrderNumber = after.OrderNumber
+	} else if before != nil {
+		e.OrderNumber = before.OrderNumber
+	}
+
+	changes, err := Diff(before, after)
+	if err != nil {
+		return e, err
+	}
+	e.Changes = changes
+
+	return e, nil
+}
+
+// GetAction get action of order changed from before into after,
+// before is nil for created order and after is nil for purged order
+func GetAction(before *model.Order, after *model.Order) string {
+	switch {
+	case before == nil:
+		return ActionCreate
+	case after == nil:
+		return ActionPurge
+	case before.DeletedAt == nil && after.DeletedAt != nil:
+		return ActionDelete
+	case before.DeletedAt != nil && after.DeletedAt == nil:
+		return ActionRestore
+	case before.Status != after.Status:
+		return ActionStatusChange
+	}
+
+	return ActionUpdate
+}
+
+// Diff get changed fields of order sorted by field name,
+// nil order means the order not exist
+func Diff(before *model.Order, after *model.Order) ([]Change, error) {
+	beforeFields, err := getOrderFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := getOrderFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	// get all field names
+	fields := []string{}
+	for field := range beforeFields {
+		fields = append(fields, field)
+	}
+	for field := range afterFields {
+		if _, ok := beforeFields[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	// compare each field
+	changes := []Change{}
+	for _, field := range fields {
+		beforeValue, afterValue := beforeFields[field], afterFields[field]
+		if bytes.Equal(beforeValue, afterValue) {
+			continue
+		}
+
+		changes = append(changes, Change{
+			Field:  field,
+			Before: Value(beforeValue),
+			After:  Value(afterValue),
+		})
+	}
+
+	return changes, nil
+}
+
+// getOrderFields get JSON value of each order field except ID
+// and timestamps and version managed by the server
+func getOrderFields(o *model.Order) (map[string]json.RawMessage, error) {
+	fields := map[string]json.RawMessage{}
+	if o == nil {
+		return fields, nil
+	}
+
+	b, err := json.Marshal(o)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(b, &fields)
+	if err != nil {
+		return nil, err
+	}
+	delete(fields, "_id")
+	delete(fields, "created_at")
+	delete(fields, "updated_at")
+	delete(fields, "version")
+
+	return fields, nil
+}
@@ -0,0 +1,245 @@
+/*
+Package audit containing immutable audit trail of order changes
+*/
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/reyhanfikridz/ecom-order-service/internal/middleware"
+	"github.com/reyhanfikridz/ecom-order-service/internal/model"
+	"github.com/reyhanfikridz/ecom-order-service/internal/money"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// getTestingOrder get order used for testing
+func getTestingOrder() model.Order {
+	o := model.Order{
+		OrderNumber:  "ORDER1",
+		Status:       model.OrderStatusInCart,
+		BuyerID:      1,
+		BuyerAddress: "Buyer Street",
+		Items: []model.OrderItem{
+			{ProductID: 1, ProductName: "Product 1",
+				ProductPrice: money.MustParse("1000"), Qty: 2},
+		},
+	}
+	o.CalculateTotal()
+
+	return o
+}
+
+// TestDiff test Diff
+func TestDiff(t *testing.T) {
+	o := getTestingOrder()
+
+	oStatus := getTestingOrder()
+	oStatus.Status = model.OrderStatusCheckedOut
+
+	oItems := getTestingOrder()
+	oItems.AddItem(model.OrderItem{
+		ProductID: 2, ProductName: "Product 2",
+		ProductPrice: money.MustParse("500"), Qty: 1,
+	})
+
+	// initialize testing table
+	testTable := []struct {
+		TestName       string
+		Before         *model.Order
+		After          *model.Order
+		ExpectedFields []string
+	}{
+		{
+			TestName:       "Test No Change",
+			Before:         &o,
+			After:          &o,
+			ExpectedFields: []string{},
+		},
+		{
+			TestName:       "Test Status Change",
+			Before:         &o,
+			After:          &oStatus,
+			ExpectedFields: []string{"status"},
+		},
+		{
+			TestName:       "Test Items Change",
+			Before:         &o,
+			After:          &oItems,
+			ExpectedFields: []string{"items", "qty", "total_price"},
+		},
+		{
+			TestName: "Test Create",
+			Before:   nil,
+			After:    &o,
+			ExpectedFields: []string{
+				"buyer_address", "buyer_full_name", "buyer_id", "currency",
+				"items", "order_number", "qty", "status", "total_price",
+			},
+		},
+	}
+
+	// test for each testing table
+	for _, test := range testTable {
+		changes, err := Diff(test.Before, test.After)
+		if err != nil {
+			t.Fatalf("[%s] Expected error nil, but got %s", test.TestName, err)
+		}
+
+		fields := []string{}
+		for _, change := range changes {
+			fields = append(fields, change.Field)
+		}
+		if len(fields) != len(test.ExpectedFields) {
+			t.Errorf("[%s] Expected changed fields %v, but got %v",
+				test.TestName, test.ExpectedFields, fields)
+			continue
+		}
+		for i := range fields {
+			if fields[i] != test.ExpectedFields[i] {
+				t.Errorf("[%s] Expected changed fields %v, but got %v",
+					test.TestName, test.ExpectedFields, fields)
+				break
+			}
+		}
+	}
+}
+
+// TestNewEntry test NewEntry and the entry JSON and BSON value
+func TestNewEntry(t *testing.T) {
+	before := getTestingOrder()
+	after := getTestingOrder()
+	after.Status = model.OrderStatusCheckedOut
+	u := middleware.User{ID: 1, FullName: "George Marcus", Role: "buyer"}
+
+	e, err := NewEntry(ActionStatusChange, u, &before, &after)
+	if err != nil {
+		t.Fatalf("Expected error nil, but got %s", err)
+	}
+	if e.OrderNumber != "ORDER1" || e.Action != ActionStatusChange ||
+		e.Actor.ID != 1 || e.Actor.Role != "buyer" || e.CreatedAt.IsZero() {
+		t.Errorf("Expected entry of order ORDER1 by buyer 1, but got %+v", e)
+	}
+
+	// check entry read back the same from bson
+	doc, err := bson.Marshal(e)
+	if err != nil {
+		t.Fatalf("There's an error when marshal entry to bson => %s", err)
+	}
+	var result Entry
+	err = bson.Unmarshal(doc, &result)
+	if err != nil {
+		t.Fatalf("There's an error when unmarshal entry from bson => %s", err)
+	}
+
+	// check entry JSON value
+	b, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("There's an error when marshal entry to json => %s", err)
+	}
+	var resp struct {
+		Changes []struct {
+			Field  string `json:"field"`
+			Before string `json:"before"`
+			After  string `json:"after"`
+		} `json:"changes"`
+	}
+	err = json.Unmarshal(b, &resp)
+	if err != nil {
+		t.Fatalf("There's an error when unmarshal entry json => %s", err)
+	}
+	if len(resp.Changes) != 1 || resp.Changes[0].Field != "status" ||
+		resp.Changes[0].Before != "in-cart" ||
+		resp.Changes[0].After != "checked-out" {
+		t.Errorf("Expected status change from in-cart to checked-out, "+
+			"but got %s", b)
+	}
+
+	// check deleted order change after value is null
+	e, err = NewEntry(ActionDelete, u, &before, nil)
+	if err != nil {
+		t.Fatalf("Expected error nil, but got %s", err)
+	}
+	b, err = json.Marshal(e.Changes[0])
+	if err != nil {
+		t.Fatalf("There's an error when marshal change to json => %s", err)
+	}
+	if string(b) != `{"field":"buyer_address","before":"Buyer Street","after":null}` {
+		t.Errorf("Expected deleted field after value null, but got %s", b)
+	}
+}
+
+// TestGetAction test GetAction
+func TestGetAction(t *testing.T) {
+	o := getTestingOrder()
+	checkedOut := getTestingOrder()
+	checkedOut.Status = model.OrderStatusCheckedOut
+	updated := getTestingOrder()
+	updated.BuyerAddress = "Buyer Street 2"
+	deletedAt := time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)
+	deleted := getTestingOrder()
+	deleted.DeletedAt = &deletedAt
+
+	// initialize testing table
+	testTable := []struct {
+		TestName       string
+		Before         *model.Order
+		After          *model.Order
+		ExpectedAction string
+	}{
+		{"Test Create", nil, &o, ActionCreate},
+		{"Test Update", &o, &updated, ActionUpdate},
+		{"Test Status Change", &o, &checkedOut, ActionStatusChange},
+		{"Test Delete", &o, &deleted, ActionDelete},
+		{"Test Restore", &deleted, &o, ActionRestore},
+		{"Test Purge", &deleted, nil, ActionPurge},
+	}
+
+	// test for each testing table
+	for _, test := range testTable {
+		result := GetAction(test.Before, test.After)
+		if result != test.ExpectedAction {
+			t.Errorf("[%s] Expected action %s, but got %s",
+				test.TestName, test.ExpectedAction, result)
+		}
+	}
+}
+
+// TestMemoryRepository test MemoryRepository
+func TestMemoryRepository(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemoryRepository()
+	o := getTestingOrder()
+	u := middleware.User{ID: 1, Role: "buyer"}
+
+	// insert entries of two orders
+	for _, orderNumber := range []string{"ORDER1", "ORDER2", "ORDER1"} {
+		o.OrderNumber = orderNumber
+		e, err := NewEntry(ActionUpdate, u, nil, &o)
+		if err != nil {
+			t.Fatalf("Expected error nil, but got %s", err)
+		}
+		err = r.Insert(ctx, e)
+		if err != nil {
+			t.Fatalf("Expected insert success, but got error %s", err)
+		}
+	}
+
+	entries, err := r.List(ctx, "ORDER1")
+	if err != nil {
+		t.Fatalf("Expected list success, but got error %s", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("Expected total entries 2, but got %d", len(entries))
+	}
+
+	entries, err = r.List(ctx, "ORDER3")
+	if err != nil {
+		t.Fatalf("Expected list success, but got error %s", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected total entries 0, but got %d", len(entries))
+	}
+}
@@ -0,0 +1,79 @@
+/*
+Package audit containing immutable audit trail of order changes
+*/
+package audit
+
+import (
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryRepository thread-safe audit entry repository stored in memory,
+// mostly used for testing
+type MemoryRepository struct {
+	mu      sync.RWMutex
+	entries []Entry
+}
+
+// NewMemoryRepository create empty in-memory audit entry repository
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{}
+}
+
+// Insert insert new audit entry to memory
+func (r *MemoryRepository) Insert(ctx context.Context, e Entry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e.ID = primitive.NewObjectID()
+	e, err := copyEntry(e)
+	if err != nil {
+		return err
+	}
+	r.entries = append(r.entries, e)
+
+	return nil
+}
+
+// List get all audit entries of order from memory sorted by time
+func (r *MemoryRepository) List(ctx context.Context,
+	orderNumber string) ([]Entry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entries := []Entry{}
+	for _, e := range r.entries {
+		if e.OrderNumber != orderNumber {
+			continue
+		}
+
+		e, err := copyEntry(e)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+// copyEntry deep copy audit entry so stored entry
+// can't be changed from outside the repository
+func copyEntry(e Entry) (Entry, error) {
+	result := Entry{}
+
+	doc, err := bson.Marshal(e)
+	if err != nil {
+		return result, err
+	}
+
+	err = bson.Unmarshal(doc, &result)
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,66 @@
+/*
+Package audit containing immutable audit trail of order changes
+*/
+package audit
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoRepository audit entry repository stored in mongodb collection
+type MongoRepository struct {
+	Collection *mongo.Collection
+}
+
+// NewMongoRepository create audit entry repository
+// using mongodb order history collection
+func NewMongoRepository(hc *mongo.Collection) *MongoRepository {
+	return &MongoRepository{Collection: hc}
+}
+
+// CreateIndexes create indexes of order history collection
+func (r *MongoRepository) CreateIndexes(ctx context.Context) error {
+	_, err := r.Collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			primitive.E{Key: "order_number", Value: 1},
+			primitive.E{Key: "created_at", Value: 1},
+		},
+	})
+
+	return err
+}
+
+// Insert insert new audit entry to order history collection
+func (r *MongoRepository) Insert(ctx context.Context, e Entry) error {
+	_, err := r.Collection.InsertOne(ctx, e)
+	return err
+}
+
+// List get all audit entries of order from order history collection
+// sorted by time
+func (r *MongoRepository) List(ctx context.Context,
+	orderNumber string) ([]Entry, error) {
+	entries := []Entry{}
+
+	cursor, err := r.Collection.Find(ctx,
+		bson.M{"order_number": orderNumber},
+		options.Find().SetSort(bson.D{
+			primitive.E{Key: "created_at", Value: 1},
+			primitive.E{Key: "_id", Value: 1},
+		}))
+	if err != nil {
+		return entries, err
+	}
+
+	err = cursor.All(ctx, &entries)
+	if err != nil {
+		return entries, err
+	}
+
+	return entries, nil
+}
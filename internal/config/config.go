@@ -4,9 +4,19 @@ Package config collection of configuration
 package config
 
 import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/reyhanfikridz/ecom-order-service/internal/money"
 )
 
 var (
@@ -15,30 +25,543 @@ var (
 	DBNameForAPITest   string
 	DBNameForModelTest string
 
+	// Port port the server listen to
+	Port int
+
 	FrontendURL       string
 	AccountServiceURL string
 	ProductServiceURL string
+
+	// AuthMode how token authorized (account-service or jwt)
+	AuthMode string
+
+	// AuthTimeout max time of each token authorization
+	// request to account service
+	AuthTimeout time.Duration
+
+	// AuthCacheTTL and AuthCacheSize time and max number of users
+	// authorized by account service cached, users not cached
+	// if one of them is zero
+	AuthCacheTTL  time.Duration
+	AuthCacheSize int
+
+	// AuthFailureThreshold consecutive failed token authorizations before
+	// account service considered down, and AuthOpenTimeout time until
+	// it's called again
+	AuthFailureThreshold int
+	AuthOpenTimeout      time.Duration
+
+	// JWTSecret shared secret of HS256 JWT verified locally
+	JWTSecret string
+
+	// JWTJWKS file path or URL of JWKS containing public keys
+	// of RS256/ES256 JWT verified locally
+	JWTJWKS string
+
+	// JWTIssuer and JWTAudience issuer and audience JWT must have,
+	// required by jwt auth mode
+	JWTIssuer   string
+	JWTAudience string
+
+	// JWTLeeway time allowed for clock skew when checking JWT expiration
+	JWTLeeway time.Duration
+
+	// ServiceKeys API keys of internal services and their scopes,
+	// each service formatted as "service:key:scope1,scope2"
+	// and separated by ";"
+	ServiceKeys string
+
+	// PolicyFile JSON file of permission matrix,
+	// default permission matrix used if empty
+	PolicyFile string
+
+	// ProductServiceToken token used to access product service
+	// when there's no user token, e.g. when releasing expired reservation
+	ProductServiceToken string
+
+	// OrderNumberFormat format of new order number
+	// (random, sortable, or prefixed)
+	OrderNumberFormat string
+
+	// OrderNumberPrefix prefix of order number with prefixed format
+	OrderNumberPrefix string
+
+	// Currency ISO 4217 code of currency new orders priced in,
+	// the same as currency of product service prices,
+	// only currency with 2 decimal places supported
+	Currency string
+
+	// ReservationTTL max time order items stock reserved
+	// before the checked out order cancelled
+	ReservationTTL time.Duration
+
+	// IdempotencyTTL time response of request with idempotency key stored
+	IdempotencyTTL time.Duration
+
+	// DeletedOrderRetention time deleted order kept before purged
+	DeletedOrderRetention time.Duration
+
+	// OutboxPublisher publisher of order events (log or file)
+	OutboxPublisher string
+
+	// OutboxFile path of file order events appended to by file publisher
+	OutboxFile string
+
+	// OutboxRelayInterval interval of publishing order events in outbox
+	OutboxRelayInterval time.Duration
+
+	// WebhookDeliveryInterval interval of sending pending webhook deliveries
+	WebhookDeliveryInterval time.Duration
+)
+
+const (
+	// envPrefix prefix of environment variable of each setting
+	envPrefix = "ECOM_ORDER_SERVICE_"
+
+	// configFileEnv and configFileFlag environment variable
+	// and command-line flag of config file path
+	configFileEnv  = envPrefix + "CONFIG_FILE"
+	configFileFlag = "config"
+
+	// authModeAccountService and authModeJWT authorization modes,
+	// authorizing token by account service or verifying it locally as JWT
+	authModeAccountService = "account-service"
+	authModeJWT            = "jwt"
 )
 
-// InitConfig initialize all config variable from environment variable
-func InitConfig() error {
-	// load all values from .env file into the system
-	// .env file must be at root directory (same level as go.mod file)
-	err := godotenv.Load(os.ExpandEnv(
-		"$GOPATH/src/github.com/reyhanfikridz/ecom-order-service/.env"))
+// setting one config variable, set from its default, config file,
+// environment variable, then command-line flag, latter source
+// overriding the former, empty value considered not set
+type setting struct {
+	// Key name of setting in config file, its environment variable
+	// is the key in upper case prefixed by ECOM_ORDER_SERVICE_,
+	// and its command-line flag is the key with "-" instead of "_"
+	Key string
+
+	Default  string
+	Required bool
+	Usage    string
+
+	// Set parse value into the config variable
+	Set func(value string) error
+}
+
+// getSettings get all settings of config variables
+func getSettings() []setting {
+	return []setting{
+		{Key: "db_uri", Required: true, Usage: "mongodb URI",
+			Set: setString(&DBURI)},
+		{Key: "db_name", Required: true, Usage: "mongodb database name",
+			Set: setString(&DBName)},
+		{Key: "db_name_for_api_test",
+			Usage: "mongodb database name of API test",
+			Set:   setString(&DBNameForAPITest)},
+		{Key: "db_name_for_model_test",
+			Usage: "mongodb database name of model test",
+			Set:   setString(&DBNameForModelTest)},
+
+		{Key: "port", Default: "8030", Usage: "port the server listen to",
+			Set: setPort(&Port)},
+
+		{Key: "frontend_url", Required: true, Usage: "frontend URL",
+			Set: setString(&FrontendURL)},
+		{Key: "account_service_url",
+			Usage: "account service URL, required by account-service auth mode",
+			Set:   setString(&AccountServiceURL)},
+		{Key: "product_service_url", Required: true,
+			Usage: "product service URL", Set: setString(&ProductServiceURL)},
+		{Key: "product_service_token", Required: true,
+			Usage: "token used to access product service without user token",
+			Set:   setString(&ProductServiceToken)},
+
+		{Key: "auth_mode",
+			Usage: "how token authorized (account-service or jwt)",
+			Set:   setOneOf(&AuthMode, authModeAccountService, authModeJWT)},
+		{Key: "auth_timeout", Default: "5s",
+			Usage: "max time of each token authorization by account service",
+			Set:   setPositiveDuration(&AuthTimeout)},
+		{Key: "auth_cache_ttl", Default: "1m",
+			Usage: "time user authorized by account service cached",
+			Set:   setDuration(&AuthCacheTTL)},
+		{Key: "auth_cache_size", Default: "10000",
+			Usage: "max number of users authorized by account service cached",
+			Set:   setInt(&AuthCacheSize, 0)},
+		{Key: "auth_failure_threshold", Default: "5",
+			Usage: "consecutive failures before account service considered down",
+			Set:   setInt(&AuthFailureThreshold, 1)},
+		{Key: "auth_open_timeout", Default: "30s",
+			Usage: "time until account service considered down called again",
+			Set:   setPositiveDuration(&AuthOpenTimeout)},
+
+		{Key: "jwt_secret", Usage: "shared secret of HS256 JWT",
+			Set: setString(&JWTSecret)},
+		{Key: "jwt_jwks", Usage: "file path or URL of JWKS of RS256/ES256 JWT",
+			Set: setString(&JWTJWKS)},
+		{Key: "jwt_issuer", Usage: "issuer JWT must have",
+			Set: setString(&JWTIssuer)},
+		{Key: "jwt_audience", Usage: "audience JWT must have",
+			Set: setString(&JWTAudience)},
+		{Key: "jwt_leeway", Default: "1m",
+			Usage: "time allowed for clock skew when checking JWT expiration",
+			Set:   setDuration(&JWTLeeway)},
+
+		{Key: "service_keys",
+			Usage: "API keys of internal services (service:key:scope1,scope2;...)",
+			Set:   setString(&ServiceKeys)},
+		{Key: "policy_file", Usage: "JSON file of permission matrix",
+			Set: setString(&PolicyFile)},
+
+		{Key: "order_number_format",
+			Usage: "format of new order number (random, sortable, or prefixed)",
+			Set:   setString(&OrderNumberFormat)},
+		{Key: "order_number_prefix",
+			Usage: "prefix of order number with prefixed format",
+			Set:   setString(&OrderNumberPrefix)},
+		{Key: "currency", Default: "IDR",
+			Usage: "ISO 4217 code of 2-decimal currency new orders priced in",
+			Set:   setCurrency(&Currency)},
+
+		{Key: "reservation_ttl", Default: "30m",
+			Usage: "max time order items stock reserved",
+			Set:   setPositiveDuration(&ReservationTTL)},
+		{Key: "idempotency_ttl", Default: "24h",
+			Usage: "time response of request with idempotency key stored",
+			Set:   setPositiveDuration(&IdempotencyTTL)},
+		{Key: "deleted_order_retention", Default: "720h",
+			Usage: "time deleted order kept before purged",
+			Set:   setPositiveDuration(&DeletedOrderRetention)},
+
+		{Key: "outbox_publisher",
+			Usage: "publisher of order events (log or file)",
+			Set:   setString(&OutboxPublisher)},
+		{Key: "outbox_file",
+			Usage: "path of file order events appended to by file publisher",
+			Set:   setString(&OutboxFile)},
+		{Key: "outbox_relay_interval", Default: "5s",
+			Usage: "interval of publishing order events in outbox",
+			Set:   setPositiveDuration(&OutboxRelayInterval)},
+		{Key: "webhook_delivery_interval", Default: "5s",
+			Usage: "interval of sending pending webhook deliveries",
+			Set:   setPositiveDuration(&WebhookDeliveryInterval)},
+	}
+}
+
+// InitConfig initialize all config variable from defaults,
+// config file, environment variable, then command-line flags args
+//
+// config file path set by -config flag or ECOM_ORDER_SERVICE_CONFIG_FILE,
+// every missing and invalid setting returned at once
+func InitConfig(args []string) error {
+	settings := getSettings()
+
+	// define command-line flags, parsed first to get config file path
+	flags := flag.NewFlagSet("ecom-order-service", flag.ContinueOnError)
+	configFile := flags.String(configFileFlag, "",
+		"config file path (.json, or KEY=value per line like .env, "+
+			"YAML and TOML not supported)")
+	for _, s := range settings {
+		flags.String(s.flagName(), "", s.Usage)
+	}
+	err := flags.Parse(args)
 	if err != nil {
 		return err
 	}
 
-	// set all config variable after all environment variable loaded
-	DBURI = os.Getenv("ECOM_ORDER_SERVICE_DB_URI")
-	DBName = os.Getenv("ECOM_ORDER_SERVICE_DB_NAME")
-	DBNameForAPITest = os.Getenv("ECOM_ORDER_SERVICE_DB_NAME_FOR_API_TEST")
-	DBNameForModelTest = os.Getenv("ECOM_ORDER_SERVICE_DB_NAME_FOR_MODEL_TEST")
+	// get value of each setting from defaults
+	values := map[string]string{}
+	for _, s := range settings {
+		values[s.Key] = s.Default
+	}
+
+	// override by config file
+	if *configFile == "" {
+		*configFile = os.Getenv(configFileEnv)
+	}
+	if *configFile != "" {
+		fileValues, err := readConfigFile(*configFile)
+		if err != nil {
+			return fmt.Errorf("config file %s invalid => %w", *configFile, err)
+		}
+
+		problems := []string{}
+		for key, value := range fileValues {
+			if _, ok := values[key]; !ok {
+				problems = append(problems, fmt.Sprintf("key '%s' unknown", key))
+				continue
+			}
+			if value != "" {
+				values[key] = value
+			}
+		}
+		if len(problems) > 0 {
+			sort.Strings(problems)
+			return fmt.Errorf("config file %s invalid => %s", *configFile,
+				strings.Join(problems, "; "))
+		}
+	}
+
+	// override by environment variable
+	for _, s := range settings {
+		if os.Getenv(s.envName()) != "" {
+			values[s.Key] = os.Getenv(s.envName())
+		}
+	}
+
+	// override by command-line flags
+	flags.Visit(func(f *flag.Flag) {
+		key := strings.ReplaceAll(f.Name, "-", "_")
+		if _, ok := values[key]; ok && f.Value.String() != "" {
+			values[key] = f.Value.String()
+		}
+	})
+
+	// set all config variable, every missing and invalid setting
+	// collected in settings order
+	problems := []string{}
+	for _, s := range settings {
+		if values[s.Key] == "" && s.Required {
+			problems = append(problems,
+				fmt.Sprintf("%s missing", s.describe()))
+		}
+
+		err = s.Set(values[s.Key])
+		if err != nil {
+			problems = append(problems,
+				fmt.Sprintf("%s invalid => %s", s.describe(), err))
+		}
+	}
+
+	// check settings required by auth mode
+	settingOf := map[string]setting{}
+	for _, s := range settings {
+		settingOf[s.Key] = s
+	}
+	switch AuthMode {
+	case authModeJWT:
+		if JWTSecret == "" && JWTJWKS == "" {
+			problems = append(problems, "jwt_secret or jwt_jwks missing, "+
+				"one of them required by jwt auth mode")
+		}
+		if JWTIssuer == "" {
+			problems = append(problems, fmt.Sprintf("%s missing, "+
+				"required by jwt auth mode", settingOf["jwt_issuer"].describe()))
+		}
+		if JWTAudience == "" {
+			problems = append(problems, fmt.Sprintf("%s missing, "+
+				"required by jwt auth mode", settingOf["jwt_audience"].describe()))
+		}
+	case "", authModeAccountService:
+		if AccountServiceURL == "" {
+			problems = append(problems, fmt.Sprintf("%s missing, "+
+				"required by account-service auth mode",
+				settingOf["account_service_url"].describe()))
+		}
+	}
 
-	FrontendURL = os.Getenv("ECOM_ORDER_SERVICE_FRONTEND_URL")
-	AccountServiceURL = os.Getenv("ECOM_ORDER_SERVICE_ACCOUNT_SERVICE_URL")
-	ProductServiceURL = os.Getenv("ECOM_ORDER_SERVICE_PRODUCT_SERVICE_URL")
+	if len(problems) > 0 {
+		return fmt.Errorf("config invalid => %s", strings.Join(problems, "; "))
+	}
 
 	return nil
 }
+
+// envName get environment variable of setting
+func (s setting) envName() string {
+	return envPrefix + strings.ToUpper(s.Key)
+}
+
+// flagName get command-line flag of setting
+func (s setting) flagName() string {
+	return strings.ReplaceAll(s.Key, "_", "-")
+}
+
+// describe get setting key, environment variable, and command-line flag
+func (s setting) describe() string {
+	return fmt.Sprintf("%s (%s, -%s)", s.Key, s.envName(), s.flagName())
+}
+
+// readConfigFile read value of each setting from config file,
+// JSON object if the file is .json, otherwise KEY=value (or key: value)
+// per line like .env file, key is the setting key or its environment variable
+//
+// YAML and TOML file rejected by its extension, since nested or typed
+// values of them can't be read as .env file
+func readConfigFile(path string) (map[string]string, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	switch ext {
+	case ".yaml", ".yml", ".toml":
+		return nil, fmt.Errorf("format %s not supported, "+
+			"use .json or .env file", ext)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rawValues := map[string]string{}
+	if ext == ".json" {
+		jsonValues := map[string]interface{}{}
+		err = json.Unmarshal(b, &jsonValues)
+		if err != nil {
+			return nil, err
+		}
+
+		for key, value := range jsonValues {
+			switch v := value.(type) {
+			case nil:
+				rawValues[key] = ""
+			case string:
+				rawValues[key] = v
+			case float64:
+				rawValues[key] = strconv.FormatFloat(v, 'f', -1, 64)
+			case bool:
+				rawValues[key] = strconv.FormatBool(v)
+			default:
+				return nil, fmt.Errorf("value of '%s' must be string, "+
+					"number, or boolean", key)
+			}
+		}
+	} else {
+		rawValues, err = godotenv.Parse(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// normalize environment variable into setting key
+	values := map[string]string{}
+	for key, value := range rawValues {
+		key = strings.TrimPrefix(strings.TrimSpace(key), envPrefix)
+		values[strings.ToLower(key)] = value
+	}
+
+	return values, nil
+}
+
+// setString set string config variable
+func setString(p *string) func(value string) error {
+	return func(value string) error {
+		*p = value
+		return nil
+	}
+}
+
+// setOneOf set string config variable, value must be one of values
+// or empty
+func setOneOf(p *string, values ...string) func(value string) error {
+	return func(value string) error {
+		*p = value
+		if value == "" {
+			return nil
+		}
+
+		for _, v := range values {
+			if value == v {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("'%s' must be one of %s", value,
+			strings.Join(values, ", "))
+	}
+}
+
+// setDuration set duration config variable, value must be
+// parseable by time.ParseDuration, e.g. 5s or 30m, and not negative
+func setDuration(p *time.Duration) func(value string) error {
+	return func(value string) error {
+		*p = 0
+		if value == "" {
+			return nil
+		}
+
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		if d < 0 {
+			return fmt.Errorf("must not be negative")
+		}
+		*p = d
+
+		return nil
+	}
+}
+
+// setPositiveDuration set duration config variable, value must be
+// parseable by time.ParseDuration and more than 0, used by interval
+// and timeout which can't be 0
+func setPositiveDuration(p *time.Duration) func(value string) error {
+	return func(value string) error {
+		*p = 0
+		if value == "" {
+			return fmt.Errorf("must be more than 0")
+		}
+
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		if d <= 0 {
+			return fmt.Errorf("must be more than 0")
+		}
+		*p = d
+
+		return nil
+	}
+}
+
+// setInt set integer config variable, value must be at least min
+func setInt(p *int, min int) func(value string) error {
+	return func(value string) error {
+		*p = 0
+		if value == "" {
+			return nil
+		}
+
+		i, err := strconv.Atoi(value)
+		if err != nil || i < min {
+			return fmt.Errorf("must be integer at least %d", min)
+		}
+		*p = i
+
+		return nil
+	}
+}
+
+// setPort set port config variable, value must be between 1 and 65535
+func setPort(p *int) func(value string) error {
+	return func(value string) error {
+		*p = 0
+		if value == "" {
+			return nil
+		}
+
+		port, err := strconv.Atoi(value)
+		if err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("must be integer between 1 and 65535")
+		}
+		*p = port
+
+		return nil
+	}
+}
+
+// setCurrency set currency config variable, value must be ISO 4217 code
+// of currency with money.Scale decimal places
+func setCurrency(p *string) func(value string) error {
+	return func(value string) error {
+		*p = value
+		if value != "" && !money.IsCurrencyValid(value) {
+			return fmt.Errorf("'%s' is not ISO 4217 code", value)
+		}
+		if value != "" && !money.IsCurrencySupported(value) {
+			return fmt.Errorf("'%s' has %d decimal places, only currency "+
+				"with %d decimal places supported", value,
+				money.GetMinorUnitExponent(value), money.Scale)
+		}
+
+		return nil
+	}
+}
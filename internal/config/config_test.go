@@ -3,13 +3,272 @@ Package config collection of configuration
 */
 package config
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
 
-// TestInitConfig test InitConfig
+// clearTestingEnv unset environment variable of every setting
+// and config file path until the test finished
+func clearTestingEnv(t *testing.T) {
+	t.Setenv(configFileEnv, "")
+	for _, s := range getSettings() {
+		t.Setenv(s.envName(), "")
+	}
+}
+
+// writeTestingConfigFile write config file named name in temporary directory
+func writeTestingConfigFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatalf("There's an error when writing config file => %s", err)
+	}
+
+	return path
+}
+
+// TestInitConfig test InitConfig set config from defaults, config file,
+// environment variable, then command-line flags
 func TestInitConfig(t *testing.T) {
-	err := InitConfig()
+	clearTestingEnv(t)
+	path := writeTestingConfigFile(t, "config.json", `{
+		"db_uri": "mongodb://localhost:27017",
+		"db_name": "file_db",
+		"port": 9000,
+		"frontend_url": "http://localhost:3000",
+		"account_service_url": "http://localhost:8010",
+		"product_service_url": "http://localhost:8020",
+		"product_service_token": "token",
+		"auth_timeout": "10s",
+		"jwt_secret": null
+	}`)
+	t.Setenv(configFileEnv, path)
+	t.Setenv("ECOM_ORDER_SERVICE_DB_NAME", "env_db")
+	t.Setenv("ECOM_ORDER_SERVICE_AUTH_TIMEOUT", "15s")
+
+	err := InitConfig([]string{"-port", "9100", "-auth-timeout", "20s"})
+	if err != nil {
+		t.Fatalf("Expected initialize config success, but failed => %s", err)
+	}
+
+	// initialize testing table
+	testTable := []struct {
+		TestName      string
+		Value         interface{}
+		ExpectedValue interface{}
+	}{
+		{"Test Config File", DBURI, "mongodb://localhost:27017"},
+		{"Test Environment Variable", DBName, "env_db"},
+		{"Test Flag", Port, 9100},
+		{"Test Flag Over Environment Variable", AuthTimeout, 20 * time.Second},
+		{"Test Default", Currency, "IDR"},
+		{"Test Default Duration", ReservationTTL, 30 * time.Minute},
+		{"Test Default Int", AuthCacheSize, 10000},
+	}
+
+	// test for each testing table
+	for _, test := range testTable {
+		if test.Value != test.ExpectedValue {
+			t.Errorf("[%s] Expected value %v, but got %v", test.TestName,
+				test.ExpectedValue, test.Value)
+		}
+	}
+}
+
+// TestInitConfigFlagConfigFile test InitConfig read .env like config file
+// from -config flag instead of environment variable
+func TestInitConfigFlagConfigFile(t *testing.T) {
+	clearTestingEnv(t)
+	envPath := writeTestingConfigFile(t, "config.json", `{"db_name": "env"}`)
+	t.Setenv(configFileEnv, envPath)
+
+	path := writeTestingConfigFile(t, ".env", strings.Join([]string{
+		"ECOM_ORDER_SERVICE_DB_URI=mongodb://localhost:27017",
+		"ECOM_ORDER_SERVICE_DB_NAME=flag",
+		"frontend_url: http://localhost:3000",
+		"ECOM_ORDER_SERVICE_PRODUCT_SERVICE_URL=http://localhost:8020",
+		"ECOM_ORDER_SERVICE_PRODUCT_SERVICE_TOKEN=token",
+		"ECOM_ORDER_SERVICE_AUTH_MODE=jwt",
+		"ECOM_ORDER_SERVICE_JWT_SECRET=secret",
+		"ECOM_ORDER_SERVICE_JWT_ISSUER=https://account.example.com",
+		"ECOM_ORDER_SERVICE_JWT_AUDIENCE=ecom-order-service",
+	}, "\n"))
+
+	err := InitConfig([]string{"-config", path})
 	if err != nil {
-		t.Errorf("Expected initialize config success, but failed => %s",
-			err.Error())
+		t.Fatalf("Expected initialize config success, but failed => %s", err)
+	}
+	if DBName != "flag" || FrontendURL != "http://localhost:3000" ||
+		JWTSecret != "secret" || Port != 8030 {
+		t.Errorf("Expected config from .env file, but got db name %s, "+
+			"frontend URL %s, JWT secret %s, port %d", DBName, FrontendURL,
+			JWTSecret, Port)
+	}
+}
+
+// TestInitConfigInvalid test InitConfig return every missing
+// and invalid setting at once
+func TestInitConfigInvalid(t *testing.T) {
+	// initialize testing table
+	testTable := []struct {
+		TestName       string
+		ConfigFile     string
+		ConfigFileName string
+		Env            map[string]string
+		Args           []string
+		ExpectedErrors []string
+	}{
+		{
+			TestName: "Test Missing All",
+			ExpectedErrors: []string{
+				"db_uri (ECOM_ORDER_SERVICE_DB_URI, -db-uri) missing",
+				"db_name (ECOM_ORDER_SERVICE_DB_NAME, -db-name) missing",
+				"frontend_url (ECOM_ORDER_SERVICE_FRONTEND_URL, -frontend-url) " +
+					"missing",
+				"product_service_url (ECOM_ORDER_SERVICE_PRODUCT_SERVICE_URL, " +
+					"-product-service-url) missing",
+				"product_service_token " +
+					"(ECOM_ORDER_SERVICE_PRODUCT_SERVICE_TOKEN, " +
+					"-product-service-token) missing",
+				"account_service_url (ECOM_ORDER_SERVICE_ACCOUNT_SERVICE_URL, " +
+					"-account-service-url) missing",
+			},
+		},
+		{
+			TestName: "Test Missing JWT Key",
+			Env: map[string]string{
+				"ECOM_ORDER_SERVICE_DB_URI":              "mongodb://localhost",
+				"ECOM_ORDER_SERVICE_DB_NAME":             "db",
+				"ECOM_ORDER_SERVICE_FRONTEND_URL":        "http://localhost",
+				"ECOM_ORDER_SERVICE_PRODUCT_SERVICE_URL": "http://localhost",
+				"ECOM_ORDER_SERVICE_AUTH_MODE":           "jwt",
+			},
+			ExpectedErrors: []string{
+				"jwt_secret or jwt_jwks missing",
+				"jwt_issuer (ECOM_ORDER_SERVICE_JWT_ISSUER, -jwt-issuer) missing",
+				"jwt_audience (ECOM_ORDER_SERVICE_JWT_AUDIENCE, -jwt-audience) " +
+					"missing",
+			},
+		},
+		{
+			TestName: "Test Invalid Values",
+			Env: map[string]string{
+				"ECOM_ORDER_SERVICE_AUTH_TIMEOUT": "5 seconds",
+				"ECOM_ORDER_SERVICE_CURRENCY":     "XXXX",
+			},
+			Args: []string{"-port", "70000", "-auth-failure-threshold", "0"},
+			ExpectedErrors: []string{
+				"auth_timeout (ECOM_ORDER_SERVICE_AUTH_TIMEOUT, -auth-timeout) " +
+					"invalid",
+				"currency (ECOM_ORDER_SERVICE_CURRENCY, -currency) invalid",
+				"port (ECOM_ORDER_SERVICE_PORT, -port) invalid",
+				"auth_failure_threshold (ECOM_ORDER_SERVICE_AUTH_FAILURE_THRESHOLD, " +
+					"-auth-failure-threshold) invalid",
+				"db_uri (ECOM_ORDER_SERVICE_DB_URI, -db-uri) missing",
+			},
+		},
+		{
+			TestName: "Test Duration Not Positive",
+			Env: map[string]string{
+				"ECOM_ORDER_SERVICE_OUTBOX_RELAY_INTERVAL":     "0s",
+				"ECOM_ORDER_SERVICE_WEBHOOK_DELIVERY_INTERVAL": "-1s",
+				"ECOM_ORDER_SERVICE_JWT_LEEWAY":                "-1m",
+			},
+			ConfigFile: `{"reservation_ttl": "-1h", "auth_timeout": "0"}`,
+			ExpectedErrors: []string{
+				"auth_timeout (ECOM_ORDER_SERVICE_AUTH_TIMEOUT, -auth-timeout) " +
+					"invalid => must be more than 0",
+				"jwt_leeway (ECOM_ORDER_SERVICE_JWT_LEEWAY, -jwt-leeway) " +
+					"invalid => must not be negative",
+				"reservation_ttl (ECOM_ORDER_SERVICE_RESERVATION_TTL, " +
+					"-reservation-ttl) invalid => must be more than 0",
+				"outbox_relay_interval (ECOM_ORDER_SERVICE_OUTBOX_RELAY_INTERVAL, " +
+					"-outbox-relay-interval) invalid => must be more than 0",
+				"webhook_delivery_interval " +
+					"(ECOM_ORDER_SERVICE_WEBHOOK_DELIVERY_INTERVAL, " +
+					"-webhook-delivery-interval) invalid => must be more than 0",
+			},
+		},
+		{
+			TestName: "Test Currency Not 2 Decimal Places",
+			Env: map[string]string{
+				"ECOM_ORDER_SERVICE_CURRENCY": "JPY",
+			},
+			ExpectedErrors: []string{
+				"currency (ECOM_ORDER_SERVICE_CURRENCY, -currency) invalid " +
+					"=> 'JPY' has 0 decimal places",
+			},
+		},
+		{
+			TestName:   "Test Config File Unknown Key",
+			ConfigFile: `{"db_uri": "mongodb://localhost", "db_url": "x"}`,
+			ExpectedErrors: []string{
+				"key 'db_url' unknown",
+			},
+		},
+		{
+			TestName:       "Test Config File Invalid Value",
+			ConfigFile:     `{"db_uri": ["mongodb://localhost"]}`,
+			ExpectedErrors: []string{"value of 'db_uri' must be string"},
+		},
+		{
+			TestName: "Test Invalid Auth Mode",
+			Env: map[string]string{
+				"ECOM_ORDER_SERVICE_AUTH_MODE": "oauth",
+			},
+			ExpectedErrors: []string{
+				"auth_mode (ECOM_ORDER_SERVICE_AUTH_MODE, -auth-mode) invalid " +
+					"=> 'oauth' must be one of account-service, jwt",
+			},
+		},
+		{
+			TestName:       "Test Config File YAML",
+			ConfigFile:     "db_uri: mongodb://localhost",
+			ConfigFileName: "config.yaml",
+			ExpectedErrors: []string{"format .yaml not supported"},
+		},
+		{
+			TestName:       "Test Config File TOML",
+			ConfigFile:     `db_uri = "mongodb://localhost"`,
+			ConfigFileName: "config.toml",
+			ExpectedErrors: []string{"format .toml not supported"},
+		},
+		{
+			TestName:       "Test Unknown Flag",
+			Args:           []string{"-db-url", "mongodb://localhost"},
+			ExpectedErrors: []string{"flag provided but not defined: -db-url"},
+		},
+	}
+
+	// test for each testing table
+	for _, test := range testTable {
+		clearTestingEnv(t)
+		if test.ConfigFile != "" {
+			name := test.ConfigFileName
+			if name == "" {
+				name = "config.json"
+			}
+			t.Setenv(configFileEnv,
+				writeTestingConfigFile(t, name, test.ConfigFile))
+		}
+		for key, value := range test.Env {
+			t.Setenv(key, value)
+		}
+
+		err := InitConfig(test.Args)
+		if err == nil {
+			t.Errorf("[%s] Expected error, but got nil", test.TestName)
+			continue
+		}
+		for _, expectedError := range test.ExpectedErrors {
+			if !strings.Contains(err.Error(), expectedError) {
+				t.Errorf("[%s] Expected error contain '%s', but got '%s'",
+					test.TestName, expectedError, err)
+			}
+		}
 	}
 }
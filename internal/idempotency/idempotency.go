@@ -0,0 +1,165 @@
+/*
+Package idempotency containing storage of request idempotency keys
+and their stored responses
+*/
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrRequestInProgress returned when request with the same key
+	// is still being processed
+	ErrRequestInProgress = errors.New(
+		"request with the same idempotency key still in progress")
+
+	// ErrRequestMismatch returned when request with the same key
+	// has different request body
+	ErrRequestMismatch = errors.New(
+		"idempotency key already used for different request")
+)
+
+// Response stored response of request
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Store storage of idempotency keys
+type Store interface {
+	// Begin start request with the key and hash of the request,
+	// return stored response if request with the same key already completed,
+	// nil if request can be processed, ErrRequestInProgress
+	// if it's still being processed, or ErrRequestMismatch
+	// if the key used for request with different hash
+	Begin(ctx context.Context, key string, requestHash string) (*Response, error)
+
+	// Complete store response of request with the key
+	Complete(ctx context.Context, key string, resp Response) error
+
+	// Abort remove request with the key, so it can be processed again
+	Abort(ctx context.Context, key string) error
+}
+
+// memoryCleanupInterval min time between removal of expired records
+// in memory, so not every request iterate all records
+const memoryCleanupInterval = time.Minute
+
+// memoryRecord record of request stored in memory
+type memoryRecord struct {
+	RequestHash string
+	Response    *Response
+	ExpiredAt   time.Time
+}
+
+// MemoryStore thread-safe idempotency key storage stored in memory,
+// each key stored until the TTL passed since the request began
+//
+// keys only stored per process, so MongoStore must be used
+// when the service run with more than one instance
+type MemoryStore struct {
+	TTL time.Duration
+
+	// Now get current time, default to time.Now
+	Now func() time.Time
+
+	mu        sync.Mutex
+	records   map[string]memoryRecord
+	cleanedAt time.Time
+}
+
+// NewMemoryStore create empty in-memory idempotency key storage
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{
+		TTL:     ttl,
+		Now:     time.Now,
+		records: make(map[string]memoryRecord),
+	}
+}
+
+// Begin start request with the key in memory
+func (s *MemoryStore) Begin(ctx context.Context, key string,
+	requestHash string) (*Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.Now()
+	s.removeExpired(now)
+
+	record, ok := s.records[key]
+	if !ok || !now.Before(record.ExpiredAt) {
+		s.records[key] = memoryRecord{
+			RequestHash: requestHash,
+			ExpiredAt:   now.Add(s.TTL),
+		}
+		return nil, nil
+	}
+
+	if record.RequestHash != requestHash {
+		return nil, ErrRequestMismatch
+	}
+	if record.Response == nil {
+		return nil, ErrRequestInProgress
+	}
+
+	return copyResponse(*record.Response), nil
+}
+
+// Complete store response of request with the key in memory
+func (s *MemoryStore) Complete(ctx context.Context, key string,
+	resp Response) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[key]
+	if !ok {
+		return nil
+	}
+	record.Response = copyResponse(resp)
+	s.records[key] = record
+
+	return nil
+}
+
+// Abort remove request with the key from memory
+func (s *MemoryStore) Abort(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+
+	return nil
+}
+
+// removeExpired remove all expired records, at most once
+// per memoryCleanupInterval
+//
+// caller must hold the lock
+func (s *MemoryStore) removeExpired(now time.Time) {
+	if now.Sub(s.cleanedAt) < memoryCleanupInterval {
+		return
+	}
+	s.cleanedAt = now
+
+	for key, record := range s.records {
+		if !now.Before(record.ExpiredAt) {
+			delete(s.records, key)
+		}
+	}
+}
+
+// copyResponse deep copy response so stored response
+// can't be changed from outside the storage
+func copyResponse(resp Response) *Response {
+	return &Response{
+		Status: resp.Status,
+		Header: resp.Header.Clone(),
+		Body:   append([]byte{}, resp.Body...),
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Package idempotency containing storage of request idempotency keys
+and their stored responses
+*/
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestMemoryStore test MemoryStore
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)
+	s := NewMemoryStore(time.Hour)
+	s.Now = func() time.Time { return now }
+
+	resp := Response{
+		Status: http.StatusCreated,
+		Header: http.Header{"Content-Type": []string{"application/json"}},
+		Body:   []byte(`{"order_number":"ORDER1"}`),
+	}
+
+	// initialize testing table, run in order
+	testTable := []struct {
+		TestName         string
+		Action           func() (*Response, error)
+		ExpectedError    error
+		ExpectedResponse bool
+	}{
+		{
+			TestName: "Test Begin New Key",
+			Action: func() (*Response, error) {
+				return s.Begin(ctx, "1:key", "hash")
+			},
+		},
+		{
+			TestName: "Test Begin In Progress",
+			Action: func() (*Response, error) {
+				return s.Begin(ctx, "1:key", "hash")
+			},
+			ExpectedError: ErrRequestInProgress,
+		},
+		{
+			TestName: "Test Begin Different Request",
+			Action: func() (*Response, error) {
+				return s.Begin(ctx, "1:key", "other hash")
+			},
+			ExpectedError: ErrRequestMismatch,
+		},
+		{
+			TestName: "Test Begin Completed",
+			Action: func() (*Response, error) {
+				s.Complete(ctx, "1:key", resp)
+				return s.Begin(ctx, "1:key", "hash")
+			},
+			ExpectedResponse: true,
+		},
+		{
+			TestName: "Test Begin Other User Key",
+			Action: func() (*Response, error) {
+				return s.Begin(ctx, "2:key", "other hash")
+			},
+		},
+		{
+			TestName: "Test Begin Aborted",
+			Action: func() (*Response, error) {
+				s.Abort(ctx, "2:key")
+				return s.Begin(ctx, "2:key", "hash")
+			},
+		},
+		{
+			TestName: "Test Begin Expired",
+			Action: func() (*Response, error) {
+				now = now.Add(time.Hour)
+				return s.Begin(ctx, "1:key", "other hash")
+			},
+		},
+	}
+
+	// test for each testing table
+	for _, test := range testTable {
+		result, err := test.Action()
+		if err != test.ExpectedError {
+			t.Errorf("[%s] Expected error %v, but got %v",
+				test.TestName, test.ExpectedError, err)
+		}
+
+		if !test.ExpectedResponse {
+			if result != nil {
+				t.Errorf("[%s] Expected no stored response, but got %+v",
+					test.TestName, result)
+			}
+			continue
+		}
+
+		if result == nil {
+			t.Errorf("[%s] Expected stored response, but got nil", test.TestName)
+			continue
+		}
+		if result.Status != resp.Status ||
+			string(result.Body) != string(resp.Body) ||
+			result.Header.Get("Content-Type") != resp.Header.Get("Content-Type") {
+			t.Errorf("[%s] Expected response %+v, but got %+v",
+				test.TestName, resp, result)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+/*
+Package idempotency containing storage of request idempotency keys
+and their stored responses
+*/
+package idempotency
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoRecord record of request stored in mongodb collection
+type mongoRecord struct {
+	Key         string    `bson:"_id"`
+	RequestHash string    `bson:"request_hash"`
+	Response    *Response `bson:"response,omitempty"`
+	ExpiresAt   time.Time `bson:"expires_at"`
+}
+
+// MongoStore idempotency key storage stored in mongodb collection,
+// shared by every instance of the service
+//
+// each key stored until the TTL passed since the request began,
+// expired key removed by TTL index of the collection
+type MongoStore struct {
+	Collection *mongo.Collection
+	TTL        time.Duration
+
+	// Now get current time, default to time.Now
+	Now func() time.Time
+}
+
+// NewMongoStore create idempotency key storage
+// using mongodb idempotency keys collection
+func NewMongoStore(ic *mongo.Collection, ttl time.Duration) *MongoStore {
+	return &MongoStore{Collection: ic, TTL: ttl, Now: time.Now}
+}
+
+// CreateIndexes create TTL index of idempotency keys collection,
+// so key removed by mongodb after it expired
+func (s *MongoStore) CreateIndexes(ctx context.Context) error {
+	_, err := s.Collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{primitive.E{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+
+	return err
+}
+
+// Begin start request with the key in idempotency keys collection
+func (s *MongoStore) Begin(ctx context.Context, key string,
+	requestHash string) (*Response, error) {
+	now := s.Now()
+	_, err := s.Collection.InsertOne(ctx, mongoRecord{
+		Key:         key,
+		RequestHash: requestHash,
+		ExpiresAt:   now.Add(s.TTL),
+	})
+	if err == nil {
+		return nil, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil, err
+	}
+
+	// key already used, begin the request again if the key expired
+	// but not removed yet by mongodb
+	result, err := s.Collection.UpdateOne(ctx,
+		bson.M{"_id": key, "expires_at": bson.M{"$lte": now}},
+		bson.M{
+			"$set": bson.M{
+				"request_hash": requestHash,
+				"expires_at":   now.Add(s.TTL),
+			},
+			"$unset": bson.M{"response": ""},
+		})
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount > 0 {
+		return nil, nil
+	}
+
+	record := mongoRecord{}
+	err = s.Collection.FindOne(ctx, bson.M{"_id": key}).Decode(&record)
+	if err == mongo.ErrNoDocuments {
+		// request with the key aborted in the meantime
+		return nil, ErrRequestInProgress
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if record.RequestHash != requestHash {
+		return nil, ErrRequestMismatch
+	}
+	if record.Response == nil {
+		return nil, ErrRequestInProgress
+	}
+
+	return record.Response, nil
+}
+
+// Complete store response of request with the key
+// in idempotency keys collection
+func (s *MongoStore) Complete(ctx context.Context, key string,
+	resp Response) error {
+	_, err := s.Collection.UpdateOne(ctx, bson.M{"_id": key},
+		bson.M{"$set": bson.M{"response": resp}})
+
+	return err
+}
+
+// Abort remove request with the key from idempotency keys collection
+func (s *MongoStore) Abort(ctx context.Context, key string) error {
+	_, err := s.Collection.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
@@ -0,0 +1,92 @@
+/*
+Package middleware collection of middleware used for API
+*/
+package middleware
+
+import (
+	"sync"
+	"time"
+)
+
+// states of circuit breaker
+const (
+	breakerClosed = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// CircuitBreaker thread-safe circuit breaker of remote service calls
+//
+// the circuit opened after FailureThreshold consecutive failures,
+// then calls rejected until OpenTimeout passed, after that only one trial
+// call allowed, the circuit closed if it's success or opened again if not
+type CircuitBreaker struct {
+	FailureThreshold int
+	OpenTimeout      time.Duration
+
+	// Now get current time, default to time.Now
+	Now func() time.Time
+
+	mu       sync.Mutex
+	state    int
+	failures int
+	openedAt time.Time
+}
+
+// NewCircuitBreaker create closed circuit breaker
+func NewCircuitBreaker(failureThreshold int,
+	openTimeout time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		FailureThreshold: failureThreshold,
+		OpenTimeout:      openTimeout,
+		Now:              time.Now,
+	}
+}
+
+// Allow check if call allowed, if not return time until
+// the next trial call allowed
+func (b *CircuitBreaker) Allow() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		retryAfter := b.openedAt.Add(b.OpenTimeout).Sub(b.Now())
+		if retryAfter > 0 {
+			return false, retryAfter
+		}
+		b.state = breakerHalfOpen
+		return true, 0
+
+	case breakerHalfOpen:
+		// trial call still in progress
+		return false, b.OpenTimeout
+	}
+
+	return true, 0
+}
+
+// Success record success call, the circuit closed
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = breakerClosed
+	b.failures = 0
+}
+
+// Failure record failed call, return time until the next trial call
+// allowed if the circuit opened
+func (b *CircuitBreaker) Failure() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.FailureThreshold {
+		b.state = breakerOpen
+		b.openedAt = b.Now()
+		return b.OpenTimeout
+	}
+
+	return 0
+}
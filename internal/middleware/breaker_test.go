@@ -0,0 +1,114 @@
+/*
+Package middleware collection of middleware used for API
+*/
+package middleware
+
+import (
+	"testing"
+	"time"
+)
+
+// TestCircuitBreaker test CircuitBreaker
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	b := NewCircuitBreaker(3, 30*time.Second)
+	b.Now = func() time.Time { return now }
+
+	// initialize testing table, each step run in order
+	testTable := []struct {
+		TestName           string
+		Elapsed            time.Duration
+		Failed             bool
+		ExpectedAllowed    bool
+		ExpectedRetryAfter time.Duration
+	}{
+		{
+			TestName:        "Test Closed Success",
+			ExpectedAllowed: true,
+		},
+		{
+			TestName:        "Test Closed Failure 1",
+			Failed:          true,
+			ExpectedAllowed: true,
+		},
+		{
+			TestName:        "Test Closed Failure 2",
+			Failed:          true,
+			ExpectedAllowed: true,
+		},
+		{
+			TestName:        "Test Closed Failure 3",
+			Failed:          true,
+			ExpectedAllowed: true,
+		},
+		{
+			TestName:           "Test Open",
+			Elapsed:            10 * time.Second,
+			ExpectedAllowed:    false,
+			ExpectedRetryAfter: 20 * time.Second,
+		},
+		{
+			TestName:        "Test Half Open Failure",
+			Elapsed:         20 * time.Second,
+			Failed:          true,
+			ExpectedAllowed: true,
+		},
+		{
+			TestName:           "Test Open Again",
+			Elapsed:            time.Second,
+			ExpectedAllowed:    false,
+			ExpectedRetryAfter: 29 * time.Second,
+		},
+		{
+			TestName:        "Test Half Open Success",
+			Elapsed:         29 * time.Second,
+			ExpectedAllowed: true,
+		},
+		{
+			TestName:        "Test Closed Again",
+			Failed:          true,
+			ExpectedAllowed: true,
+		},
+		{
+			TestName:        "Test Still Closed",
+			ExpectedAllowed: true,
+		},
+	}
+
+	// test for each testing table
+	for _, test := range testTable {
+		now = now.Add(test.Elapsed)
+
+		allowed, retryAfter := b.Allow()
+		if allowed != test.ExpectedAllowed {
+			t.Errorf("[%s] Expected allowed %t, but got %t", test.TestName,
+				test.ExpectedAllowed, allowed)
+		}
+		if retryAfter != test.ExpectedRetryAfter {
+			t.Errorf("[%s] Expected retry after %s, but got %s", test.TestName,
+				test.ExpectedRetryAfter, retryAfter)
+		}
+		if !allowed {
+			continue
+		}
+
+		if test.Failed {
+			b.Failure()
+		} else {
+			b.Success()
+		}
+	}
+
+	// only one trial call allowed when half open
+	b = NewCircuitBreaker(1, time.Second)
+	b.Now = func() time.Time { return now }
+	b.Failure()
+	now = now.Add(time.Second)
+	if allowed, _ := b.Allow(); !allowed {
+		t.Errorf("Expected trial call allowed, but got rejected")
+	}
+	if allowed, _ := b.Allow(); allowed {
+		t.Errorf("Expected second call rejected while trial call " +
+			"in progress, but got allowed")
+	}
+}
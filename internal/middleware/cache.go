@@ -0,0 +1,213 @@
+/*
+Package middleware collection of middleware used for API
+*/
+package middleware
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// UnavailableError returned when token can't be authorized
+// because the authorizer is down
+type UnavailableError struct {
+	// RetryAfter time until authorizer can be called again,
+	// zero if unknown
+	RetryAfter time.Duration
+
+	Err error
+}
+
+// Error get unavailable error message
+func (e *UnavailableError) Error() string {
+	if e.Err == nil {
+		return "authorizer unavailable"
+	}
+
+	return "authorizer unavailable => " + e.Err.Error()
+}
+
+// Unwrap get cause of unavailable error
+func (e *UnavailableError) Unwrap() error {
+	return e.Err
+}
+
+// cacheEntry user of token cached until expired
+type cacheEntry struct {
+	key       string
+	user      User
+	expiredAt time.Time
+}
+
+// authorizeCall in-flight authorization of token shared by callers
+type authorizeCall struct {
+	done chan struct{}
+	user User
+	err  error
+}
+
+// CachedAuthorizer thread-safe authorizer caching users of token
+// authorized by remote authorizer
+//
+// each user cached until the TTL passed, the least recently used one
+// removed when there are more than MaxEntries users,
+// concurrent authorizations of the same token call remote authorizer once,
+// and remote authorizer not called while it's down (circuit opened)
+type CachedAuthorizer struct {
+	Next       TokenAuthorizer
+	Breaker    *CircuitBreaker
+	TTL        time.Duration
+	MaxEntries int
+
+	// Now get current time, default to time.Now
+	Now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	calls   map[string]*authorizeCall
+}
+
+// NewCachedAuthorizer create authorizer caching users of token
+// authorized by next
+func NewCachedAuthorizer(next TokenAuthorizer, breaker *CircuitBreaker,
+	ttl time.Duration, maxEntries int) *CachedAuthorizer {
+	return &CachedAuthorizer{
+		Next:       next,
+		Breaker:    breaker,
+		TTL:        ttl,
+		MaxEntries: maxEntries,
+		Now:        time.Now,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+		calls:      make(map[string]*authorizeCall),
+	}
+}
+
+// Authorize get user of token from cache, or from remote authorizer
+// if it's not cached
+//
+// return ErrAuthorizationCancelled if ctx cancelled before the remote
+// authorizer responded, or UnavailableError if ctx deadline exceeded
+func (a *CachedAuthorizer) Authorize(ctx context.Context,
+	token string) (User, error) {
+	// token hashed so raw tokens not kept in memory
+	sum := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(sum[:])
+
+	a.mu.Lock()
+	user, ok := a.get(key)
+	if ok {
+		a.mu.Unlock()
+		return user, nil
+	}
+
+	call, ok := a.calls[key]
+	if !ok {
+		call = &authorizeCall{done: make(chan struct{})}
+		a.calls[key] = call
+
+		// authorize with context not cancelled by the first caller,
+		// so the other callers still get the result
+		go a.authorize(key, token, call)
+	}
+	a.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.user, call.err
+	case <-ctx.Done():
+		if ctx.Err() == context.Canceled {
+			return User{}, ErrAuthorizationCancelled
+		}
+		return User{}, &UnavailableError{Err: ctx.Err()}
+	}
+}
+
+// authorize authorize token by remote authorizer,
+// then cache the user if token valid
+func (a *CachedAuthorizer) authorize(key string, token string,
+	call *authorizeCall) {
+	call.user, call.err = a.authorizeRemote(token)
+
+	a.mu.Lock()
+	delete(a.calls, key)
+	if call.err == nil {
+		a.set(key, call.user)
+	}
+	a.mu.Unlock()
+
+	close(call.done)
+}
+
+// authorizeRemote authorize token by remote authorizer if circuit closed,
+// failure other than invalid token open the circuit
+func (a *CachedAuthorizer) authorizeRemote(token string) (User, error) {
+	allowed, retryAfter := a.Breaker.Allow()
+	if !allowed {
+		return User{}, &UnavailableError{
+			RetryAfter: retryAfter,
+			Err:        fmt.Errorf("circuit open"),
+		}
+	}
+
+	user, err := a.Next.Authorize(context.Background(), token)
+	if err == nil || errors.Is(err, ErrTokenInvalid) {
+		a.Breaker.Success()
+		return user, err
+	}
+
+	retryAfter = a.Breaker.Failure()
+	return User{}, &UnavailableError{RetryAfter: retryAfter, Err: err}
+}
+
+// get get cached user of key if not expired
+//
+// caller must hold the lock
+func (a *CachedAuthorizer) get(key string) (User, bool) {
+	elem, ok := a.entries[key]
+	if !ok {
+		return User{}, false
+	}
+
+	entry := elem.Value.(*cacheEntry)
+	if !a.Now().Before(entry.expiredAt) {
+		a.lru.Remove(elem)
+		delete(a.entries, key)
+		return User{}, false
+	}
+	a.lru.MoveToFront(elem)
+
+	return entry.user, true
+}
+
+// set cache user of key, the least recently used users removed
+// if there are too many
+//
+// caller must hold the lock
+func (a *CachedAuthorizer) set(key string, user User) {
+	if a.TTL <= 0 || a.MaxEntries <= 0 {
+		return
+	}
+
+	entry := &cacheEntry{key: key, user: user,
+		expiredAt: a.Now().Add(a.TTL)}
+	if elem, ok := a.entries[key]; ok {
+		elem.Value = entry
+		a.lru.MoveToFront(elem)
+		return
+	}
+	a.entries[key] = a.lru.PushFront(entry)
+
+	for a.lru.Len() > a.MaxEntries {
+		elem := a.lru.Back()
+		a.lru.Remove(elem)
+		delete(a.entries, elem.Value.(*cacheEntry).key)
+	}
+}
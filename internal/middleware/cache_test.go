@@ -0,0 +1,248 @@
+/*
+Package middleware collection of middleware used for API
+*/
+package middleware
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testingAuthorizer authorizer counting its calls, token "invalid"
+// rejected and token "down" failed
+type testingAuthorizer struct {
+	calls int32
+
+	// release block authorization until closed, if set
+	release chan struct{}
+}
+
+// Authorize get testing user with token as email
+func (a *testingAuthorizer) Authorize(ctx context.Context,
+	token string) (User, error) {
+	atomic.AddInt32(&a.calls, 1)
+	if a.release != nil {
+		<-a.release
+	}
+
+	switch token {
+	case "invalid":
+		return User{}, ErrTokenInvalid
+	case "down":
+		return User{}, errors.New("connection refused")
+	}
+
+	return User{ID: 1, Email: token, Role: "buyer"}, nil
+}
+
+// TestCachedAuthorizer test CachedAuthorizer cache
+func TestCachedAuthorizer(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	next := &testingAuthorizer{}
+	a := NewCachedAuthorizer(next, NewCircuitBreaker(2, 30*time.Second),
+		time.Minute, 2)
+	a.Now = func() time.Time { return now }
+	a.Breaker.Now = a.Now
+
+	// initialize testing table, each step run in order
+	testTable := []struct {
+		TestName      string
+		Elapsed       time.Duration
+		Token         string
+		ExpectedCalls int32
+		ExpectedError error
+	}{
+		{
+			TestName:      "Test Not Cached",
+			Token:         "token-1",
+			ExpectedCalls: 1,
+		},
+		{
+			TestName:      "Test Cached",
+			Elapsed:       30 * time.Second,
+			Token:         "token-1",
+			ExpectedCalls: 1,
+		},
+		{
+			TestName:      "Test Invalid Token Not Cached",
+			Token:         "invalid",
+			ExpectedCalls: 2,
+			ExpectedError: ErrTokenInvalid,
+		},
+		{
+			TestName:      "Test Invalid Token Not Cached Again",
+			Token:         "invalid",
+			ExpectedCalls: 3,
+			ExpectedError: ErrTokenInvalid,
+		},
+		{
+			TestName:      "Test Another Token Cached",
+			Token:         "token-2",
+			ExpectedCalls: 4,
+		},
+		{
+			TestName:      "Test Cached Used Again",
+			Token:         "token-1",
+			ExpectedCalls: 4,
+		},
+		{
+			TestName:      "Test Least Recently Used Removed",
+			Token:         "token-3",
+			ExpectedCalls: 5,
+		},
+		{
+			TestName:      "Test Recently Used Still Cached",
+			Token:         "token-1",
+			ExpectedCalls: 5,
+		},
+		{
+			TestName:      "Test Removed Not Cached",
+			Token:         "token-2",
+			ExpectedCalls: 6,
+		},
+		{
+			TestName:      "Test Expired",
+			Elapsed:       31 * time.Second,
+			Token:         "token-1",
+			ExpectedCalls: 7,
+		},
+	}
+
+	// test for each testing table
+	for _, test := range testTable {
+		now = now.Add(test.Elapsed)
+
+		u, err := a.Authorize(ctx, test.Token)
+		if !errors.Is(err, test.ExpectedError) {
+			t.Errorf("[%s] Expected error %v, but got %v", test.TestName,
+				test.ExpectedError, err)
+		}
+		if err == nil && u.Email != test.Token {
+			t.Errorf("[%s] Expected user of token %s, but got %+v",
+				test.TestName, test.Token, u)
+		}
+		if calls := atomic.LoadInt32(&next.calls); calls != test.ExpectedCalls {
+			t.Errorf("[%s] Expected remote authorizer called %d times, "+
+				"but got %d", test.TestName, test.ExpectedCalls, calls)
+		}
+	}
+}
+
+// TestCachedAuthorizerUnavailable test CachedAuthorizer when remote
+// authorizer down, cached users still authorized
+func TestCachedAuthorizerUnavailable(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	next := &testingAuthorizer{}
+	a := NewCachedAuthorizer(next, NewCircuitBreaker(2, 30*time.Second),
+		time.Hour, 10)
+	a.Now = func() time.Time { return now }
+	a.Breaker.Now = a.Now
+
+	_, err := a.Authorize(ctx, "token-1")
+	if err != nil {
+		t.Fatalf("Expected token authorized, but got error => %s", err)
+	}
+
+	// initialize testing table, each step run in order
+	testTable := []struct {
+		TestName            string
+		Token               string
+		ExpectedCalls       int32
+		ExpectedUnavailable bool
+		ExpectedRetryAfter  time.Duration
+	}{
+		{
+			TestName:            "Test Failure",
+			Token:               "down",
+			ExpectedCalls:       2,
+			ExpectedUnavailable: true,
+		},
+		{
+			TestName:            "Test Failure Open Circuit",
+			Token:               "down",
+			ExpectedCalls:       3,
+			ExpectedUnavailable: true,
+			ExpectedRetryAfter:  30 * time.Second,
+		},
+		{
+			TestName:            "Test Circuit Open",
+			Token:               "token-2",
+			ExpectedCalls:       3,
+			ExpectedUnavailable: true,
+			ExpectedRetryAfter:  30 * time.Second,
+		},
+		{
+			TestName:      "Test Cached While Circuit Open",
+			Token:         "token-1",
+			ExpectedCalls: 3,
+		},
+	}
+
+	// test for each testing table
+	for _, test := range testTable {
+		_, err := a.Authorize(ctx, test.Token)
+
+		var unavailableErr *UnavailableError
+		if errors.As(err, &unavailableErr) != test.ExpectedUnavailable {
+			t.Errorf("[%s] Expected unavailable %t, but got error %v",
+				test.TestName, test.ExpectedUnavailable, err)
+		}
+		if unavailableErr != nil &&
+			unavailableErr.RetryAfter != test.ExpectedRetryAfter {
+			t.Errorf("[%s] Expected retry after %s, but got %s", test.TestName,
+				test.ExpectedRetryAfter, unavailableErr.RetryAfter)
+		}
+		if calls := atomic.LoadInt32(&next.calls); calls != test.ExpectedCalls {
+			t.Errorf("[%s] Expected remote authorizer called %d times, "+
+				"but got %d", test.TestName, test.ExpectedCalls, calls)
+		}
+	}
+}
+
+// TestCachedAuthorizerConcurrent test concurrent authorizations
+// of the same token call remote authorizer once
+func TestCachedAuthorizerConcurrent(t *testing.T) {
+	next := &testingAuthorizer{release: make(chan struct{})}
+	a := NewCachedAuthorizer(next, NewCircuitBreaker(5, time.Second),
+		time.Minute, 10)
+
+	// the first caller leaving doesn't cancel the others
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := a.Authorize(ctx, "token-1")
+	if err != ErrAuthorizationCancelled {
+		t.Errorf("Expected cancelled caller get %s, but got %v",
+			ErrAuthorizationCancelled, err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := a.Authorize(context.Background(), "token-1")
+			errs <- err
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(next.release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Expected token authorized, but got error => %s", err)
+		}
+	}
+	if calls := atomic.LoadInt32(&next.calls); calls != 1 {
+		t.Errorf("Expected remote authorizer called once, but got %d", calls)
+	}
+}
@@ -0,0 +1,33 @@
+/*
+Package middleware collection of middleware used for API
+*/
+package middleware
+
+import (
+	"fmt"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// DeprecationHeader header set on response of deprecated route
+	DeprecationHeader = "Deprecation"
+
+	// LinkHeader header containing link to successor of deprecated route
+	LinkHeader = "Link"
+)
+
+// DeprecationMiddleware mark response of deprecated route with Deprecation
+// header and Link header to its successor route, the route still works as usual
+func DeprecationMiddleware(successor string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			header := c.Response().Header()
+			header.Set(DeprecationHeader, "true")
+			header.Add(LinkHeader,
+				fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+
+			return next(c)
+		}
+	}
+}
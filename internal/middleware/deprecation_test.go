@@ -0,0 +1,42 @@
+/*
+Package middleware collection of middleware used for API
+*/
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+// TestDeprecationMiddleware test DeprecationMiddleware
+func TestDeprecationMiddleware(t *testing.T) {
+	e := echo.New()
+	handler := DeprecationMiddleware("/api/v1/orders")(
+		func(c echo.Context) error {
+			return c.JSON(http.StatusOK, map[string]string{"message": "ok"})
+		})
+
+	req := httptest.NewRequest("GET", "/api/orders/", nil)
+	response := httptest.NewRecorder()
+	err := handler(e.NewContext(req, response))
+	if err != nil {
+		t.Fatalf("Expected error nil, but got %s", err)
+	}
+
+	if response.Code != http.StatusOK {
+		t.Errorf("Expected status %d got %d", http.StatusOK, response.Code)
+	}
+	if response.Header().Get(DeprecationHeader) != "true" {
+		t.Errorf("Expected %s header true, but got '%s'", DeprecationHeader,
+			response.Header().Get(DeprecationHeader))
+	}
+
+	expectedLink := `</api/v1/orders>; rel="successor-version"`
+	if response.Header().Get(LinkHeader) != expectedLink {
+		t.Errorf("Expected %s header %s, but got '%s'", LinkHeader,
+			expectedLink, response.Header().Get(LinkHeader))
+	}
+}
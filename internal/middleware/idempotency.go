@@ -0,0 +1,172 @@
+/*
+Package middleware collection of middleware used for API
+*/
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/reyhanfikridz/ecom-order-service/internal/idempotency"
+	"github.com/reyhanfikridz/ecom-order-service/internal/problem"
+)
+
+const (
+	// IdempotencyKeyHeader header of request idempotency key
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	// IdempotentReplayedHeader header set when response replayed
+	// from stored response
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	// maxIdempotencyKeyLength max length of idempotency key
+	maxIdempotencyKeyLength = 255
+)
+
+// error codes of idempotency problem
+const (
+	codeIdempotencyKeyReused         = "idempotency_key_reused"
+	codeIdempotencyRequestInProgress = "idempotency_request_in_progress"
+)
+
+// replayedHeaders response headers stored and replayed for repeated request
+var replayedHeaders = []string{
+	echo.HeaderContentType, echo.HeaderLocation, "ETag",
+}
+
+// IdempotencyMiddleware make request with Idempotency-Key header processed
+// only once per user, the response stored and replayed for repeated request
+//
+// operation name of the logical operation of the route, e.g. "create-order",
+// so repeated request replayed by any route of the same operation,
+// like legacy and versioned route, empty means request method and route path
+//
+// must be used after AuthorizationMiddleware,
+// request without Idempotency-Key header processed as usual
+func IdempotencyMiddleware(store idempotency.Store,
+	operation string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			// get idempotency key
+			key := c.Request().Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				return next(c)
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				return problem.Newf(http.StatusBadRequest, problem.CodeBadRequest,
+					"%s too long, max %d characters",
+					IdempotencyKeyHeader, maxIdempotencyKeyLength)
+			}
+
+			// get user data, key is per user, or per service
+			// for internal service which has no user ID
+			u, ok := c.Get("user").(User)
+			if !ok {
+				return problem.Internal(errors.New("user data invalid"))
+			}
+			if u.Role == RoleService {
+				key = "service:" + u.Service + ":" + key
+			} else {
+				key = strconv.Itoa(u.ID) + ":" + key
+			}
+
+			// get request hash from operation and body
+			body, err := io.ReadAll(c.Request().Body)
+			if err != nil {
+				return problem.Newf(http.StatusBadRequest, problem.CodeBadRequest,
+					"Request body invalid => %s", err)
+			}
+			c.Request().Body = io.NopCloser(bytes.NewReader(body))
+
+			requestOperation := operation
+			if requestOperation == "" {
+				requestOperation = c.Request().Method + " " + c.Path()
+			}
+			hash := sha256.New()
+			hash.Write([]byte(requestOperation + "\n"))
+			hash.Write(body)
+			requestHash := hex.EncodeToString(hash.Sum(nil))
+
+			// begin request, replay stored response if already completed
+			ctx := c.Request().Context()
+			resp, err := store.Begin(ctx, key, requestHash)
+			if err != nil {
+				switch err {
+				case idempotency.ErrRequestMismatch:
+					return problem.New(http.StatusUnprocessableEntity,
+						codeIdempotencyKeyReused, err.Error())
+				case idempotency.ErrRequestInProgress:
+					return problem.New(http.StatusConflict,
+						codeIdempotencyRequestInProgress, err.Error())
+				}
+
+				return problem.Internal(fmt.Errorf(
+					"There's an error when checking idempotency key => %w",
+					err))
+			}
+			if resp != nil {
+				for name, values := range resp.Header {
+					c.Response().Header()[name] = values
+				}
+				c.Response().Header().Set(IdempotentReplayedHeader, "true")
+				c.Response().WriteHeader(resp.Status)
+				_, err = c.Response().Write(resp.Body)
+				return err
+			}
+
+			// process request while recording the response
+			recorder := &responseRecorder{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = recorder
+
+			// error response written here, so client error response
+			// also stored and replayed
+			err = next(c)
+			if err != nil {
+				c.Error(err)
+			}
+			if c.Response().Status >= http.StatusInternalServerError {
+				// server error can be retried with the same key
+				store.Abort(ctx, key)
+				return nil
+			}
+
+			// store response for repeated request
+			header := http.Header{}
+			for _, name := range replayedHeaders {
+				if value := c.Response().Header().Get(name); value != "" {
+					header.Set(name, value)
+				}
+			}
+			err = store.Complete(ctx, key, idempotency.Response{
+				Status: c.Response().Status,
+				Header: header,
+				Body:   recorder.Body.Bytes(),
+			})
+			if err != nil {
+				c.Logger().Errorf("There's an error when storing "+
+					"idempotent response => %s", err)
+			}
+
+			return nil
+		}
+	}
+}
+
+// responseRecorder response writer that also record the response body
+type responseRecorder struct {
+	http.ResponseWriter
+	Body bytes.Buffer
+}
+
+// Write write response body and record it
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.Body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
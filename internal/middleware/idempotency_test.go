@@ -0,0 +1,257 @@
+/*
+Package middleware collection of middleware used for API
+*/
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/reyhanfikridz/ecom-order-service/internal/idempotency"
+	"github.com/reyhanfikridz/ecom-order-service/internal/problem"
+)
+
+// TestIdempotencyMiddleware test IdempotencyMiddleware
+func TestIdempotencyMiddleware(t *testing.T) {
+	e := echo.New()
+	e.HTTPErrorHandler = problem.HTTPErrorHandler
+	var calls int32
+	store := idempotency.NewMemoryStore(time.Hour)
+	next := func(c echo.Context) error {
+		n := atomic.AddInt32(&calls, 1)
+		if c.QueryParam("fail") != "" {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"message": "failed",
+			})
+		}
+
+		return c.JSON(http.StatusCreated, map[string]int32{"call": n})
+	}
+	handlers := map[string]echo.HandlerFunc{
+		"add-order":   IdempotencyMiddleware(store, "add-order")(next),
+		"add-webhook": IdempotencyMiddleware(store, "add-webhook")(next),
+	}
+
+	// initialize testing table, run in order
+	testTable := []struct {
+		TestName         string
+		Key              string
+		Operation        string
+		Path             string
+		Body             string
+		Query            string
+		User             User
+		ExpectedStatus   int
+		ExpectedCalls    int32
+		ExpectedReplayed bool
+	}{
+		{
+			TestName:       "Test Without Key",
+			Body:           `{"status":"in-cart"}`,
+			User:           User{ID: 1},
+			ExpectedStatus: http.StatusCreated,
+			ExpectedCalls:  1,
+		},
+		{
+			TestName:       "Test First Request",
+			Key:            "key",
+			Body:           `{"status":"in-cart"}`,
+			User:           User{ID: 1},
+			ExpectedStatus: http.StatusCreated,
+			ExpectedCalls:  2,
+		},
+		{
+			TestName:         "Test Repeated Request",
+			Key:              "key",
+			Body:             `{"status":"in-cart"}`,
+			User:             User{ID: 1},
+			ExpectedStatus:   http.StatusCreated,
+			ExpectedCalls:    2,
+			ExpectedReplayed: true,
+		},
+		{
+			TestName:         "Test Repeated Request Other Route",
+			Key:              "key",
+			Path:             "/api/order/",
+			Body:             `{"status":"in-cart"}`,
+			User:             User{ID: 1},
+			ExpectedStatus:   http.StatusCreated,
+			ExpectedCalls:    2,
+			ExpectedReplayed: true,
+		},
+		{
+			TestName:       "Test Repeated Request Other Operation",
+			Key:            "key",
+			Operation:      "add-webhook",
+			Body:           `{"status":"in-cart"}`,
+			User:           User{ID: 1},
+			ExpectedStatus: http.StatusUnprocessableEntity,
+			ExpectedCalls:  2,
+		},
+		{
+			TestName:       "Test Repeated Request Different Body",
+			Key:            "key",
+			Body:           `{"status":"paid"}`,
+			User:           User{ID: 1},
+			ExpectedStatus: http.StatusUnprocessableEntity,
+			ExpectedCalls:  2,
+		},
+		{
+			TestName:       "Test Same Key Other User",
+			Key:            "key",
+			Body:           `{"status":"paid"}`,
+			User:           User{ID: 2},
+			ExpectedStatus: http.StatusCreated,
+			ExpectedCalls:  3,
+		},
+		{
+			TestName:       "Test Same Key Service",
+			Key:            "key",
+			Body:           `{"status":"paid"}`,
+			User:           User{Role: RoleService, Service: "payment"},
+			ExpectedStatus: http.StatusCreated,
+			ExpectedCalls:  4,
+		},
+		{
+			TestName:       "Test Same Key Other Service",
+			Key:            "key",
+			Body:           `{"status":"shipped"}`,
+			User:           User{Role: RoleService, Service: "shipping"},
+			ExpectedStatus: http.StatusCreated,
+			ExpectedCalls:  5,
+		},
+		{
+			TestName:       "Test Server Error",
+			Key:            "failed key",
+			Body:           `{"status":"in-cart"}`,
+			Query:          "fail=true",
+			User:           User{ID: 1},
+			ExpectedStatus: http.StatusInternalServerError,
+			ExpectedCalls:  6,
+		},
+		{
+			TestName:       "Test Retry After Server Error",
+			Key:            "failed key",
+			Body:           `{"status":"in-cart"}`,
+			Query:          "fail=true",
+			User:           User{ID: 1},
+			ExpectedStatus: http.StatusInternalServerError,
+			ExpectedCalls:  7,
+		},
+		{
+			TestName:       "Test Key Too Long",
+			Key:            strings.Repeat("k", 256),
+			Body:           `{"status":"in-cart"}`,
+			User:           User{ID: 1},
+			ExpectedStatus: http.StatusBadRequest,
+			ExpectedCalls:  7,
+		},
+	}
+
+	// test for each testing table
+	var firstBody string
+	for _, test := range testTable {
+		operation := test.Operation
+		if operation == "" {
+			operation = "add-order"
+		}
+		path := test.Path
+		if path == "" {
+			path = "/api/v1/orders"
+		}
+
+		req := httptest.NewRequest("POST", path+"?"+test.Query,
+			strings.NewReader(test.Body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if test.Key != "" {
+			req.Header.Set(IdempotencyKeyHeader, test.Key)
+		}
+
+		response := httptest.NewRecorder()
+		c := e.NewContext(req, response)
+		c.Set("user", test.User)
+		err := handlers[operation](c)
+		if err != nil {
+			var p *problem.Problem
+			if !errors.As(err, &p) {
+				t.Errorf("[%s] Expected error nil or problem, but got %s",
+					test.TestName, err)
+			}
+			c.Error(err)
+		}
+
+		if response.Code != test.ExpectedStatus {
+			t.Errorf("[%s] Expected status %d got %d",
+				test.TestName, test.ExpectedStatus, response.Code)
+		}
+		if atomic.LoadInt32(&calls) != test.ExpectedCalls {
+			t.Errorf("[%s] Expected handler called %d times, but got %d",
+				test.TestName, test.ExpectedCalls, calls)
+		}
+
+		replayed := response.Header().Get(IdempotentReplayedHeader) == "true"
+		if replayed != test.ExpectedReplayed {
+			t.Errorf("[%s] Expected replayed %t, but got %t",
+				test.TestName, test.ExpectedReplayed, replayed)
+		}
+		if test.TestName == "Test First Request" {
+			firstBody = response.Body.String()
+		}
+		if replayed && response.Body.String() != firstBody {
+			t.Errorf("[%s] Expected replayed body %s, but got %s",
+				test.TestName, firstBody, response.Body.String())
+		}
+	}
+}
+
+// TestIdempotencyMiddlewareConcurrent test IdempotencyMiddleware
+// with concurrent requests with the same key
+func TestIdempotencyMiddlewareConcurrent(t *testing.T) {
+	e := echo.New()
+	e.HTTPErrorHandler = problem.HTTPErrorHandler
+	var calls int32
+	handler := IdempotencyMiddleware(idempotency.NewMemoryStore(time.Hour),
+		"add-order")(
+		func(c echo.Context) error {
+			atomic.AddInt32(&calls, 1)
+			time.Sleep(10 * time.Millisecond)
+			return c.JSON(http.StatusCreated, map[string]string{"message": "ok"})
+		})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			req := httptest.NewRequest("POST", "/",
+				strings.NewReader(`{"status":"in-cart"}`))
+			req.Header.Set(IdempotencyKeyHeader, "key")
+
+			response := httptest.NewRecorder()
+			c := e.NewContext(req, response)
+			c.Set("user", User{ID: 1})
+			if err := handler(c); err != nil {
+				c.Error(err)
+			}
+
+			if response.Code != http.StatusCreated &&
+				response.Code != http.StatusConflict {
+				t.Errorf("Expected status %d or %d, but got %d",
+					http.StatusCreated, http.StatusConflict, response.Code)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("Expected handler called once, but got %d", calls)
+	}
+}
@@ -0,0 +1,291 @@
+/*
+Package middleware collection of middleware used for API
+*/
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// jwksFetchTimeout max time of fetching JWKS from URL
+	jwksFetchTimeout = 10 * time.Second
+
+	// jwksRefreshInterval min time between refreshing JWKS from URL
+	// when token signed by unknown key, so keys can be rotated,
+	// failed refresh also not retried before this interval
+	jwksRefreshInterval = time.Minute
+)
+
+// errJWKSKeyNotFound returned when there's no key in JWKS
+// for the token key ID
+var errJWKSKeyNotFound = errors.New("JWKS key not found")
+
+// JWKS thread-safe set of public keys from JSON Web Key Set document,
+// keys from URL refreshed when token signed by unknown key
+type JWKS struct {
+	source string
+
+	// refreshMu serialize refreshing keys, so concurrent tokens
+	// signed by unknown key only refresh the keys once
+	refreshMu sync.Mutex
+
+	mu   sync.RWMutex
+	keys map[string]crypto.PublicKey
+
+	// refreshedAt time of the last refresh, successful or not
+	refreshedAt time.Time
+}
+
+// jwksDocument JSON Web Key Set document
+type jwksDocument struct {
+	Keys []jwk `json:"keys"`
+}
+
+// jwk JSON Web Key, only RSA and EC public key used
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// LoadJWKS load JWKS from source, source can be file path
+// or http(s) URL
+func LoadJWKS(ctx context.Context, source string) (*JWKS, error) {
+	keys := &JWKS{source: source}
+	err := keys.refresh(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// Key get public key by key ID, empty key ID allowed if there's only
+// one key, keys from URL refreshed if there's none and not refreshed
+// in the last refresh interval
+//
+// return ErrTokenInvalid if there's no key with the key ID,
+// or UnavailableError if refreshing keys from URL failed
+func (s *JWKS) Key(ctx context.Context, kid string,
+	alg string) (crypto.PublicKey, error) {
+	key, ok := s.getKey(kid)
+	if ok {
+		return key, nil
+	}
+
+	if isURL(s.source) {
+		err := s.refreshIfStale(ctx)
+		if err != nil {
+			return nil, &UnavailableError{
+				RetryAfter: jwksRefreshInterval,
+				Err: fmt.Errorf("There's an error when refreshing JWKS => %w",
+					err),
+			}
+		}
+
+		key, ok = s.getKey(kid)
+		if ok {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w => %s with key ID '%s' (%s)", ErrTokenInvalid,
+		errJWKSKeyNotFound, kid, alg)
+}
+
+// getKey get public key by key ID
+func (s *JWKS) getKey(kid string) (crypto.PublicKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+// refreshIfStale refresh keys if last refreshed longer than
+// refresh interval, concurrent callers wait for the same refresh
+// and don't refresh again
+func (s *JWKS) refreshIfStale(ctx context.Context) error {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	s.mu.RLock()
+	stale := time.Since(s.refreshedAt) >= jwksRefreshInterval
+	s.mu.RUnlock()
+	if !stale {
+		return nil
+	}
+
+	return s.refresh(ctx)
+}
+
+// refresh load keys again from source,
+// refresh time recorded even if it's failed
+func (s *JWKS) refresh(ctx context.Context) error {
+	s.mu.Lock()
+	s.refreshedAt = time.Now()
+	s.mu.Unlock()
+
+	var b []byte
+	var err error
+	if isURL(s.source) {
+		b, err = fetchJWKS(ctx, s.source)
+	} else {
+		b, err = os.ReadFile(s.source)
+	}
+	if err != nil {
+		return err
+	}
+
+	keys, err := parseJWKSKeys(b)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+
+	return nil
+}
+
+// fetchJWKS get JWKS document from URL
+func fetchJWKS(ctx context.Context, url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, jwksFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("JWKS response status %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// parseJWKSKeys parse public keys of JWKS document by key ID,
+// keys not used for signature and unknown key types skipped
+func parseJWKSKeys(b []byte) (map[string]crypto.PublicKey, error) {
+	var doc jwksDocument
+	err := json.Unmarshal(b, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("JWKS invalid => %w", err)
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for i, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		var key crypto.PublicKey
+		switch k.Kty {
+		case "RSA":
+			key, err = parseRSAKey(k)
+		case "EC":
+			key, err = parseECKey(k)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("JWKS key %d invalid => %w", i, err)
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS has no signature key")
+	}
+
+	return keys, nil
+}
+
+// parseRSAKey get RSA public key of JWK
+func parseRSAKey(k jwk) (*rsa.PublicKey, error) {
+	n, err := decodeJWKInt(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("n invalid => %w", err)
+	}
+	e, err := decodeJWKInt(k.E)
+	if err != nil || !e.IsInt64() || e.Int64() > 1<<31-1 {
+		return nil, fmt.Errorf("e invalid")
+	}
+
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+// parseECKey get EC public key of JWK, only P-256 curve supported
+func parseECKey(k jwk) (*ecdsa.PublicKey, error) {
+	if k.Crv != "P-256" {
+		return nil, fmt.Errorf("curve '%s' not supported", k.Crv)
+	}
+
+	x, err := decodeJWKInt(k.X)
+	if err != nil {
+		return nil, fmt.Errorf("x invalid => %w", err)
+	}
+	y, err := decodeJWKInt(k.Y)
+	if err != nil {
+		return nil, fmt.Errorf("y invalid => %w", err)
+	}
+
+	curve := elliptic.P256()
+	if !curve.IsOnCurve(x, y) {
+		return nil, fmt.Errorf("point not on curve %s", k.Crv)
+	}
+
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// decodeJWKInt decode base64url big-endian integer of JWK
+func decodeJWKInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, fmt.Errorf("empty")
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
+
+// isURL check if JWKS source is http(s) URL
+func isURL(source string) bool {
+	return strings.HasPrefix(source, "http://") ||
+		strings.HasPrefix(source, "https://")
+}
@@ -0,0 +1,327 @@
+/*
+Package middleware collection of middleware used for API
+*/
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// signing algorithms of JWT verified locally
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+)
+
+// JWTConfig configuration of local JWT verification
+//
+// token signed with HS256 verified with Secret, or signed with
+// RS256/ES256 verified with public keys in JWKS (file path or URL),
+// only one of them can be set
+type JWTConfig struct {
+	Secret   string
+	JWKS     string
+	Issuer   string
+	Audience string
+
+	// Leeway time allowed for clock skew when checking
+	// expiration and not before time
+	Leeway time.Duration
+}
+
+// JWTAuthorizer authorizer of token by verifying it locally as JWT,
+// the user taken from token claims
+type JWTAuthorizer struct {
+	secret   []byte
+	keys     *JWKS
+	issuer   string
+	audience string
+	leeway   time.Duration
+
+	// now get current time, used for checking expiration
+	now func() time.Time
+}
+
+// jwtHeader JOSE header of JWT
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// jwtClaims claims of JWT, user claims have the same name
+// as user fields, user ID taken from subject if there's no id claim
+type jwtClaims struct {
+	Subject   string      `json:"sub"`
+	Issuer    string      `json:"iss"`
+	Audience  jwtAudience `json:"aud"`
+	ExpiresAt *float64    `json:"exp"`
+	NotBefore *float64    `json:"nbf"`
+
+	ID          *int   `json:"id"`
+	Email       string `json:"email"`
+	FullName    string `json:"full_name"`
+	Address     string `json:"address"`
+	PhoneNumber string `json:"phone_number"`
+	Role        string `json:"role"`
+}
+
+// jwtAudience audience claim, can be one string or array of strings
+type jwtAudience []string
+
+// UnmarshalJSON unmarshal audience from string or array of strings
+func (a *jwtAudience) UnmarshalJSON(b []byte) error {
+	var audience string
+	if json.Unmarshal(b, &audience) == nil {
+		*a = jwtAudience{audience}
+		return nil
+	}
+
+	var audiences []string
+	err := json.Unmarshal(b, &audiences)
+	if err != nil {
+		return err
+	}
+	*a = audiences
+
+	return nil
+}
+
+// NewJWTAuthorizer create authorizer of token by verifying it locally
+// as JWT, public keys in JWKS loaded before returned
+func NewJWTAuthorizer(ctx context.Context,
+	jwtConfig JWTConfig) (*JWTAuthorizer, error) {
+	a := &JWTAuthorizer{
+		issuer:   jwtConfig.Issuer,
+		audience: jwtConfig.Audience,
+		leeway:   jwtConfig.Leeway,
+		now:      time.Now,
+	}
+
+	switch {
+	case jwtConfig.Secret != "" && jwtConfig.JWKS != "":
+		return nil, fmt.Errorf("JWT secret and JWKS can't be both set")
+
+	case jwtConfig.Secret != "":
+		a.secret = []byte(jwtConfig.Secret)
+
+	case jwtConfig.JWKS != "":
+		keys, err := LoadJWKS(ctx, jwtConfig.JWKS)
+		if err != nil {
+			return nil, fmt.Errorf("There's an error when loading JWKS => %w",
+				err)
+		}
+		a.keys = keys
+
+	default:
+		return nil, fmt.Errorf("JWT secret or JWKS empty/not found")
+	}
+
+	return a, nil
+}
+
+// Authorize verify token signature, expiration, issuer, and audience,
+// then get user from token claims
+func (a *JWTAuthorizer) Authorize(ctx context.Context,
+	token string) (User, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return User{}, fmt.Errorf("%w => JWT malformed", ErrTokenInvalid)
+	}
+
+	// get header and verify signature
+	var header jwtHeader
+	err := decodeJWTPart(parts[0], &header)
+	if err != nil {
+		return User{}, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return User{}, fmt.Errorf("%w => JWT signature malformed",
+			ErrTokenInvalid)
+	}
+
+	err = a.verifySignature(ctx, header, parts[0]+"."+parts[1], signature)
+	if err != nil {
+		return User{}, err
+	}
+
+	// get and check claims
+	var claims jwtClaims
+	err = decodeJWTPart(parts[1], &claims)
+	if err != nil {
+		return User{}, err
+	}
+
+	err = a.checkClaims(claims)
+	if err != nil {
+		return User{}, err
+	}
+
+	return getUserFromClaims(claims)
+}
+
+// verifySignature verify signature of signed content (header and claims)
+// with secret or public key of the token algorithm
+func (a *JWTAuthorizer) verifySignature(ctx context.Context, header jwtHeader,
+	signed string, signature []byte) error {
+	// secret only used for HS256, and public key only used for RS256/ES256,
+	// so token can't choose how it's verified
+	if a.secret != nil {
+		if header.Alg != AlgHS256 {
+			return fmt.Errorf("%w => JWT algorithm '%s' not allowed",
+				ErrTokenInvalid, header.Alg)
+		}
+
+		mac := hmac.New(sha256.New, a.secret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return fmt.Errorf("%w => JWT signature invalid", ErrTokenInvalid)
+		}
+
+		return nil
+	}
+
+	key, err := a.keys.Key(ctx, header.Kid, header.Alg)
+	if err != nil {
+		return err
+	}
+
+	return verifyPublicKeySignature(key, header.Alg, signed, signature)
+}
+
+// verifyPublicKeySignature verify RS256/ES256 signature
+// of signed content with public key
+func verifyPublicKeySignature(key crypto.PublicKey, alg string,
+	signed string, signature []byte) error {
+	digest := sha256.Sum256([]byte(signed))
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if alg != AlgRS256 {
+			break
+		}
+
+		err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature)
+		if err != nil {
+			return fmt.Errorf("%w => JWT signature invalid", ErrTokenInvalid)
+		}
+		return nil
+
+	case *ecdsa.PublicKey:
+		if alg != AlgES256 || key.Curve.Params().BitSize != 256 {
+			break
+		}
+
+		// ES256 signature is R and S of 32 bytes each
+		if len(signature) != 64 {
+			return fmt.Errorf("%w => JWT signature invalid", ErrTokenInvalid)
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(key, digest[:], r, s) {
+			return fmt.Errorf("%w => JWT signature invalid", ErrTokenInvalid)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("%w => JWT algorithm '%s' not allowed for the key",
+		ErrTokenInvalid, alg)
+}
+
+// checkClaims check token not expired, already valid,
+// and issued by issuer for audience, if they're set
+func (a *JWTAuthorizer) checkClaims(claims jwtClaims) error {
+	now := a.now()
+	if claims.ExpiresAt == nil {
+		return fmt.Errorf("%w => JWT expiration time empty/not found",
+			ErrTokenInvalid)
+	}
+	if now.After(getNumericDate(*claims.ExpiresAt).Add(a.leeway)) {
+		return fmt.Errorf("%w => JWT expired", ErrTokenInvalid)
+	}
+	if claims.NotBefore != nil &&
+		now.Add(a.leeway).Before(getNumericDate(*claims.NotBefore)) {
+		return fmt.Errorf("%w => JWT not valid yet", ErrTokenInvalid)
+	}
+
+	if a.issuer != "" && claims.Issuer != a.issuer {
+		return fmt.Errorf("%w => JWT issuer '%s' invalid",
+			ErrTokenInvalid, claims.Issuer)
+	}
+
+	if a.audience != "" {
+		for _, audience := range claims.Audience {
+			if audience == a.audience {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("%w => JWT audience %v invalid",
+			ErrTokenInvalid, []string(claims.Audience))
+	}
+
+	return nil
+}
+
+// getUserFromClaims get user from token claims
+func getUserFromClaims(claims jwtClaims) (User, error) {
+	user := User{
+		Email:       claims.Email,
+		FullName:    claims.FullName,
+		Address:     claims.Address,
+		PhoneNumber: claims.PhoneNumber,
+		Role:        claims.Role,
+	}
+
+	if claims.ID != nil {
+		user.ID = *claims.ID
+	} else {
+		id, err := strconv.Atoi(claims.Subject)
+		if err != nil {
+			return User{}, fmt.Errorf("%w => JWT user ID invalid",
+				ErrTokenInvalid)
+		}
+		user.ID = id
+	}
+
+	if user.ID <= 0 || user.Role == "" {
+		return User{}, fmt.Errorf("%w => JWT user ID or role empty/not found",
+			ErrTokenInvalid)
+	}
+
+	return user, nil
+}
+
+// decodeJWTPart decode base64url JSON part of JWT into v
+func decodeJWTPart(part string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return fmt.Errorf("%w => JWT malformed", ErrTokenInvalid)
+	}
+
+	err = json.Unmarshal(b, v)
+	if err != nil {
+		return fmt.Errorf("%w => JWT malformed", ErrTokenInvalid)
+	}
+
+	return nil
+}
+
+// getNumericDate get time of JWT numeric date (seconds since epoch)
+func getNumericDate(seconds float64) time.Time {
+	return time.Unix(0, int64(seconds*float64(time.Second)))
+}
@@ -0,0 +1,594 @@
+/*
+Package middleware collection of middleware used for API
+*/
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/reyhanfikridz/ecom-order-service/internal/problem"
+)
+
+// testingJWTTime current time when verifying testing JWT
+var testingJWTTime = time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+
+// signTestingJWT sign claims as JWT with algorithm and key ID,
+// key is secret for HS256 or private key for RS256/ES256
+func signTestingJWT(t *testing.T, alg string, kid string, key interface{},
+	claims map[string]interface{}) string {
+	header, err := json.Marshal(map[string]string{
+		"alg": alg, "kid": kid, "typ": "JWT",
+	})
+	if err != nil {
+		t.Fatalf("There's an error when marshal JWT header => %s", err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("There's an error when marshal JWT claims => %s", err)
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." +
+		base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch key := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256,
+			digest[:])
+
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, key, digest[:])
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+	if err != nil {
+		t.Fatalf("There's an error when signing JWT => %s", err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// getTestingJWTClaims get valid claims of testing JWT with changes
+func getTestingJWTClaims(changes map[string]interface{}) map[string]interface{} {
+	claims := map[string]interface{}{
+		"sub":       "1",
+		"iss":       "account-service",
+		"aud":       []string{"order-service"},
+		"exp":       testingJWTTime.Add(time.Hour).Unix(),
+		"nbf":       testingJWTTime.Add(-time.Hour).Unix(),
+		"email":     "buyer@gmail.com",
+		"full_name": "buyer",
+		"role":      "buyer",
+	}
+	for key, value := range changes {
+		if value == nil {
+			delete(claims, key)
+			continue
+		}
+		claims[key] = value
+	}
+
+	return claims
+}
+
+// getTestingJWKS get JWKS document of RSA and EC public key
+func getTestingJWKS(rsaKey *rsa.PublicKey, ecKey *ecdsa.PublicKey) []byte {
+	encode := func(b []byte) string {
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+
+	b, _ := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{
+			{
+				"kty": "RSA", "kid": "rsa1", "use": "sig", "alg": AlgRS256,
+				"n": encode(rsaKey.N.Bytes()),
+				"e": encode(big.NewInt(int64(rsaKey.E)).Bytes()),
+			},
+			{
+				"kty": "EC", "kid": "ec1", "use": "sig", "alg": AlgES256,
+				"crv": "P-256",
+				"x":   encode(ecKey.X.FillBytes(make([]byte, 32))),
+				"y":   encode(ecKey.Y.FillBytes(make([]byte, 32))),
+			},
+			{"kty": "oct", "kid": "secret1", "k": "c2VjcmV0"},
+		},
+	})
+
+	return b
+}
+
+// TestJWTAuthorizer test JWTAuthorizer
+func TestJWTAuthorizer(t *testing.T) {
+	ctx := context.Background()
+	secret := []byte("secret")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("There's an error when generating RSA key => %s", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("There's an error when generating EC key => %s", err)
+	}
+	otherRSAKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("There's an error when generating RSA key => %s", err)
+	}
+
+	// write JWKS to file
+	jwksPath := filepath.Join(t.TempDir(), "jwks.json")
+	err = os.WriteFile(jwksPath, getTestingJWKS(&rsaKey.PublicKey,
+		&ecKey.PublicKey), 0644)
+	if err != nil {
+		t.Fatalf("There's an error when writing JWKS => %s", err)
+	}
+
+	hsConfig := JWTConfig{
+		Secret: string(secret), Issuer: "account-service",
+		Audience: "order-service",
+	}
+	jwksConfig := JWTConfig{
+		JWKS: jwksPath, Issuer: "account-service", Audience: "order-service",
+	}
+
+	// initialize testing table
+	testTable := []struct {
+		TestName      string
+		Config        JWTConfig
+		Token         string
+		ExpectedUser  User
+		ExpectedError error
+	}{
+		{
+			TestName: "Test HS256 Valid",
+			Config:   hsConfig,
+			Token: signTestingJWT(t, AlgHS256, "", secret,
+				getTestingJWTClaims(nil)),
+			ExpectedUser: User{ID: 1, Email: "buyer@gmail.com",
+				FullName: "buyer", Role: "buyer"},
+		},
+		{
+			TestName: "Test RS256 Valid",
+			Config:   jwksConfig,
+			Token: signTestingJWT(t, AlgRS256, "rsa1", rsaKey,
+				getTestingJWTClaims(map[string]interface{}{
+					"sub": nil, "id": 20, "role": "seller",
+					"aud": "order-service",
+				})),
+			ExpectedUser: User{ID: 20, Email: "buyer@gmail.com",
+				FullName: "buyer", Role: "seller"},
+		},
+		{
+			TestName: "Test ES256 Valid",
+			Config:   jwksConfig,
+			Token: signTestingJWT(t, AlgES256, "ec1", ecKey,
+				getTestingJWTClaims(nil)),
+			ExpectedUser: User{ID: 1, Email: "buyer@gmail.com",
+				FullName: "buyer", Role: "buyer"},
+		},
+		{
+			TestName: "Test Expired",
+			Config:   hsConfig,
+			Token: signTestingJWT(t, AlgHS256, "", secret,
+				getTestingJWTClaims(map[string]interface{}{
+					"exp": testingJWTTime.Add(-2 * time.Minute).Unix(),
+				})),
+			ExpectedError: ErrTokenInvalid,
+		},
+		{
+			TestName: "Test Expired Within Leeway",
+			Config: JWTConfig{Secret: string(secret),
+				Leeway: 5 * time.Minute},
+			Token: signTestingJWT(t, AlgHS256, "", secret,
+				getTestingJWTClaims(map[string]interface{}{
+					"exp": testingJWTTime.Add(-2 * time.Minute).Unix(),
+				})),
+			ExpectedUser: User{ID: 1, Email: "buyer@gmail.com",
+				FullName: "buyer", Role: "buyer"},
+		},
+		{
+			TestName: "Test Without Expiration",
+			Config:   hsConfig,
+			Token: signTestingJWT(t, AlgHS256, "", secret,
+				getTestingJWTClaims(map[string]interface{}{"exp": nil})),
+			ExpectedError: ErrTokenInvalid,
+		},
+		{
+			TestName: "Test Not Valid Yet",
+			Config:   hsConfig,
+			Token: signTestingJWT(t, AlgHS256, "", secret,
+				getTestingJWTClaims(map[string]interface{}{
+					"nbf": testingJWTTime.Add(time.Hour).Unix(),
+				})),
+			ExpectedError: ErrTokenInvalid,
+		},
+		{
+			TestName: "Test Issuer Invalid",
+			Config:   hsConfig,
+			Token: signTestingJWT(t, AlgHS256, "", secret,
+				getTestingJWTClaims(map[string]interface{}{"iss": "other"})),
+			ExpectedError: ErrTokenInvalid,
+		},
+		{
+			TestName: "Test Audience Invalid",
+			Config:   hsConfig,
+			Token: signTestingJWT(t, AlgHS256, "", secret,
+				getTestingJWTClaims(map[string]interface{}{
+					"aud": []string{"product-service"},
+				})),
+			ExpectedError: ErrTokenInvalid,
+		},
+		{
+			TestName: "Test Audience String Invalid",
+			Config:   jwksConfig,
+			Token: signTestingJWT(t, AlgRS256, "rsa1", rsaKey,
+				getTestingJWTClaims(map[string]interface{}{
+					"aud": "product-service",
+				})),
+			ExpectedError: ErrTokenInvalid,
+		},
+		{
+			TestName: "Test Without Audience",
+			Config:   hsConfig,
+			Token: signTestingJWT(t, AlgHS256, "", secret,
+				getTestingJWTClaims(map[string]interface{}{"aud": nil})),
+			ExpectedError: ErrTokenInvalid,
+		},
+		{
+			TestName: "Test Signature Invalid",
+			Config:   hsConfig,
+			Token: signTestingJWT(t, AlgHS256, "", []byte("other"),
+				getTestingJWTClaims(nil)),
+			ExpectedError: ErrTokenInvalid,
+		},
+		{
+			TestName: "Test Signed By Unknown Key",
+			Config:   jwksConfig,
+			Token: signTestingJWT(t, AlgRS256, "rsa1", otherRSAKey,
+				getTestingJWTClaims(nil)),
+			ExpectedError: ErrTokenInvalid,
+		},
+		{
+			TestName: "Test Key ID Not Found",
+			Config:   jwksConfig,
+			Token: signTestingJWT(t, AlgRS256, "rsa2", rsaKey,
+				getTestingJWTClaims(nil)),
+			ExpectedError: ErrTokenInvalid,
+		},
+		{
+			TestName: "Test Algorithm Not Match Key",
+			Config:   jwksConfig,
+			Token: signTestingJWT(t, AlgES256, "rsa1", ecKey,
+				getTestingJWTClaims(nil)),
+			ExpectedError: ErrTokenInvalid,
+		},
+		{
+			TestName: "Test HS256 With JWKS",
+			Config:   jwksConfig,
+			Token: signTestingJWT(t, AlgHS256, "secret1", []byte("secret"),
+				getTestingJWTClaims(nil)),
+			ExpectedError: ErrTokenInvalid,
+		},
+		{
+			TestName: "Test Algorithm None",
+			Config:   hsConfig,
+			Token: base64.RawURLEncoding.EncodeToString(
+				[]byte(`{"alg":"none"}`)) + "." +
+				base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"1"}`)) + ".",
+			ExpectedError: ErrTokenInvalid,
+		},
+		{
+			TestName: "Test Without Role",
+			Config:   hsConfig,
+			Token: signTestingJWT(t, AlgHS256, "", secret,
+				getTestingJWTClaims(map[string]interface{}{"role": nil})),
+			ExpectedError: ErrTokenInvalid,
+		},
+		{
+			TestName:      "Test Malformed",
+			Config:        hsConfig,
+			Token:         "This is valid token",
+			ExpectedError: ErrTokenInvalid,
+		},
+	}
+
+	// test for each testing table
+	for _, test := range testTable {
+		a, err := NewJWTAuthorizer(ctx, test.Config)
+		if err != nil {
+			t.Fatalf("[%s] There's an error when creating JWT authorizer => %s",
+				test.TestName, err)
+		}
+		a.now = func() time.Time { return testingJWTTime }
+
+		u, err := a.Authorize(ctx, test.Token)
+		if !errors.Is(err, test.ExpectedError) {
+			t.Errorf("[%s] Expected error %v, but got %v", test.TestName,
+				test.ExpectedError, err)
+			continue
+		}
+		if !reflect.DeepEqual(u, test.ExpectedUser) {
+			t.Errorf("[%s] Expected user %+v, but got %+v", test.TestName,
+				test.ExpectedUser, u)
+		}
+	}
+}
+
+// TestNewJWTAuthorizer test NewJWTAuthorizer and NewTokenAuthorizer
+// configuration
+func TestNewJWTAuthorizer(t *testing.T) {
+	ctx := context.Background()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("There's an error when generating RSA key => %s", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("There's an error when generating EC key => %s", err)
+	}
+
+	// serve JWKS from URL
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(getTestingJWKS(&rsaKey.PublicKey, &ecKey.PublicKey))
+		}))
+	defer server.Close()
+
+	// initialize testing table
+	testTable := []struct {
+		TestName      string
+		Mode          string
+		Config        JWTConfig
+		ExpectedError bool
+	}{
+		{
+			TestName: "Test Account Service Mode",
+			Mode:     "",
+		},
+		{
+			TestName: "Test JWT Mode With JWKS URL",
+			Mode:     AuthModeJWT,
+			Config:   JWTConfig{JWKS: server.URL},
+		},
+		{
+			TestName:      "Test JWT Mode With JWKS Not Found",
+			Mode:          AuthModeJWT,
+			Config:        JWTConfig{JWKS: server.URL + "/not-found.json"},
+			ExpectedError: true,
+		},
+		{
+			TestName:      "Test JWT Mode Without Key",
+			Mode:          AuthModeJWT,
+			ExpectedError: true,
+		},
+		{
+			TestName:      "Test JWT Mode With Secret And JWKS",
+			Mode:          AuthModeJWT,
+			Config:        JWTConfig{Secret: "secret", JWKS: server.URL},
+			ExpectedError: true,
+		},
+		{
+			TestName:      "Test Mode Invalid",
+			Mode:          "basic",
+			ExpectedError: true,
+		},
+	}
+
+	// test for each testing table
+	for _, test := range testTable {
+		_, err := NewTokenAuthorizer(ctx, test.Mode,
+			AccountServiceConfig{URL: "http://localhost"}, test.Config)
+		if (err != nil) != test.ExpectedError {
+			t.Errorf("[%s] Expected error %t, but got %v", test.TestName,
+				test.ExpectedError, err)
+		}
+	}
+
+	// token signed by key in JWKS from URL verified
+	a, err := NewJWTAuthorizer(ctx, JWTConfig{JWKS: server.URL})
+	if err != nil {
+		t.Fatalf("There's an error when creating JWT authorizer => %s", err)
+	}
+	a.now = func() time.Time { return testingJWTTime }
+
+	u, err := a.Authorize(ctx, signTestingJWT(t, AlgES256, "ec1", ecKey,
+		getTestingJWTClaims(nil)))
+	if err != nil || u.ID != 1 {
+		t.Errorf("Expected token authorized as user 1, but got %+v => %v", u, err)
+	}
+}
+
+// TestJWKSRefresh test JWKS from URL refreshed once by concurrent tokens
+// signed by unknown key, and failed refresh not retried immediately
+func TestJWKSRefresh(t *testing.T) {
+	ctx := context.Background()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("There's an error when generating RSA key => %s", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("There's an error when generating EC key => %s", err)
+	}
+	otherRSAKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("There's an error when generating RSA key => %s", err)
+	}
+
+	// serve JWKS from URL, counting fetches
+	var mu sync.Mutex
+	fetches := 0
+	failing := false
+	doc := getTestingJWKS(&rsaKey.PublicKey, &ecKey.PublicKey)
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			fetches++
+			if failing {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.Write(doc)
+		}))
+	defer server.Close()
+
+	keys, err := LoadJWKS(ctx, server.URL)
+	if err != nil {
+		t.Fatalf("There's an error when loading JWKS => %s", err)
+	}
+
+	// concurrent tokens signed by unknown key while JWKS URL failing
+	mu.Lock()
+	failing = true
+	mu.Unlock()
+	keys.refreshedAt = time.Now().Add(-jwksRefreshInterval)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 10)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = keys.Key(ctx, "unknown", AlgRS256)
+		}(i)
+	}
+	wg.Wait()
+
+	unavailable := 0
+	for _, err := range errs {
+		var unavailableErr *UnavailableError
+		switch {
+		case errors.As(err, &unavailableErr):
+			unavailable++
+		case !errors.Is(err, ErrTokenInvalid):
+			t.Errorf("Expected token invalid or unavailable, but got %v", err)
+		}
+	}
+	if fetches != 2 || unavailable != 1 {
+		t.Errorf("Expected JWKS fetched once more and 1 unavailable error, "+
+			"but got %d fetches and %d unavailable errors", fetches-1,
+			unavailable)
+	}
+
+	// failed refresh not retried before refresh interval
+	_, err = keys.Key(ctx, "unknown", AlgRS256)
+	if !errors.Is(err, ErrTokenInvalid) || fetches != 2 {
+		t.Errorf("Expected token invalid without fetching JWKS, "+
+			"but got %v and %d fetches", err, fetches)
+	}
+
+	// rotated key loaded after refresh interval
+	mu.Lock()
+	failing = false
+	doc = getTestingJWKS(&otherRSAKey.PublicKey, &ecKey.PublicKey)
+	mu.Unlock()
+	keys.refreshedAt = time.Now().Add(-jwksRefreshInterval)
+
+	key, err := keys.Key(ctx, "unknown", AlgRS256)
+	if !errors.Is(err, ErrTokenInvalid) || fetches != 3 {
+		t.Errorf("Expected token invalid after fetching JWKS, "+
+			"but got %v and %d fetches", err, fetches)
+	}
+	key, err = keys.Key(ctx, "rsa1", AlgRS256)
+	if err != nil || !reflect.DeepEqual(key, &otherRSAKey.PublicKey) {
+		t.Errorf("Expected rotated RSA key, but got %v => %v", key, err)
+	}
+}
+
+// TestAuthorizationMiddleware test AuthorizationMiddleware
+func TestAuthorizationMiddleware(t *testing.T) {
+	secret := []byte("secret")
+	a, err := NewJWTAuthorizer(context.Background(),
+		JWTConfig{Secret: string(secret)})
+	if err != nil {
+		t.Fatalf("There's an error when creating JWT authorizer => %s", err)
+	}
+	a.now = func() time.Time { return testingJWTTime }
+
+	// initialize testing table
+	testTable := []struct {
+		TestName       string
+		Authorization  string
+		ExpectedStatus int
+		ExpectedUser   User
+	}{
+		{
+			TestName: "Test Authorized",
+			Authorization: "Bearer " + signTestingJWT(t, AlgHS256, "", secret,
+				getTestingJWTClaims(nil)),
+			ExpectedStatus: http.StatusOK,
+			ExpectedUser: User{ID: 1, Email: "buyer@gmail.com",
+				FullName: "buyer", Role: "buyer"},
+		},
+		{
+			TestName: "Test Token Invalid",
+			Authorization: "Bearer " + signTestingJWT(t, AlgHS256, "",
+				[]byte("other"), getTestingJWTClaims(nil)),
+			ExpectedStatus: http.StatusUnauthorized,
+		},
+		{
+			TestName:       "Test Token Empty",
+			ExpectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	// test for each testing table
+	e := echo.New()
+	e.HTTPErrorHandler = problem.HTTPErrorHandler
+	for _, test := range testTable {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", test.Authorization)
+		response := httptest.NewRecorder()
+		c := e.NewContext(req, response)
+
+		var u User
+		err := AuthorizationMiddleware(a, nil)(func(c echo.Context) error {
+			u, _ = c.Get("user").(User)
+			return c.NoContent(http.StatusOK)
+		})(c)
+		if err != nil {
+			c.Error(err)
+		}
+
+		if response.Code != test.ExpectedStatus {
+			t.Errorf("[%s] Expected status %d got %d", test.TestName,
+				test.ExpectedStatus, response.Code)
+		}
+		if !reflect.DeepEqual(u, test.ExpectedUser) {
+			t.Errorf("[%s] Expected user %+v, but got %+v", test.TestName,
+				test.ExpectedUser, u)
+		}
+	}
+}
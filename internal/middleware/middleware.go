@@ -5,14 +5,19 @@ package middleware
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
-	"github.com/reyhanfikridz/ecom-order-service/internal/config"
+	"github.com/reyhanfikridz/ecom-order-service/internal/problem"
 )
 
 // User containing user data after authorization
@@ -24,68 +29,233 @@ type User struct {
 	Address     string `json:"address"`
 	PhoneNumber string `json:"phone_number"`
 	Role        string `json:"role"`
+
+	// Service and Scopes name and scopes of internal service
+	// authorized by API key, empty for user authorized by token
+	Service string   `json:"-"`
+	Scopes  []string `json:"-"`
 }
 
-// AuthorizationMiddleware authorize each API route by checking JWT Token
-func AuthorizationMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
-	return func(c echo.Context) error {
-		// get token
-		token := GetTokenFromHeader(c.Request().Header)
-		if token == "" {
-			return c.JSON(http.StatusForbidden, map[string]string{
-				"message": "Token authorization empty/not found",
-			})
-		}
+// authorization modes, how token is authorized
+const (
+	// AuthModeAccountService token authorized by account service
+	AuthModeAccountService = "account-service"
 
-		// set form data
-		formData := map[string]io.Reader{
-			"token": strings.NewReader(token),
-		}
+	// AuthModeJWT token verified locally as JWT
+	AuthModeJWT = "jwt"
+)
 
-		// transform form data to bytes buffer
-		var bFormData bytes.Buffer
-		bFormDataWriter := multipart.NewWriter(&bFormData)
-		for key, formDataReader := range formData {
-			fieldWriter, err := bFormDataWriter.CreateFormField(key)
-			if err != nil {
-				return c.JSON(http.StatusInternalServerError, map[string]string{
-					"message": err.Error(),
-				})
+// ErrTokenInvalid returned when token is rejected by authorizer
+var ErrTokenInvalid = errors.New("token invalid")
+
+// ErrAuthorizationCancelled returned when token authorization stopped
+// because the request cancelled by client
+var ErrAuthorizationCancelled = errors.New("authorization cancelled")
+
+// TokenAuthorizer authorizer of token, get user owning the token
+type TokenAuthorizer interface {
+	// Authorize get user of token,
+	// return ErrTokenInvalid if the token is rejected
+	Authorize(ctx context.Context, token string) (User, error)
+}
+
+// NewTokenAuthorizer get token authorizer by authorization mode
+// (account-service or jwt), empty mode means account-service
+//
+// accountService only used by account-service mode,
+// jwtConfig only used by jwt mode
+func NewTokenAuthorizer(ctx context.Context, mode string,
+	accountService AccountServiceConfig,
+	jwtConfig JWTConfig) (TokenAuthorizer, error) {
+	switch mode {
+	case "", AuthModeAccountService:
+		return NewCachedAuthorizer(
+			NewAccountServiceAuthorizer(accountService.URL,
+				accountService.Timeout),
+			NewCircuitBreaker(accountService.FailureThreshold,
+				accountService.OpenTimeout),
+			accountService.CacheTTL, accountService.CacheSize), nil
+	case AuthModeJWT:
+		return NewJWTAuthorizer(ctx, jwtConfig)
+	}
+
+	return nil, fmt.Errorf("authorization mode '%s' invalid", mode)
+}
+
+// AuthorizationMiddleware authorize each API route by checking
+// bearer token with authorizer, or API key of internal service
+// with serviceKeys, the user of the token or the service set as "user"
+func AuthorizationMiddleware(authorizer TokenAuthorizer,
+	serviceKeys ServiceKeys) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			// authorize internal service by API key
+			apiKey := GetAPIKeyFromHeader(c.Request().Header)
+			if apiKey != "" {
+				service, err := serviceKeys.Authorize(apiKey)
+				if err != nil {
+					return problem.New(http.StatusUnauthorized,
+						problem.CodeUnauthorized, "API key invalid")
+				}
+
+				c.Set("user", service)
+				return next(c)
 			}
 
-			_, err = io.Copy(fieldWriter, formDataReader)
+			// get token
+			token := GetTokenFromHeader(c.Request().Header)
+			if token == "" {
+				return problem.New(http.StatusUnauthorized,
+					problem.CodeUnauthorized,
+					"Token authorization empty/not found")
+			}
+
+			// authorize token, service role only for API key
+			user, err := authorizer.Authorize(c.Request().Context(), token)
+			if errors.Is(err, ErrTokenInvalid) ||
+				(err == nil && user.Role == RoleService) {
+				return problem.New(http.StatusUnauthorized,
+					problem.CodeUnauthorized, "Token authorization invalid")
+			}
+
+			// client already gone, so nothing failed
+			// and the response only seen in logs
+			if errors.Is(err, ErrAuthorizationCancelled) ||
+				errors.Is(err, context.Canceled) {
+				return problem.New(problem.StatusClientClosedRequest,
+					problem.CodeClientClosedRequest,
+					"Request cancelled by client")
+			}
+
+			var unavailableErr *UnavailableError
+			if errors.As(err, &unavailableErr) {
+				// retry after in seconds, rounded up
+				retryAfter := int((unavailableErr.RetryAfter + time.Second - 1) /
+					time.Second)
+				if retryAfter < 1 {
+					retryAfter = 1
+				}
+				c.Response().Header().Set("Retry-After",
+					strconv.Itoa(retryAfter))
+
+				return problem.New(http.StatusServiceUnavailable,
+					problem.CodeServiceUnavailable,
+					"Token authorization unavailable, retry later").
+					WithCause(err)
+			}
 			if err != nil {
-				return c.JSON(http.StatusInternalServerError, map[string]string{
-					"message": err.Error(),
-				})
+				return problem.Internal(fmt.Errorf(
+					"There's an error when authorizing token => %w", err))
 			}
+
+			c.Set("user", user)
+			return next(c)
 		}
-		bFormDataWriter.Close()
-
-		// authorize to account service
-		resp, err := http.Post(config.AccountServiceURL+"/api/authorize/",
-			bFormDataWriter.FormDataContentType(),
-			&bFormData)
-		if err != nil { // if error occured
-			return c.JSON(http.StatusInternalServerError, map[string]string{
-				"message": err.Error(),
-			})
-		}
-		if resp.StatusCode != http.StatusOK { // if unauthorized
-			return c.JSON(http.StatusForbidden, "Token authorization invalid")
+	}
+}
+
+// AccountServiceConfig configuration of token authorization
+// by account service
+type AccountServiceConfig struct {
+	// URL base URL of account service
+	URL string
+
+	// Timeout max time of each authorization request
+	Timeout time.Duration
+
+	// CacheTTL and CacheSize time and max number of authorized users cached,
+	// users not cached if one of them is zero
+	CacheTTL  time.Duration
+	CacheSize int
+
+	// FailureThreshold consecutive failed authorizations before
+	// account service considered down, and OpenTimeout time until
+	// it's called again
+	FailureThreshold int
+	OpenTimeout      time.Duration
+}
+
+// AccountServiceAuthorizer authorizer of token by account service
+type AccountServiceAuthorizer struct {
+	// URL base URL of account service
+	URL    string
+	Client *http.Client
+}
+
+// NewAccountServiceAuthorizer create authorizer of token
+// by account service in URL, each request timed out after timeout
+func NewAccountServiceAuthorizer(url string,
+	timeout time.Duration) *AccountServiceAuthorizer {
+	// every request goes to the same host,
+	// so keep more idle connections for reuse
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = 100
+	transport.MaxIdleConnsPerHost = 100
+
+	return &AccountServiceAuthorizer{
+		URL:    url,
+		Client: &http.Client{Timeout: timeout, Transport: transport},
+	}
+}
+
+// Authorize get user of token from account service
+func (a *AccountServiceAuthorizer) Authorize(ctx context.Context,
+	token string) (User, error) {
+	// set form data
+	formData := map[string]io.Reader{
+		"token": strings.NewReader(token),
+	}
+
+	// transform form data to bytes buffer
+	var bFormData bytes.Buffer
+	bFormDataWriter := multipart.NewWriter(&bFormData)
+	for key, formDataReader := range formData {
+		fieldWriter, err := bFormDataWriter.CreateFormField(key)
+		if err != nil {
+			return User{}, err
 		}
 
-		// get user data from authorization response
-		user, err := GetUserFromAuthorizationResp(resp)
+		_, err = io.Copy(fieldWriter, formDataReader)
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{
-				"message": err.Error(),
-			})
+			return User{}, err
 		}
+	}
+	bFormDataWriter.Close()
+
+	// authorize to account service
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		a.URL+"/api/authorize/", &bFormData)
+	if err != nil {
+		return User{}, err
+	}
+	req.Header.Set("Content-Type", bFormDataWriter.FormDataContentType())
+
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return User{}, err
+	}
+	defer func() {
+		// body read until the end so the connection can be reused
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}()
 
-		c.Set("user", user)
-		return next(c)
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return User{}, fmt.Errorf("account service response status %d",
+			resp.StatusCode)
 	}
+	if resp.StatusCode != http.StatusOK { // if unauthorized
+		return User{}, ErrTokenInvalid
+	}
+
+	// get user data from authorization response
+	user, err := GetUserFromAuthorizationResp(resp)
+	if err != nil {
+		return User{}, fmt.Errorf(
+			"There's an error when getting authorized user => %w", err)
+	}
+
+	return user, nil
 }
 
 // GetTokenFromHeader getting token (bearer) from request header
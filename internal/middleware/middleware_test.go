@@ -5,10 +5,17 @@ package middleware
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/reyhanfikridz/ecom-order-service/internal/problem"
 )
 
 // TestGetTokenFromHeader test GetTokenFromHeader
@@ -71,3 +78,197 @@ func TestGetUserDataFromAuthorizationResp(t *testing.T) {
 		t.Errorf("Expected Role %s, but got %s", expectedU.Role, u.Role)
 	}
 }
+
+// TestAccountServiceAuthorizer test AccountServiceAuthorizer
+func TestAccountServiceAuthorizer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.FormValue("token") {
+			case "valid":
+				json.NewEncoder(w).Encode(User{ID: 1, Role: "buyer"})
+			case "slow":
+				time.Sleep(200 * time.Millisecond)
+				json.NewEncoder(w).Encode(User{ID: 1, Role: "buyer"})
+			case "down":
+				w.WriteHeader(http.StatusBadGateway)
+			default:
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		}))
+	defer server.Close()
+
+	// initialize testing table
+	testTable := []struct {
+		TestName        string
+		Token           string
+		ExpectedUserID  int
+		ExpectedInvalid bool
+		ExpectedError   bool
+	}{
+		{
+			TestName:       "Test Valid",
+			Token:          "valid",
+			ExpectedUserID: 1,
+		},
+		{
+			TestName:        "Test Invalid",
+			Token:           "invalid",
+			ExpectedInvalid: true,
+			ExpectedError:   true,
+		},
+		{
+			TestName:      "Test Account Service Error",
+			Token:         "down",
+			ExpectedError: true,
+		},
+		{
+			TestName:      "Test Timeout",
+			Token:         "slow",
+			ExpectedError: true,
+		},
+	}
+
+	// test for each testing table
+	a := NewAccountServiceAuthorizer(server.URL, 100*time.Millisecond)
+	for _, test := range testTable {
+		u, err := a.Authorize(context.Background(), test.Token)
+		if (err != nil) != test.ExpectedError {
+			t.Errorf("[%s] Expected error %t, but got %v", test.TestName,
+				test.ExpectedError, err)
+		}
+		if errors.Is(err, ErrTokenInvalid) != test.ExpectedInvalid {
+			t.Errorf("[%s] Expected token invalid %t, but got %v",
+				test.TestName, test.ExpectedInvalid, err)
+		}
+		if u.ID != test.ExpectedUserID {
+			t.Errorf("[%s] Expected user ID %d, but got %d", test.TestName,
+				test.ExpectedUserID, u.ID)
+		}
+	}
+}
+
+// TestAuthorizationMiddlewareUnavailable test AuthorizationMiddleware
+// respond 503 with Retry-After when account service down
+func TestAuthorizationMiddlewareUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+	defer server.Close()
+
+	authorizer, err := NewTokenAuthorizer(context.Background(), "",
+		AccountServiceConfig{
+			URL:              server.URL,
+			Timeout:          time.Second,
+			CacheTTL:         time.Minute,
+			CacheSize:        10,
+			FailureThreshold: 2,
+			OpenTimeout:      30 * time.Second,
+		}, JWTConfig{})
+	if err != nil {
+		t.Fatalf("There's an error when creating authorizer => %s", err)
+	}
+
+	// initialize testing table, each step run in order
+	testTable := []struct {
+		TestName           string
+		ExpectedRetryAfter string
+	}{
+		{
+			TestName:           "Test Failure",
+			ExpectedRetryAfter: "1",
+		},
+		{
+			TestName:           "Test Failure Open Circuit",
+			ExpectedRetryAfter: "30",
+		},
+		{
+			TestName:           "Test Circuit Open",
+			ExpectedRetryAfter: "30",
+		},
+	}
+
+	// test for each testing table
+	e := echo.New()
+	e.HTTPErrorHandler = problem.HTTPErrorHandler
+	for _, test := range testTable {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer This is valid token")
+		response := httptest.NewRecorder()
+		c := e.NewContext(req, response)
+
+		err := AuthorizationMiddleware(authorizer, nil)(func(c echo.Context) error {
+			return c.NoContent(http.StatusOK)
+		})(c)
+		if err != nil {
+			c.Error(err)
+		}
+
+		if response.Code != http.StatusServiceUnavailable {
+			t.Errorf("[%s] Expected status %d got %d", test.TestName,
+				http.StatusServiceUnavailable, response.Code)
+		}
+		if response.Header().Get("Retry-After") != test.ExpectedRetryAfter {
+			t.Errorf("[%s] Expected Retry-After %s got %s", test.TestName,
+				test.ExpectedRetryAfter, response.Header().Get("Retry-After"))
+		}
+	}
+}
+
+// TestAuthorizationMiddlewareCancelled test AuthorizationMiddleware
+// respond 499 instead of internal error when client cancelled the request
+// while token authorized, and 503 when the request deadline exceeded
+func TestAuthorizationMiddlewareCancelled(t *testing.T) {
+	next := &testingAuthorizer{release: make(chan struct{})}
+	defer close(next.release)
+	authorizer := NewCachedAuthorizer(next, NewCircuitBreaker(5, time.Second),
+		time.Minute, 10)
+
+	// initialize testing table
+	testTable := []struct {
+		TestName       string
+		Deadline       bool
+		ExpectedStatus int
+	}{
+		{
+			TestName:       "Test Cancelled By Client",
+			ExpectedStatus: problem.StatusClientClosedRequest,
+		},
+		{
+			TestName:       "Test Deadline Exceeded",
+			Deadline:       true,
+			ExpectedStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	// test for each testing table
+	e := echo.New()
+	e.HTTPErrorHandler = problem.HTTPErrorHandler
+	for _, test := range testTable {
+		ctx, cancel := context.WithCancel(context.Background())
+		if test.Deadline {
+			ctx, cancel = context.WithTimeout(context.Background(),
+				10*time.Millisecond)
+		} else {
+			time.AfterFunc(10*time.Millisecond, cancel)
+		}
+
+		req := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+		req.Header.Set("Authorization", "Bearer This is valid token")
+		response := httptest.NewRecorder()
+		c := e.NewContext(req, response)
+
+		err := AuthorizationMiddleware(authorizer, nil)(func(c echo.Context) error {
+			return c.NoContent(http.StatusOK)
+		})(c)
+		if err != nil {
+			c.Error(err)
+		}
+		cancel()
+
+		if response.Code != test.ExpectedStatus {
+			t.Errorf("[%s] Expected status %d got %d", test.TestName,
+				test.ExpectedStatus, response.Code)
+		}
+	}
+}
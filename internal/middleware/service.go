@@ -0,0 +1,115 @@
+/*
+Package middleware collection of middleware used for API
+*/
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// HeaderAPIKey request header containing API key of internal service
+const HeaderAPIKey = "X-API-Key"
+
+// RoleService role of internal service authorized by API key
+const RoleService = "service"
+
+// ServiceKey API key of internal service and scopes it's allowed to access
+type ServiceKey struct {
+	Service string
+	Scopes  []string
+
+	// keyHash SHA-256 hash of the key, so keys compared in constant time
+	keyHash [sha256.Size]byte
+}
+
+// ServiceKeys API keys of all internal services
+type ServiceKeys []ServiceKey
+
+// ParseServiceKeys parse API keys of internal services from s,
+// each service formatted as "service:key:scope1,scope2"
+// and separated by ";", e.g.
+// "product-service:secret1:orders:read;account-service:secret2:orders:read"
+//
+// scope can contain ":", so only the first two ":" separate the fields
+func ParseServiceKeys(s string) (ServiceKeys, error) {
+	keys := ServiceKeys{}
+	for i, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		fields := strings.SplitN(entry, ":", 3)
+		if len(fields) != 3 || fields[0] == "" || fields[1] == "" {
+			return nil, fmt.Errorf("service key %d invalid => "+
+				"must be formatted as service:key:scopes", i)
+		}
+
+		scopes := []string{}
+		for _, scope := range strings.Split(fields[2], ",") {
+			scope = strings.TrimSpace(scope)
+			if scope != "" {
+				scopes = append(scopes, scope)
+			}
+		}
+		if len(scopes) == 0 {
+			return nil, fmt.Errorf("service key %d invalid => "+
+				"scopes empty/not found", i)
+		}
+
+		keys = append(keys, ServiceKey{
+			Service: fields[0],
+			Scopes:  scopes,
+			keyHash: sha256.Sum256([]byte(fields[1])),
+		})
+	}
+
+	return keys, nil
+}
+
+// Authorize get service user of API key,
+// return ErrTokenInvalid if there's no service with the key
+func (keys ServiceKeys) Authorize(key string) (User, error) {
+	// every key compared so time taken doesn't tell which key matched
+	keyHash := sha256.Sum256([]byte(key))
+	found := -1
+	for i, k := range keys {
+		if subtle.ConstantTimeCompare(keyHash[:], k.keyHash[:]) == 1 {
+			found = i
+		}
+	}
+	if found < 0 {
+		return User{}, ErrTokenInvalid
+	}
+
+	return User{
+		FullName: keys[found].Service,
+		Role:     RoleService,
+		Service:  keys[found].Service,
+		Scopes:   append([]string{}, keys[found].Scopes...),
+	}, nil
+}
+
+// HasScope check if user is internal service allowed to access scope
+func (u User) HasScope(scope string) bool {
+	if u.Role != RoleService {
+		return false
+	}
+
+	for _, s := range u.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetAPIKeyFromHeader get API key of internal service from request header
+func GetAPIKeyFromHeader(header http.Header) string {
+	return strings.TrimSpace(header.Get(HeaderAPIKey))
+}
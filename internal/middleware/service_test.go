@@ -0,0 +1,178 @@
+/*
+Package middleware collection of middleware used for API
+*/
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/reyhanfikridz/ecom-order-service/internal/problem"
+)
+
+// TestParseServiceKeys test ParseServiceKeys and ServiceKeys.Authorize
+func TestParseServiceKeys(t *testing.T) {
+	// initialize testing table
+	testTable := []struct {
+		TestName      string
+		Value         string
+		Key           string
+		ExpectedUser  User
+		ExpectedError bool
+	}{
+		{
+			TestName: "Test Valid",
+			Value: "product-service:secret1:orders:read,orders:status; " +
+				"account-service:secret2:orders:read",
+			Key: "secret2",
+			ExpectedUser: User{FullName: "account-service", Role: RoleService,
+				Service: "account-service", Scopes: []string{"orders:read"}},
+		},
+		{
+			TestName: "Test Valid Multiple Scopes",
+			Value:    "product-service:secret1:orders:read, orders:status;",
+			Key:      "secret1",
+			ExpectedUser: User{FullName: "product-service", Role: RoleService,
+				Service: "product-service",
+				Scopes:  []string{"orders:read", "orders:status"}},
+		},
+		{
+			TestName: "Test Empty",
+			Value:    "",
+			Key:      "secret1",
+		},
+		{
+			TestName:      "Test Without Key",
+			Value:         "product-service::orders:read",
+			ExpectedError: true,
+		},
+		{
+			TestName:      "Test Without Scopes",
+			Value:         "product-service:secret1:",
+			ExpectedError: true,
+		},
+		{
+			TestName:      "Test Malformed",
+			Value:         "product-service",
+			ExpectedError: true,
+		},
+	}
+
+	// test for each testing table
+	for _, test := range testTable {
+		keys, err := ParseServiceKeys(test.Value)
+		if (err != nil) != test.ExpectedError {
+			t.Errorf("[%s] Expected error %t, but got %v", test.TestName,
+				test.ExpectedError, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		u, err := keys.Authorize(test.Key)
+		if test.ExpectedUser.Service == "" {
+			if !errors.Is(err, ErrTokenInvalid) {
+				t.Errorf("[%s] Expected key invalid, but got %v",
+					test.TestName, err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(u, test.ExpectedUser) {
+			t.Errorf("[%s] Expected user %+v, but got %+v => %v",
+				test.TestName, test.ExpectedUser, u, err)
+		}
+	}
+}
+
+// TestAuthorizationMiddlewareService test AuthorizationMiddleware
+// authorize internal service by API key along with user by token
+func TestAuthorizationMiddlewareService(t *testing.T) {
+	secret := []byte("secret")
+	authorizer, err := NewJWTAuthorizer(context.Background(),
+		JWTConfig{Secret: string(secret)})
+	if err != nil {
+		t.Fatalf("There's an error when creating JWT authorizer => %s", err)
+	}
+	authorizer.now = func() time.Time { return testingJWTTime }
+
+	serviceKeys, err := ParseServiceKeys("product-service:secret1:orders:read")
+	if err != nil {
+		t.Fatalf("There's an error when parsing service keys => %s", err)
+	}
+
+	// initialize testing table
+	testTable := []struct {
+		TestName       string
+		Header         http.Header
+		ExpectedStatus int
+		ExpectedUser   User
+	}{
+		{
+			TestName:       "Test Service",
+			Header:         http.Header{HeaderAPIKey: {"secret1"}},
+			ExpectedStatus: http.StatusOK,
+			ExpectedUser: User{FullName: "product-service", Role: RoleService,
+				Service: "product-service", Scopes: []string{"orders:read"}},
+		},
+		{
+			TestName:       "Test Service Key Invalid",
+			Header:         http.Header{HeaderAPIKey: {"secret2"}},
+			ExpectedStatus: http.StatusUnauthorized,
+		},
+		{
+			TestName: "Test User",
+			Header: http.Header{"Authorization": {"Bearer " +
+				signTestingJWT(t, AlgHS256, "", secret, getTestingJWTClaims(nil))}},
+			ExpectedStatus: http.StatusOK,
+			ExpectedUser: User{ID: 1, Email: "buyer@gmail.com",
+				FullName: "buyer", Role: "buyer"},
+		},
+		{
+			TestName: "Test User With Service Role",
+			Header: http.Header{"Authorization": {"Bearer " +
+				signTestingJWT(t, AlgHS256, "", secret,
+					getTestingJWTClaims(map[string]interface{}{
+						"role": RoleService,
+					}))}},
+			ExpectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	// test for each testing table
+	e := echo.New()
+	e.HTTPErrorHandler = problem.HTTPErrorHandler
+	for _, test := range testTable {
+		req := httptest.NewRequest("GET", "/", nil)
+		for key, values := range test.Header {
+			req.Header.Set(key, values[0])
+		}
+		response := httptest.NewRecorder()
+		c := e.NewContext(req, response)
+
+		var u User
+		err := AuthorizationMiddleware(authorizer, serviceKeys)(
+			func(c echo.Context) error {
+				u, _ = c.Get("user").(User)
+				return c.NoContent(http.StatusOK)
+			})(c)
+		if err != nil {
+			c.Error(err)
+		}
+
+		if response.Code != test.ExpectedStatus {
+			t.Errorf("[%s] Expected status %d got %d", test.TestName,
+				test.ExpectedStatus, response.Code)
+		}
+		if !reflect.DeepEqual(u, test.ExpectedUser) {
+			t.Errorf("[%s] Expected user %+v, but got %+v", test.TestName,
+				test.ExpectedUser, u)
+		}
+	}
+}
@@ -6,67 +6,282 @@ package model
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/reyhanfikridz/ecom-order-service/internal/money"
 	"github.com/reyhanfikridz/ecom-order-service/internal/utils"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // Order contain order detail
+//
+// Qty and TotalPrice are derived from the order items,
+// all prices of the order are in Currency,
+// ReservedAt is the time items stock reserved when order checked out,
+// DeletedAt and DeletedBy are set when order deleted (soft deleted)
+//
+// CreatedAt, UpdatedAt, and Version are managed by the server,
+// Version incremented on each change so concurrent changes can be detected
+//
+// StockReleasePending set when order with reserved items stock cancelled
+// until the stock released, so failed release retried later
 type Order struct {
 	ID                  primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty" form:"_id,omitempty"`
 	OrderNumber         string             `bson:"order_number" json:"order_number" form:"order_number"`
 	Status              string             `bson:"status" json:"status" form:"status"`
+	Items               []OrderItem        `bson:"items" json:"items" form:"-"`
 	Qty                 int                `bson:"qty" json:"qty" form:"qty"`
-	TotalPrice          float64            `bson:"total_price" json:"total_price" form:"total_price"`
+	TotalPrice          money.Amount       `bson:"total_price" json:"total_price" form:"total_price"`
+	Currency            string             `bson:"currency" json:"currency" form:"currency"`
 	BuyerID             int                `bson:"buyer_id" json:"buyer_id" form:"buyer_id"`
 	BuyerFullName       string             `bson:"buyer_full_name" json:"buyer_full_name" form:"buyer_full_name"`
 	BuyerAddress        string             `bson:"buyer_address" json:"buyer_address" form:"buyer_address"`
-	ProductID           int                `bson:"product_id" json:"product_id" form:"product_id"`
-	ProductSKU          string             `bson:"product_sku" json:"product_sku" form:"product_sku"`
-	ProductName         string             `bson:"product_name" json:"product_name" form:"product_name"`
-	ProductPrice        float64            `bson:"product_price" json:"product_price" form:"product_price"`
-	ProductWeight       float32            `bson:"product_weight" json:"product_weight" form:"product_weight"`
-	ProductDescription  string             `bson:"product_description" json:"product_description" form:"product_description"`
-	ProductStock        int                `bson:"product_stock" json:"product_stock" form:"product_stock"`
-	ProductUserID       int                `bson:"product_user_id" json:"product_user_id" form:"product_user_id"`
-	ProductUserFullName string             `bson:"product_user_full_name" json:"product_user_full_name" form:"product_user_full_name"`
-	ProductImagesPath   []string           `bson:"product_images_path" json:"product_images_path" form:"product_images_path"`
-}
-
-// InsertOrder insert order document to orders collection
-func InsertOrder(ctx context.Context, oc *mongo.Collection, o Order) (Order, error) {
-	// get random order number until new one found
-	// by checking it in collection
-	var orderNumber string
-	for {
-		orderNumber = utils.GetRandomOrderNumber()
-
-		var tmp bson.M
-		err := oc.FindOne(ctx, bson.D{
-			primitive.E{Key: "order_number", Value: orderNumber},
-		}).Decode(&tmp)
+	ReservedAt          *time.Time         `bson:"reserved_at,omitempty" json:"reserved_at,omitempty" form:"-"`
+	StockReleasePending bool               `bson:"stock_release_pending,omitempty" json:"-" form:"-"`
+	DeletedAt           *time.Time         `bson:"deleted_at,omitempty" json:"deleted_at,omitempty" form:"-"`
+	DeletedBy           *OrderActor        `bson:"deleted_by,omitempty" json:"deleted_by,omitempty" form:"-"`
+	CreatedAt           time.Time          `bson:"created_at" json:"created_at" form:"-"`
+	UpdatedAt           time.Time          `bson:"updated_at" json:"updated_at" form:"-"`
+	Version             int                `bson:"version" json:"version" form:"version"`
+}
 
-		if err != nil {
-			if err == mongo.ErrNoDocuments {
-				break
-			}
+// OrderActor user who made change to an order
+type OrderActor struct {
+	ID       int    `bson:"id" json:"id"`
+	FullName string `bson:"full_name" json:"full_name"`
+	Role     string `bson:"role" json:"role"`
+}
+
+// OrderItem contain one line item of an order
+// with product detail snapshot at the time it's ordered
+type OrderItem struct {
+	ProductID           int          `bson:"product_id" json:"product_id" form:"product_id"`
+	ProductSKU          string       `bson:"product_sku" json:"product_sku" form:"product_sku"`
+	ProductName         string       `bson:"product_name" json:"product_name" form:"product_name"`
+	ProductPrice        money.Amount `bson:"product_price" json:"product_price" form:"product_price"`
+	ProductWeight       float32      `bson:"product_weight" json:"product_weight" form:"product_weight"`
+	ProductDescription  string       `bson:"product_description" json:"product_description" form:"product_description"`
+	ProductStock        int          `bson:"product_stock" json:"product_stock" form:"product_stock"`
+	ProductUserID       int          `bson:"product_user_id" json:"product_user_id" form:"product_user_id"`
+	ProductUserFullName string       `bson:"product_user_full_name" json:"product_user_full_name" form:"product_user_full_name"`
+	ProductImagesPath   []string     `bson:"product_images_path" json:"product_images_path" form:"product_images_path"`
+	Qty                 int          `bson:"qty" json:"qty" form:"qty"`
+	Subtotal            money.Amount `bson:"subtotal" json:"subtotal" form:"subtotal"`
+}
+
+// CalculateTotal calculate each item subtotal, order total qty,
+// and order total price from order items
+func (o *Order) CalculateTotal() {
+	o.Qty = 0
+	o.TotalPrice = 0
+	for i := range o.Items {
+		o.Items[i].Subtotal = o.Items[i].ProductPrice.Mul(o.Items[i].Qty)
+		o.Qty += o.Items[i].Qty
+		o.TotalPrice += o.Items[i].Subtotal
+	}
+}
+
+// AddItem add item to order
+//
+// if product already in order, the qty is added to the existing item
+// and the product detail replaced with the new one
+func (o *Order) AddItem(item OrderItem) {
+	for i := range o.Items {
+		if o.Items[i].ProductID == item.ProductID {
+			item.Qty += o.Items[i].Qty
+			o.Items[i] = item
+			o.CalculateTotal()
+			return
+		}
+	}
+
+	o.Items = append(o.Items, item)
+	o.CalculateTotal()
+}
+
+// UpdateItemQty change qty of order item by product ID
+func (o *Order) UpdateItemQty(productID int, qty int) error {
+	for i := range o.Items {
+		if o.Items[i].ProductID == productID {
+			o.Items[i].Qty = qty
+			o.CalculateTotal()
+			return nil
 		}
 	}
 
-	// insert order to database
-	o.OrderNumber = orderNumber
-	result, err := oc.InsertOne(ctx, o)
+	return fmt.Errorf("item with product_id %d not found", productID)
+}
+
+// RemoveItem remove order item by product ID
+func (o *Order) RemoveItem(productID int) error {
+	for i := range o.Items {
+		if o.Items[i].ProductID == productID {
+			items := make([]OrderItem, 0, len(o.Items)-1)
+			items = append(items, o.Items[:i]...)
+			items = append(items, o.Items[i+1:]...)
+			o.Items = items
+			o.CalculateTotal()
+			return nil
+		}
+	}
+
+	return fmt.Errorf("item with product_id %d not found", productID)
+}
+
+// MaxInsertOrderAttempts max attempts of inserting order
+// when the generated order number already used
+const MaxInsertOrderAttempts = 10
+
+// ErrOrderNumberExhausted returned when there's no unused order number
+// generated after max attempts
+var ErrOrderNumberExhausted = errors.New(
+	"unique order number not found after max attempts")
+
+// CreateOrderIndexes create indexes of orders collection,
+// including unique index of order number, index of last update time
+// used for sorting, and index of deletion time used for purging
+// deleted orders
+func CreateOrderIndexes(ctx context.Context, oc *mongo.Collection) error {
+	_, err := oc.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{primitive.E{Key: "order_number", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{
+				primitive.E{Key: "updated_at", Value: 1},
+				primitive.E{Key: "_id", Value: 1},
+			},
+		},
+		{
+			Keys: bson.D{primitive.E{Key: "deleted_at", Value: 1}},
+		},
+	})
+
+	return err
+}
+
+// MigrateOrderTimestamps set creation time, last update time,
+// and version of orders created before they're recorded,
+// both times are set to the creation time in order ID
+func MigrateOrderTimestamps(ctx context.Context,
+	oc *mongo.Collection) (int64, error) {
+	result, err := oc.UpdateMany(ctx,
+		bson.M{"created_at": bson.M{"$exists": false}},
+		bson.A{bson.M{"$set": bson.M{
+			"created_at": bson.M{"$toDate": "$_id"},
+			"updated_at": bson.M{"$toDate": "$_id"},
+			"version":    1,
+		}}})
 	if err != nil {
-		return o, err
+		return 0, err
 	}
 
-	// get order ID
-	o.ID = result.InsertedID.(primitive.ObjectID)
+	return result.ModifiedCount, nil
+}
 
-	return o, nil
+// MigrateOrderMoney rewrite prices of orders stored as double
+// into exact amount (Decimal128), and set currency of orders
+// created before currency recorded
+//
+// double price rounded to the nearest minor unit when read,
+// so the order only need to be saved again
+func MigrateOrderMoney(ctx context.Context, oc *mongo.Collection,
+	currency string) (int64, error) {
+	cursor, err := oc.Find(ctx, bson.M{"$or": bson.A{
+		bson.M{"currency": bson.M{"$exists": false}},
+		bson.M{"total_price": bson.M{"$type": "double"}},
+		bson.M{"items.product_price": bson.M{"$type": "double"}},
+		bson.M{"items.subtotal": bson.M{"$type": "double"}},
+	}})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var migrated int64
+	for cursor.Next(ctx) {
+		var o Order
+		err = cursor.Decode(&o)
+		if err != nil {
+			return migrated, err
+		}
+		if o.Currency == "" {
+			o.Currency = currency
+		}
+
+		// only prices and currency rewritten, order version unchanged
+		// because the order itself is not changed
+		fields := bson.M{"currency": o.Currency, "total_price": o.TotalPrice}
+		if o.Items != nil {
+			fields["items"] = o.Items
+		}
+		result, err := oc.UpdateOne(ctx, bson.M{"_id": o.ID},
+			bson.M{"$set": fields})
+		if err != nil {
+			return migrated, err
+		}
+		migrated += result.ModifiedCount
+	}
+
+	return migrated, cursor.Err()
+}
+
+// InsertOrder insert order with new order number from the generator
+// and write OrderCreated event to order outbox in the same transaction,
+// the new order also recorded by order change recorder of ctx
+//
+// order number uniqueness guaranteed by unique index of orders collection,
+// so insertion retried with another order number on duplicate key error
+func InsertOrder(ctx context.Context, oc *mongo.Collection, o Order,
+	gen utils.OrderNumberGenerator) (Order, error) {
+	o.CalculateTotal()
+	o.CreatedAt = time.Now().UTC().Truncate(time.Millisecond)
+	o.UpdatedAt = o.CreatedAt
+	o.Version = 1
+
+	for attempt := 0; attempt < MaxInsertOrderAttempts; attempt++ {
+		// get new order number
+		orderNumber, err := gen.Generate()
+		if err != nil {
+			return o, err
+		}
+		o.OrderNumber = orderNumber
+		o.ID = primitive.NewObjectID()
+
+		// insert order and its event to database
+		err = withTransaction(ctx, oc, func(sc mongo.SessionContext) error {
+			_, err := oc.InsertOne(sc, o)
+			if err != nil {
+				return err
+			}
+
+			_, err = getOrderOutbox(oc).InsertOne(sc,
+				NewOrderEvent(EventOrderCreated, nil, o))
+			if err != nil {
+				return err
+			}
+
+			return RecordOrderChange(sc, nil, &o)
+		})
+		if err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				continue
+			}
+			o.ID = primitive.NilObjectID
+			return o, err
+		}
+
+		return o, nil
+	}
+
+	o.ID = primitive.NilObjectID
+	return o, ErrOrderNumberExhausted
 }
 
 // GetOrder get order document by some key from orders collection
@@ -82,20 +297,29 @@ func GetOrder(ctx context.Context, oc *mongo.Collection,
 	return o, nil
 }
 
+// ErrNoDataUpdated returned when there's no order match
+// the filter when updating order
+var ErrNoDataUpdated = errors.New("no data updated")
+
 // UpdateOrder update order document by some key in orders collection
+// and write event of the update to order outbox in the same transaction
 func UpdateOrder(ctx context.Context, oc *mongo.Collection,
 	filter bson.M, oUpdate Order) error {
 	// set fields that need to be updated
+	fields := bson.M{"$set": GetOrderUpdateFields(oUpdate)}
+
+	// update order
+	return changeOrder(ctx, oc, filter, fields, GetOrderUpdateEventType,
+		ErrNoDataUpdated)
+}
+
+// GetOrderUpdateFields get order fields that need to be updated
+// (field with non zero value) as map of bson field name to its value
+func GetOrderUpdateFields(oUpdate Order) bson.M {
 	value := bson.M{}
 	if oUpdate.Status != "" {
 		value["status"] = oUpdate.Status
 	}
-	if oUpdate.Qty != 0 {
-		value["qty"] = oUpdate.Qty
-	}
-	if oUpdate.TotalPrice != 0 {
-		value["total_price"] = oUpdate.TotalPrice
-	}
 	if oUpdate.BuyerID != 0 {
 		value["buyer_id"] = oUpdate.BuyerID
 	}
@@ -105,70 +329,190 @@ func UpdateOrder(ctx context.Context, oc *mongo.Collection,
 	if oUpdate.BuyerAddress != "" {
 		value["buyer_address"] = oUpdate.BuyerAddress
 	}
-	if oUpdate.ProductID != 0 {
-		value["product_id"] = oUpdate.ProductID
-	}
-	if oUpdate.ProductSKU != "" {
-		value["product_sku"] = oUpdate.ProductSKU
-	}
-	if oUpdate.ProductName != "" {
-		value["product_name"] = oUpdate.ProductName
+	if oUpdate.ReservedAt != nil {
+		value["reserved_at"] = oUpdate.ReservedAt
 	}
-	if oUpdate.ProductPrice != 0 {
-		value["product_price"] = oUpdate.ProductPrice
+	if oUpdate.StockReleasePending {
+		value["stock_release_pending"] = true
 	}
-	if oUpdate.ProductWeight != 0 {
-		value["product_weight"] = oUpdate.ProductWeight
-	}
-	if oUpdate.ProductDescription != "" {
-		value["product_description"] = oUpdate.ProductDescription
+	if oUpdate.Items != nil {
+		// items replaced as a whole, so qty and total price
+		// need to be derived again from the new items
+		oUpdate.Items = append([]OrderItem{}, oUpdate.Items...)
+		oUpdate.CalculateTotal()
+		value["items"] = oUpdate.Items
+		value["qty"] = oUpdate.Qty
+		value["total_price"] = oUpdate.TotalPrice
 	}
-	if oUpdate.ProductStock != 0 {
-		value["product_stock"] = oUpdate.ProductStock
+
+	return value
+}
+
+// PatchOrder set fields (bson field name) of order document by some key
+// in orders collection to their value in oPatch, including zero value,
+// and write event of the change to order outbox in the same transaction
+func PatchOrder(ctx context.Context, oc *mongo.Collection,
+	filter bson.M, oPatch Order, fields []string) error {
+	// set fields that need to be updated
+	value, err := GetOrderPatchFields(oPatch, fields)
+	if err != nil {
+		return err
 	}
-	if oUpdate.ProductUserID != 0 {
-		value["product_user_id"] = oUpdate.ProductUserID
+
+	// update order
+	return changeOrder(ctx, oc, filter, bson.M{"$set": value},
+		GetOrderUpdateEventType, ErrNoDataUpdated)
+}
+
+// GetOrderPatchFields get fields (bson field name) of oPatch
+// as map of bson field name to its value, including zero value
+//
+// qty and total price derived again if items need to be updated
+func GetOrderPatchFields(oPatch Order, fields []string) (bson.M, error) {
+	oPatch.Items = append([]OrderItem{}, oPatch.Items...)
+	oPatch.CalculateTotal()
+
+	doc, err := bson.Marshal(oPatch)
+	if err != nil {
+		return nil, err
 	}
-	if oUpdate.ProductUserFullName != "" {
-		value["product_user_full_name"] = oUpdate.ProductUserFullName
+	all := bson.M{}
+	err = bson.Unmarshal(doc, &all)
+	if err != nil {
+		return nil, err
 	}
-	if oUpdate.ProductImagesPath != nil {
-		value["product_images_path"] = oUpdate.ProductImagesPath
+
+	value := bson.M{}
+	for _, field := range fields {
+		fieldValue, ok := all[field]
+		if !ok {
+			return nil, fmt.Errorf("field '%s' invalid", field)
+		}
+		value[field] = fieldValue
+
+		if field == "items" {
+			value["qty"] = all["qty"]
+			value["total_price"] = all["total_price"]
+		}
 	}
 
-	fields := bson.M{"$set": value}
+	return value, nil
+}
+
+// ErrNoDataDeleted returned when there's no order match
+// the filter when deleting order
+var ErrNoDataDeleted = errors.New("no data deleted")
+
+// DeleteOrder mark order document by some key in orders collection
+// as deleted by actor and write OrderDeleted event to order outbox
+// in the same transaction, the order document is kept until purged
+func DeleteOrder(ctx context.Context, oc *mongo.Collection,
+	filter bson.M, deletedBy OrderActor) error {
+	// set deletion time and actor
+	fields := bson.M{"$set": bson.M{
+		"deleted_at": time.Now().UTC(),
+		"deleted_by": deletedBy,
+	}}
+
+	// mark order as deleted
+	return changeOrder(ctx, oc, filter, fields,
+		func(before Order, after Order) string { return EventOrderDeleted },
+		ErrNoDataDeleted)
+}
+
+// RestoreOrder unmark deleted order document by some key
+// in orders collection and write OrderRestored event to order outbox
+// in the same transaction
+func RestoreOrder(ctx context.Context, oc *mongo.Collection,
+	filter bson.M) error {
+	// unset deletion time and actor
+	fields := bson.M{"$unset": bson.M{"deleted_at": "", "deleted_by": ""}}
+
+	// restore order
+	return changeOrder(ctx, oc, filter, fields,
+		func(before Order, after Order) string { return EventOrderRestored },
+		ErrNoDataUpdated)
+}
+
+// SetOrderStockReleasePending set whether items stock release
+// of order document by some key in orders collection pending,
+// the order version not changed and no event written
+// because it's not a change of the order seen by client
+func SetOrderStockReleasePending(ctx context.Context, oc *mongo.Collection,
+	filter bson.M, pending bool) error {
+	fields := bson.M{"$set": bson.M{"stock_release_pending": true}}
+	if !pending {
+		fields = bson.M{"$unset": bson.M{"stock_release_pending": ""}}
+	}
 
-	// update order
 	result, err := oc.UpdateOne(ctx, filter, fields)
 	if err != nil {
 		return err
 	}
 	if result.MatchedCount == 0 {
-		return fmt.Errorf("no data updated")
+		return ErrNoDataUpdated
 	}
 
 	return nil
 }
 
-// DeleteOrder delete order document by some key in orders collection
-func DeleteOrder(ctx context.Context, oc *mongo.Collection,
-	filter bson.M) error {
-	// delete order
-	_, err := oc.DeleteOne(ctx, filter)
+// PurgeOrders permanently delete order documents by some key
+// in orders collection, return total orders deleted
+//
+// every deleted order recorded by order change recorder of ctx
+// in the same transaction
+func PurgeOrders(ctx context.Context, oc *mongo.Collection,
+	filter bson.M) (int64, error) {
+	var total int64
+	err := withTransaction(ctx, oc, func(sc mongo.SessionContext) error {
+		total = 0
+
+		// get orders that need to be deleted
+		orders, err := GetOrders(sc, oc, filter)
+		if err != nil {
+			return err
+		}
+		if len(orders) == 0 {
+			return nil
+		}
+
+		ids := make([]primitive.ObjectID, 0, len(orders))
+		for _, o := range orders {
+			ids = append(ids, o.ID)
+		}
+
+		// delete the orders
+		result, err := oc.DeleteMany(sc, bson.M{"_id": bson.M{"$in": ids}})
+		if err != nil {
+			return err
+		}
+		total = result.DeletedCount
+
+		for i := range orders {
+			err = RecordOrderChange(sc, &orders[i], nil)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	return nil
+	return total, nil
 }
 
 // GetOrders get order documents by some key in orders collection
+//
+// opts can be used for sorting and pagination
 func GetOrders(ctx context.Context, oc *mongo.Collection,
-	filter bson.M) ([]Order, error) {
+	filter bson.M, opts ...*options.FindOptions) ([]Order, error) {
 	orders := []Order{}
 
 	// get orders cursor
-	cur, err := oc.Find(ctx, filter)
+	cur, err := oc.Find(ctx, filter, opts...)
 	if err != nil {
 		return orders, err
 	}
@@ -186,3 +530,9 @@ func GetOrders(ctx context.Context, oc *mongo.Collection,
 
 	return orders, nil
 }
+
+// CountOrders count order documents by some key in orders collection
+func CountOrders(ctx context.Context, oc *mongo.Collection,
+	filter bson.M) (int64, error) {
+	return oc.CountDocuments(ctx, filter)
+}
@@ -11,6 +11,8 @@ import (
 	"testing"
 
 	"github.com/reyhanfikridz/ecom-order-service/internal/config"
+	"github.com/reyhanfikridz/ecom-order-service/internal/money"
+	"github.com/reyhanfikridz/ecom-order-service/internal/utils"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -22,7 +24,7 @@ func TestMain(m *testing.M) {
 	ctx := context.Background()
 
 	// init all config before can be used
-	err := config.InitConfig()
+	err := config.InitConfig(nil)
 	if err != nil {
 		log.Fatalf("There's an error when initialize config => %s", err)
 	}
@@ -69,32 +71,55 @@ func TestCreateOrder(t *testing.T) {
 
 	// create order struct
 	o := Order{
-		Status:              "in-cart",
-		Qty:                 2,
-		TotalPrice:          2000001,
-		BuyerID:             1,
-		BuyerFullName:       "George Marcus",
-		BuyerAddress:        "Buyer Street",
-		ProductID:           1,
-		ProductSKU:          "testsku",
-		ProductName:         "product name",
-		ProductPrice:        1000000.50,
-		ProductWeight:       1.5,
-		ProductDescription:  "product description",
-		ProductStock:        100,
-		ProductUserID:       10,
-		ProductUserFullName: "Reyhan",
-		ProductImagesPath:   []string{"product 1.1.jpg", "product 1.2.jpg"},
+		Status:        "in-cart",
+		BuyerID:       1,
+		BuyerFullName: "George Marcus",
+		BuyerAddress:  "Buyer Street",
+		Items: []OrderItem{
+			{
+				ProductID:           1,
+				ProductSKU:          "testsku",
+				ProductName:         "product name",
+				ProductPrice:        money.MustParse("1000000.50"),
+				ProductWeight:       1.5,
+				ProductDescription:  "product description",
+				ProductStock:        100,
+				ProductUserID:       10,
+				ProductUserFullName: "Reyhan",
+				ProductImagesPath:   []string{"product 1.1.jpg", "product 1.2.jpg"},
+				Qty:                 2,
+			},
+			{
+				ProductID:           2,
+				ProductSKU:          "testsku2",
+				ProductName:         "product name 2",
+				ProductPrice:        money.MustParse("2000000.50"),
+				ProductWeight:       2.5,
+				ProductDescription:  "product description 2",
+				ProductStock:        200,
+				ProductUserID:       10,
+				ProductUserFullName: "Reyhan",
+				ProductImagesPath:   []string{"product 2.1.jpg"},
+				Qty:                 1,
+			},
+		},
 	}
 
 	// test insert order success
-	o, err = InsertOrder(ctx, collections["orders"], o)
+	o, err = InsertOrder(ctx, collections["orders"], o,
+		utils.RandomOrderNumberGenerator{Length: 15})
 	if err != nil {
 		t.Fatalf("Expected insert success, but got error => %s", err)
 	}
 	if o.ID == primitive.NilObjectID {
 		t.Errorf("Expected ID not nil, but got nil")
 	}
+	if o.Qty != 3 {
+		t.Errorf("Expected Qty 3, but got %d", o.Qty)
+	}
+	if o.TotalPrice != money.MustParse("4000001.50") {
+		t.Errorf("Expected TotalPrice %s, but got %s", "4000001.50", o.TotalPrice)
+	}
 
 	// remove all data order after test
 	_, err = collections["orders"].DeleteMany(ctx, bson.D{})
@@ -104,6 +129,123 @@ func TestCreateOrder(t *testing.T) {
 	}
 }
 
+// fixedOrderNumberGenerator order number generator
+// that always generate the same order number
+type fixedOrderNumberGenerator string
+
+// Generate generate the fixed order number
+func (g fixedOrderNumberGenerator) Generate() (string, error) {
+	return string(g), nil
+}
+
+// TestInsertOrderDuplicateOrderNumber test InsertOrder
+// when generated order number already used
+func TestInsertOrderDuplicateOrderNumber(t *testing.T) {
+	ctx := context.Background()
+
+	// get map of collection
+	collections, err := getTestingCollections(ctx)
+	if err != nil {
+		t.Fatalf("There's an error when getting "+
+			"mongodb collections => %s", err)
+	}
+
+	o := Order{
+		Status:  "in-cart",
+		BuyerID: 1,
+		Items: []OrderItem{
+			{ProductID: 1, ProductName: "product name",
+				ProductPrice: money.MustParse("1000"), Qty: 1},
+		},
+	}
+	gen := fixedOrderNumberGenerator("DUPLICATE")
+
+	// first insert get the order number
+	_, err = InsertOrder(ctx, collections["orders"], o, gen)
+	if err != nil {
+		t.Fatalf("Expected insert order success, but got error => %s", err)
+	}
+
+	// second insert can't get unused order number
+	_, err = InsertOrder(ctx, collections["orders"], o, gen)
+	if err != ErrOrderNumberExhausted {
+		t.Errorf("Expected error %v, but got %v", ErrOrderNumberExhausted, err)
+	}
+}
+
+// TestMigrateOrderMoney test MigrateOrderMoney
+func TestMigrateOrderMoney(t *testing.T) {
+	ctx := context.Background()
+
+	// get map of collection
+	collections, err := getTestingCollections(ctx)
+	if err != nil {
+		t.Fatalf("There's an error when getting "+
+			"mongodb collections => %s", err)
+	}
+
+	// insert order with prices stored as double and without currency
+	result, err := collections["orders"].InsertOne(ctx, bson.M{
+		"order_number": "MONEY1",
+		"status":       "in-cart",
+		"items": bson.A{bson.M{
+			"product_id":    1,
+			"product_price": 1000000.5,
+			"qty":           2,
+			"subtotal":      2000001.0,
+		}},
+		"qty":         2,
+		"total_price": 2000001.0,
+	})
+	if err != nil {
+		t.Fatalf("There's an error when inserting order => %s", err)
+	}
+
+	migrated, err := MigrateOrderMoney(ctx, collections["orders"], "IDR")
+	if err != nil {
+		t.Fatalf("Expected migrate order money success, but got error => %s",
+			err)
+	}
+	if migrated < 1 {
+		t.Errorf("Expected order migrated, but got %d migrated", migrated)
+	}
+
+	// prices stored as Decimal128 and read exactly
+	var doc bson.M
+	err = collections["orders"].FindOne(ctx,
+		bson.M{"_id": result.InsertedID}).Decode(&doc)
+	if err != nil {
+		t.Fatalf("There's an error when getting order => %s", err)
+	}
+	if _, ok := doc["total_price"].(primitive.Decimal128); !ok {
+		t.Errorf("Expected total_price stored as Decimal128, but got %T",
+			doc["total_price"])
+	}
+
+	var o Order
+	err = collections["orders"].FindOne(ctx,
+		bson.M{"_id": result.InsertedID}).Decode(&o)
+	if err != nil {
+		t.Fatalf("There's an error when getting order => %s", err)
+	}
+	if o.Currency != "IDR" || o.TotalPrice != money.MustParse("2000001") ||
+		o.Items[0].ProductPrice != money.MustParse("1000000.50") ||
+		o.Items[0].Subtotal != money.MustParse("2000001") {
+		t.Errorf("Expected order prices and currency migrated, but got %+v", o)
+	}
+
+	// migrated order not migrated again
+	migrated, err = MigrateOrderMoney(ctx, collections["orders"], "IDR")
+	if err != nil {
+		t.Fatalf("Expected migrate order money success, but got error => %s",
+			err)
+	}
+	if migrated != 0 {
+		t.Errorf("Expected no order migrated again, but got %d migrated",
+			migrated)
+	}
+}
+
 // TestGetOrder test GetOrder
 //
 // Required for the test: CreateOrder
@@ -119,26 +261,30 @@ func TestGetOrder(t *testing.T) {
 
 	// create order struct
 	o := Order{
-		Status:              "in-cart",
-		Qty:                 2,
-		TotalPrice:          2000001,
-		BuyerID:             1,
-		BuyerFullName:       "George Marcus",
-		BuyerAddress:        "Buyer Street",
-		ProductID:           1,
-		ProductSKU:          "testsku",
-		ProductName:         "product name",
-		ProductPrice:        1000000.50,
-		ProductWeight:       1.5,
-		ProductDescription:  "product description",
-		ProductStock:        100,
-		ProductUserID:       10,
-		ProductUserFullName: "Reyhan",
-		ProductImagesPath:   []string{"product 1.1.jpg", "product 1.2.jpg"},
+		Status:        "in-cart",
+		BuyerID:       1,
+		BuyerFullName: "George Marcus",
+		BuyerAddress:  "Buyer Street",
+		Items: []OrderItem{
+			{
+				ProductID:           1,
+				ProductSKU:          "testsku",
+				ProductName:         "product name",
+				ProductPrice:        money.MustParse("1000000.50"),
+				ProductWeight:       1.5,
+				ProductDescription:  "product description",
+				ProductStock:        100,
+				ProductUserID:       10,
+				ProductUserFullName: "Reyhan",
+				ProductImagesPath:   []string{"product 1.1.jpg", "product 1.2.jpg"},
+				Qty:                 2,
+			},
+		},
 	}
 
 	// insert order
-	o, err = InsertOrder(ctx, collections["orders"], o)
+	o, err = InsertOrder(ctx, collections["orders"], o,
+		utils.RandomOrderNumberGenerator{Length: 15})
 	if err != nil {
 		t.Fatalf("There's an error when inserting order data => %s", err)
 	}
@@ -166,7 +312,7 @@ func TestGetOrder(t *testing.T) {
 			o.Qty, result.Qty)
 	}
 	if o.TotalPrice != result.TotalPrice {
-		t.Errorf("Expected TotalPrice %f, but TotalPrice %f",
+		t.Errorf("Expected TotalPrice %s, but TotalPrice %s",
 			o.TotalPrice, result.TotalPrice)
 	}
 	if o.BuyerID != result.BuyerID {
@@ -181,58 +327,8 @@ func TestGetOrder(t *testing.T) {
 		t.Errorf("Expected BuyerAddress %s, but BuyerAddress %s",
 			o.BuyerAddress, result.BuyerAddress)
 	}
-	if o.ProductID != result.ProductID {
-		t.Errorf("Expected ProductID %d, but ProductID %d",
-			o.ProductID, result.ProductID)
-	}
-	if o.ProductSKU != result.ProductSKU {
-		t.Errorf("Expected ProductSKU %s, but ProductSKU %s",
-			o.ProductSKU, result.ProductSKU)
-	}
-	if o.ProductName != result.ProductName {
-		t.Errorf("Expected ProductName %s, but ProductName %s",
-			o.ProductName, result.ProductName)
-	}
-	if o.ProductPrice != result.ProductPrice {
-		t.Errorf("Expected ProductPrice %f, but ProductPrice %f",
-			o.ProductPrice, result.ProductPrice)
-	}
-	if o.ProductWeight != result.ProductWeight {
-		t.Errorf("Expected ProductWeight %f, but ProductWeight %f",
-			o.ProductWeight, result.ProductWeight)
-	}
-	if o.ProductDescription != result.ProductDescription {
-		t.Errorf("Expected ProductDescription %s, but ProductDescription %s",
-			o.ProductDescription, result.ProductDescription)
-	}
-	if o.ProductStock != result.ProductStock {
-		t.Errorf("Expected ProductStock %d, but ProductStock %d",
-			o.ProductStock, result.ProductStock)
-	}
-	if o.ProductUserID != result.ProductUserID {
-		t.Errorf("Expected ProductUserID %d, but ProductUserID %d",
-			o.ProductUserID, result.ProductUserID)
-	}
-	if o.ProductUserFullName != result.ProductUserFullName {
-		t.Errorf("Expected ProductUserFullName %s, but ProductUserFullName %s",
-			o.ProductUserFullName, result.ProductUserFullName)
-	}
-	if len(o.ProductImagesPath) != len(result.ProductImagesPath) {
-		t.Errorf("Expected total ProductImagesPath %d, but total ProductImagesPath %d",
-			len(o.ProductImagesPath), len(result.ProductImagesPath))
-	} else {
-		for _, expectedProductImagePath := range o.ProductImagesPath {
-			imageExist := false
-			for _, resultProductImagePath := range result.ProductImagesPath {
-				if expectedProductImagePath == resultProductImagePath {
-					imageExist = true
-				}
-			}
-			if !imageExist {
-				t.Errorf("Expected ProductImagesPath %s not found",
-					expectedProductImagePath)
-			}
-		}
+	if !isOrderItemsEqual(o.Items, result.Items) {
+		t.Errorf("Expected Items %v, but Items %v", o.Items, result.Items)
 	}
 
 	// remove all data order after test
@@ -262,49 +358,56 @@ func TestUpdateOrder(t *testing.T) {
 
 	// create order struct
 	o := Order{
-		Status:              "in-cart",
-		Qty:                 2,
-		TotalPrice:          2000001,
-		BuyerID:             1,
-		BuyerFullName:       "George Marcus",
-		BuyerAddress:        "Buyer Street",
-		ProductID:           1,
-		ProductSKU:          "testsku",
-		ProductName:         "product name",
-		ProductPrice:        1000000.50,
-		ProductWeight:       1.5,
-		ProductDescription:  "product description",
-		ProductStock:        100,
-		ProductUserID:       10,
-		ProductUserFullName: "Reyhan",
-		ProductImagesPath:   []string{"product 1.1.jpg", "product 1.2.jpg"},
+		Status:        "in-cart",
+		BuyerID:       1,
+		BuyerFullName: "George Marcus",
+		BuyerAddress:  "Buyer Street",
+		Items: []OrderItem{
+			{
+				ProductID:           1,
+				ProductSKU:          "testsku",
+				ProductName:         "product name",
+				ProductPrice:        money.MustParse("1000000.50"),
+				ProductWeight:       1.5,
+				ProductDescription:  "product description",
+				ProductStock:        100,
+				ProductUserID:       10,
+				ProductUserFullName: "Reyhan",
+				ProductImagesPath:   []string{"product 1.1.jpg", "product 1.2.jpg"},
+				Qty:                 2,
+			},
+		},
 	}
 
 	// insert order
-	o, err = InsertOrder(ctx, collections["orders"], o)
+	o, err = InsertOrder(ctx, collections["orders"], o,
+		utils.RandomOrderNumberGenerator{Length: 15})
 	if err != nil {
 		t.Fatalf("There's an error when inserting order data => %s", err)
 	}
 
 	// test update order and check the result
 	oUpdate := Order{
-		OrderNumber:         o.OrderNumber,
-		Status:              "processed",
-		Qty:                 3,
-		TotalPrice:          6000001.50,
-		BuyerID:             2,
-		BuyerFullName:       "Sean Marco",
-		BuyerAddress:        "Buyer Street 2",
-		ProductID:           2,
-		ProductSKU:          "testsku2",
-		ProductName:         "product name 2",
-		ProductPrice:        2000000.50,
-		ProductWeight:       2.5,
-		ProductDescription:  "product description 2",
-		ProductStock:        200,
-		ProductUserID:       20,
-		ProductUserFullName: "Fikri",
-		ProductImagesPath:   []string{"product 2.1.jpg", "product 2.2.jpg", "product 2.3.jpg"},
+		OrderNumber:   o.OrderNumber,
+		Status:        "checked-out",
+		BuyerID:       2,
+		BuyerFullName: "Sean Marco",
+		BuyerAddress:  "Buyer Street 2",
+		Items: []OrderItem{
+			{
+				ProductID:           2,
+				ProductSKU:          "testsku2",
+				ProductName:         "product name 2",
+				ProductPrice:        money.MustParse("2000000.50"),
+				ProductWeight:       2.5,
+				ProductDescription:  "product description 2",
+				ProductStock:        200,
+				ProductUserID:       20,
+				ProductUserFullName: "Fikri",
+				ProductImagesPath:   []string{"product 2.1.jpg", "product 2.2.jpg", "product 2.3.jpg"},
+				Qty:                 3,
+			},
+		},
 	}
 
 	filter := bson.M{"order_number": oUpdate.OrderNumber}
@@ -320,6 +423,7 @@ func TestUpdateOrder(t *testing.T) {
 		t.Errorf("There's an error when get order by order number => %s",
 			err)
 	}
+	oUpdate.CalculateTotal()
 	if oUpdate.OrderNumber != result.OrderNumber {
 		t.Errorf("Expected OrderNumber %s, but OrderNumber %s",
 			oUpdate.OrderNumber, result.OrderNumber)
@@ -333,7 +437,7 @@ func TestUpdateOrder(t *testing.T) {
 			oUpdate.Qty, result.Qty)
 	}
 	if oUpdate.TotalPrice != result.TotalPrice {
-		t.Errorf("Expected TotalPrice %f, but TotalPrice %f",
+		t.Errorf("Expected TotalPrice %s, but TotalPrice %s",
 			oUpdate.TotalPrice, result.TotalPrice)
 	}
 	if oUpdate.BuyerID != result.BuyerID {
@@ -348,63 +452,19 @@ func TestUpdateOrder(t *testing.T) {
 		t.Errorf("Expected BuyerAddress %s, but BuyerAddress %s",
 			oUpdate.BuyerAddress, result.BuyerAddress)
 	}
-	if oUpdate.ProductID != result.ProductID {
-		t.Errorf("Expected ProductID %d, but ProductID %d",
-			oUpdate.ProductID, result.ProductID)
-	}
-	if oUpdate.ProductSKU != result.ProductSKU {
-		t.Errorf("Expected ProductSKU %s, but ProductSKU %s",
-			oUpdate.ProductSKU, result.ProductSKU)
-	}
-	if oUpdate.ProductName != result.ProductName {
-		t.Errorf("Expected ProductName %s, but ProductName %s",
-			oUpdate.ProductName, result.ProductName)
-	}
-	if oUpdate.ProductPrice != result.ProductPrice {
-		t.Errorf("Expected ProductPrice %f, but ProductPrice %f",
-			oUpdate.ProductPrice, result.ProductPrice)
-	}
-	if oUpdate.ProductWeight != result.ProductWeight {
-		t.Errorf("Expected ProductWeight %f, but ProductWeight %f",
-			oUpdate.ProductWeight, result.ProductWeight)
-	}
-	if oUpdate.ProductDescription != result.ProductDescription {
-		t.Errorf("Expected ProductDescription %s, but ProductDescription %s",
-			oUpdate.ProductDescription, result.ProductDescription)
-	}
-	if oUpdate.ProductStock != result.ProductStock {
-		t.Errorf("Expected ProductStock %d, but ProductStock %d",
-			oUpdate.ProductStock, result.ProductStock)
-	}
-	if oUpdate.ProductUserID != result.ProductUserID {
-		t.Errorf("Expected ProductUserID %d, but ProductUserID %d",
-			oUpdate.ProductUserID, result.ProductUserID)
+	if !isOrderItemsEqual(oUpdate.Items, result.Items) {
+		t.Errorf("Expected Items %v, but Items %v", oUpdate.Items, result.Items)
 	}
-	if oUpdate.ProductUserFullName != result.ProductUserFullName {
-		t.Errorf("Expected ProductUserFullName %s, but ProductUserFullName %s",
-			oUpdate.ProductUserFullName, result.ProductUserFullName)
-	}
-	if len(oUpdate.ProductImagesPath) != len(result.ProductImagesPath) {
-		t.Errorf("Expected total ProductImagesPath %d, but total ProductImagesPath %d",
-			len(oUpdate.ProductImagesPath), len(result.ProductImagesPath))
-	} else {
-		for _, expectedProductImagePath := range oUpdate.ProductImagesPath {
-			imageExist := false
-			for _, resultProductImagePath := range result.ProductImagesPath {
-				if expectedProductImagePath == resultProductImagePath {
-					imageExist = true
-				}
-			}
-			if !imageExist {
-				t.Errorf("Expected ProductImagesPath %s not found",
-					expectedProductImagePath)
-			}
-		}
+	if result.Version != o.Version+1 || !result.CreatedAt.Equal(o.CreatedAt) ||
+		result.UpdatedAt.Before(o.UpdatedAt) {
+		t.Errorf("Expected Version %d and UpdatedAt changed, but Version %d "+
+			"and UpdatedAt %v", o.Version+1, result.Version, result.UpdatedAt)
 	}
 
 	// test update order no data updated
 	oUpdate = Order{
 		OrderNumber: "this order number not exist in database",
+		Status:      "checked-out",
 	}
 
 	filter = bson.M{"order_number": oUpdate.OrderNumber}
@@ -444,40 +504,46 @@ func TestDeleteOrder(t *testing.T) {
 
 	// create order struct
 	o := Order{
-		Status:              "in-cart",
-		Qty:                 2,
-		TotalPrice:          2000001,
-		BuyerID:             1,
-		BuyerFullName:       "George Marcus",
-		BuyerAddress:        "Buyer Street",
-		ProductID:           1,
-		ProductSKU:          "testsku",
-		ProductName:         "product name",
-		ProductPrice:        1000000.50,
-		ProductWeight:       1.5,
-		ProductDescription:  "product description",
-		ProductStock:        100,
-		ProductUserID:       10,
-		ProductUserFullName: "Reyhan",
-		ProductImagesPath:   []string{"product 1.1.jpg", "product 1.2.jpg"},
+		Status:        "in-cart",
+		BuyerID:       1,
+		BuyerFullName: "George Marcus",
+		BuyerAddress:  "Buyer Street",
+		Items: []OrderItem{
+			{
+				ProductID:           1,
+				ProductSKU:          "testsku",
+				ProductName:         "product name",
+				ProductPrice:        money.MustParse("1000000.50"),
+				ProductWeight:       1.5,
+				ProductDescription:  "product description",
+				ProductStock:        100,
+				ProductUserID:       10,
+				ProductUserFullName: "Reyhan",
+				ProductImagesPath:   []string{"product 1.1.jpg", "product 1.2.jpg"},
+				Qty:                 2,
+			},
+		},
 	}
 
 	// insert order
-	o, err = InsertOrder(ctx, collections["orders"], o)
+	o, err = InsertOrder(ctx, collections["orders"], o,
+		utils.RandomOrderNumberGenerator{Length: 15})
 	if err != nil {
 		t.Fatalf("There's an error when inserting order data => %s", err)
 	}
 
 	// test delete order by order number and check the result
 	filter := bson.M{"order_number": o.OrderNumber}
+	notDeletedFilter := bson.M{"order_number": o.OrderNumber, "deleted_at": nil}
+	deletedBy := OrderActor{ID: 1, FullName: "George Marcus", Role: "buyer"}
 
-	err = DeleteOrder(ctx, collections["orders"], filter)
+	err = DeleteOrder(ctx, collections["orders"], notDeletedFilter, deletedBy)
 	if err != nil {
 		t.Errorf("Expected delete order by order number success, "+
 			"but got error => %s", err)
 	}
 
-	_, err = GetOrder(ctx, collections["orders"], filter)
+	_, err = GetOrder(ctx, collections["orders"], notDeletedFilter)
 	if err == nil {
 		t.Errorf("Expected error errNoDocuments, but got no error")
 	} else {
@@ -486,6 +552,51 @@ func TestDeleteOrder(t *testing.T) {
 		}
 	}
 
+	result, err := GetOrder(ctx, collections["orders"], filter)
+	if err != nil {
+		t.Fatalf("Expected deleted order still exist, but got error %s", err)
+	}
+	if result.DeletedAt == nil || result.DeletedBy == nil ||
+		*result.DeletedBy != deletedBy {
+		t.Errorf("Expected order deleted by %v, but got deleted at %v by %v",
+			deletedBy, result.DeletedAt, result.DeletedBy)
+	}
+
+	// test delete order not exist
+	err = DeleteOrder(ctx, collections["orders"], notDeletedFilter, deletedBy)
+	if err != ErrNoDataDeleted {
+		t.Errorf("Expected error ErrNoDataDeleted, but got %v", err)
+	}
+
+	// test restore order and check the result
+	err = RestoreOrder(ctx, collections["orders"], filter)
+	if err != nil {
+		t.Errorf("Expected restore order success, but got error => %s", err)
+	}
+
+	result, err = GetOrder(ctx, collections["orders"], notDeletedFilter)
+	if err != nil {
+		t.Errorf("Expected restored order found, but got error %s", err)
+	}
+	if result.DeletedAt != nil || result.DeletedBy != nil {
+		t.Errorf("Expected restored order not deleted, but got deleted at %v",
+			result.DeletedAt)
+	}
+
+	// test purge order and check the result
+	total, err := PurgeOrders(ctx, collections["orders"], filter)
+	if err != nil {
+		t.Errorf("Expected purge order success, but got error => %s", err)
+	}
+	if total != 1 {
+		t.Errorf("Expected total purged order 1, but got %d", total)
+	}
+
+	_, err = GetOrder(ctx, collections["orders"], filter)
+	if err != mongo.ErrNoDocuments {
+		t.Errorf("Expected error errNoDocuments, but got %v", err)
+	}
+
 	// remove all data order after test
 	_, err = collections["orders"].DeleteMany(ctx, bson.D{})
 	if err != nil {
@@ -510,64 +621,87 @@ func TestGetOrders(t *testing.T) {
 	// create orders struct
 	orders := []Order{
 		{
-			Status:              "in-cart",
-			Qty:                 2,
-			TotalPrice:          2000001,
-			BuyerID:             1,
-			BuyerFullName:       "George Marcus",
-			BuyerAddress:        "Buyer Street",
-			ProductID:           1,
-			ProductSKU:          "testsku",
-			ProductName:         "product name",
-			ProductPrice:        1000000.50,
-			ProductWeight:       1.5,
-			ProductDescription:  "product description",
-			ProductStock:        100,
-			ProductUserID:       10,
-			ProductUserFullName: "Reyhan",
-			ProductImagesPath:   []string{"product 1.1.jpg", "product 1.2.jpg"},
+			Status:        "in-cart",
+			BuyerID:       1,
+			BuyerFullName: "George Marcus",
+			BuyerAddress:  "Buyer Street",
+			Items: []OrderItem{
+				{
+					ProductID:           1,
+					ProductSKU:          "testsku",
+					ProductName:         "product name",
+					ProductPrice:        money.MustParse("1000000.50"),
+					ProductWeight:       1.5,
+					ProductDescription:  "product description",
+					ProductStock:        100,
+					ProductUserID:       10,
+					ProductUserFullName: "Reyhan",
+					ProductImagesPath:   []string{"product 1.1.jpg", "product 1.2.jpg"},
+					Qty:                 2,
+				},
+			},
 		},
 		{
-			Status:              "done",
-			Qty:                 2,
-			TotalPrice:          4000001,
-			BuyerID:             1,
-			BuyerFullName:       "George Marcus",
-			BuyerAddress:        "Buyer Street",
-			ProductID:           2,
-			ProductSKU:          "testsku2",
-			ProductName:         "product name 2",
-			ProductPrice:        2000000.50,
-			ProductWeight:       2.5,
-			ProductDescription:  "product description 2",
-			ProductStock:        200,
-			ProductUserID:       20,
-			ProductUserFullName: "Fikri",
-			ProductImagesPath:   []string{"product 2.1.jpg", "product 2.2.jpg", "product 2.3.jpg"},
+			Status:        "done",
+			BuyerID:       1,
+			BuyerFullName: "George Marcus",
+			BuyerAddress:  "Buyer Street",
+			Items: []OrderItem{
+				{
+					ProductID:           2,
+					ProductSKU:          "testsku2",
+					ProductName:         "product name 2",
+					ProductPrice:        money.MustParse("2000000.50"),
+					ProductWeight:       2.5,
+					ProductDescription:  "product description 2",
+					ProductStock:        200,
+					ProductUserID:       20,
+					ProductUserFullName: "Fikri",
+					ProductImagesPath:   []string{"product 2.1.jpg", "product 2.2.jpg", "product 2.3.jpg"},
+					Qty:                 2,
+				},
+			},
 		},
 		{
-			Status:              "in-cart",
-			Qty:                 2,
-			TotalPrice:          6000001,
-			BuyerID:             2,
-			BuyerFullName:       "Linda",
-			BuyerAddress:        "Buyer Street 2",
-			ProductID:           3,
-			ProductSKU:          "testsku 3",
-			ProductName:         "product name 3",
-			ProductPrice:        3000000.50,
-			ProductWeight:       3.5,
-			ProductDescription:  "product description 3",
-			ProductStock:        300,
-			ProductUserID:       30,
-			ProductUserFullName: "Dzikriansyah",
-			ProductImagesPath:   []string{},
+			Status:        "in-cart",
+			BuyerID:       2,
+			BuyerFullName: "Linda",
+			BuyerAddress:  "Buyer Street 2",
+			Items: []OrderItem{
+				{
+					ProductID:           3,
+					ProductSKU:          "testsku 3",
+					ProductName:         "product name 3",
+					ProductPrice:        money.MustParse("3000000.50"),
+					ProductWeight:       3.5,
+					ProductDescription:  "product description 3",
+					ProductStock:        300,
+					ProductUserID:       30,
+					ProductUserFullName: "Dzikriansyah",
+					ProductImagesPath:   []string{},
+					Qty:                 2,
+				},
+				{
+					ProductID:           2,
+					ProductSKU:          "testsku2",
+					ProductName:         "product name 2",
+					ProductPrice:        money.MustParse("2000000.50"),
+					ProductWeight:       2.5,
+					ProductDescription:  "product description 2",
+					ProductStock:        200,
+					ProductUserID:       20,
+					ProductUserFullName: "Fikri",
+					ProductImagesPath:   []string{"product 2.1.jpg", "product 2.2.jpg", "product 2.3.jpg"},
+					Qty:                 1,
+				},
+			},
 		},
 	}
 
 	// insert orders
 	for i, o := range orders {
-		orders[i], err = InsertOrder(ctx, collections["orders"], o)
+		orders[i], err = InsertOrder(ctx, collections["orders"], o,
+			utils.RandomOrderNumberGenerator{Length: 15})
 		if err != nil {
 			t.Fatalf("There's an error when inserting order data => %s", err)
 		}
@@ -594,6 +728,11 @@ func TestGetOrders(t *testing.T) {
 			Filter:          bson.M{"buyer_id": 1, "status": "in-cart"},
 			ExpectedResults: []Order{orders[0]},
 		},
+		{
+			TestName:        "Test Get Order By Item Product User ID <20>",
+			Filter:          bson.M{"items.product_user_id": 20},
+			ExpectedResults: []Order{orders[1], orders[2]},
+		},
 	}
 
 	// do test in test table
@@ -621,36 +760,8 @@ func TestGetOrders(t *testing.T) {
 					expectedResult.BuyerID == result.BuyerID &&
 					expectedResult.BuyerFullName == result.BuyerFullName &&
 					expectedResult.BuyerAddress == result.BuyerAddress &&
-					expectedResult.ProductID == result.ProductID &&
-					expectedResult.ProductSKU == result.ProductSKU &&
-					expectedResult.ProductName == result.ProductName &&
-					expectedResult.ProductPrice == result.ProductPrice &&
-					expectedResult.ProductWeight == result.ProductWeight &&
-					expectedResult.ProductDescription == result.ProductDescription &&
-					expectedResult.ProductStock == result.ProductStock &&
-					expectedResult.ProductUserID == result.ProductUserID &&
-					expectedResult.ProductUserFullName == result.ProductUserFullName {
-
-					if len(expectedResult.ProductImagesPath) ==
-						len(result.ProductImagesPath) {
-						totalImageExist := 0
-						for _, expectedProductImagePath := range expectedResult.ProductImagesPath {
-							imageExist := false
-							for _, resultProductImagePath := range result.ProductImagesPath {
-								if expectedProductImagePath == resultProductImagePath {
-									imageExist = true
-								}
-							}
-							if imageExist {
-								totalImageExist++
-							}
-						}
-						if len(expectedResult.ProductImagesPath) ==
-							totalImageExist {
-							resultExist = true
-						}
-					}
-
+					isOrderItemsEqual(expectedResult.Items, result.Items) {
+					resultExist = true
 				}
 			}
 
@@ -670,6 +781,204 @@ func TestGetOrders(t *testing.T) {
 	}
 }
 
+// TestOrderItems test Order AddItem, UpdateItemQty, and RemoveItem
+func TestOrderItems(t *testing.T) {
+	o := Order{}
+
+	// test add new items
+	o.AddItem(OrderItem{ProductID: 1,
+		ProductPrice: money.MustParse("1000.50"), Qty: 2})
+	o.AddItem(OrderItem{ProductID: 2, ProductPrice: money.MustParse("500"), Qty: 1})
+	if len(o.Items) != 2 {
+		t.Errorf("Expected total item 2, but got %d", len(o.Items))
+	}
+	if o.Qty != 3 || o.TotalPrice != money.MustParse("2501") {
+		t.Errorf("Expected Qty 3 and TotalPrice 2501, but got Qty %d "+
+			"and TotalPrice %s", o.Qty, o.TotalPrice)
+	}
+
+	// test add existing product item
+	o.AddItem(OrderItem{ProductID: 1,
+		ProductPrice: money.MustParse("1000.50"), Qty: 1})
+	if len(o.Items) != 2 {
+		t.Errorf("Expected total item 2, but got %d", len(o.Items))
+	}
+	if o.Items[0].Qty != 3 || o.Items[0].Subtotal != money.MustParse("3001.50") {
+		t.Errorf("Expected item Qty 3 and Subtotal 3001.50, but got Qty %d "+
+			"and Subtotal %s", o.Items[0].Qty, o.Items[0].Subtotal)
+	}
+
+	// test update item qty
+	err := o.UpdateItemQty(2, 4)
+	if err != nil {
+		t.Errorf("Expected update item qty success, but got error => %s", err)
+	}
+	if o.Qty != 7 || o.TotalPrice != money.MustParse("5001.50") {
+		t.Errorf("Expected Qty 7 and TotalPrice 5001.50, but got Qty %d "+
+			"and TotalPrice %s", o.Qty, o.TotalPrice)
+	}
+
+	err = o.UpdateItemQty(3, 1)
+	if err == nil {
+		t.Errorf("Expected error item not found, but got no error")
+	}
+
+	// test remove item
+	err = o.RemoveItem(1)
+	if err != nil {
+		t.Errorf("Expected remove item success, but got error => %s", err)
+	}
+	if len(o.Items) != 1 || o.Items[0].ProductID != 2 {
+		t.Errorf("Expected only item with product ID 2 left, but got %v", o.Items)
+	}
+	if o.Qty != 4 || o.TotalPrice != money.MustParse("2000") {
+		t.Errorf("Expected Qty 4 and TotalPrice 2000, but got Qty %d "+
+			"and TotalPrice %s", o.Qty, o.TotalPrice)
+	}
+
+	err = o.RemoveItem(1)
+	if err == nil {
+		t.Errorf("Expected error item not found, but got no error")
+	}
+}
+
+// TestIsOrderStatusTransitionAllowed test IsOrderStatusTransitionAllowed
+func TestIsOrderStatusTransitionAllowed(t *testing.T) {
+	// create testing table
+	testTable := []struct {
+		TestName        string
+		CurrentStatus   string
+		RequestedStatus string
+		ExpectedResult  bool
+	}{
+		{
+			TestName:        "Test In Cart To Checked Out",
+			CurrentStatus:   OrderStatusInCart,
+			RequestedStatus: OrderStatusCheckedOut,
+			ExpectedResult:  true,
+		},
+		{
+			TestName:        "Test Paid To Refunded",
+			CurrentStatus:   OrderStatusPaid,
+			RequestedStatus: OrderStatusRefunded,
+			ExpectedResult:  true,
+		},
+		{
+			TestName:        "Test Same Status",
+			CurrentStatus:   OrderStatusShipped,
+			RequestedStatus: OrderStatusShipped,
+			ExpectedResult:  true,
+		},
+		{
+			TestName:        "Test Done To In Cart",
+			CurrentStatus:   OrderStatusDone,
+			RequestedStatus: OrderStatusInCart,
+			ExpectedResult:  false,
+		},
+		{
+			TestName:        "Test In Cart To Shipped",
+			CurrentStatus:   OrderStatusInCart,
+			RequestedStatus: OrderStatusShipped,
+			ExpectedResult:  false,
+		},
+		{
+			TestName:        "Test Cancelled To Paid",
+			CurrentStatus:   OrderStatusCancelled,
+			RequestedStatus: OrderStatusPaid,
+			ExpectedResult:  false,
+		},
+		{
+			TestName:        "Test Unknown Status",
+			CurrentStatus:   OrderStatusInCart,
+			RequestedStatus: "waiting-for-payment",
+			ExpectedResult:  false,
+		},
+	}
+
+	// do test in test table
+	for _, test := range testTable {
+		result := IsOrderStatusTransitionAllowed(test.CurrentStatus,
+			test.RequestedStatus)
+		if result != test.ExpectedResult {
+			t.Errorf("[%s] Expected result %t, but got %t", test.TestName,
+				test.ExpectedResult, result)
+		}
+	}
+}
+
+// TestOrderEvents test order events written to order outbox
+// by InsertOrder, UpdateOrder, and DeleteOrder
+func TestOrderEvents(t *testing.T) {
+	ctx := context.Background()
+
+	// get map of collection
+	collections, err := getTestingCollections(ctx)
+	if err != nil {
+		t.Fatalf("There's an error when getting "+
+			"mongodb collections => %s", err)
+	}
+
+	// insert, update, and delete order
+	o, err := InsertOrder(ctx, collections["orders"], Order{
+		Status:  OrderStatusInCart,
+		BuyerID: 1,
+		Items: []OrderItem{
+			{ProductID: 1, ProductName: "product name",
+				ProductPrice: money.MustParse("1000"), Qty: 1},
+		},
+	}, utils.RandomOrderNumberGenerator{Length: 15})
+	if err != nil {
+		t.Fatalf("There's an error when inserting order data => %s", err)
+	}
+
+	filter := bson.M{"order_number": o.OrderNumber}
+	err = UpdateOrder(ctx, collections["orders"], filter,
+		Order{Status: OrderStatusCancelled})
+	if err != nil {
+		t.Fatalf("There's an error when updating order data => %s", err)
+	}
+
+	err = DeleteOrder(ctx, collections["orders"], filter, OrderActor{ID: 1})
+	if err != nil {
+		t.Fatalf("There's an error when deleting order data => %s", err)
+	}
+
+	// check events written in order
+	events := []OrderEvent{}
+	cursor, err := collections[OrderOutboxCollection].Find(ctx, filter,
+		options.Find().SetSort(bson.D{primitive.E{Key: "_id", Value: 1}}))
+	if err != nil {
+		t.Fatalf("There's an error when getting order events => %s", err)
+	}
+	err = cursor.All(ctx, &events)
+	if err != nil {
+		t.Fatalf("There's an error when decoding order events => %s", err)
+	}
+
+	expectedTypes := []string{
+		EventOrderCreated, EventOrderCancelled, EventOrderDeleted,
+	}
+	if len(events) != len(expectedTypes) {
+		t.Fatalf("Expected total events %d, but got %d",
+			len(expectedTypes), len(events))
+	}
+	for i, e := range events {
+		if e.Type != expectedTypes[i] || e.PublishedAt != nil {
+			t.Errorf("Expected unpublished event %s, but got %+v",
+				expectedTypes[i], e)
+		}
+	}
+
+	// remove all data order and events after test
+	for _, name := range []string{"orders", OrderOutboxCollection} {
+		_, err = collections[name].DeleteMany(ctx, bson.D{})
+		if err != nil {
+			t.Fatalf("There's an error when truncating "+
+				"%s collection after test => %s", name, err)
+		}
+	}
+}
+
 // getTestingCollections get map of testing mongodb collection
 func getTestingCollections(ctx context.Context) (map[string]*mongo.Collection, error) {
 	collections := make(map[string]*mongo.Collection)
@@ -725,5 +1034,46 @@ func getTestingCollections(ctx context.Context) (map[string]*mongo.Collection, e
 	// put collection orders to map of collection
 	collections["orders"] = DB.Collection("orders")
 
+	// create unique index of order number
+	err = CreateOrderIndexes(ctx, collections["orders"])
+	if err != nil {
+		return nil, err
+	}
+
+	// put collection order outbox to map of collection
+	collections[OrderOutboxCollection] = DB.Collection(OrderOutboxCollection)
+
 	return collections, nil
 }
+
+// isOrderItemsEqual check if two list of order item have the same value
+func isOrderItemsEqual(expected []OrderItem, results []OrderItem) bool {
+	if len(expected) != len(results) {
+		return false
+	}
+
+	for i := range expected {
+		if expected[i].ProductID != results[i].ProductID ||
+			expected[i].ProductSKU != results[i].ProductSKU ||
+			expected[i].ProductName != results[i].ProductName ||
+			expected[i].ProductPrice != results[i].ProductPrice ||
+			expected[i].ProductWeight != results[i].ProductWeight ||
+			expected[i].ProductDescription != results[i].ProductDescription ||
+			expected[i].ProductStock != results[i].ProductStock ||
+			expected[i].ProductUserID != results[i].ProductUserID ||
+			expected[i].ProductUserFullName != results[i].ProductUserFullName ||
+			expected[i].Qty != results[i].Qty ||
+			expected[i].Subtotal != results[i].Subtotal ||
+			len(expected[i].ProductImagesPath) != len(results[i].ProductImagesPath) {
+			return false
+		}
+
+		for j := range expected[i].ProductImagesPath {
+			if expected[i].ProductImagesPath[j] != results[i].ProductImagesPath[j] {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,224 @@
+/*
+Package model containing structs and functions
+for database transaction
+*/
+package model
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// order domain event types
+const (
+	EventOrderCreated       = "OrderCreated"
+	EventOrderUpdated       = "OrderUpdated"
+	EventOrderStatusChanged = "OrderStatusChanged"
+	EventOrderCancelled     = "OrderCancelled"
+	EventOrderDeleted       = "OrderDeleted"
+	EventOrderRestored      = "OrderRestored"
+)
+
+// OrderOutboxCollection name of collection in the same database
+// as orders collection where order events written
+const OrderOutboxCollection = "order_outbox"
+
+// OrderOutboxRetention how long published events kept
+// in order outbox collection before removed
+const OrderOutboxRetention = 7 * 24 * time.Hour
+
+// OrderEvent domain event of order change written to the outbox
+//
+// PublishedAt, Attempts, NextAttemptAt, DeadAt, and LastError are delivery
+// state of the event, not part of the published event, event with DeadAt
+// failed too many times and never published again
+type OrderEvent struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Type           string             `bson:"type" json:"type"`
+	OrderNumber    string             `bson:"order_number" json:"order_number"`
+	PreviousStatus string             `bson:"previous_status,omitempty" json:"previous_status,omitempty"`
+	Status         string             `bson:"status" json:"status"`
+	Order          Order              `bson:"order" json:"order"`
+	OccurredAt     time.Time          `bson:"occurred_at" json:"occurred_at"`
+	PublishedAt    *time.Time         `bson:"published_at,omitempty" json:"-"`
+	Attempts       int                `bson:"attempts" json:"-"`
+	NextAttemptAt  *time.Time         `bson:"next_attempt_at,omitempty" json:"-"`
+	DeadAt         *time.Time         `bson:"dead_at,omitempty" json:"-"`
+	LastError      string             `bson:"last_error,omitempty" json:"-"`
+}
+
+// NewOrderEvent create event of order changed from before into after,
+// before is nil for created order
+func NewOrderEvent(eventType string, before *Order, after Order) OrderEvent {
+	e := OrderEvent{
+		ID:          primitive.NewObjectID(),
+		Type:        eventType,
+		OrderNumber: after.OrderNumber,
+		Status:      after.Status,
+		Order:       after,
+		OccurredAt:  time.Now().UTC(),
+	}
+	if before != nil {
+		e.PreviousStatus = before.Status
+	}
+
+	return e
+}
+
+// GetOrderUpdateEventType get event type of order updated
+// from before into after
+func GetOrderUpdateEventType(before Order, after Order) string {
+	switch {
+	case before.Status == after.Status:
+		return EventOrderUpdated
+	case after.Status == OrderStatusCancelled:
+		return EventOrderCancelled
+	}
+
+	return EventOrderStatusChanged
+}
+
+// CreateOrderOutboxIndexes create indexes of order outbox collection
+// used for getting unpublished events in order and removing
+// published events after OrderOutboxRetention
+func CreateOrderOutboxIndexes(ctx context.Context,
+	ec *mongo.Collection) error {
+	_, err := ec.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				primitive.E{Key: "published_at", Value: 1},
+				primitive.E{Key: "_id", Value: 1},
+			},
+		},
+		{
+			Keys: bson.D{primitive.E{Key: "published_at", Value: 1}},
+			Options: options.Index().
+				SetExpireAfterSeconds(int32(OrderOutboxRetention.Seconds())),
+		},
+	})
+
+	return err
+}
+
+// OrderChangeRecorder record order changed from before into after,
+// e.g. into audit trail, in the same transaction as the change,
+// before is nil for created order and after is nil for purged order
+//
+// the change aborted if the recorder return error
+type OrderChangeRecorder func(ctx context.Context, before *Order,
+	after *Order) error
+
+// orderChangeRecorderKey context key of order change recorder
+type orderChangeRecorderKey struct{}
+
+// WithOrderChangeRecorder get copy of ctx with recorder of every order
+// changed using the context
+func WithOrderChangeRecorder(ctx context.Context,
+	recorder OrderChangeRecorder) context.Context {
+	return context.WithValue(ctx, orderChangeRecorderKey{}, recorder)
+}
+
+// RecordOrderChange record order changed from before into after
+// with order change recorder of ctx, if any
+func RecordOrderChange(ctx context.Context, before *Order,
+	after *Order) error {
+	recorder, ok := ctx.Value(orderChangeRecorderKey{}).(OrderChangeRecorder)
+	if !ok {
+		return nil
+	}
+
+	return recorder(ctx, before, after)
+}
+
+// getOrderOutbox get order outbox collection of orders collection
+func getOrderOutbox(oc *mongo.Collection) *mongo.Collection {
+	return oc.Database().Collection(OrderOutboxCollection)
+}
+
+// withTransaction run fn in mongodb transaction of orders collection client,
+// so order change and its events written together
+func withTransaction(ctx context.Context, oc *mongo.Collection,
+	fn func(sc mongo.SessionContext) error) error {
+	session, err := oc.Database().Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx,
+		func(sc mongo.SessionContext) (interface{}, error) {
+			return nil, fn(sc)
+		})
+
+	return err
+}
+
+// changeOrder update one order document match the filter with fields
+// and write event of the change to order outbox in the same transaction,
+// event type got from getEventType, return errNoData if there's none
+//
+// the change also recorded by order change recorder of ctx
+// in the same transaction
+//
+// order last update time set and version incremented with the change
+func changeOrder(ctx context.Context, oc *mongo.Collection, filter bson.M,
+	fields bson.M, getEventType func(before Order, after Order) string,
+	errNoData error) error {
+	fields = getOrderChangeFields(fields, time.Now().UTC())
+
+	return withTransaction(ctx, oc, func(sc mongo.SessionContext) error {
+		// update order and get order before updated
+		before := Order{}
+		err := oc.FindOneAndUpdate(sc, filter, fields,
+			options.FindOneAndUpdate().SetReturnDocument(options.Before)).
+			Decode(&before)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				return errNoData
+			}
+			return err
+		}
+
+		// get order after updated
+		after := Order{}
+		err = oc.FindOne(sc, bson.M{"_id": before.ID}).Decode(&after)
+		if err != nil {
+			return err
+		}
+
+		// write event of the change
+		e := NewOrderEvent(getEventType(before, after), &before, after)
+		_, err = getOrderOutbox(oc).InsertOne(sc, e)
+		if err != nil {
+			return err
+		}
+
+		return RecordOrderChange(sc, &before, &after)
+	})
+}
+
+// getOrderChangeFields get copy of update fields with last update time
+// set to now and version incremented
+func getOrderChangeFields(fields bson.M, now time.Time) bson.M {
+	result := bson.M{}
+	for operator, value := range fields {
+		result[operator] = value
+	}
+
+	set := bson.M{}
+	if value, ok := result["$set"].(bson.M); ok {
+		for field, fieldValue := range value {
+			set[field] = fieldValue
+		}
+	}
+	set["updated_at"] = now
+	result["$set"] = set
+	result["$inc"] = bson.M{"version": 1}
+
+	return result
+}
@@ -0,0 +1,62 @@
+/*
+Package model containing structs and functions
+for database transaction
+*/
+package model
+
+// order status lifecycle
+//
+// in-cart -> checked-out -> paid -> shipped -> delivered -> done,
+// with cancelled and refunded as terminal side branches
+const (
+	OrderStatusInCart     = "in-cart"
+	OrderStatusCheckedOut = "checked-out"
+	OrderStatusPaid       = "paid"
+	OrderStatusShipped    = "shipped"
+	OrderStatusDelivered  = "delivered"
+	OrderStatusDone       = "done"
+	OrderStatusCancelled  = "cancelled"
+	OrderStatusRefunded   = "refunded"
+)
+
+// orderStatusTransitions map of order status to the statuses
+// it allowed to move into
+var orderStatusTransitions = map[string][]string{
+	OrderStatusInCart:     {OrderStatusCheckedOut, OrderStatusCancelled},
+	OrderStatusCheckedOut: {OrderStatusPaid, OrderStatusCancelled},
+	OrderStatusPaid:       {OrderStatusShipped, OrderStatusCancelled, OrderStatusRefunded},
+	OrderStatusShipped:    {OrderStatusDelivered, OrderStatusRefunded},
+	OrderStatusDelivered:  {OrderStatusDone, OrderStatusRefunded},
+	OrderStatusDone:       {},
+	OrderStatusCancelled:  {},
+	OrderStatusRefunded:   {},
+}
+
+// IsOrderStatusValid check if status is one of the known order status
+func IsOrderStatusValid(status string) bool {
+	_, ok := orderStatusTransitions[status]
+	return ok
+}
+
+// IsOrderStatusTransitionAllowed check if order status can be changed
+// from current status to requested status
+//
+// keeping the same status is always allowed for known status
+func IsOrderStatusTransitionAllowed(current string, requested string) bool {
+	nextStatuses, ok := orderStatusTransitions[current]
+	if !ok || !IsOrderStatusValid(requested) {
+		return false
+	}
+
+	if current == requested {
+		return true
+	}
+
+	for _, status := range nextStatuses {
+		if status == requested {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,237 @@
+/*
+Package money containing exact decimal money amount and currency
+*/
+package money
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Scale number of decimal places of amount, so only currency
+// with the same minor unit exponent supported (see IsCurrencySupported)
+const Scale = 2
+
+// Unit amount of one currency unit (1.00)
+const Unit Amount = 100
+
+// Amount money amount in minor units (1/100 of currency unit),
+// so arithmetic on amount is exact
+//
+// amount rendered in JSON as decimal string (e.g. "1000000.50")
+// and stored in mongodb as Decimal128, amount stored as double
+// before amount is exact can still be read
+type Amount int64
+
+// ErrAmountInvalid returned when amount is not a decimal number
+// with at most Scale decimal places
+var ErrAmountInvalid = errors.New("amount invalid")
+
+// minorUnits big.Rat of Unit, used for parsing amount
+var minorUnits = big.NewRat(int64(Unit), 1)
+
+// Parse parse decimal number (e.g. "1000000.50", "-2", "1.5e3")
+// into amount
+func Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.IndexFunc(s, func(r rune) bool {
+		return !strings.ContainsRune("0123456789.+-eE", r)
+	}) != -1 {
+		return 0, fmt.Errorf("%w => '%s' is not a decimal number",
+			ErrAmountInvalid, s)
+	}
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, fmt.Errorf("%w => '%s' is not a decimal number",
+			ErrAmountInvalid, s)
+	}
+
+	r.Mul(r, minorUnits)
+	if !r.IsInt() {
+		return 0, fmt.Errorf("%w => '%s' has more than %d decimal places",
+			ErrAmountInvalid, s, Scale)
+	}
+	if !r.Num().IsInt64() {
+		return 0, fmt.Errorf("%w => '%s' out of range", ErrAmountInvalid, s)
+	}
+
+	return Amount(r.Num().Int64()), nil
+}
+
+// MustParse parse decimal number into amount, panic if it's invalid
+func MustParse(s string) Amount {
+	a, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return a
+}
+
+// FromFloat get amount of float rounded to the nearest minor unit,
+// only used for reading amount stored as float
+func FromFloat(f float64) Amount {
+	return Amount(math.Round(f * float64(Unit)))
+}
+
+// Mul get amount multiplied by n
+func (a Amount) Mul(n int) Amount {
+	return a * Amount(n)
+}
+
+// String get amount as decimal number with Scale decimal places
+func (a Amount) String() string {
+	sign := ""
+	units := int64(a)
+	if units < 0 {
+		sign = "-"
+	}
+
+	// avoid overflow of negating min int64
+	whole := units / int64(Unit)
+	fraction := units % int64(Unit)
+	if whole < 0 {
+		whole = -whole
+	}
+	if fraction < 0 {
+		fraction = -fraction
+	}
+
+	return fmt.Sprintf("%s%d.%0*d", sign, whole, Scale, fraction)
+}
+
+// MarshalJSON marshal amount as decimal string
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+// UnmarshalJSON unmarshal amount from decimal string or number
+func (a *Amount) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(b, []byte("null")) {
+		return nil
+	}
+
+	s := string(b)
+	if len(b) > 0 && b[0] == '"' {
+		err := json.Unmarshal(b, &s)
+		if err != nil {
+			return err
+		}
+	}
+
+	amount, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = amount
+
+	return nil
+}
+
+// UnmarshalParam unmarshal amount from form or query param
+func (a *Amount) UnmarshalParam(param string) error {
+	amount, err := Parse(param)
+	if err != nil {
+		return err
+	}
+	*a = amount
+
+	return nil
+}
+
+// MarshalBSONValue marshal amount as Decimal128
+func (a Amount) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	d, err := primitive.ParseDecimal128(a.String())
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return bson.MarshalValue(d)
+}
+
+// UnmarshalBSONValue unmarshal amount from Decimal128,
+// or from double and integer stored before amount is exact
+func (a *Amount) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	value := bson.RawValue{Type: t, Value: data}
+	switch t {
+	case bsontype.Decimal128:
+		amount, err := Parse(value.Decimal128().String())
+		if err != nil {
+			return err
+		}
+		*a = amount
+
+	case bsontype.Double:
+		*a = FromFloat(value.Double())
+
+	case bsontype.Int32:
+		*a = Amount(value.Int32()) * Unit
+
+	case bsontype.Int64:
+		*a = Amount(value.Int64()) * Unit
+
+	case bsontype.Null, bsontype.Undefined:
+		*a = 0
+
+	default:
+		return fmt.Errorf("%w => can't decode BSON %s into amount",
+			ErrAmountInvalid, t)
+	}
+
+	return nil
+}
+
+// minorUnitExponents ISO 4217 minor unit exponent of currencies
+// whose exponent is not 2, e.g. JPY has no minor unit
+// and KWD has 1/1000 minor unit
+var minorUnitExponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0,
+	"KRW": 0, "PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0,
+	"XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLF": 4, "UYW": 4,
+}
+
+// GetMinorUnitExponent get ISO 4217 minor unit exponent of currency,
+// i.e. its number of decimal places
+func GetMinorUnitExponent(currency string) int {
+	exponent, ok := minorUnitExponents[currency]
+	if !ok {
+		return 2
+	}
+
+	return exponent
+}
+
+// IsCurrencySupported check if currency is valid ISO 4217 currency code
+// and its minor unit exponent is Scale, so amount of the currency
+// can be parsed and rendered exactly
+func IsCurrencySupported(currency string) bool {
+	return IsCurrencyValid(currency) &&
+		GetMinorUnitExponent(currency) == Scale
+}
+
+// IsCurrencyValid check if currency is ISO 4217 currency code format
+// (3 uppercase letters, e.g. IDR)
+func IsCurrencyValid(currency string) bool {
+	if len(currency) != 3 {
+		return false
+	}
+	for _, r := range currency {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,256 @@
+/*
+Package money containing exact decimal money amount and currency
+*/
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// TestParse test Parse and Amount.String
+func TestParse(t *testing.T) {
+	// initialize testing table
+	testTable := []struct {
+		TestName       string
+		Value          string
+		ExpectedAmount Amount
+		ExpectedString string
+		ExpectedError  error
+	}{
+		{
+			TestName:       "Test Parse Decimal",
+			Value:          "1000000.50",
+			ExpectedAmount: 100000050,
+			ExpectedString: "1000000.50",
+		},
+		{
+			TestName:       "Test Parse Integer",
+			Value:          "2000001",
+			ExpectedAmount: 200000100,
+			ExpectedString: "2000001.00",
+		},
+		{
+			TestName:       "Test Parse Negative",
+			Value:          "-0.5",
+			ExpectedAmount: -50,
+			ExpectedString: "-0.50",
+		},
+		{
+			TestName:       "Test Parse Exponent",
+			Value:          "1.5e3",
+			ExpectedAmount: 150000,
+			ExpectedString: "1500.00",
+		},
+		{
+			TestName:      "Test Parse Too Many Decimal Places",
+			Value:         "0.001",
+			ExpectedError: ErrAmountInvalid,
+		},
+		{
+			TestName:      "Test Parse Fraction",
+			Value:         "1/3",
+			ExpectedError: ErrAmountInvalid,
+		},
+		{
+			TestName:      "Test Parse Out Of Range",
+			Value:         "100000000000000000000",
+			ExpectedError: ErrAmountInvalid,
+		},
+		{
+			TestName:      "Test Parse Empty",
+			Value:         "",
+			ExpectedError: ErrAmountInvalid,
+		},
+	}
+
+	// test for each testing table
+	for _, test := range testTable {
+		a, err := Parse(test.Value)
+		if !errors.Is(err, test.ExpectedError) {
+			t.Errorf("[%s] Expected error %v, but got %v", test.TestName,
+				test.ExpectedError, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		if a != test.ExpectedAmount || a.String() != test.ExpectedString {
+			t.Errorf("[%s] Expected amount %d (%s), but got %d (%s)",
+				test.TestName, test.ExpectedAmount, test.ExpectedString,
+				a, a.String())
+		}
+	}
+}
+
+// TestAmountArithmetic test arithmetic of amount is exact
+func TestAmountArithmetic(t *testing.T) {
+	total := Amount(0)
+	for i := 0; i < 10; i++ {
+		total += MustParse("0.10")
+	}
+	if total != MustParse("1") {
+		t.Errorf("Expected total 1.00, but got %s", total)
+	}
+
+	if MustParse("1000000.50").Mul(3) != MustParse("3000001.50") {
+		t.Errorf("Expected 1000000.50 x 3 = 3000001.50, but got %s",
+			MustParse("1000000.50").Mul(3))
+	}
+}
+
+// TestAmountJSON test amount marshalled into JSON string
+// and unmarshalled from JSON string or number
+func TestAmountJSON(t *testing.T) {
+	b, err := json.Marshal(struct {
+		Price Amount `json:"price"`
+	}{Price: MustParse("1000000.50")})
+	if err != nil {
+		t.Fatalf("There's an error when marshal amount => %s", err)
+	}
+	if string(b) != `{"price":"1000000.50"}` {
+		t.Errorf("Expected amount marshalled as string, but got %s", b)
+	}
+
+	// initialize testing table
+	testTable := []struct {
+		TestName       string
+		JSON           string
+		ExpectedAmount Amount
+		ExpectedError  bool
+	}{
+		{
+			TestName:       "Test Unmarshal String",
+			JSON:           `{"price": "1000000.50"}`,
+			ExpectedAmount: MustParse("1000000.50"),
+		},
+		{
+			TestName:       "Test Unmarshal Number",
+			JSON:           `{"price": 1000000.5}`,
+			ExpectedAmount: MustParse("1000000.50"),
+		},
+		{
+			TestName:       "Test Unmarshal Null",
+			JSON:           `{"price": null}`,
+			ExpectedAmount: 0,
+		},
+		{
+			TestName:      "Test Unmarshal Invalid",
+			JSON:          `{"price": "ten"}`,
+			ExpectedError: true,
+		},
+	}
+
+	// test for each testing table
+	for _, test := range testTable {
+		var v struct {
+			Price Amount `json:"price"`
+		}
+		err := json.Unmarshal([]byte(test.JSON), &v)
+		if (err != nil) != test.ExpectedError {
+			t.Errorf("[%s] Expected error %t, but got %v", test.TestName,
+				test.ExpectedError, err)
+			continue
+		}
+		if v.Price != test.ExpectedAmount {
+			t.Errorf("[%s] Expected amount %s, but got %s", test.TestName,
+				test.ExpectedAmount, v.Price)
+		}
+	}
+}
+
+// TestAmountBSON test amount stored as Decimal128, and amount stored
+// as double or integer before amount is exact can be read
+func TestAmountBSON(t *testing.T) {
+	type document struct {
+		Price Amount `bson:"price"`
+	}
+
+	// initialize testing table
+	testTable := []struct {
+		TestName       string
+		Document       interface{}
+		ExpectedAmount Amount
+	}{
+		{
+			TestName:       "Test Decimal128",
+			Document:       document{Price: MustParse("1000000.50")},
+			ExpectedAmount: MustParse("1000000.50"),
+		},
+		{
+			TestName:       "Test Double",
+			Document:       bson.M{"price": 1000000.5},
+			ExpectedAmount: MustParse("1000000.50"),
+		},
+		{
+			TestName:       "Test Double Rounded",
+			Document:       bson.M{"price": 0.1 + 0.2},
+			ExpectedAmount: MustParse("0.30"),
+		},
+		{
+			TestName:       "Test Integer",
+			Document:       bson.M{"price": int32(500)},
+			ExpectedAmount: MustParse("500"),
+		},
+	}
+
+	// test for each testing table
+	for _, test := range testTable {
+		b, err := bson.Marshal(test.Document)
+		if err != nil {
+			t.Fatalf("[%s] There's an error when marshal document => %s",
+				test.TestName, err)
+		}
+
+		var doc document
+		err = bson.Unmarshal(b, &doc)
+		if err != nil {
+			t.Errorf("[%s] Expected unmarshal success, but got error => %s",
+				test.TestName, err)
+			continue
+		}
+		if doc.Price != test.ExpectedAmount {
+			t.Errorf("[%s] Expected amount %s, but got %s", test.TestName,
+				test.ExpectedAmount, doc.Price)
+		}
+	}
+
+	// amount stored as Decimal128
+	b, err := bson.Marshal(document{Price: MustParse("1000000.50")})
+	if err != nil {
+		t.Fatalf("There's an error when marshal document => %s", err)
+	}
+	if bson.Raw(b).Lookup("price").Type != bson.TypeDecimal128 {
+		t.Errorf("Expected amount stored as Decimal128, but got %s",
+			bson.Raw(b).Lookup("price").Type)
+	}
+}
+
+// TestIsCurrencyValid test IsCurrencyValid
+func TestIsCurrencyValid(t *testing.T) {
+	for currency, expected := range map[string]bool{
+		"IDR": true, "USD": true, "idr": false, "RUPIAH": false, "": false,
+	} {
+		if IsCurrencyValid(currency) != expected {
+			t.Errorf("Expected currency '%s' valid %t, but got %t", currency,
+				expected, !expected)
+		}
+	}
+}
+
+// TestIsCurrencySupported test IsCurrencySupported
+func TestIsCurrencySupported(t *testing.T) {
+	for currency, expected := range map[string]bool{
+		"IDR": true, "USD": true, "EUR": true, "JPY": false, "KRW": false,
+		"KWD": false, "BHD": false, "CLF": false, "idr": false, "": false,
+	} {
+		if IsCurrencySupported(currency) != expected {
+			t.Errorf("Expected currency '%s' supported %t, but got %t",
+				currency, expected, !expected)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+/*
+Package outbox containing relay of order events written to the outbox
+and publishers the events published through
+*/
+package outbox
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/reyhanfikridz/ecom-order-service/internal/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryStore thread-safe order event storage stored in memory,
+// mostly used for testing
+type MemoryStore struct {
+	mu     sync.RWMutex
+	events []model.OrderEvent
+}
+
+// NewMemoryStore create empty in-memory order event storage
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Add add new event to memory
+func (s *MemoryStore) Add(e model.OrderEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e.ID == primitive.NilObjectID {
+		e.ID = primitive.NewObjectID()
+	}
+	s.events = append(s.events, e)
+}
+
+// Events get all events sorted by the time written
+func (s *MemoryStore) Events() []model.OrderEvent {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]model.OrderEvent{}, s.events...)
+}
+
+// Pending get unpublished and not dead events from memory sorted
+// by the time written, skipping orders with event waiting
+// for its next attempt after now
+func (s *MemoryStore) Pending(ctx context.Context, now time.Time,
+	limit int64) ([]model.OrderEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	waitingOrders := map[string]bool{}
+	for _, e := range s.events {
+		if e.PublishedAt == nil && e.DeadAt == nil &&
+			e.NextAttemptAt != nil && e.NextAttemptAt.After(now) {
+			waitingOrders[e.OrderNumber] = true
+		}
+	}
+
+	events := []model.OrderEvent{}
+	for _, e := range s.events {
+		if limit > 0 && int64(len(events)) >= limit {
+			break
+		}
+		if e.PublishedAt == nil && e.DeadAt == nil &&
+			!waitingOrders[e.OrderNumber] {
+			events = append(events, e)
+		}
+	}
+
+	return events, nil
+}
+
+// MarkPublished mark event in memory as published
+func (s *MemoryStore) MarkPublished(ctx context.Context,
+	id primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.events {
+		if s.events[i].ID == id {
+			publishedAt := time.Now().UTC()
+			s.events[i].PublishedAt = &publishedAt
+			s.events[i].NextAttemptAt = nil
+			s.events[i].Attempts++
+			break
+		}
+	}
+
+	return nil
+}
+
+// MarkFailed record failed attempt of publishing event in memory
+func (s *MemoryStore) MarkFailed(ctx context.Context, id primitive.ObjectID,
+	cause error, nextAttemptAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.events {
+		if s.events[i].ID == id {
+			nextAttemptAt = nextAttemptAt.UTC()
+			s.events[i].Attempts++
+			s.events[i].LastError = cause.Error()
+			s.events[i].NextAttemptAt = &nextAttemptAt
+			break
+		}
+	}
+
+	return nil
+}
+
+// MarkDead record last failed attempt of publishing event in memory
+func (s *MemoryStore) MarkDead(ctx context.Context, id primitive.ObjectID,
+	cause error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.events {
+		if s.events[i].ID == id {
+			deadAt := time.Now().UTC()
+			s.events[i].Attempts++
+			s.events[i].LastError = cause.Error()
+			s.events[i].NextAttemptAt = nil
+			s.events[i].DeadAt = &deadAt
+			break
+		}
+	}
+
+	return nil
+}
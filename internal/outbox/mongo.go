@@ -0,0 +1,112 @@
+/*
+Package outbox containing relay of order events written to the outbox
+and publishers the events published through
+*/
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/reyhanfikridz/ecom-order-service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoStore order event storage stored in mongodb order outbox collection
+type MongoStore struct {
+	Collection *mongo.Collection
+}
+
+// NewMongoStore create order event storage
+// using mongodb order outbox collection
+func NewMongoStore(ec *mongo.Collection) *MongoStore {
+	return &MongoStore{Collection: ec}
+}
+
+// Pending get unpublished and not dead events from order outbox collection
+// sorted by the time written, skipping orders with event waiting
+// for its next attempt after now
+func (s *MongoStore) Pending(ctx context.Context, now time.Time,
+	limit int64) ([]model.OrderEvent, error) {
+	events := []model.OrderEvent{}
+
+	// get orders with failed event not due yet, the next events
+	// of those orders must wait for it
+	waitingOrders, err := s.Collection.Distinct(ctx, "order_number", bson.M{
+		"published_at":    nil,
+		"dead_at":         nil,
+		"next_attempt_at": bson.M{"$gt": now},
+	})
+	if err != nil {
+		return events, err
+	}
+	if waitingOrders == nil {
+		waitingOrders = []interface{}{}
+	}
+
+	// event ID generated when it's written, so sorting by ID
+	// is sorting by the time written
+	findOpts := options.Find().SetSort(bson.D{primitive.E{Key: "_id", Value: 1}})
+	if limit > 0 {
+		findOpts.SetLimit(limit)
+	}
+
+	cursor, err := s.Collection.Find(ctx, bson.M{
+		"published_at": nil,
+		"dead_at":      nil,
+		"order_number": bson.M{"$nin": waitingOrders},
+	}, findOpts)
+	if err != nil {
+		return events, err
+	}
+
+	err = cursor.All(ctx, &events)
+	if err != nil {
+		return events, err
+	}
+
+	return events, nil
+}
+
+// MarkPublished mark event in order outbox collection as published
+func (s *MongoStore) MarkPublished(ctx context.Context,
+	id primitive.ObjectID) error {
+	_, err := s.Collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set":   bson.M{"published_at": time.Now().UTC()},
+		"$unset": bson.M{"next_attempt_at": ""},
+		"$inc":   bson.M{"attempts": 1},
+	})
+
+	return err
+}
+
+// MarkFailed record failed attempt of publishing event
+// in order outbox collection
+func (s *MongoStore) MarkFailed(ctx context.Context, id primitive.ObjectID,
+	cause error, nextAttemptAt time.Time) error {
+	_, err := s.Collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set": bson.M{
+			"last_error":      cause.Error(),
+			"next_attempt_at": nextAttemptAt.UTC(),
+		},
+		"$inc": bson.M{"attempts": 1},
+	})
+
+	return err
+}
+
+// MarkDead record last failed attempt of publishing event
+// in order outbox collection
+func (s *MongoStore) MarkDead(ctx context.Context, id primitive.ObjectID,
+	cause error) error {
+	_, err := s.Collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set":   bson.M{"last_error": cause.Error(), "dead_at": time.Now().UTC()},
+		"$unset": bson.M{"next_attempt_at": ""},
+		"$inc":   bson.M{"attempts": 1},
+	})
+
+	return err
+}
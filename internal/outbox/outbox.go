@@ -0,0 +1,204 @@
+/*
+Package outbox containing relay of order events written to the outbox
+and publishers the events published through
+*/
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/reyhanfikridz/ecom-order-service/internal/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// publisher kinds
+const (
+	PublisherLog  = "log"
+	PublisherFile = "file"
+)
+
+// relay defaults
+const (
+	defaultBatchSize   = 100
+	defaultMaxAttempts = 10
+	defaultBaseBackoff = 5 * time.Second
+	defaultMaxBackoff  = time.Hour
+)
+
+// Store storage of order events waiting to be published
+type Store interface {
+	// Pending get unpublished and not dead events sorted by the time
+	// written, max limit events, orders with event waiting for its next
+	// attempt after now are skipped so events of one order stay in order
+	Pending(ctx context.Context, now time.Time,
+		limit int64) ([]model.OrderEvent, error)
+
+	// MarkPublished mark event as published
+	MarkPublished(ctx context.Context, id primitive.ObjectID) error
+
+	// MarkFailed record failed attempt of publishing event,
+	// event attempted again at nextAttemptAt
+	MarkFailed(ctx context.Context, id primitive.ObjectID, cause error,
+		nextAttemptAt time.Time) error
+
+	// MarkDead record last failed attempt of publishing event,
+	// event never attempted again
+	MarkDead(ctx context.Context, id primitive.ObjectID, cause error) error
+}
+
+// Publisher publisher of order events to other services
+//
+// event can be published more than once, so consumer must
+// ignore event with ID already received
+type Publisher interface {
+	Publish(ctx context.Context, e model.OrderEvent) error
+}
+
+// NewPublisher get publisher by kind (log or file), empty kind means log,
+// path only used by file publisher
+func NewPublisher(kind string, path string) (Publisher, error) {
+	switch kind {
+	case "", PublisherLog:
+		return NewLogPublisher(log.Writer()), nil
+	case PublisherFile:
+		if path == "" {
+			return nil, fmt.Errorf("file path of file publisher empty")
+		}
+		return NewFilePublisher(path)
+	}
+
+	return nil, fmt.Errorf("publisher '%s' invalid", kind)
+}
+
+// Relay publish events from the store through the publisher
+// with at-least-once delivery
+//
+// event marked as published only after publisher succeed, so event
+// published again if marking failed, events of one order are published
+// in the order they written
+//
+// failed event attempted again with exponential backoff and marked as dead
+// after MaxAttempts, so it doesn't block events of other orders forever
+type Relay struct {
+	Store     Store
+	Publisher Publisher
+
+	// BatchSize max total events published in one relay
+	BatchSize int64
+
+	// MaxAttempts max total attempts of publishing one event
+	MaxAttempts int
+
+	// BaseBackoff wait before the second attempt, doubled every next attempt
+	// up to MaxBackoff
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+
+	// Now get current time, time.Now if nil
+	Now func() time.Time
+}
+
+// NewRelay create relay of events from store to publisher
+func NewRelay(store Store, publisher Publisher) *Relay {
+	return &Relay{
+		Store:       store,
+		Publisher:   publisher,
+		BatchSize:   defaultBatchSize,
+		MaxAttempts: defaultMaxAttempts,
+		BaseBackoff: defaultBaseBackoff,
+		MaxBackoff:  defaultMaxBackoff,
+	}
+}
+
+// now get current time in UTC
+func (r *Relay) now() time.Time {
+	if r.Now != nil {
+		return r.Now().UTC()
+	}
+	return time.Now().UTC()
+}
+
+// backoff get wait before the next attempt of event already attempted
+// the given total times
+func (r *Relay) backoff(attempts int) time.Duration {
+	wait := r.BaseBackoff
+	for i := 1; i < attempts && wait < r.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > r.MaxBackoff {
+		wait = r.MaxBackoff
+	}
+
+	return wait
+}
+
+// RelayOnce publish pending events once, return total events published
+//
+// when one event failed to be published, the next events
+// of the same order are left pending until the failed event published
+// or marked as dead
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	now := r.now()
+	events, err := r.Store.Pending(ctx, now, r.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	failedOrders := map[string]bool{}
+	for _, e := range events {
+		if failedOrders[e.OrderNumber] {
+			continue
+		}
+
+		// publish event
+		err = r.Publisher.Publish(ctx, e)
+		if err != nil {
+			failedOrders[e.OrderNumber] = true
+
+			attempts := e.Attempts + 1
+			if r.MaxAttempts > 0 && attempts >= r.MaxAttempts {
+				log.Printf("Order event %s of order %s marked as dead after "+
+					"%d attempts => %s", e.ID.Hex(), e.OrderNumber, attempts, err)
+				err = r.Store.MarkDead(ctx, e.ID, err)
+			} else {
+				err = r.Store.MarkFailed(ctx, e.ID, err, now.Add(r.backoff(attempts)))
+			}
+			if err != nil {
+				return total, err
+			}
+			continue
+		}
+
+		// mark event published
+		err = r.Store.MarkPublished(ctx, e.ID)
+		if err != nil {
+			return total, err
+		}
+		total++
+	}
+
+	return total, nil
+}
+
+// Run periodically publish pending events until ctx done
+func (r *Relay) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := r.RelayOnce(ctx)
+			if err != nil {
+				log.Printf("There's an error when publishing order events "+
+					"=> %s", err)
+			}
+		}
+	}
+}
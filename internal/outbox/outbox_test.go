@@ -0,0 +1,260 @@
+/*
+Package outbox containing relay of order events written to the outbox
+and publishers the events published through
+*/
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/reyhanfikridz/ecom-order-service/internal/model"
+)
+
+// getTestingEvent get event of order for testing
+func getTestingEvent(eventType string, orderNumber string) model.OrderEvent {
+	return model.NewOrderEvent(eventType, nil, model.Order{
+		OrderNumber: orderNumber,
+		Status:      model.OrderStatusInCart,
+		BuyerID:     1,
+	})
+}
+
+// orderFailingPublisher publisher failing events of one order
+type orderFailingPublisher struct {
+	*MemoryPublisher
+	OrderNumber string
+}
+
+// Publish publish event, failed if it's event of the failing order
+func (p orderFailingPublisher) Publish(ctx context.Context,
+	e model.OrderEvent) error {
+	if e.OrderNumber == p.OrderNumber {
+		return errors.New("order event rejected")
+	}
+	return p.MemoryPublisher.Publish(ctx, e)
+}
+
+// TestRelayOnce test Relay RelayOnce
+func TestRelayOnce(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	publisher := NewMemoryPublisher()
+	relay := NewRelay(store, publisher)
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	relay.Now = func() time.Time { return now }
+
+	store.Add(getTestingEvent(model.EventOrderCreated, "ORDER1"))
+	store.Add(getTestingEvent(model.EventOrderCreated, "ORDER2"))
+	store.Add(getTestingEvent(model.EventOrderStatusChanged, "ORDER1"))
+
+	// test failed events kept pending
+	publisher.Err = errors.New("broker down")
+	total, err := relay.RelayOnce(ctx)
+	if err != nil {
+		t.Fatalf("Expected error nil, but got %s", err)
+	}
+	if total != 0 {
+		t.Errorf("Expected total published 0, but got %d", total)
+	}
+
+	events := store.Events()
+	if events[0].Attempts != 1 || events[0].LastError != "broker down" {
+		t.Errorf("Expected failed attempt recorded, but got %+v", events[0])
+	}
+	if events[0].NextAttemptAt == nil ||
+		!events[0].NextAttemptAt.Equal(now.Add(relay.BaseBackoff)) {
+		t.Errorf("Expected next attempt at %s, but got %v",
+			now.Add(relay.BaseBackoff), events[0].NextAttemptAt)
+	}
+	if events[2].Attempts != 0 {
+		t.Errorf("Expected next event of failed order not attempted, "+
+			"but got %d attempts", events[2].Attempts)
+	}
+
+	// test failed events not attempted again before backoff passed
+	publisher.Err = nil
+	total, err = relay.RelayOnce(ctx)
+	if err != nil {
+		t.Fatalf("Expected error nil, but got %s", err)
+	}
+	if total != 0 {
+		t.Errorf("Expected total published 0 before backoff passed, "+
+			"but got %d", total)
+	}
+
+	// test all events published in order after backoff passed
+	now = now.Add(relay.BaseBackoff)
+	total, err = relay.RelayOnce(ctx)
+	if err != nil {
+		t.Fatalf("Expected error nil, but got %s", err)
+	}
+	if total != 3 {
+		t.Errorf("Expected total published 3, but got %d", total)
+	}
+
+	published := publisher.Events()
+	if len(published) != 3 || published[0].OrderNumber != "ORDER1" ||
+		published[2].Type != model.EventOrderStatusChanged {
+		t.Errorf("Expected events published in order, but got %+v", published)
+	}
+
+	// test published events not published again
+	total, err = relay.RelayOnce(ctx)
+	if err != nil {
+		t.Fatalf("Expected error nil, but got %s", err)
+	}
+	if total != 0 || len(publisher.Events()) != 3 {
+		t.Errorf("Expected no event published again, but got %d", total)
+	}
+}
+
+// TestRelayOnceFailedEvent test Relay RelayOnce with event of one order
+// keep failing
+func TestRelayOnceFailedEvent(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	publisher := orderFailingPublisher{
+		MemoryPublisher: NewMemoryPublisher(),
+		OrderNumber:     "ORDER1",
+	}
+	relay := NewRelay(store, publisher)
+	relay.BatchSize = 2
+	relay.MaxAttempts = 3
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	relay.Now = func() time.Time { return now }
+
+	store.Add(getTestingEvent(model.EventOrderCreated, "ORDER1"))
+	store.Add(getTestingEvent(model.EventOrderStatusChanged, "ORDER1"))
+	store.Add(getTestingEvent(model.EventOrderCreated, "ORDER2"))
+	store.Add(getTestingEvent(model.EventOrderCreated, "ORDER3"))
+
+	// test failed order not blocking events of other orders
+	// while waiting for the next attempt
+	for i := 0; i < 2; i++ {
+		_, err := relay.RelayOnce(ctx)
+		if err != nil {
+			t.Fatalf("Expected error nil, but got %s", err)
+		}
+	}
+	if len(publisher.Events()) != 2 {
+		t.Fatalf("Expected events of other orders published, but got %+v",
+			publisher.Events())
+	}
+
+	// test failed event attempted with backoff doubled
+	// and marked as dead after max attempts
+	backoffs := []time.Duration{relay.BaseBackoff, 2 * relay.BaseBackoff}
+	for _, backoff := range backoffs {
+		now = now.Add(backoff)
+		_, err := relay.RelayOnce(ctx)
+		if err != nil {
+			t.Fatalf("Expected error nil, but got %s", err)
+		}
+	}
+
+	events := store.Events()
+	if events[0].Attempts != 3 || events[0].DeadAt == nil ||
+		events[0].NextAttemptAt != nil {
+		t.Errorf("Expected event dead after 3 attempts, but got %+v", events[0])
+	}
+
+	// test dead event not attempted again and next event
+	// of the same order no longer blocked
+	now = now.Add(relay.MaxBackoff)
+	_, err := relay.RelayOnce(ctx)
+	if err != nil {
+		t.Fatalf("Expected error nil, but got %s", err)
+	}
+
+	events = store.Events()
+	if events[0].Attempts != 3 {
+		t.Errorf("Expected dead event not attempted again, but got %d attempts",
+			events[0].Attempts)
+	}
+	if events[1].Attempts != 1 {
+		t.Errorf("Expected next event of the order attempted, "+
+			"but got %d attempts", events[1].Attempts)
+	}
+}
+
+// TestLogPublisher test LogPublisher and NewPublisher
+func TestLogPublisher(t *testing.T) {
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	publisher := NewLogPublisher(&buf)
+	e := getTestingEvent(model.EventOrderCreated, "ORDER1")
+	err := publisher.Publish(ctx, e)
+	if err != nil {
+		t.Fatalf("Expected error nil, but got %s", err)
+	}
+
+	var result map[string]interface{}
+	err = json.Unmarshal(buf.Bytes(), &result)
+	if err != nil {
+		t.Fatalf("Expected event published as JSON, but got %s", buf.String())
+	}
+	if result["id"] != e.ID.Hex() || result["type"] != model.EventOrderCreated ||
+		result["order_number"] != "ORDER1" {
+		t.Errorf("Expected event %s of ORDER1, but got %s", e.ID.Hex(),
+			buf.String())
+	}
+	if _, ok := result["attempts"]; ok {
+		t.Errorf("Expected delivery state not published, but got %s",
+			buf.String())
+	}
+
+	// test file publisher
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	filePublisher, err := NewPublisher(PublisherFile, path)
+	if err != nil {
+		t.Fatalf("Expected error nil, but got %s", err)
+	}
+	err = filePublisher.Publish(ctx, e)
+	if err != nil {
+		t.Errorf("Expected error nil, but got %s", err)
+	}
+
+	// test invalid publisher
+	_, err = NewPublisher("kafka", "")
+	if err == nil {
+		t.Errorf("Expected error publisher invalid, but got nil")
+	}
+	_, err = NewPublisher(PublisherFile, "")
+	if err == nil {
+		t.Errorf("Expected error file path empty, but got nil")
+	}
+}
+
+// TestMultiPublisher test MultiPublisher
+func TestMultiPublisher(t *testing.T) {
+	ctx := context.Background()
+
+	first := NewMemoryPublisher()
+	second := NewMemoryPublisher()
+	publisher := MultiPublisher{first, second}
+
+	e := getTestingEvent(model.EventOrderCreated, "ORDER1")
+	err := publisher.Publish(ctx, e)
+	if err != nil {
+		t.Fatalf("Expected error nil, but got %s", err)
+	}
+	if len(first.Events()) != 1 || len(second.Events()) != 1 {
+		t.Errorf("Expected event published through all publishers, "+
+			"but got %d and %d events", len(first.Events()),
+			len(second.Events()))
+	}
+
+	// test one of the publishers failed
+	second.Err = errors.New("webhook storage down")
+	err = publisher.Publish(ctx, e)
+	if err == nil {
+		t.Errorf("Expected error publisher failed, but got nil")
+	}
+}
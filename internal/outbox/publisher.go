@@ -0,0 +1,99 @@
+/*
+Package outbox containing relay of order events written to the outbox
+and publishers the events published through
+*/
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/reyhanfikridz/ecom-order-service/internal/model"
+)
+
+// LogPublisher thread-safe publisher writing each event
+// as one line of JSON
+type LogPublisher struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewLogPublisher create publisher writing events to w
+func NewLogPublisher(w io.Writer) *LogPublisher {
+	return &LogPublisher{encoder: json.NewEncoder(w)}
+}
+
+// NewFilePublisher create publisher appending events to file in path
+func NewFilePublisher(path string) (*LogPublisher, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewLogPublisher(f), nil
+}
+
+// Publish write event as one line of JSON
+func (p *LogPublisher) Publish(ctx context.Context, e model.OrderEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.encoder.Encode(e)
+}
+
+// MemoryPublisher thread-safe publisher storing events in memory,
+// mostly used for testing
+type MemoryPublisher struct {
+	// Err error returned when publishing event, nil means success
+	Err error
+
+	mu     sync.RWMutex
+	events []model.OrderEvent
+}
+
+// NewMemoryPublisher create empty in-memory publisher
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{}
+}
+
+// Publish store event in memory
+func (p *MemoryPublisher) Publish(ctx context.Context, e model.OrderEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.Err != nil {
+		return p.Err
+	}
+	p.events = append(p.events, e)
+
+	return nil
+}
+
+// Events get all published events sorted by the time published
+func (p *MemoryPublisher) Events() []model.OrderEvent {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return append([]model.OrderEvent{}, p.events...)
+}
+
+// MultiPublisher publisher publishing each event through all publishers
+//
+// event failed if one of the publishers failed, so it's published again
+// through all publishers in the next relay
+type MultiPublisher []Publisher
+
+// Publish publish event through all publishers
+func (p MultiPublisher) Publish(ctx context.Context, e model.OrderEvent) error {
+	for _, publisher := range p {
+		err := publisher.Publish(ctx, e)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,462 @@
+/*
+Package patch containing JSON Merge Patch (RFC 7396)
+and JSON Patch (RFC 6902) of JSON documents
+*/
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSON Patch operations
+const (
+	OpAdd     = "add"
+	OpRemove  = "remove"
+	OpReplace = "replace"
+	OpMove    = "move"
+	OpCopy    = "copy"
+	OpTest    = "test"
+)
+
+var (
+	// ErrInvalidPatch returned when patch document is malformed
+	ErrInvalidPatch = errors.New("patch document invalid")
+
+	// ErrTestFailed returned when value of JSON Patch test operation
+	// not the same as the value in the document
+	ErrTestFailed = errors.New("patch test failed")
+)
+
+// Operation one operation of JSON Patch
+type Operation struct {
+	Op    string           `json:"op"`
+	Path  string           `json:"path"`
+	From  string           `json:"from,omitempty"`
+	Value *json.RawMessage `json:"value,omitempty"`
+}
+
+// MergePatch apply JSON Merge Patch to JSON document,
+// null value in the patch remove the field from the document
+func MergePatch(doc []byte, patch []byte) ([]byte, error) {
+	target, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := decode(patch)
+	if err != nil {
+		return nil, fmt.Errorf("%w => %s", ErrInvalidPatch, err)
+	}
+
+	return json.Marshal(mergePatch(target, p))
+}
+
+// DecodeJSONPatch decode JSON Patch document into its operations
+func DecodeJSONPatch(patch []byte) ([]Operation, error) {
+	ops := []Operation{}
+	err := json.Unmarshal(patch, &ops)
+	if err != nil {
+		return nil, fmt.Errorf("%w => %s", ErrInvalidPatch, err)
+	}
+
+	for i, op := range ops {
+		switch op.Op {
+		case OpAdd, OpReplace, OpTest:
+			if op.Value == nil {
+				return nil, fmt.Errorf("%w => operation %d value empty/not found",
+					ErrInvalidPatch, i)
+			}
+		case OpMove, OpCopy:
+			if _, err := parsePointer(op.From); err != nil {
+				return nil, fmt.Errorf("%w => operation %d from invalid",
+					ErrInvalidPatch, i)
+			}
+		case OpRemove:
+		default:
+			return nil, fmt.Errorf("%w => operation %d op '%s' invalid",
+				ErrInvalidPatch, i, op.Op)
+		}
+
+		if _, err := parsePointer(op.Path); err != nil {
+			return nil, fmt.Errorf("%w => operation %d path invalid",
+				ErrInvalidPatch, i)
+		}
+	}
+
+	return ops, nil
+}
+
+// JSONPatch apply JSON Patch operations to JSON document in order,
+// no operation applied if one of them failed
+func JSONPatch(doc []byte, ops []Operation) ([]byte, error) {
+	target, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, op := range ops {
+		target, err = applyOperation(target, op)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d => %w", i, err)
+		}
+	}
+
+	return json.Marshal(target)
+}
+
+// ChangedFields get top level fields of JSON object changed
+// from before into after, sorted by field name
+func ChangedFields(before []byte, after []byte) ([]string, error) {
+	beforeFields, err := decodeObject(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := decodeObject(after)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := []string{}
+	for field, value := range beforeFields {
+		afterValue, ok := afterFields[field]
+		if !ok || !reflect.DeepEqual(value, afterValue) {
+			fields = append(fields, field)
+		}
+	}
+	for field := range afterFields {
+		if _, ok := beforeFields[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	return fields, nil
+}
+
+// mergePatch merge patch value into target value
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+
+	for field, value := range patchObject {
+		if value == nil {
+			delete(targetObject, field)
+			continue
+		}
+		targetObject[field] = mergePatch(targetObject[field], value)
+	}
+
+	return targetObject
+}
+
+// applyOperation apply one JSON Patch operation to document,
+// return the new document
+func applyOperation(doc interface{}, op Operation) (interface{}, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	if op.Value != nil {
+		value, err = decode(*op.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%w => %s", ErrInvalidPatch, err)
+		}
+	}
+
+	switch op.Op {
+	case OpAdd:
+		return add(doc, path, value)
+
+	case OpRemove:
+		doc, _, err = remove(doc, path)
+		return doc, err
+
+	case OpReplace:
+		if _, err = get(doc, path); err != nil {
+			return nil, err
+		}
+		if len(path) == 0 {
+			return value, nil
+		}
+		doc, _, err = remove(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+
+	case OpMove:
+		from, _ := parsePointer(op.From)
+		if isPrefix(from, path) && len(from) < len(path) {
+			return nil, fmt.Errorf("path '%s' is child of from '%s'",
+				op.Path, op.From)
+		}
+		doc, value, err = remove(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+
+	case OpCopy:
+		from, _ := parsePointer(op.From)
+		value, err = get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		value, err = copyValue(value)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+
+	case OpTest:
+		current, err := get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(current, value) {
+			return nil, fmt.Errorf("%w => value of path '%s' not the same",
+				ErrTestFailed, op.Path)
+		}
+		return doc, nil
+	}
+
+	return nil, fmt.Errorf("%w => op '%s' invalid", ErrInvalidPatch, op.Op)
+}
+
+// get get value located by path in document
+func get(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("path '%s' not found", formatPointer(path))
+			}
+			doc = value
+		case []interface{}:
+			i, err := getIndex(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, fmt.Errorf("path '%s' not found", formatPointer(path))
+		}
+	}
+
+	return doc, nil
+}
+
+// add add value to location of path in document,
+// array element inserted before the index or appended for "-"
+func add(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return modifyParent(doc, path, func(parent interface{},
+		token string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			node[token] = value
+			return node, nil
+		case []interface{}:
+			if token == "-" {
+				return append(node, value), nil
+			}
+			i, err := getIndex(token, len(node))
+			if err != nil {
+				return nil, err
+			}
+			result := make([]interface{}, 0, len(node)+1)
+			result = append(result, node[:i]...)
+			result = append(result, value)
+			return append(result, node[i:]...), nil
+		}
+
+		return nil, fmt.Errorf("path '%s' not found", formatPointer(path))
+	})
+}
+
+// remove remove value located by path from document,
+// return the new document and the removed value
+func remove(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("document root can't be removed")
+	}
+
+	var removed interface{}
+	doc, err := modifyParent(doc, path, func(parent interface{},
+		token string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("path '%s' not found", formatPointer(path))
+			}
+			removed = value
+			delete(node, token)
+			return node, nil
+		case []interface{}:
+			i, err := getIndex(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			removed = node[i]
+			result := make([]interface{}, 0, len(node)-1)
+			result = append(result, node[:i]...)
+			return append(result, node[i+1:]...), nil
+		}
+
+		return nil, fmt.Errorf("path '%s' not found", formatPointer(path))
+	})
+
+	return doc, removed, err
+}
+
+// modifyParent change parent of value located by path in document
+// with modify function, return the new document
+func modifyParent(doc interface{}, path []string,
+	modify func(parent interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return modify(doc, path[0])
+	}
+
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		child, ok := node[path[0]]
+		if !ok {
+			return nil, fmt.Errorf("path '%s' not found", formatPointer(path))
+		}
+		child, err := modifyParent(child, path[1:], modify)
+		if err != nil {
+			return nil, err
+		}
+		node[path[0]] = child
+		return node, nil
+	case []interface{}:
+		i, err := getIndex(path[0], len(node)-1)
+		if err != nil {
+			return nil, err
+		}
+		child, err := modifyParent(node[i], path[1:], modify)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = child
+		return node, nil
+	}
+
+	return nil, fmt.Errorf("path '%s' not found", formatPointer(path))
+}
+
+// getIndex get array index from pointer token, index can't be more than max
+func getIndex(token string, max int) (int, error) {
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i > max ||
+		(len(token) > 1 && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("array index '%s' invalid", token)
+	}
+
+	return i, nil
+}
+
+// parsePointer parse JSON Pointer (RFC 6901) into its tokens
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("pointer '%s' invalid", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		token = strings.ReplaceAll(token, "~1", "/")
+		tokens[i] = strings.ReplaceAll(token, "~0", "~")
+	}
+
+	return tokens, nil
+}
+
+// formatPointer format tokens into JSON Pointer
+func formatPointer(tokens []string) string {
+	var b strings.Builder
+	for _, token := range tokens {
+		token = strings.ReplaceAll(token, "~", "~0")
+		b.WriteString("/" + strings.ReplaceAll(token, "/", "~1"))
+	}
+
+	return b.String()
+}
+
+// isPrefix check if tokens of pointer a is prefix of tokens of pointer b
+func isPrefix(a []string, b []string) bool {
+	if len(a) > len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// copyValue deep copy decoded JSON value
+func copyValue(value interface{}) (interface{}, error) {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return decode(b)
+}
+
+// decode decode JSON document keeping numbers as they're written
+func decode(doc []byte) (interface{}, error) {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(doc))
+	decoder.UseNumber()
+	err := decoder.Decode(&value)
+	if err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("document has more than one JSON value")
+	}
+
+	return value, nil
+}
+
+// decodeObject decode JSON object document into map of field to its value
+func decodeObject(doc []byte) (map[string]interface{}, error) {
+	value, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("document is not JSON object")
+	}
+
+	return object, nil
+}
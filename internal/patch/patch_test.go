@@ -0,0 +1,247 @@
+/*
+Package patch containing JSON Merge Patch (RFC 7396)
+and JSON Patch (RFC 6902) of JSON documents
+*/
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// TestMergePatch test MergePatch
+func TestMergePatch(t *testing.T) {
+	// initialize testing table, examples from RFC 7396
+	testTable := []struct {
+		TestName string
+		Doc      string
+		Patch    string
+		Expected string
+	}{
+		{
+			TestName: "Test Merge Patch Replace",
+			Doc:      `{"a":"b"}`,
+			Patch:    `{"a":"c"}`,
+			Expected: `{"a":"c"}`,
+		},
+		{
+			TestName: "Test Merge Patch Add",
+			Doc:      `{"a":"b"}`,
+			Patch:    `{"b":"c"}`,
+			Expected: `{"a":"b","b":"c"}`,
+		},
+		{
+			TestName: "Test Merge Patch Remove",
+			Doc:      `{"a":"b","b":"c"}`,
+			Patch:    `{"a":null}`,
+			Expected: `{"b":"c"}`,
+		},
+		{
+			TestName: "Test Merge Patch Array Replaced",
+			Doc:      `{"a":[{"b":"c"}]}`,
+			Patch:    `{"a":[1]}`,
+			Expected: `{"a":[1]}`,
+		},
+		{
+			TestName: "Test Merge Patch Nested",
+			Doc:      `{"a":{"b":"c","d":"e"}}`,
+			Patch:    `{"a":{"b":null,"f":0}}`,
+			Expected: `{"a":{"d":"e","f":0}}`,
+		},
+		{
+			TestName: "Test Merge Patch Not Object",
+			Doc:      `{"a":"b"}`,
+			Patch:    `["c"]`,
+			Expected: `["c"]`,
+		},
+	}
+
+	// test for each testing table
+	for _, test := range testTable {
+		result, err := MergePatch([]byte(test.Doc), []byte(test.Patch))
+		if err != nil {
+			t.Errorf("[%s] Expected error nil, but got %s", test.TestName, err)
+			continue
+		}
+		if !isJSONEqual(t, result, []byte(test.Expected)) {
+			t.Errorf("[%s] Expected %s, but got %s", test.TestName,
+				test.Expected, result)
+		}
+	}
+
+	// test invalid patch
+	_, err := MergePatch([]byte(`{"a":"b"}`), []byte(`{"a":`))
+	if !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("Expected error ErrInvalidPatch, but got %v", err)
+	}
+}
+
+// TestJSONPatch test DecodeJSONPatch and JSONPatch
+func TestJSONPatch(t *testing.T) {
+	// initialize testing table, mostly examples from RFC 6902
+	testTable := []struct {
+		TestName      string
+		Doc           string
+		Patch         string
+		Expected      string
+		ExpectedError error
+	}{
+		{
+			TestName: "Test JSON Patch Add Object Member",
+			Doc:      `{"foo":"bar"}`,
+			Patch:    `[{"op":"add","path":"/baz","value":"qux"}]`,
+			Expected: `{"baz":"qux","foo":"bar"}`,
+		},
+		{
+			TestName: "Test JSON Patch Add Array Element",
+			Doc:      `{"foo":["bar","baz"]}`,
+			Patch:    `[{"op":"add","path":"/foo/1","value":"qux"}]`,
+			Expected: `{"foo":["bar","qux","baz"]}`,
+		},
+		{
+			TestName: "Test JSON Patch Add Array End",
+			Doc:      `{"foo":["bar"]}`,
+			Patch:    `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`,
+			Expected: `{"foo":["bar",["abc","def"]]}`,
+		},
+		{
+			TestName: "Test JSON Patch Remove",
+			Doc:      `{"baz":"qux","foo":["bar","qux","baz"]}`,
+			Patch: `[{"op":"remove","path":"/baz"},` +
+				`{"op":"remove","path":"/foo/1"}]`,
+			Expected: `{"foo":["bar","baz"]}`,
+		},
+		{
+			TestName: "Test JSON Patch Replace",
+			Doc:      `{"baz":"qux","foo":"bar"}`,
+			Patch:    `[{"op":"replace","path":"/baz","value":"boo"}]`,
+			Expected: `{"baz":"boo","foo":"bar"}`,
+		},
+		{
+			TestName: "Test JSON Patch Move",
+			Doc:      `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			Patch:    `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			Expected: `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`,
+		},
+		{
+			TestName: "Test JSON Patch Copy",
+			Doc:      `{"foo":{"bar":1}}`,
+			Patch:    `[{"op":"copy","from":"/foo","path":"/baz"}]`,
+			Expected: `{"foo":{"bar":1},"baz":{"bar":1}}`,
+		},
+		{
+			TestName: "Test JSON Patch Escaped Path",
+			Doc:      `{"a/b":1,"m~n":2}`,
+			Patch: `[{"op":"replace","path":"/a~1b","value":3},` +
+				`{"op":"remove","path":"/m~0n"}]`,
+			Expected: `{"a/b":3}`,
+		},
+		{
+			TestName: "Test JSON Patch Test Success",
+			Doc:      `{"baz":"qux","foo":["a",2,"c"]}`,
+			Patch: `[{"op":"test","path":"/baz","value":"qux"},` +
+				`{"op":"test","path":"/foo/1","value":2}]`,
+			Expected: `{"baz":"qux","foo":["a",2,"c"]}`,
+		},
+		{
+			TestName:      "Test JSON Patch Test Failed",
+			Doc:           `{"baz":"qux"}`,
+			Patch:         `[{"op":"test","path":"/baz","value":"bar"}]`,
+			ExpectedError: ErrTestFailed,
+		},
+		{
+			TestName: "Test JSON Patch Path Not Found",
+			Doc:      `{"foo":"bar"}`,
+			Patch:    `[{"op":"add","path":"/baz/bat","value":"qux"}]`,
+		},
+		{
+			TestName: "Test JSON Patch Array Index Invalid",
+			Doc:      `{"foo":["bar"]}`,
+			Patch:    `[{"op":"remove","path":"/foo/01"}]`,
+		},
+		{
+			TestName:      "Test JSON Patch Op Invalid",
+			Doc:           `{"foo":"bar"}`,
+			Patch:         `[{"op":"delete","path":"/foo"}]`,
+			ExpectedError: ErrInvalidPatch,
+		},
+		{
+			TestName:      "Test JSON Patch Value Empty",
+			Doc:           `{"foo":"bar"}`,
+			Patch:         `[{"op":"replace","path":"/foo"}]`,
+			ExpectedError: ErrInvalidPatch,
+		},
+		{
+			TestName:      "Test JSON Patch Path Invalid",
+			Doc:           `{"foo":"bar"}`,
+			Patch:         `[{"op":"remove","path":"foo"}]`,
+			ExpectedError: ErrInvalidPatch,
+		},
+	}
+
+	// test for each testing table
+	for _, test := range testTable {
+		ops, err := DecodeJSONPatch([]byte(test.Patch))
+		var result []byte
+		if err == nil {
+			result, err = JSONPatch([]byte(test.Doc), ops)
+		}
+
+		if test.Expected == "" {
+			if err == nil {
+				t.Errorf("[%s] Expected error, but got %s", test.TestName, result)
+			}
+			if test.ExpectedError != nil && !errors.Is(err, test.ExpectedError) {
+				t.Errorf("[%s] Expected error %s, but got %v", test.TestName,
+					test.ExpectedError, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("[%s] Expected error nil, but got %s", test.TestName, err)
+			continue
+		}
+		if !isJSONEqual(t, result, []byte(test.Expected)) {
+			t.Errorf("[%s] Expected %s, but got %s", test.TestName,
+				test.Expected, result)
+		}
+	}
+}
+
+// TestChangedFields test ChangedFields
+func TestChangedFields(t *testing.T) {
+	fields, err := ChangedFields(
+		[]byte(`{"a":1,"b":{"c":2},"d":"e","f":[1]}`),
+		[]byte(`{"a":1,"b":{"c":3},"f":[1],"g":null}`))
+	if err != nil {
+		t.Fatalf("Expected error nil, but got %s", err)
+	}
+
+	expected := []string{"b", "d", "g"}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("Expected changed fields %v, but got %v", expected, fields)
+	}
+
+	_, err = ChangedFields([]byte(`{"a":1}`), []byte(`[1]`))
+	if err == nil {
+		t.Errorf("Expected error document is not JSON object, but got nil")
+	}
+}
+
+// isJSONEqual check if two JSON document have the same value
+func isJSONEqual(t *testing.T, a []byte, b []byte) bool {
+	var aValue, bValue interface{}
+	if err := json.Unmarshal(a, &aValue); err != nil {
+		t.Errorf("There's an error when unmarshal %s => %s", a, err)
+		return false
+	}
+	if err := json.Unmarshal(b, &bValue); err != nil {
+		t.Errorf("There's an error when unmarshal %s => %s", b, err)
+		return false
+	}
+
+	return reflect.DeepEqual(aValue, bValue)
+}
@@ -0,0 +1,299 @@
+/*
+Package policy containing per role authorization policy of orders
+*/
+package policy
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/reyhanfikridz/ecom-order-service/internal/middleware"
+	"github.com/reyhanfikridz/ecom-order-service/internal/model"
+	"github.com/reyhanfikridz/ecom-order-service/internal/webhook"
+)
+
+// actions of permission matrix
+const (
+	ActionOrderCreate          = "order.create"
+	ActionOrderRead            = "order.read"
+	ActionOrderList            = "order.list"
+	ActionOrderReadDeleted     = "order.read_deleted"
+	ActionOrderItemsChange     = "order.items.change"
+	ActionOrderItemsEditDetail = "order.items.edit_detail"
+	ActionOrderDelete          = "order.delete"
+	ActionOrderRestore         = "order.restore"
+	ActionWebhookCreate        = "webhook.create"
+	ActionWebhookList          = "webhook.list"
+	ActionWebhookManage        = "webhook.manage"
+
+	// ActionOrderUpdatePrefix prefix of action updating order field,
+	// followed by the field JSON name, e.g. order.update.buyer_address
+	ActionOrderUpdatePrefix = "order.update."
+
+	// ActionOrderStatusPrefix prefix of action changing order status,
+	// followed by the new status, e.g. order.status.shipped
+	ActionOrderStatusPrefix = "order.status."
+)
+
+// owners of resource, rule with owner only match user owning the resource
+const (
+	// OwnerBuyer user is the buyer of the order
+	OwnerBuyer = "buyer"
+
+	// OwnerSeller user is the seller of one of the order items,
+	// or the seller of the webhook subscription
+	OwnerSeller = "seller"
+
+	// OwnerSoleSeller user is the seller of every order item,
+	// used for action affecting the whole order of multi-seller order
+	OwnerSoleSeller = "sole_seller"
+)
+
+var (
+	// orderUpdateFields order fields (JSON field name) that can be updated,
+	// other fields can't be updated by anyone
+	orderUpdateFields = []string{
+		"buyer_id", "buyer_full_name", "buyer_address", "items", "status",
+	}
+
+	// orderStatuses all order status
+	orderStatuses = []string{
+		model.OrderStatusInCart,
+		model.OrderStatusCheckedOut,
+		model.OrderStatusPaid,
+		model.OrderStatusShipped,
+		model.OrderStatusDelivered,
+		model.OrderStatusDone,
+		model.OrderStatusCancelled,
+		model.OrderStatusRefunded,
+	}
+)
+
+// defaultMatrix default permission matrix, used when there's no policy file
+//
+//go:embed policy.json
+var defaultMatrix []byte
+
+// Rule rule allowing action, user matched the rule if they have one
+// of the roles, all the scopes, and owning the resource if owner is set
+type Rule struct {
+	Roles  []string `json:"roles"`
+	Scopes []string `json:"scopes,omitempty"`
+	Owner  string   `json:"owner,omitempty"`
+}
+
+// Resource owners of resource the action is done to
+type Resource struct {
+	BuyerID   int
+	SellerIDs []int
+}
+
+// Policy permission matrix of actions and the rules allowing them,
+// action without rule not allowed for anyone
+type Policy struct {
+	rules map[string][]Rule
+}
+
+// Default get policy of default permission matrix
+func Default() *Policy {
+	p, err := Parse(defaultMatrix)
+	if err != nil {
+		panic(fmt.Sprintf("default policy invalid => %s", err))
+	}
+
+	return p
+}
+
+// Load load policy from JSON file in path,
+// default permission matrix used if path is empty
+func Load(path string) (*Policy, error) {
+	if path == "" {
+		return Default(), nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("policy file %s invalid => %w", path, err)
+	}
+
+	return p, nil
+}
+
+// Parse parse permission matrix JSON, an object of action and its rules,
+// every invalid action and rule returned at once
+func Parse(b []byte) (*Policy, error) {
+	rules := map[string][]Rule{}
+	err := json.Unmarshal(b, &rules)
+	if err != nil {
+		return nil, err
+	}
+
+	known := map[string]bool{}
+	for _, action := range getActions() {
+		known[action] = true
+	}
+
+	problems := []string{}
+	for action, actionRules := range rules {
+		if !known[action] {
+			problems = append(problems,
+				fmt.Sprintf("action '%s' unknown", action))
+			continue
+		}
+
+		for i, rule := range actionRules {
+			if len(rule.Roles) == 0 {
+				problems = append(problems,
+					fmt.Sprintf("%s rule %d roles empty/not found", action, i))
+			}
+			if rule.Owner != "" && rule.Owner != OwnerBuyer &&
+				rule.Owner != OwnerSeller && rule.Owner != OwnerSoleSeller {
+				problems = append(problems,
+					fmt.Sprintf("%s rule %d owner '%s' unknown", action, i,
+						rule.Owner))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+
+	return &Policy{rules: rules}, nil
+}
+
+// Allow check if user allowed to do action to resource,
+// return ErrForbidden if there's no rule allowing it
+func (p *Policy) Allow(u middleware.User, action string, r Resource) error {
+	for _, rule := range p.getUserRules(u, action) {
+		if isOwner(u, rule.Owner, r) {
+			return nil
+		}
+	}
+
+	return ErrForbidden
+}
+
+// getUserRules get rules of action matching user role and scopes,
+// regardless of the owner
+func (p *Policy) getUserRules(u middleware.User, action string) []Rule {
+	result := []Rule{}
+	for _, rule := range p.rules[action] {
+		if !hasRole(rule.Roles, u.Role) {
+			continue
+		}
+
+		hasScopes := true
+		for _, scope := range rule.Scopes {
+			if !u.HasScope(scope) {
+				hasScopes = false
+				break
+			}
+		}
+		if hasScopes {
+			result = append(result, rule)
+		}
+	}
+
+	return result
+}
+
+// getOwners get owner of rules of action matching user, empty owner
+// means user allowed to do action to any resource
+func (p *Policy) getOwners(u middleware.User, action string) []string {
+	owners := []string{}
+	for _, rule := range p.getUserRules(u, action) {
+		if rule.Owner == "" {
+			return []string{""}
+		}
+		owners = append(owners, rule.Owner)
+	}
+
+	return owners
+}
+
+// OrderResource get resource of order
+func OrderResource(o model.Order) Resource {
+	r := Resource{BuyerID: o.BuyerID}
+	for _, item := range o.Items {
+		r.SellerIDs = append(r.SellerIDs, item.ProductUserID)
+	}
+
+	return r
+}
+
+// WebhookResource get resource of webhook subscription
+func WebhookResource(s webhook.Subscription) Resource {
+	return Resource{SellerIDs: []int{s.SellerID}}
+}
+
+// isOwner check if user is the owner of resource, always true
+// if there's no owner
+func isOwner(u middleware.User, owner string, r Resource) bool {
+	switch owner {
+	case "":
+		return true
+	case OwnerBuyer:
+		return u.ID != 0 && r.BuyerID == u.ID
+	case OwnerSeller:
+		if u.ID == 0 {
+			return false
+		}
+		for _, id := range r.SellerIDs {
+			if id == u.ID {
+				return true
+			}
+		}
+	case OwnerSoleSeller:
+		if u.ID == 0 || len(r.SellerIDs) == 0 {
+			return false
+		}
+		for _, id := range r.SellerIDs {
+			if id != u.ID {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// hasRole check if role is one of the roles
+func hasRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getActions get all actions of permission matrix
+func getActions() []string {
+	actions := []string{
+		ActionOrderCreate, ActionOrderRead, ActionOrderList,
+		ActionOrderReadDeleted, ActionOrderItemsChange,
+		ActionOrderItemsEditDetail, ActionOrderDelete, ActionOrderRestore,
+		ActionWebhookCreate, ActionWebhookList, ActionWebhookManage,
+	}
+	for _, field := range orderUpdateFields {
+		actions = append(actions, ActionOrderUpdatePrefix+field)
+	}
+	for _, status := range orderStatuses {
+		actions = append(actions, ActionOrderStatusPrefix+status)
+	}
+
+	return actions
+}
@@ -0,0 +1,270 @@
+/*
+Package policy containing per role authorization policy of orders
+*/
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/reyhanfikridz/ecom-order-service/internal/middleware"
+	"github.com/reyhanfikridz/ecom-order-service/internal/model"
+	"github.com/reyhanfikridz/ecom-order-service/internal/webhook"
+)
+
+// TestDefaultMatrix test Allow of every action in default permission matrix
+// for buyer, seller, and admin, against order with buyer ID 1
+// and product seller ID 10
+func TestDefaultMatrix(t *testing.T) {
+	buyer := middleware.User{ID: 1, Role: RoleBuyer}
+	otherBuyer := middleware.User{ID: 2, Role: RoleBuyer}
+	seller := middleware.User{ID: 10, Role: RoleSeller}
+	otherSeller := middleware.User{ID: 20, Role: RoleSeller}
+	admin := middleware.User{ID: 100, Role: RoleAdmin}
+
+	// initialize testing table, users in Allowed allowed to do the action,
+	// other users forbidden
+	testTable := []struct {
+		TestName string
+		Action   string
+		Resource Resource
+		Allowed  []middleware.User
+	}{
+		{
+			TestName: "Test Order Create",
+			Action:   ActionOrderCreate,
+			Allowed:  []middleware.User{buyer, otherBuyer},
+		},
+		{
+			TestName: "Test Order Read",
+			Action:   ActionOrderRead,
+			Resource: OrderResource(getTestingOrder()),
+			Allowed:  []middleware.User{buyer, seller, admin},
+		},
+		{
+			TestName: "Test Order Read Deleted",
+			Action:   ActionOrderReadDeleted,
+			Allowed:  []middleware.User{admin},
+		},
+		{
+			TestName: "Test Order Update Buyer ID",
+			Action:   ActionOrderUpdatePrefix + "buyer_id",
+			Resource: OrderResource(getTestingOrder()),
+			Allowed:  []middleware.User{admin},
+		},
+		{
+			TestName: "Test Order Update Buyer Address",
+			Action:   ActionOrderUpdatePrefix + "buyer_address",
+			Resource: OrderResource(getTestingOrder()),
+			Allowed:  []middleware.User{buyer, admin},
+		},
+		{
+			TestName: "Test Order Update Status",
+			Action:   ActionOrderUpdatePrefix + "status",
+			Resource: OrderResource(getTestingOrder()),
+			Allowed:  []middleware.User{buyer, seller, admin},
+		},
+		{
+			TestName: "Test Order Status In Cart",
+			Action:   ActionOrderStatusPrefix + model.OrderStatusInCart,
+			Resource: OrderResource(getTestingOrder()),
+			Allowed:  []middleware.User{admin},
+		},
+		{
+			TestName: "Test Order Status Paid",
+			Action:   ActionOrderStatusPrefix + model.OrderStatusPaid,
+			Resource: OrderResource(getTestingOrder()),
+			Allowed:  []middleware.User{admin},
+		},
+		{
+			TestName: "Test Order Status Shipped",
+			Action:   ActionOrderStatusPrefix + model.OrderStatusShipped,
+			Resource: OrderResource(getTestingOrder()),
+			Allowed:  []middleware.User{seller, admin},
+		},
+		{
+			TestName: "Test Multi Seller Order Status Shipped",
+			Action:   ActionOrderStatusPrefix + model.OrderStatusShipped,
+			Resource: Resource{BuyerID: 1, SellerIDs: []int{10, 20}},
+			Allowed:  []middleware.User{admin},
+		},
+		{
+			TestName: "Test Order Status Cancelled",
+			Action:   ActionOrderStatusPrefix + model.OrderStatusCancelled,
+			Resource: OrderResource(getTestingOrder()),
+			Allowed:  []middleware.User{buyer, seller, admin},
+		},
+		{
+			TestName: "Test Multi Seller Order Status Cancelled",
+			Action:   ActionOrderStatusPrefix + model.OrderStatusCancelled,
+			Resource: Resource{BuyerID: 1, SellerIDs: []int{10, 20}},
+			Allowed:  []middleware.User{buyer, admin},
+		},
+		{
+			TestName: "Test Multi Seller Order Read",
+			Action:   ActionOrderRead,
+			Resource: Resource{BuyerID: 1, SellerIDs: []int{10, 20}},
+			Allowed:  []middleware.User{buyer, seller, otherSeller, admin},
+		},
+		{
+			TestName: "Test Order Items Change",
+			Action:   ActionOrderItemsChange,
+			Resource: OrderResource(getTestingOrder()),
+			Allowed:  []middleware.User{buyer, admin},
+		},
+		{
+			TestName: "Test Order Items Edit Detail",
+			Action:   ActionOrderItemsEditDetail,
+			Resource: OrderResource(getTestingOrder()),
+			Allowed:  []middleware.User{admin},
+		},
+		{
+			TestName: "Test Order Delete",
+			Action:   ActionOrderDelete,
+			Resource: OrderResource(getTestingOrder()),
+			Allowed:  []middleware.User{buyer, admin},
+		},
+		{
+			TestName: "Test Order Restore",
+			Action:   ActionOrderRestore,
+			Resource: OrderResource(getTestingOrder()),
+			Allowed:  []middleware.User{admin},
+		},
+		{
+			TestName: "Test Webhook Manage",
+			Action:   ActionWebhookManage,
+			Resource: WebhookResource(webhook.Subscription{SellerID: 10}),
+			Allowed:  []middleware.User{seller, admin},
+		},
+		{
+			TestName: "Test Unknown Action",
+			Action:   "order.unknown",
+			Resource: OrderResource(getTestingOrder()),
+			Allowed:  []middleware.User{},
+		},
+	}
+
+	// test for each testing table
+	p := Default()
+	users := []middleware.User{buyer, otherBuyer, seller, otherSeller, admin}
+	for _, test := range testTable {
+		for _, u := range users {
+			expectedResult := ErrForbidden
+			for _, allowed := range test.Allowed {
+				if allowed.ID == u.ID {
+					expectedResult = nil
+				}
+			}
+
+			result := p.Allow(u, test.Action, test.Resource)
+			if result != expectedResult {
+				t.Errorf("[%s] Expected result of %s %d %v got %v",
+					test.TestName, u.Role, u.ID, expectedResult, result)
+			}
+		}
+	}
+}
+
+// TestParse test Parse return every invalid action and rule
+func TestParse(t *testing.T) {
+	// initialize testing table
+	testTable := []struct {
+		TestName       string
+		Matrix         string
+		ExpectedErrors []string
+	}{
+		{
+			TestName: "Test Valid",
+			Matrix: `{"order.create": [{"roles": ["buyer", "admin"]}],
+				"order.read": [{"roles": ["seller"], "owner": "seller"}]}`,
+		},
+		{
+			TestName: "Test Invalid",
+			Matrix: `{"order.unknown": [{"roles": ["buyer"]}],
+				"order.read": [{"roles": []}, {"roles": ["buyer"], "owner": "x"}]}`,
+			ExpectedErrors: []string{
+				"action 'order.unknown' unknown",
+				"order.read rule 0 roles empty/not found",
+				"order.read rule 1 owner 'x' unknown",
+			},
+		},
+		{
+			TestName:       "Test Malformed",
+			Matrix:         `{"order.create": {"roles": ["buyer"]}}`,
+			ExpectedErrors: []string{"cannot unmarshal"},
+		},
+	}
+
+	// test for each testing table
+	for _, test := range testTable {
+		_, err := Parse([]byte(test.Matrix))
+		if len(test.ExpectedErrors) == 0 {
+			if err != nil {
+				t.Errorf("[%s] Expected no error, but got %s",
+					test.TestName, err)
+			}
+			continue
+		}
+
+		if err == nil {
+			t.Errorf("[%s] Expected error, but got nil", test.TestName)
+			continue
+		}
+		for _, expectedError := range test.ExpectedErrors {
+			if !strings.Contains(err.Error(), expectedError) {
+				t.Errorf("[%s] Expected error contain '%s', but got '%s'",
+					test.TestName, expectedError, err)
+			}
+		}
+	}
+}
+
+// TestLoad test Load policy from file overriding default permission matrix
+func TestLoad(t *testing.T) {
+	// seller also allowed to restore order in policy file
+	path := filepath.Join(t.TempDir(), "policy.json")
+	err := os.WriteFile(path, []byte(`{
+		"order.restore": [
+			{"roles": ["admin"]},
+			{"roles": ["seller"], "owner": "seller"}
+		]
+	}`), 0600)
+	if err != nil {
+		t.Fatalf("There's an error when writing policy file => %s", err)
+	}
+
+	p, err := Load(path)
+	if err != nil {
+		t.Fatalf("There's an error when loading policy file => %s", err)
+	}
+
+	o := getTestingOrder()
+	if err := p.CanRestoreOrder(middleware.User{ID: 10, Role: RoleSeller},
+		o); err != nil {
+		t.Errorf("Expected seller can restore order, but got %v", err)
+	}
+
+	// action not in policy file not allowed for anyone
+	if err := p.CanAccessOrder(middleware.User{ID: 100, Role: RoleAdmin},
+		o); err != ErrForbidden {
+		t.Errorf("Expected admin can't access order, but got %v", err)
+	}
+
+	// empty path use default permission matrix
+	p, err = Load("")
+	if err != nil {
+		t.Fatalf("There's an error when loading default policy => %s", err)
+	}
+	if err := p.CanRestoreOrder(middleware.User{ID: 10, Role: RoleSeller},
+		o); err != ErrForbidden {
+		t.Errorf("Expected seller can't restore order, but got %v", err)
+	}
+
+	// file not found
+	_, err = Load(filepath.Join(t.TempDir(), "not-found.json"))
+	if err == nil {
+		t.Errorf("Expected error when policy file not found, but got nil")
+	}
+}
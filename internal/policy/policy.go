@@ -0,0 +1,226 @@
+/*
+Package policy containing per role authorization policy of orders
+*/
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/reyhanfikridz/ecom-order-service/internal/middleware"
+	"github.com/reyhanfikridz/ecom-order-service/internal/model"
+	"github.com/reyhanfikridz/ecom-order-service/internal/repository"
+	"github.com/reyhanfikridz/ecom-order-service/internal/webhook"
+)
+
+// user roles
+const (
+	RoleBuyer  = "buyer"
+	RoleSeller = "seller"
+	RoleAdmin  = "admin"
+
+	// RoleService role of internal service authorized by API key,
+	// it can only access what its scopes allow
+	RoleService = middleware.RoleService
+)
+
+// scopes of internal service
+const (
+	// ScopeOrdersRead get any order and its history
+	ScopeOrdersRead = "orders:read"
+
+	// ScopeOrdersStatus change status of any order,
+	// ScopeOrdersRead also needed to get the order being changed
+	ScopeOrdersStatus = "orders:status"
+)
+
+// ErrForbidden returned when user doesn't have authority to access order
+var ErrForbidden = errors.New("user doesn't have authority to access this order")
+
+// IsOrderBuyer check if user is the buyer of the order
+func IsOrderBuyer(u middleware.User, o model.Order) bool {
+	return u.Role == RoleBuyer && isOwner(u, OwnerBuyer, OrderResource(o))
+}
+
+// IsOrderSeller check if user is the seller of one of the order items
+func IsOrderSeller(u middleware.User, o model.Order) bool {
+	return u.Role == RoleSeller && isOwner(u, OwnerSeller, OrderResource(o))
+}
+
+// CanAddOrder check if user can add order
+func (p *Policy) CanAddOrder(u middleware.User) error {
+	return p.Allow(u, ActionOrderCreate, Resource{})
+}
+
+// CanAccessOrder check if user can access order
+func (p *Policy) CanAccessOrder(u middleware.User, o model.Order) error {
+	return p.Allow(u, ActionOrderRead, OrderResource(o))
+}
+
+// CanAccessDeletedOrders check if user can access deleted orders
+func (p *Policy) CanAccessDeletedOrders(u middleware.User) error {
+	return p.Allow(u, ActionOrderReadDeleted, Resource{})
+}
+
+// ScopeOrderFilter restrict orders filter to orders user can access
+//
+// user allowed to list orders they own only get their own orders,
+// e.g. buyer only get their orders and seller only get orders containing
+// their product, filtering by other buyer/seller ID is forbidden
+func (p *Policy) ScopeOrderFilter(u middleware.User,
+	filter repository.OrderFilter) (repository.OrderFilter, error) {
+	if (filter.IncludeDeleted || filter.DeletedOnly ||
+		!filter.DeletedBefore.IsZero()) && p.CanAccessDeletedOrders(u) != nil {
+		return filter, ErrForbidden
+	}
+
+	for _, owner := range p.getOwners(u, ActionOrderList) {
+		switch {
+		case owner == "":
+			return filter, nil
+
+		case owner == OwnerBuyer && u.ID != 0:
+			if filter.BuyerID != 0 && filter.BuyerID != u.ID {
+				return filter, ErrForbidden
+			}
+			filter.BuyerID = u.ID
+
+			return filter, nil
+
+		case owner == OwnerSeller && u.ID != 0:
+			if filter.ProductUserID != 0 && filter.ProductUserID != u.ID {
+				return filter, ErrForbidden
+			}
+			filter.ProductUserID = u.ID
+
+			return filter, nil
+		}
+	}
+
+	return filter, ErrForbidden
+}
+
+// CanUpdateOrder check if user can update order o with oUpdate,
+// every changed field (non zero value different from the order)
+// must be allowed, including the new status
+func (p *Policy) CanUpdateOrder(u middleware.User, o model.Order,
+	oUpdate model.Order) error {
+	fields := []string{}
+	if oUpdate.BuyerID != 0 && oUpdate.BuyerID != o.BuyerID {
+		fields = append(fields, "buyer_id")
+	}
+	if oUpdate.BuyerFullName != "" && oUpdate.BuyerFullName != o.BuyerFullName {
+		fields = append(fields, "buyer_full_name")
+	}
+	if oUpdate.BuyerAddress != "" && oUpdate.BuyerAddress != o.BuyerAddress {
+		fields = append(fields, "buyer_address")
+	}
+	if oUpdate.Items != nil {
+		fields = append(fields, "items")
+	}
+	if oUpdate.Status != "" && oUpdate.Status != o.Status {
+		fields = append(fields, "status")
+	}
+
+	r := OrderResource(o)
+	for _, field := range fields {
+		if p.Allow(u, ActionOrderUpdatePrefix+field, r) != nil {
+			return ErrForbidden
+		}
+	}
+
+	return p.canChangeStatus(u, o, oUpdate)
+}
+
+// CanPatchOrder check if user can patch fields (JSON field name)
+// of order o into oPatch
+//
+// user must be able to access the order and update every patched field,
+// including the new status
+func (p *Policy) CanPatchOrder(u middleware.User, o model.Order,
+	oPatch model.Order, fields []string) error {
+	err := p.CanAccessOrder(u, o)
+	if err != nil {
+		return err
+	}
+
+	// check all fields allowed to be updated
+	r := OrderResource(o)
+	forbiddenFields := []string{}
+	for _, field := range fields {
+		if p.Allow(u, ActionOrderUpdatePrefix+field, r) != nil {
+			forbiddenFields = append(forbiddenFields, field)
+		}
+	}
+	if len(forbiddenFields) > 0 {
+		return fmt.Errorf("%w => fields can't be changed by %s: %s",
+			ErrForbidden, u.Role, strings.Join(forbiddenFields, ", "))
+	}
+
+	return p.canChangeStatus(u, o, oPatch)
+}
+
+// CanChangeOrderItems check if user can add, update, or delete order items
+func (p *Policy) CanChangeOrderItems(u middleware.User, o model.Order) error {
+	return p.Allow(u, ActionOrderItemsChange, OrderResource(o))
+}
+
+// CanEditOrderItemDetail check if user can change product detail
+// of item already in the order, instead of only its qty
+func (p *Policy) CanEditOrderItemDetail(u middleware.User,
+	o model.Order) error {
+	return p.Allow(u, ActionOrderItemsEditDetail, OrderResource(o))
+}
+
+// CanDeleteOrder check if user can delete order
+func (p *Policy) CanDeleteOrder(u middleware.User, o model.Order) error {
+	return p.Allow(u, ActionOrderDelete, OrderResource(o))
+}
+
+// CanRestoreOrder check if user can restore deleted order
+func (p *Policy) CanRestoreOrder(u middleware.User, o model.Order) error {
+	return p.Allow(u, ActionOrderRestore, OrderResource(o))
+}
+
+// CanAddWebhook check if user can subscribe to events of their orders
+func (p *Policy) CanAddWebhook(u middleware.User) error {
+	return p.Allow(u, ActionWebhookCreate,
+		WebhookResource(webhook.Subscription{SellerID: u.ID}))
+}
+
+// ScopeWebhookSellerID restrict seller ID of listed webhook subscriptions
+// to subscriptions user can access, zero seller ID means all subscriptions
+//
+// user allowed to list subscriptions they own only get their own
+func (p *Policy) ScopeWebhookSellerID(u middleware.User,
+	sellerID int) (int, error) {
+	for _, owner := range p.getOwners(u, ActionWebhookList) {
+		switch {
+		case owner == "":
+			return sellerID, nil
+		case owner == OwnerSeller && u.ID != 0:
+			return u.ID, nil
+		}
+	}
+
+	return 0, ErrForbidden
+}
+
+// CanManageWebhook check if user can get, delete, or get deliveries
+// of webhook subscription
+func (p *Policy) CanManageWebhook(u middleware.User,
+	s webhook.Subscription) error {
+	return p.Allow(u, ActionWebhookManage, WebhookResource(s))
+}
+
+// canChangeStatus check if order status not changed
+// or user can change it into the new status
+func (p *Policy) canChangeStatus(u middleware.User, o model.Order,
+	oUpdate model.Order) error {
+	if oUpdate.Status == "" || oUpdate.Status == o.Status {
+		return nil
+	}
+
+	return p.Allow(u, ActionOrderStatusPrefix+oUpdate.Status, OrderResource(o))
+}
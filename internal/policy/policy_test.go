@@ -0,0 +1,607 @@
+/*
+Package policy containing per role authorization policy of orders
+*/
+package policy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/reyhanfikridz/ecom-order-service/internal/middleware"
+	"github.com/reyhanfikridz/ecom-order-service/internal/model"
+	"github.com/reyhanfikridz/ecom-order-service/internal/money"
+	"github.com/reyhanfikridz/ecom-order-service/internal/repository"
+	"github.com/reyhanfikridz/ecom-order-service/internal/webhook"
+)
+
+// getTestingOrder get order with buyer ID 1 and product seller ID 10
+func getTestingOrder() model.Order {
+	return model.Order{
+		OrderNumber: "ORDER1",
+		Status:      model.OrderStatusInCart,
+		BuyerID:     1,
+		Items: []model.OrderItem{
+			{
+				ProductID:     1,
+				ProductName:   "Product 1",
+				ProductPrice:  money.MustParse("1000"),
+				ProductUserID: 10,
+				Qty:           1,
+			},
+		},
+	}
+}
+
+// testing internal services, shipping service can get orders
+// and change their status, reporting service can only get orders
+var (
+	testingShippingService = middleware.User{
+		FullName: "product-service",
+		Role:     RoleService,
+		Service:  "product-service",
+		Scopes:   []string{ScopeOrdersRead, ScopeOrdersStatus},
+	}
+	testingReportingService = middleware.User{
+		FullName: "account-service",
+		Role:     RoleService,
+		Service:  "account-service",
+		Scopes:   []string{ScopeOrdersRead},
+	}
+)
+
+// TestCanAccessOrder test CanAccessOrder
+func TestCanAccessOrder(t *testing.T) {
+	// initialize testing table
+	testTable := []struct {
+		TestName       string
+		User           middleware.User
+		ExpectedResult error
+	}{
+		{
+			TestName:       "Test Buyer Owner",
+			User:           middleware.User{ID: 1, Role: RoleBuyer},
+			ExpectedResult: nil,
+		},
+		{
+			TestName:       "Test Buyer Other",
+			User:           middleware.User{ID: 2, Role: RoleBuyer},
+			ExpectedResult: ErrForbidden,
+		},
+		{
+			TestName:       "Test Seller Owner",
+			User:           middleware.User{ID: 10, Role: RoleSeller},
+			ExpectedResult: nil,
+		},
+		{
+			TestName:       "Test Seller Other",
+			User:           middleware.User{ID: 20, Role: RoleSeller},
+			ExpectedResult: ErrForbidden,
+		},
+		{
+			TestName:       "Test Seller With Buyer ID",
+			User:           middleware.User{ID: 1, Role: RoleSeller},
+			ExpectedResult: ErrForbidden,
+		},
+		{
+			TestName:       "Test Admin",
+			User:           middleware.User{ID: 100, Role: RoleAdmin},
+			ExpectedResult: nil,
+		},
+		{
+			TestName:       "Test Unknown Role",
+			User:           middleware.User{ID: 1, Role: "guest"},
+			ExpectedResult: ErrForbidden,
+		},
+		{
+			TestName:       "Test Service With Read Scope",
+			User:           testingReportingService,
+			ExpectedResult: nil,
+		},
+		{
+			TestName:       "Test Service Without Scope",
+			User:           middleware.User{Role: RoleService, Service: "other"},
+			ExpectedResult: ErrForbidden,
+		},
+		{
+			TestName: "Test User With Scopes",
+			User: middleware.User{ID: 2, Role: RoleBuyer,
+				Scopes: []string{ScopeOrdersRead}},
+			ExpectedResult: ErrForbidden,
+		},
+	}
+
+	// test for each testing table
+	o := getTestingOrder()
+	for _, test := range testTable {
+		result := Default().CanAccessOrder(test.User, o)
+		if result != test.ExpectedResult {
+			t.Errorf("[%s] Expected result %v got %v",
+				test.TestName, test.ExpectedResult, result)
+		}
+	}
+}
+
+// TestScopeOrderFilter test ScopeOrderFilter
+func TestScopeOrderFilter(t *testing.T) {
+	// initialize testing table
+	testTable := []struct {
+		TestName       string
+		User           middleware.User
+		Filter         repository.OrderFilter
+		ExpectedFilter repository.OrderFilter
+		ExpectedError  error
+	}{
+		{
+			TestName:       "Test Buyer Without Filter",
+			User:           middleware.User{ID: 1, Role: RoleBuyer},
+			Filter:         repository.OrderFilter{},
+			ExpectedFilter: repository.OrderFilter{BuyerID: 1},
+			ExpectedError:  nil,
+		},
+		{
+			TestName: "Test Buyer With Status",
+			User:     middleware.User{ID: 1, Role: RoleBuyer},
+			Filter: repository.OrderFilter{
+				Status: model.OrderStatusPaid,
+			},
+			ExpectedFilter: repository.OrderFilter{
+				Status:  model.OrderStatusPaid,
+				BuyerID: 1,
+			},
+			ExpectedError: nil,
+		},
+		{
+			TestName:      "Test Buyer Other Buyer ID",
+			User:          middleware.User{ID: 1, Role: RoleBuyer},
+			Filter:        repository.OrderFilter{BuyerID: 2},
+			ExpectedError: ErrForbidden,
+		},
+		{
+			TestName:       "Test Seller Without Filter",
+			User:           middleware.User{ID: 10, Role: RoleSeller},
+			Filter:         repository.OrderFilter{},
+			ExpectedFilter: repository.OrderFilter{ProductUserID: 10},
+			ExpectedError:  nil,
+		},
+		{
+			TestName:      "Test Seller Other Product User ID",
+			User:          middleware.User{ID: 10, Role: RoleSeller},
+			Filter:        repository.OrderFilter{ProductUserID: 20},
+			ExpectedError: ErrForbidden,
+		},
+		{
+			TestName:       "Test Admin",
+			User:           middleware.User{ID: 100, Role: RoleAdmin},
+			Filter:         repository.OrderFilter{BuyerID: 2},
+			ExpectedFilter: repository.OrderFilter{BuyerID: 2},
+			ExpectedError:  nil,
+		},
+		{
+			TestName:      "Test Buyer Without ID",
+			User:          middleware.User{Role: RoleBuyer},
+			Filter:        repository.OrderFilter{},
+			ExpectedError: ErrForbidden,
+		},
+		{
+			TestName:      "Test Buyer Include Deleted",
+			User:          middleware.User{ID: 1, Role: RoleBuyer},
+			Filter:        repository.OrderFilter{IncludeDeleted: true},
+			ExpectedError: ErrForbidden,
+		},
+		{
+			TestName:      "Test Seller Include Deleted",
+			User:          middleware.User{ID: 10, Role: RoleSeller},
+			Filter:        repository.OrderFilter{IncludeDeleted: true},
+			ExpectedError: ErrForbidden,
+		},
+		{
+			TestName:       "Test Admin Include Deleted",
+			User:           middleware.User{ID: 100, Role: RoleAdmin},
+			Filter:         repository.OrderFilter{IncludeDeleted: true},
+			ExpectedFilter: repository.OrderFilter{IncludeDeleted: true},
+			ExpectedError:  nil,
+		},
+		{
+			TestName:       "Test Service With Read Scope",
+			User:           testingReportingService,
+			Filter:         repository.OrderFilter{ProductUserID: 20},
+			ExpectedFilter: repository.OrderFilter{ProductUserID: 20},
+			ExpectedError:  nil,
+		},
+		{
+			TestName:      "Test Service Include Deleted",
+			User:          testingReportingService,
+			Filter:        repository.OrderFilter{IncludeDeleted: true},
+			ExpectedError: ErrForbidden,
+		},
+		{
+			TestName: "Test Service Without Read Scope",
+			User: middleware.User{Role: RoleService, Service: "other",
+				Scopes: []string{ScopeOrdersStatus}},
+			Filter:        repository.OrderFilter{},
+			ExpectedError: ErrForbidden,
+		},
+	}
+
+	// test for each testing table
+	for _, test := range testTable {
+		filter, err := Default().ScopeOrderFilter(test.User, test.Filter)
+		if err != test.ExpectedError {
+			t.Errorf("[%s] Expected error %v got %v",
+				test.TestName, test.ExpectedError, err)
+		}
+
+		if err == nil && filter != test.ExpectedFilter {
+			t.Errorf("[%s] Expected filter %+v got %+v",
+				test.TestName, test.ExpectedFilter, filter)
+		}
+	}
+}
+
+// TestCanUpdateOrder test CanUpdateOrder
+func TestCanUpdateOrder(t *testing.T) {
+	// initialize testing table
+	testTable := []struct {
+		TestName       string
+		User           middleware.User
+		OrderUpdate    model.Order
+		ExpectedResult error
+	}{
+		{
+			TestName:       "Test Buyer Status",
+			User:           middleware.User{ID: 1, Role: RoleBuyer},
+			OrderUpdate:    model.Order{Status: model.OrderStatusCheckedOut},
+			ExpectedResult: nil,
+		},
+		{
+			TestName:       "Test Buyer Forbidden Status",
+			User:           middleware.User{ID: 1, Role: RoleBuyer},
+			OrderUpdate:    model.Order{Status: model.OrderStatusShipped},
+			ExpectedResult: ErrForbidden,
+		},
+		{
+			TestName:       "Test Buyer Address",
+			User:           middleware.User{ID: 1, Role: RoleBuyer},
+			OrderUpdate:    model.Order{BuyerAddress: "New Address"},
+			ExpectedResult: nil,
+		},
+		{
+			TestName:       "Test Buyer Change Buyer ID",
+			User:           middleware.User{ID: 1, Role: RoleBuyer},
+			OrderUpdate:    model.Order{BuyerID: 2},
+			ExpectedResult: ErrForbidden,
+		},
+		{
+			TestName:       "Test Buyer Other",
+			User:           middleware.User{ID: 2, Role: RoleBuyer},
+			OrderUpdate:    model.Order{Status: model.OrderStatusCancelled},
+			ExpectedResult: ErrForbidden,
+		},
+		{
+			TestName:       "Test Seller Status",
+			User:           middleware.User{ID: 10, Role: RoleSeller},
+			OrderUpdate:    model.Order{Status: model.OrderStatusShipped},
+			ExpectedResult: nil,
+		},
+		{
+			TestName:       "Test Seller Forbidden Status",
+			User:           middleware.User{ID: 10, Role: RoleSeller},
+			OrderUpdate:    model.Order{Status: model.OrderStatusPaid},
+			ExpectedResult: ErrForbidden,
+		},
+		{
+			TestName:       "Test Buyer Paid",
+			User:           middleware.User{ID: 1, Role: RoleBuyer},
+			OrderUpdate:    model.Order{Status: model.OrderStatusPaid},
+			ExpectedResult: ErrForbidden,
+		},
+		{
+			TestName:       "Test Service Paid",
+			User:           testingShippingService,
+			OrderUpdate:    model.Order{Status: model.OrderStatusPaid},
+			ExpectedResult: nil,
+		},
+		{
+			TestName:       "Test Seller Buyer Field",
+			User:           middleware.User{ID: 10, Role: RoleSeller},
+			OrderUpdate:    model.Order{BuyerFullName: "Seller"},
+			ExpectedResult: ErrForbidden,
+		},
+		{
+			TestName:       "Test Seller Items",
+			User:           middleware.User{ID: 10, Role: RoleSeller},
+			OrderUpdate:    model.Order{Items: []model.OrderItem{}},
+			ExpectedResult: ErrForbidden,
+		},
+		{
+			TestName:       "Test Seller Other",
+			User:           middleware.User{ID: 20, Role: RoleSeller},
+			OrderUpdate:    model.Order{Status: model.OrderStatusShipped},
+			ExpectedResult: ErrForbidden,
+		},
+		{
+			TestName: "Test Admin",
+			User:     middleware.User{ID: 100, Role: RoleAdmin},
+			OrderUpdate: model.Order{
+				Status:  model.OrderStatusRefunded,
+				BuyerID: 3,
+			},
+			ExpectedResult: nil,
+		},
+		{
+			TestName:       "Test Service Status",
+			User:           testingShippingService,
+			OrderUpdate:    model.Order{Status: model.OrderStatusShipped},
+			ExpectedResult: nil,
+		},
+		{
+			TestName:       "Test Service Buyer Field",
+			User:           testingShippingService,
+			OrderUpdate:    model.Order{BuyerAddress: "New Address"},
+			ExpectedResult: ErrForbidden,
+		},
+		{
+			TestName:       "Test Service Without Status Scope",
+			User:           testingReportingService,
+			OrderUpdate:    model.Order{Status: model.OrderStatusShipped},
+			ExpectedResult: ErrForbidden,
+		},
+	}
+
+	// test for each testing table
+	o := getTestingOrder()
+	for _, test := range testTable {
+		result := Default().CanUpdateOrder(test.User, o, test.OrderUpdate)
+		if result != test.ExpectedResult {
+			t.Errorf("[%s] Expected result %v got %v",
+				test.TestName, test.ExpectedResult, result)
+		}
+	}
+}
+
+// TestCanPatchOrder test CanPatchOrder
+func TestCanPatchOrder(t *testing.T) {
+	// initialize testing table
+	testTable := []struct {
+		TestName       string
+		User           middleware.User
+		OrderPatch     model.Order
+		Fields         []string
+		ExpectedResult error
+	}{
+		{
+			TestName:       "Test Buyer Clear Address",
+			User:           middleware.User{ID: 1, Role: RoleBuyer},
+			Fields:         []string{"buyer_address"},
+			ExpectedResult: nil,
+		},
+		{
+			TestName:       "Test Buyer Status",
+			User:           middleware.User{ID: 1, Role: RoleBuyer},
+			OrderPatch:     model.Order{Status: model.OrderStatusCheckedOut},
+			Fields:         []string{"status"},
+			ExpectedResult: nil,
+		},
+		{
+			TestName:       "Test Buyer Forbidden Status",
+			User:           middleware.User{ID: 1, Role: RoleBuyer},
+			OrderPatch:     model.Order{Status: model.OrderStatusShipped},
+			Fields:         []string{"status"},
+			ExpectedResult: ErrForbidden,
+		},
+		{
+			TestName:       "Test Buyer Buyer ID",
+			User:           middleware.User{ID: 1, Role: RoleBuyer},
+			OrderPatch:     model.Order{BuyerID: 2},
+			Fields:         []string{"buyer_id"},
+			ExpectedResult: ErrForbidden,
+		},
+		{
+			TestName:       "Test Buyer Other",
+			User:           middleware.User{ID: 2, Role: RoleBuyer},
+			Fields:         []string{"buyer_address"},
+			ExpectedResult: ErrForbidden,
+		},
+		{
+			TestName:       "Test Seller Status",
+			User:           middleware.User{ID: 10, Role: RoleSeller},
+			OrderPatch:     model.Order{Status: model.OrderStatusShipped},
+			Fields:         []string{"status"},
+			ExpectedResult: nil,
+		},
+		{
+			TestName:       "Test Seller Items",
+			User:           middleware.User{ID: 10, Role: RoleSeller},
+			Fields:         []string{"items"},
+			ExpectedResult: ErrForbidden,
+		},
+		{
+			TestName:       "Test Admin Buyer ID",
+			User:           middleware.User{ID: 100, Role: RoleAdmin},
+			OrderPatch:     model.Order{BuyerID: 3},
+			Fields:         []string{"buyer_id", "items"},
+			ExpectedResult: nil,
+		},
+		{
+			TestName:       "Test Admin Read Only Field",
+			User:           middleware.User{ID: 100, Role: RoleAdmin},
+			Fields:         []string{"total_price"},
+			ExpectedResult: ErrForbidden,
+		},
+		{
+			TestName:       "Test Service Status",
+			User:           testingShippingService,
+			OrderPatch:     model.Order{Status: model.OrderStatusShipped},
+			Fields:         []string{"status"},
+			ExpectedResult: nil,
+		},
+		{
+			TestName:       "Test Service Buyer ID",
+			User:           testingShippingService,
+			OrderPatch:     model.Order{BuyerID: 3},
+			Fields:         []string{"buyer_id"},
+			ExpectedResult: ErrForbidden,
+		},
+		{
+			TestName:       "Test Service Without Status Scope",
+			User:           testingReportingService,
+			OrderPatch:     model.Order{Status: model.OrderStatusShipped},
+			Fields:         []string{"status"},
+			ExpectedResult: ErrForbidden,
+		},
+	}
+
+	// test for each testing table
+	o := getTestingOrder()
+	for _, test := range testTable {
+		oPatch := o
+		if test.OrderPatch.Status != "" {
+			oPatch.Status = test.OrderPatch.Status
+		}
+		if test.OrderPatch.BuyerID != 0 {
+			oPatch.BuyerID = test.OrderPatch.BuyerID
+		}
+
+		result := Default().CanPatchOrder(test.User, o, oPatch, test.Fields)
+		if !errors.Is(result, test.ExpectedResult) {
+			t.Errorf("[%s] Expected result %v got %v",
+				test.TestName, test.ExpectedResult, result)
+		}
+	}
+}
+
+// TestCanChangeOrderItemsAndDeleteOrder test CanChangeOrderItems
+// and CanDeleteOrder
+func TestCanChangeOrderItemsAndDeleteOrder(t *testing.T) {
+	// initialize testing table
+	testTable := []struct {
+		TestName       string
+		User           middleware.User
+		ExpectedResult error
+	}{
+		{
+			TestName:       "Test Buyer Owner",
+			User:           middleware.User{ID: 1, Role: RoleBuyer},
+			ExpectedResult: nil,
+		},
+		{
+			TestName:       "Test Buyer Other",
+			User:           middleware.User{ID: 2, Role: RoleBuyer},
+			ExpectedResult: ErrForbidden,
+		},
+		{
+			TestName:       "Test Seller Owner",
+			User:           middleware.User{ID: 10, Role: RoleSeller},
+			ExpectedResult: ErrForbidden,
+		},
+		{
+			TestName:       "Test Admin",
+			User:           middleware.User{ID: 100, Role: RoleAdmin},
+			ExpectedResult: nil,
+		},
+	}
+
+	// test for each testing table
+	o := getTestingOrder()
+	for _, test := range testTable {
+		result := Default().CanChangeOrderItems(test.User, o)
+		if result != test.ExpectedResult {
+			t.Errorf("[%s] Expected CanChangeOrderItems result %v got %v",
+				test.TestName, test.ExpectedResult, result)
+		}
+
+		result = Default().CanDeleteOrder(test.User, o)
+		if result != test.ExpectedResult {
+			t.Errorf("[%s] Expected CanDeleteOrder result %v got %v",
+				test.TestName, test.ExpectedResult, result)
+		}
+	}
+}
+
+// TestCanRestoreOrder test CanRestoreOrder
+func TestCanRestoreOrder(t *testing.T) {
+	// initialize testing table
+	testTable := []struct {
+		TestName       string
+		User           middleware.User
+		ExpectedResult error
+	}{
+		{
+			TestName:       "Test Buyer Owner",
+			User:           middleware.User{ID: 1, Role: RoleBuyer},
+			ExpectedResult: ErrForbidden,
+		},
+		{
+			TestName:       "Test Seller Owner",
+			User:           middleware.User{ID: 10, Role: RoleSeller},
+			ExpectedResult: ErrForbidden,
+		},
+		{
+			TestName:       "Test Admin",
+			User:           middleware.User{ID: 100, Role: RoleAdmin},
+			ExpectedResult: nil,
+		},
+	}
+
+	// test for each testing table
+	o := getTestingOrder()
+	for _, test := range testTable {
+		result := Default().CanRestoreOrder(test.User, o)
+		if result != test.ExpectedResult {
+			t.Errorf("[%s] Expected result %v got %v",
+				test.TestName, test.ExpectedResult, result)
+		}
+	}
+}
+
+// TestCanManageWebhook test CanAddWebhook and CanManageWebhook
+func TestCanManageWebhook(t *testing.T) {
+	// initialize testing table
+	testTable := []struct {
+		TestName          string
+		User              middleware.User
+		ExpectedAddResult error
+		ExpectedResult    error
+	}{
+		{
+			TestName:          "Test Seller Owner",
+			User:              middleware.User{ID: 10, Role: RoleSeller},
+			ExpectedAddResult: nil,
+			ExpectedResult:    nil,
+		},
+		{
+			TestName:          "Test Seller Other",
+			User:              middleware.User{ID: 20, Role: RoleSeller},
+			ExpectedAddResult: nil,
+			ExpectedResult:    ErrForbidden,
+		},
+		{
+			TestName:          "Test Buyer",
+			User:              middleware.User{ID: 10, Role: RoleBuyer},
+			ExpectedAddResult: ErrForbidden,
+			ExpectedResult:    ErrForbidden,
+		},
+		{
+			TestName:          "Test Admin",
+			User:              middleware.User{ID: 100, Role: RoleAdmin},
+			ExpectedAddResult: ErrForbidden,
+			ExpectedResult:    nil,
+		},
+	}
+
+	// test for each testing table
+	s := webhook.Subscription{SellerID: 10}
+	for _, test := range testTable {
+		result := Default().CanAddWebhook(test.User)
+		if result != test.ExpectedAddResult {
+			t.Errorf("[%s] Expected CanAddWebhook result %v got %v",
+				test.TestName, test.ExpectedAddResult, result)
+		}
+
+		result = Default().CanManageWebhook(test.User, s)
+		if result != test.ExpectedResult {
+			t.Errorf("[%s] Expected CanManageWebhook result %v got %v",
+				test.TestName, test.ExpectedResult, result)
+		}
+	}
+}
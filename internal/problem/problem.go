@@ -0,0 +1,221 @@
+/*
+Package problem containing API error model based on
+problem details for HTTP APIs (RFC 7807)
+*/
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// MIMEProblemJSON media type of problem details response
+const MIMEProblemJSON = "application/problem+json"
+
+// StatusClientClosedRequest non-standard status of request cancelled
+// by client before it's responded, the response only seen in logs
+const StatusClientClosedRequest = 499
+
+// stable error codes of problem, client can rely on these
+// instead of the detail message
+const (
+	CodeBadRequest           = "bad_request"
+	CodeValidationFailed     = "validation_failed"
+	CodeUnauthorized         = "unauthorized"
+	CodeForbidden            = "forbidden"
+	CodeNotFound             = "not_found"
+	CodeMethodNotAllowed     = "method_not_allowed"
+	CodeConflict             = "conflict"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeUnprocessableEntity  = "unprocessable_entity"
+	CodeTooManyRequests      = "too_many_requests"
+	CodeInternal             = "internal_error"
+	CodeServiceUnavailable   = "service_unavailable"
+	CodeClientClosedRequest  = "client_closed_request"
+)
+
+// statusCodes default error code of HTTP status
+var statusCodes = map[int]string{
+	http.StatusBadRequest:           CodeBadRequest,
+	http.StatusUnauthorized:         CodeUnauthorized,
+	http.StatusForbidden:            CodeForbidden,
+	http.StatusNotFound:             CodeNotFound,
+	http.StatusMethodNotAllowed:     CodeMethodNotAllowed,
+	http.StatusConflict:             CodeConflict,
+	http.StatusUnsupportedMediaType: CodeUnsupportedMediaType,
+	http.StatusUnprocessableEntity:  CodeUnprocessableEntity,
+	http.StatusTooManyRequests:      CodeTooManyRequests,
+	http.StatusInternalServerError:  CodeInternal,
+	http.StatusServiceUnavailable:   CodeServiceUnavailable,
+	StatusClientClosedRequest:       CodeClientClosedRequest,
+}
+
+// internalDetail detail of internal error shown to client,
+// the error itself only logged
+const internalDetail = "There's an internal error, " +
+	"retry later or report it with the request ID"
+
+// FieldError error of one request field
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+}
+
+// Problem API error response,
+// extensions marshalled as additional members of the problem
+type Problem struct {
+	Type       string                 `json:"type"`
+	Title      string                 `json:"title"`
+	Status     int                    `json:"status"`
+	Detail     string                 `json:"detail,omitempty"`
+	Instance   string                 `json:"instance,omitempty"`
+	Code       string                 `json:"code"`
+	RequestID  string                 `json:"request_id,omitempty"`
+	Errors     []FieldError           `json:"errors,omitempty"`
+	Extensions map[string]interface{} `json:"-"`
+
+	// err internal cause of the problem, logged but not exposed
+	err error
+}
+
+// New create problem with HTTP status, error code, and detail message
+func New(status int, code string, detail string) *Problem {
+	title := http.StatusText(status)
+	if status == StatusClientClosedRequest {
+		title = "Client Closed Request"
+	}
+
+	return &Problem{
+		Type:   "/problems/" + code,
+		Title:  title,
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// Newf create problem with HTTP status, error code,
+// and detail message formatted by fmt.Sprintf
+func Newf(status int, code string, format string, a ...interface{}) *Problem {
+	return New(status, code, fmt.Sprintf(format, a...))
+}
+
+// Internal create internal error problem, err only logged
+// and not exposed to client
+func Internal(err error) *Problem {
+	p := New(http.StatusInternalServerError, CodeInternal, internalDetail)
+	p.err = err
+
+	return p
+}
+
+// From get problem of error, error which is not problem or echo HTTP error
+// treated as internal error
+func From(err error) *Problem {
+	var p *Problem
+	if errors.As(err, &p) {
+		result := *p
+		return &result
+	}
+
+	var he *echo.HTTPError
+	if errors.As(err, &he) && he.Code < http.StatusInternalServerError {
+		code, ok := statusCodes[he.Code]
+		if !ok {
+			code = CodeBadRequest
+		}
+		return New(he.Code, code, fmt.Sprint(he.Message))
+	}
+
+	return Internal(err)
+}
+
+// WithErrors add field errors to problem
+func (p *Problem) WithErrors(errs ...FieldError) *Problem {
+	p.Errors = append(p.Errors, errs...)
+	return p
+}
+
+// With add extension member to problem
+func (p *Problem) With(key string, value interface{}) *Problem {
+	if p.Extensions == nil {
+		p.Extensions = map[string]interface{}{}
+	}
+	p.Extensions[key] = value
+
+	return p
+}
+
+// WithCause set internal cause of problem, only logged
+// and not exposed to client
+func (p *Problem) WithCause(err error) *Problem {
+	p.err = err
+	return p
+}
+
+// Error get problem message, including internal cause
+func (p *Problem) Error() string {
+	if p.err != nil {
+		return fmt.Sprintf("%s => %s", p.Code, p.err)
+	}
+
+	return fmt.Sprintf("%s => %s", p.Code, p.Detail)
+}
+
+// Unwrap get internal cause of the problem
+func (p *Problem) Unwrap() error {
+	return p.err
+}
+
+// MarshalJSON marshal problem with its extension members
+func (p Problem) MarshalJSON() ([]byte, error) {
+	type problem Problem
+	b, err := json.Marshal(problem(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return b, err
+	}
+
+	members := map[string]interface{}{}
+	for key, value := range p.Extensions {
+		members[key] = value
+	}
+	err = json.Unmarshal(b, &members)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(members)
+}
+
+// HTTPErrorHandler echo HTTP error handler responding error as problem
+// with request ID, internal error logged with the request ID
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	p := From(err)
+	p.Instance = c.Request().URL.Path
+	p.RequestID = c.Response().Header().Get(echo.HeaderXRequestID)
+	if p.Status >= http.StatusInternalServerError {
+		log.Printf("[%s] %s %s => %s", p.RequestID, c.Request().Method,
+			c.Request().URL.Path, err)
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, MIMEProblemJSON)
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(p.Status)
+	} else {
+		err = c.JSON(p.Status, p)
+	}
+	if err != nil {
+		log.Printf("[%s] There's an error when sending error response => %s",
+			p.RequestID, err)
+	}
+}
@@ -0,0 +1,154 @@
+/*
+Package problem containing API error model based on
+problem details for HTTP APIs (RFC 7807)
+*/
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+// TestHTTPErrorHandler test HTTPErrorHandler
+func TestHTTPErrorHandler(t *testing.T) {
+	// initialize testing table
+	testTable := []struct {
+		TestName       string
+		Err            error
+		ExpectedStatus int
+		ExpectedCode   string
+		ExpectedDetail string
+		ExpectedFields map[string]interface{}
+	}{
+		{
+			TestName: "Test Problem",
+			Err: New(http.StatusBadRequest, CodeValidationFailed,
+				"Order data not completed/invalid => qty empty/not found").
+				WithErrors(FieldError{Field: "qty", Message: "empty/not found"}),
+			ExpectedStatus: http.StatusBadRequest,
+			ExpectedCode:   CodeValidationFailed,
+			ExpectedDetail: "Order data not completed/invalid => qty empty/not found",
+			ExpectedFields: map[string]interface{}{
+				"errors": []interface{}{
+					map[string]interface{}{"field": "qty", "message": "empty/not found"},
+				},
+			},
+		},
+		{
+			TestName: "Test Problem Extensions",
+			Err: Newf(http.StatusConflict, "order_version_conflict",
+				"Order has been changed since version %d", 1).
+				With("current_version", 2).
+				With("status", "ignored"),
+			ExpectedStatus: http.StatusConflict,
+			ExpectedCode:   "order_version_conflict",
+			ExpectedDetail: "Order has been changed since version 1",
+			ExpectedFields: map[string]interface{}{
+				"current_version": float64(2),
+				"status":          float64(http.StatusConflict),
+			},
+		},
+		{
+			TestName: "Test Wrapped Problem",
+			Err: fmt.Errorf("getting order => %w",
+				New(http.StatusNotFound, CodeNotFound, "Order not found")),
+			ExpectedStatus: http.StatusNotFound,
+			ExpectedCode:   CodeNotFound,
+			ExpectedDetail: "Order not found",
+		},
+		{
+			TestName:       "Test Echo HTTP Error",
+			Err:            echo.ErrMethodNotAllowed,
+			ExpectedStatus: http.StatusMethodNotAllowed,
+			ExpectedCode:   CodeMethodNotAllowed,
+			ExpectedDetail: "Method Not Allowed",
+		},
+		{
+			TestName:       "Test Internal Error",
+			Err:            Internal(errors.New("mongo: connection refused")),
+			ExpectedStatus: http.StatusInternalServerError,
+			ExpectedCode:   CodeInternal,
+			ExpectedDetail: internalDetail,
+		},
+		{
+			TestName: "Test Problem With Cause",
+			Err: New(http.StatusServiceUnavailable, CodeServiceUnavailable,
+				"Token authorization unavailable, retry later").
+				WithCause(errors.New("mongo: connection refused")),
+			ExpectedStatus: http.StatusServiceUnavailable,
+			ExpectedCode:   CodeServiceUnavailable,
+			ExpectedDetail: "Token authorization unavailable, retry later",
+		},
+		{
+			TestName:       "Test Unknown Error",
+			Err:            errors.New("mongo: connection refused"),
+			ExpectedStatus: http.StatusInternalServerError,
+			ExpectedCode:   CodeInternal,
+			ExpectedDetail: internalDetail,
+		},
+	}
+
+	// test for each testing table
+	e := echo.New()
+	for _, test := range testTable {
+		req := httptest.NewRequest("GET", "/api/v1/orders/1", nil)
+		response := httptest.NewRecorder()
+		c := e.NewContext(req, response)
+		c.Response().Header().Set(echo.HeaderXRequestID, "request-1")
+		HTTPErrorHandler(test.Err, c)
+
+		if response.Code != test.ExpectedStatus {
+			t.Errorf("[%s] Expected status %d got %d", test.TestName,
+				test.ExpectedStatus, response.Code)
+		}
+		if response.Header().Get(echo.HeaderContentType) != MIMEProblemJSON {
+			t.Errorf("[%s] Expected Content-Type %s got %s", test.TestName,
+				MIMEProblemJSON, response.Header().Get(echo.HeaderContentType))
+		}
+		if strings.Contains(response.Body.String(), "mongo") {
+			t.Errorf("[%s] Expected internal error not exposed, but got %s",
+				test.TestName, response.Body.String())
+		}
+
+		var body map[string]interface{}
+		err := json.Unmarshal(response.Body.Bytes(), &body)
+		if err != nil {
+			t.Errorf("[%s] There's an error when unmarshal body response => %s",
+				test.TestName, err)
+			continue
+		}
+
+		expected := map[string]interface{}{
+			"status":     float64(test.ExpectedStatus),
+			"code":       test.ExpectedCode,
+			"detail":     test.ExpectedDetail,
+			"title":      http.StatusText(test.ExpectedStatus),
+			"type":       "/problems/" + test.ExpectedCode,
+			"instance":   "/api/v1/orders/1",
+			"request_id": "request-1",
+		}
+		for key, value := range test.ExpectedFields {
+			expected[key] = value
+		}
+		for key, value := range expected {
+			if !jsonEqual(body[key], value) {
+				t.Errorf("[%s] Expected %s %v, but got %v", test.TestName,
+					key, value, body[key])
+			}
+		}
+	}
+}
+
+// jsonEqual check if two decoded JSON value are the same
+func jsonEqual(a interface{}, b interface{}) bool {
+	aJSON, _ := json.Marshal(a)
+	bJSON, _ := json.Marshal(b)
+	return string(aJSON) == string(bJSON)
+}
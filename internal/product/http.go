@@ -0,0 +1,124 @@
+/*
+Package product containing client of product service
+used for verifying ordered products
+*/
+package product
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// HTTPService product service accessed by its HTTP API
+type HTTPService struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+// NewHTTPService create product service client with base URL of product service
+func NewHTTPService(baseURL string) *HTTPService {
+	return &HTTPService{
+		BaseURL: baseURL,
+		Client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// GetProduct get product by ID from product service API
+// (Method: GET, Path: /api/product/?id=)
+func (s *HTTPService) GetProduct(ctx context.Context, token string,
+	id int) (Product, error) {
+	p := Product{}
+
+	// create request with user token
+	params := url.Values{}
+	params.Add("id", strconv.Itoa(id))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		s.BaseURL+"/api/product/?"+params.Encode(), nil)
+	if err != nil {
+		return p, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	// get product from product service
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return p, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return p, ErrProductNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return p, fmt.Errorf("product service response status %d",
+			resp.StatusCode)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&p)
+	if err != nil {
+		return p, err
+	}
+
+	return p, nil
+}
+
+// ReserveStock reserve product stock using product service API
+// (Method: POST, Path: /api/product/stock/reserve/)
+func (s *HTTPService) ReserveStock(ctx context.Context, token string,
+	id int, qty int) error {
+	return s.changeStock(ctx, token, "/api/product/stock/reserve/", id, qty)
+}
+
+// ReleaseStock release product stock using product service API
+// (Method: POST, Path: /api/product/stock/release/)
+func (s *HTTPService) ReleaseStock(ctx context.Context, token string,
+	id int, qty int) error {
+	return s.changeStock(ctx, token, "/api/product/stock/release/", id, qty)
+}
+
+// changeStock send reserve/release product stock request to product service
+func (s *HTTPService) changeStock(ctx context.Context, token string,
+	path string, id int, qty int) error {
+	// create request with user token
+	body, err := json.Marshal(StockRequest{ID: id, Qty: qty})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		s.BaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	// change product stock in product service
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return ErrProductNotFound
+	case http.StatusConflict:
+		return ErrStockInsufficient
+	}
+
+	return fmt.Errorf("product service response status %d", resp.StatusCode)
+}
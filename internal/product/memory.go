@@ -0,0 +1,86 @@
+/*
+Package product containing client of product service
+used for verifying ordered products
+*/
+package product
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryService product service stored in memory,
+// mostly used for testing
+type MemoryService struct {
+	mu       sync.RWMutex
+	products map[int]Product
+}
+
+// NewMemoryService create in-memory product service with products
+func NewMemoryService(products ...Product) *MemoryService {
+	s := &MemoryService{products: make(map[int]Product)}
+	for _, p := range products {
+		s.products[p.ID] = p
+	}
+
+	return s
+}
+
+// SetProduct insert or replace product in memory
+func (s *MemoryService) SetProduct(p Product) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.products[p.ID] = p
+}
+
+// GetProduct get product by ID from memory
+func (s *MemoryService) GetProduct(ctx context.Context, token string,
+	id int) (Product, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p, ok := s.products[id]
+	if !ok {
+		return Product{}, ErrProductNotFound
+	}
+
+	return p, nil
+}
+
+// ReserveStock decrease product stock in memory
+func (s *MemoryService) ReserveStock(ctx context.Context, token string,
+	id int, qty int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.products[id]
+	if !ok {
+		return ErrProductNotFound
+	}
+	if p.Stock < qty {
+		return ErrStockInsufficient
+	}
+
+	p.Stock -= qty
+	s.products[id] = p
+
+	return nil
+}
+
+// ReleaseStock increase product stock in memory
+func (s *MemoryService) ReleaseStock(ctx context.Context, token string,
+	id int, qty int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.products[id]
+	if !ok {
+		return ErrProductNotFound
+	}
+
+	p.Stock += qty
+	s.products[id] = p
+
+	return nil
+}
@@ -0,0 +1,133 @@
+/*
+Package product containing client of product service
+used for verifying ordered products
+*/
+package product
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/reyhanfikridz/ecom-order-service/internal/model"
+	"github.com/reyhanfikridz/ecom-order-service/internal/money"
+)
+
+var (
+	// ErrProductNotFound returned when product not exist in product service
+	ErrProductNotFound = errors.New("product not found")
+
+	// ErrProductMismatch returned when client-supplied order item detail
+	// not match the product data in product service
+	ErrProductMismatch = errors.New("product detail not match")
+
+	// ErrStockInsufficient returned when product stock
+	// less than qty need to be reserved
+	ErrStockInsufficient = errors.New("product stock insufficient")
+)
+
+// Product product data from product service,
+// price is in the currency of order service
+type Product struct {
+	ID           int          `json:"id"`
+	SKU          string       `json:"sku"`
+	Name         string       `json:"name"`
+	Price        money.Amount `json:"price"`
+	Weight       float32      `json:"weight"`
+	Description  string       `json:"description"`
+	Stock        int          `json:"stock"`
+	UserID       int          `json:"user_id"`
+	UserFullName string       `json:"user_full_name"`
+	ImagesPath   []string     `json:"images_path"`
+}
+
+// Service source of product data
+type Service interface {
+	// GetProduct get product by ID using user token,
+	// return ErrProductNotFound if there's none
+	GetProduct(ctx context.Context, token string, id int) (Product, error)
+
+	// ReserveStock decrease product stock by qty using user token,
+	// return ErrStockInsufficient if stock less than qty
+	ReserveStock(ctx context.Context, token string, id int, qty int) error
+
+	// ReleaseStock increase back product stock reserved before
+	// by qty using user token
+	ReleaseStock(ctx context.Context, token string, id int, qty int) error
+}
+
+// StockRequest request body of reserving/releasing product stock
+type StockRequest struct {
+	ID  int `json:"id"`
+	Qty int `json:"qty"`
+}
+
+// GetOrderItemProduct get product data snapshotted in order item
+func GetOrderItemProduct(item model.OrderItem) Product {
+	return Product{
+		ID:           item.ProductID,
+		SKU:          item.ProductSKU,
+		Name:         item.ProductName,
+		Price:        item.ProductPrice,
+		Weight:       item.ProductWeight,
+		Description:  item.ProductDescription,
+		Stock:        item.ProductStock,
+		UserID:       item.ProductUserID,
+		UserFullName: item.ProductUserFullName,
+		ImagesPath:   item.ProductImagesPath,
+	}
+}
+
+// SnapshotOrderItem fill order item product detail with product data
+// and calculate its subtotal
+//
+// product detail supplied by client is optional, but if supplied
+// it must be the same as product data, otherwise ErrProductMismatch returned
+func SnapshotOrderItem(item model.OrderItem, p Product) (model.OrderItem, error) {
+	// check client-supplied product detail
+	if item.ProductID != p.ID {
+		return item, fmt.Errorf("%w => product_id %d, expected %d",
+			ErrProductMismatch, item.ProductID, p.ID)
+	}
+	if item.ProductSKU != "" && item.ProductSKU != p.SKU {
+		return item, fmt.Errorf("%w => product_sku '%s', expected '%s'",
+			ErrProductMismatch, item.ProductSKU, p.SKU)
+	}
+	if item.ProductName != "" && item.ProductName != p.Name {
+		return item, fmt.Errorf("%w => product_name '%s', expected '%s'",
+			ErrProductMismatch, item.ProductName, p.Name)
+	}
+	if item.ProductPrice != 0 && item.ProductPrice != p.Price {
+		return item, fmt.Errorf("%w => product_price %v, expected %v",
+			ErrProductMismatch, item.ProductPrice, p.Price)
+	}
+	if item.ProductWeight != 0 && item.ProductWeight != p.Weight {
+		return item, fmt.Errorf("%w => product_weight %v, expected %v",
+			ErrProductMismatch, item.ProductWeight, p.Weight)
+	}
+	if item.ProductUserID != 0 && item.ProductUserID != p.UserID {
+		return item, fmt.Errorf("%w => product_user_id %d, expected %d",
+			ErrProductMismatch, item.ProductUserID, p.UserID)
+	}
+
+	// check client-supplied subtotal
+	subtotal := p.Price.Mul(item.Qty)
+	if item.Subtotal != 0 && item.Subtotal != subtotal {
+		return item, fmt.Errorf("%w => subtotal %v, expected %v",
+			ErrProductMismatch, item.Subtotal, subtotal)
+	}
+
+	// snapshot product data
+	item.ProductSKU = p.SKU
+	item.ProductName = p.Name
+	item.ProductPrice = p.Price
+	item.ProductWeight = p.Weight
+	item.ProductDescription = p.Description
+	item.ProductStock = p.Stock
+	item.ProductUserID = p.UserID
+	item.ProductUserFullName = p.UserFullName
+	item.ProductImagesPath = append([]string{}, p.ImagesPath...)
+	item.Subtotal = subtotal
+
+	return item, nil
+}
@@ -0,0 +1,241 @@
+/*
+Package product containing client of product service
+used for verifying ordered products
+*/
+package product
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/reyhanfikridz/ecom-order-service/internal/model"
+	"github.com/reyhanfikridz/ecom-order-service/internal/money"
+)
+
+// getTestingProduct get product used for testing
+func getTestingProduct() Product {
+	return Product{
+		ID:           1,
+		SKU:          "sku1",
+		Name:         "Product 1",
+		Price:        money.MustParse("1000"),
+		Weight:       1.5,
+		Description:  "Product description",
+		Stock:        100,
+		UserID:       10,
+		UserFullName: "Seller",
+		ImagesPath:   []string{"product 1.1.jpg"},
+	}
+}
+
+// TestSnapshotOrderItem test SnapshotOrderItem
+func TestSnapshotOrderItem(t *testing.T) {
+	p := getTestingProduct()
+
+	// initialize testing table
+	testTable := []struct {
+		TestName       string
+		Item           model.OrderItem
+		ExpectedResult error
+	}{
+		{
+			TestName:       "Test Without Product Detail",
+			Item:           model.OrderItem{ProductID: 1, Qty: 2},
+			ExpectedResult: nil,
+		},
+		{
+			TestName: "Test With Same Product Detail",
+			Item: model.OrderItem{
+				ProductID:     1,
+				ProductSKU:    "sku1",
+				ProductName:   "Product 1",
+				ProductPrice:  money.MustParse("1000"),
+				ProductWeight: 1.5,
+				ProductUserID: 10,
+				Qty:           2,
+				Subtotal:      money.MustParse("2000"),
+			},
+			ExpectedResult: nil,
+		},
+		{
+			TestName:       "Test Different Product ID",
+			Item:           model.OrderItem{ProductID: 2, Qty: 2},
+			ExpectedResult: ErrProductMismatch,
+		},
+		{
+			TestName:       "Test Different Product Name",
+			Item:           model.OrderItem{ProductID: 1, ProductName: "Product 2", Qty: 2},
+			ExpectedResult: ErrProductMismatch,
+		},
+		{
+			TestName: "Test Different Product Price",
+			Item: model.OrderItem{ProductID: 1,
+				ProductPrice: money.MustParse("0.01"), Qty: 2},
+			ExpectedResult: ErrProductMismatch,
+		},
+		{
+			TestName:       "Test Different Product Weight",
+			Item:           model.OrderItem{ProductID: 1, ProductWeight: 1, Qty: 2},
+			ExpectedResult: ErrProductMismatch,
+		},
+		{
+			TestName:       "Test Different Product User ID",
+			Item:           model.OrderItem{ProductID: 1, ProductUserID: 20, Qty: 2},
+			ExpectedResult: ErrProductMismatch,
+		},
+		{
+			TestName: "Test Different Subtotal",
+			Item: model.OrderItem{ProductID: 1,
+				Subtotal: money.MustParse("1000"), Qty: 2},
+			ExpectedResult: ErrProductMismatch,
+		},
+	}
+
+	// test for each testing table
+	for _, test := range testTable {
+		item, err := SnapshotOrderItem(test.Item, p)
+		if !errors.Is(err, test.ExpectedResult) {
+			t.Errorf("[%s] Expected error %v got %v",
+				test.TestName, test.ExpectedResult, err)
+		}
+		if err != nil {
+			continue
+		}
+
+		if item.ProductSKU != p.SKU ||
+			item.ProductName != p.Name ||
+			item.ProductPrice != p.Price ||
+			item.ProductWeight != p.Weight ||
+			item.ProductDescription != p.Description ||
+			item.ProductStock != p.Stock ||
+			item.ProductUserID != p.UserID ||
+			item.ProductUserFullName != p.UserFullName ||
+			len(item.ProductImagesPath) != len(p.ImagesPath) {
+			t.Errorf("[%s] Expected item filled with product %+v, but got %+v",
+				test.TestName, p, item)
+		}
+		if item.Subtotal != money.MustParse("2000") {
+			t.Errorf("[%s] Expected subtotal 2000, but got %v",
+				test.TestName, item.Subtotal)
+		}
+	}
+}
+
+// TestGetOrderItemProduct test GetOrderItemProduct
+func TestGetOrderItemProduct(t *testing.T) {
+	item := model.OrderItem{
+		ProductID:           1,
+		ProductSKU:          "sku1",
+		ProductName:         "Product 1",
+		ProductPrice:        money.MustParse("1000"),
+		ProductWeight:       1.5,
+		ProductDescription:  "Product description",
+		ProductStock:        10,
+		ProductUserID:       10,
+		ProductUserFullName: "Seller 1",
+		ProductImagesPath:   []string{"product 1.1.jpg"},
+		Qty:                 2,
+		Subtotal:            money.MustParse("2000"),
+	}
+
+	// snapshot of product of the item is the same item
+	result, err := SnapshotOrderItem(model.OrderItem{ProductID: 1, Qty: 2},
+		GetOrderItemProduct(item))
+	if err != nil {
+		t.Fatalf("Expected snapshot success, but got error => %s", err)
+	}
+	if !reflect.DeepEqual(result, item) {
+		t.Errorf("Expected order item %+v, but got %+v", item, result)
+	}
+}
+
+// TestHTTPServiceGetProduct test HTTPService.GetProduct
+func TestHTTPServiceGetProduct(t *testing.T) {
+	p := getTestingProduct()
+
+	// create testing product service
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet || r.URL.Path != "/api/product/" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if r.Header.Get("Authorization") != "Bearer token" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			switch r.URL.Query().Get("id") {
+			case "1":
+				json.NewEncoder(w).Encode(p)
+			case "2":
+				w.WriteHeader(http.StatusInternalServerError)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+	defer server.Close()
+
+	// initialize testing table
+	testTable := []struct {
+		TestName      string
+		Token         string
+		ID            int
+		ExpectedError bool
+		ExpectedErr   error
+	}{
+		{
+			TestName: "Test Get Product Success",
+			Token:    "token",
+			ID:       1,
+		},
+		{
+			TestName:      "Test Get Product Not Found",
+			Token:         "token",
+			ID:            3,
+			ExpectedError: true,
+			ExpectedErr:   ErrProductNotFound,
+		},
+		{
+			TestName:      "Test Get Product Service Error",
+			Token:         "token",
+			ID:            2,
+			ExpectedError: true,
+		},
+		{
+			TestName:      "Test Get Product Invalid Token",
+			Token:         "",
+			ID:            1,
+			ExpectedError: true,
+		},
+	}
+
+	// test for each testing table
+	s := NewHTTPService(server.URL)
+	for _, test := range testTable {
+		result, err := s.GetProduct(context.Background(), test.Token, test.ID)
+		if test.ExpectedError {
+			if err == nil {
+				t.Errorf("[%s] Expected error, but got nil", test.TestName)
+			} else if test.ExpectedErr != nil && err != test.ExpectedErr {
+				t.Errorf("[%s] Expected error %v, but got %v",
+					test.TestName, test.ExpectedErr, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("[%s] Expected error nil, but got %v", test.TestName, err)
+		}
+		if result.ID != p.ID || result.Name != p.Name ||
+			result.Price != p.Price || result.UserID != p.UserID {
+			t.Errorf("[%s] Expected product %+v, but got %+v",
+				test.TestName, p, result)
+		}
+	}
+}
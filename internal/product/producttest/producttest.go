@@ -0,0 +1,110 @@
+/*
+Package producttest containing local HTTP stub of product service
+used for testing
+*/
+package producttest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+
+	"github.com/reyhanfikridz/ecom-order-service/internal/product"
+)
+
+// Server local HTTP stub of product service,
+// products stored in memory and can be checked or changed from test
+type Server struct {
+	*httptest.Server
+	Products *product.MemoryService
+}
+
+// NewServer start product service stub with products,
+// caller should call Close when finished
+func NewServer(products ...product.Product) *Server {
+	s := &Server{Products: product.NewMemoryService(products...)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/product/", s.getProductHandler)
+	mux.HandleFunc("/api/product/stock/reserve/", s.reserveStockHandler)
+	mux.HandleFunc("/api/product/stock/release/", s.releaseStockHandler)
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+// Service get product service client connected to the stub
+func (s *Server) Service() *product.HTTPService {
+	return product.NewHTTPService(s.URL)
+}
+
+// getProductHandler route handler for get product (Method: GET)
+func (s *Server) getProductHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	p, err := s.Products.GetProduct(r.Context(), "", id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p)
+}
+
+// reserveStockHandler route handler for reserve product stock (Method: POST)
+func (s *Server) reserveStockHandler(w http.ResponseWriter, r *http.Request) {
+	s.changeStock(w, r, s.Products.ReserveStock)
+}
+
+// releaseStockHandler route handler for release product stock (Method: POST)
+func (s *Server) releaseStockHandler(w http.ResponseWriter, r *http.Request) {
+	s.changeStock(w, r, s.Products.ReleaseStock)
+}
+
+// changeStock decode stock request and change product stock with change
+func (s *Server) changeStock(w http.ResponseWriter, r *http.Request,
+	change func(ctx context.Context, token string, id int, qty int) error) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var stockReq product.StockRequest
+	err := json.NewDecoder(r.Body).Decode(&stockReq)
+	if err != nil || stockReq.Qty <= 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	err = change(r.Context(), "", stockReq.ID, stockReq.Qty)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// writeError write response status of product service error
+func writeError(w http.ResponseWriter, err error) {
+	switch err {
+	case product.ErrProductNotFound:
+		w.WriteHeader(http.StatusNotFound)
+	case product.ErrStockInsufficient:
+		w.WriteHeader(http.StatusConflict)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,106 @@
+/*
+Package producttest containing local HTTP stub of product service
+used for testing
+*/
+package producttest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/reyhanfikridz/ecom-order-service/internal/money"
+	"github.com/reyhanfikridz/ecom-order-service/internal/product"
+)
+
+// TestServer test product service client against product service stub
+func TestServer(t *testing.T) {
+	ctx := context.Background()
+	server := NewServer(product.Product{
+		ID:     1,
+		Name:   "Product 1",
+		Price:  money.MustParse("1000"),
+		Weight: 1.5,
+		Stock:  3,
+		UserID: 10,
+	})
+	defer server.Close()
+	s := server.Service()
+
+	// initialize testing table, run in order
+	testTable := []struct {
+		TestName      string
+		Action        func() error
+		ExpectedError error
+		ExpectedStock int
+	}{
+		{
+			TestName: "Test Get Product",
+			Action: func() error {
+				_, err := s.GetProduct(ctx, "token", 1)
+				return err
+			},
+			ExpectedError: nil,
+			ExpectedStock: 3,
+		},
+		{
+			TestName: "Test Get Product Not Found",
+			Action: func() error {
+				_, err := s.GetProduct(ctx, "token", 2)
+				return err
+			},
+			ExpectedError: product.ErrProductNotFound,
+			ExpectedStock: 3,
+		},
+		{
+			TestName: "Test Reserve Stock",
+			Action: func() error {
+				return s.ReserveStock(ctx, "token", 1, 2)
+			},
+			ExpectedError: nil,
+			ExpectedStock: 1,
+		},
+		{
+			TestName: "Test Reserve Stock Insufficient",
+			Action: func() error {
+				return s.ReserveStock(ctx, "token", 1, 2)
+			},
+			ExpectedError: product.ErrStockInsufficient,
+			ExpectedStock: 1,
+		},
+		{
+			TestName: "Test Reserve Stock Product Not Found",
+			Action: func() error {
+				return s.ReserveStock(ctx, "token", 2, 1)
+			},
+			ExpectedError: product.ErrProductNotFound,
+			ExpectedStock: 1,
+		},
+		{
+			TestName: "Test Release Stock",
+			Action: func() error {
+				return s.ReleaseStock(ctx, "token", 1, 2)
+			},
+			ExpectedError: nil,
+			ExpectedStock: 3,
+		},
+	}
+
+	// test for each testing table
+	for _, test := range testTable {
+		err := test.Action()
+		if err != test.ExpectedError {
+			t.Errorf("[%s] Expected error %v, but got %v",
+				test.TestName, test.ExpectedError, err)
+		}
+
+		p, err := s.GetProduct(ctx, "token", 1)
+		if err != nil {
+			t.Fatalf("[%s] There's an error when getting product => %s",
+				test.TestName, err)
+		}
+		if p.Stock != test.ExpectedStock {
+			t.Errorf("[%s] Expected stock %d, but got %d",
+				test.TestName, test.ExpectedStock, p.Stock)
+		}
+	}
+}
@@ -0,0 +1,390 @@
+/*
+Package repository containing storage-agnostic order repository
+and its implementations
+*/
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/reyhanfikridz/ecom-order-service/internal/model"
+	"github.com/reyhanfikridz/ecom-order-service/internal/outbox"
+	"github.com/reyhanfikridz/ecom-order-service/internal/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryOrderRepository thread-safe order repository stored in memory,
+// mostly used for testing
+//
+// order change recorded by order change recorder of ctx before changed,
+// so the change not applied if the recorder return error
+type MemoryOrderRepository struct {
+	// OrderNumbers order number generator,
+	// default to random order number generator
+	OrderNumbers utils.OrderNumberGenerator
+
+	// Outbox storage where order events written when order changed
+	Outbox *outbox.MemoryStore
+
+	mu     sync.RWMutex
+	orders []model.Order
+}
+
+// NewMemoryOrderRepository create empty in-memory order repository
+func NewMemoryOrderRepository() *MemoryOrderRepository {
+	return &MemoryOrderRepository{
+		OrderNumbers: utils.RandomOrderNumberGenerator{Length: 15},
+		Outbox:       outbox.NewMemoryStore(),
+	}
+}
+
+// Insert insert new order to memory
+func (r *MemoryOrderRepository) Insert(ctx context.Context,
+	o model.Order) (model.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// get new order number until unused one found
+	unique := false
+	for attempt := 0; attempt < model.MaxInsertOrderAttempts; attempt++ {
+		orderNumber, err := r.OrderNumbers.Generate()
+		if err != nil {
+			return o, err
+		}
+
+		if _, ok := r.find(OrderFilter{OrderNumber: orderNumber}); !ok {
+			o.OrderNumber = orderNumber
+			unique = true
+			break
+		}
+	}
+	if !unique {
+		return o, model.ErrOrderNumberExhausted
+	}
+
+	o.ID = primitive.NewObjectID()
+	o.CalculateTotal()
+	o.CreatedAt = time.Now().UTC().Truncate(time.Millisecond)
+	o.UpdatedAt = o.CreatedAt
+	o.Version = 1
+
+	stored, err := copyOrder(o)
+	if err != nil {
+		return o, err
+	}
+	err = model.RecordOrderChange(ctx, nil, &stored)
+	if err != nil {
+		return o, err
+	}
+	r.orders = append(r.orders, stored)
+	r.addEvent(model.EventOrderCreated, nil, stored)
+
+	return copyOrder(stored)
+}
+
+// Get get one order match the filter from memory
+func (r *MemoryOrderRepository) Get(ctx context.Context,
+	filter OrderFilter) (model.Order, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i, ok := r.find(filter)
+	if !ok {
+		return model.Order{}, ErrOrderNotFound
+	}
+
+	return copyOrder(r.orders[i])
+}
+
+// List get page of orders match the filter from memory
+func (r *MemoryOrderRepository) List(ctx context.Context,
+	filter OrderFilter, opts ListOptions) (OrderPage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	page := OrderPage{Items: []model.Order{}}
+
+	// get all orders match the filter
+	orders := []model.Order{}
+	for _, o := range r.orders {
+		if filter.Match(o) {
+			orders = append(orders, o)
+		}
+	}
+	page.Total = int64(len(orders))
+
+	// sort orders
+	field, desc := getSortField(opts.Sort)
+	sort.SliceStable(orders, func(i, j int) bool {
+		return compareOrders(field, desc, orders[i], orders[j]) < 0
+	})
+
+	// start page right after the cursor order
+	if opts.After != primitive.NilObjectID {
+		cursorExist := false
+		var cursor model.Order
+		for _, o := range r.orders {
+			if o.ID == opts.After {
+				cursor = o
+				cursorExist = true
+				break
+			}
+		}
+		if !cursorExist {
+			return page, ErrInvalidCursor
+		}
+
+		afterOrders := []model.Order{}
+		for _, o := range orders {
+			if compareOrders(field, desc, cursor, o) < 0 {
+				afterOrders = append(afterOrders, o)
+			}
+		}
+		orders = afterOrders
+	}
+
+	// paginate orders
+	if opts.Offset >= int64(len(orders)) {
+		orders = []model.Order{}
+	} else {
+		orders = orders[opts.Offset:]
+	}
+
+	if opts.Limit > 0 && int64(len(orders)) > opts.Limit {
+		orders = orders[:opts.Limit]
+		page.NextCursor = orders[len(orders)-1].ID.Hex()
+	}
+
+	for _, o := range orders {
+		o, err := copyOrder(o)
+		if err != nil {
+			return page, err
+		}
+		page.Items = append(page.Items, o)
+	}
+
+	return page, nil
+}
+
+// Update update order match the filter in memory
+func (r *MemoryOrderRepository) Update(ctx context.Context,
+	filter OrderFilter, oUpdate model.Order) error {
+	return r.change(ctx, filter, model.GetOrderUpdateFields(oUpdate))
+}
+
+// Patch set fields of order match the filter in memory
+func (r *MemoryOrderRepository) Patch(ctx context.Context,
+	filter OrderFilter, oPatch model.Order, fields []string) error {
+	value, err := model.GetOrderPatchFields(oPatch, fields)
+	if err != nil {
+		return err
+	}
+
+	return r.change(ctx, filter, value)
+}
+
+// change set fields (map of bson field name to its value)
+// of order match the filter in memory
+func (r *MemoryOrderRepository) change(ctx context.Context,
+	filter OrderFilter, fields bson.M) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i, ok := r.find(filter)
+	if !ok {
+		return r.getUpdateError(filter)
+	}
+
+	// apply the same update fields as used in mongodb
+	// by decoding them on top of the stored order
+	doc, err := bson.Marshal(fields)
+	if err != nil {
+		return err
+	}
+
+	o, err := copyOrder(r.orders[i])
+	if err != nil {
+		return err
+	}
+	err = bson.Unmarshal(doc, &o)
+	if err != nil {
+		return err
+	}
+	touchOrder(&o)
+	err = model.RecordOrderChange(ctx, &r.orders[i], &o)
+	if err != nil {
+		return err
+	}
+	r.addEvent(model.GetOrderUpdateEventType(r.orders[i], o), &r.orders[i], o)
+	r.orders[i] = o
+
+	return nil
+}
+
+// Delete mark order match the filter in memory as deleted
+func (r *MemoryOrderRepository) Delete(ctx context.Context,
+	filter OrderFilter, deletedBy model.OrderActor) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i, ok := r.find(filter)
+	if !ok {
+		return ErrNoDataDeleted
+	}
+
+	before := r.orders[i]
+	o := r.orders[i]
+	deletedAt := time.Now().UTC()
+	o.DeletedAt = &deletedAt
+	o.DeletedBy = &deletedBy
+	touchOrder(&o)
+	err := model.RecordOrderChange(ctx, &before, &o)
+	if err != nil {
+		return err
+	}
+	r.orders[i] = o
+	r.addEvent(model.EventOrderDeleted, &before, r.orders[i])
+
+	return nil
+}
+
+// Restore unmark deleted order match the filter in memory
+func (r *MemoryOrderRepository) Restore(ctx context.Context,
+	filter OrderFilter) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	filter.DeletedOnly = true
+	i, ok := r.find(filter)
+	if !ok {
+		return ErrNoDataUpdated
+	}
+
+	before := r.orders[i]
+	o := r.orders[i]
+	o.DeletedAt = nil
+	o.DeletedBy = nil
+	touchOrder(&o)
+	err := model.RecordOrderChange(ctx, &before, &o)
+	if err != nil {
+		return err
+	}
+	r.orders[i] = o
+	r.addEvent(model.EventOrderRestored, &before, r.orders[i])
+
+	return nil
+}
+
+// Purge permanently delete deleted orders match the filter from memory
+func (r *MemoryOrderRepository) Purge(ctx context.Context,
+	filter OrderFilter) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	filter.DeletedOnly = true
+	orders := []model.Order{}
+	for i, o := range r.orders {
+		if !filter.Match(o) {
+			orders = append(orders, o)
+			continue
+		}
+
+		err := model.RecordOrderChange(ctx, &r.orders[i], nil)
+		if err != nil {
+			return 0, err
+		}
+	}
+	total := int64(len(r.orders) - len(orders))
+	r.orders = orders
+
+	return total, nil
+}
+
+// SetStockReleasePending set whether items stock release of order
+// match the filter in memory pending
+func (r *MemoryOrderRepository) SetStockReleasePending(ctx context.Context,
+	filter OrderFilter, pending bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i, ok := r.find(filter)
+	if !ok {
+		return ErrNoDataUpdated
+	}
+	r.orders[i].StockReleasePending = pending
+
+	return nil
+}
+
+// find get index of first order match the filter
+//
+// caller must hold the lock
+func (r *MemoryOrderRepository) find(filter OrderFilter) (int, bool) {
+	for i, o := range r.orders {
+		if filter.Match(o) {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// getUpdateError get error of no order match the filter when updating,
+// ErrVersionConflict if the order match except its version
+//
+// caller must hold the lock
+func (r *MemoryOrderRepository) getUpdateError(filter OrderFilter) error {
+	if filter.Version != 0 {
+		filter.Version = 0
+		if _, ok := r.find(filter); ok {
+			return ErrVersionConflict
+		}
+	}
+
+	return ErrNoDataUpdated
+}
+
+// addEvent write event of order changed from before into after to outbox
+//
+// caller must hold the lock
+func (r *MemoryOrderRepository) addEvent(eventType string, before *model.Order,
+	after model.Order) {
+	if r.Outbox == nil {
+		return
+	}
+
+	after, err := copyOrder(after)
+	if err != nil {
+		return
+	}
+	r.Outbox.Add(model.NewOrderEvent(eventType, before, after))
+}
+
+// touchOrder set order last update time to now and increment its version,
+// the same as changing order in mongodb
+func touchOrder(o *model.Order) {
+	o.UpdatedAt = time.Now().UTC().Truncate(time.Millisecond)
+	o.Version++
+}
+
+// copyOrder deep copy order so stored order
+// can't be changed from outside the repository
+func copyOrder(o model.Order) (model.Order, error) {
+	result := model.Order{}
+
+	doc, err := bson.Marshal(o)
+	if err != nil {
+		return result, err
+	}
+
+	err = bson.Unmarshal(doc, &result)
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
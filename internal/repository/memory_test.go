@@ -0,0 +1,742 @@
+/*
+Package repository containing storage-agnostic order repository
+and its implementations
+*/
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/reyhanfikridz/ecom-order-service/internal/model"
+	"github.com/reyhanfikridz/ecom-order-service/internal/money"
+	"github.com/reyhanfikridz/ecom-order-service/internal/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TestMemoryOrderRepositoryInsert test MemoryOrderRepository Insert
+func TestMemoryOrderRepositoryInsert(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemoryOrderRepository()
+
+	o, err := r.Insert(ctx, getTestingOrder(1, 10))
+	if err != nil {
+		t.Fatalf("Expected insert success, but got error => %s", err)
+	}
+	if o.ID == primitive.NilObjectID {
+		t.Errorf("Expected ID not nil, but got nil")
+	}
+	if len(o.OrderNumber) != 15 {
+		t.Errorf("Expected OrderNumber length 15, but got %d", len(o.OrderNumber))
+	}
+	if o.TotalPrice != money.MustParse("2000001") {
+		t.Errorf("Expected TotalPrice %s, but got %s", "2000001.00", o.TotalPrice)
+	}
+
+	// test returned order can't change stored order
+	o.Items[0].ProductName = "changed"
+	result, err := r.Get(ctx, OrderFilter{OrderNumber: o.OrderNumber})
+	if err != nil {
+		t.Fatalf("Expected get success, but got error => %s", err)
+	}
+	if result.Items[0].ProductName != "product name" {
+		t.Errorf("Expected stored ProductName not changed, but got %s",
+			result.Items[0].ProductName)
+	}
+}
+
+// TestMemoryOrderRepositoryInsertDuplicateOrderNumber test
+// MemoryOrderRepository Insert when generated order number already used
+func TestMemoryOrderRepositoryInsertDuplicateOrderNumber(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemoryOrderRepository()
+	r.OrderNumbers = utils.PrefixedOrderNumberGenerator{Prefix: "ORD"}
+
+	// first insert get the only possible order number
+	o, err := r.Insert(ctx, getTestingOrder(1, 10))
+	if err != nil {
+		t.Fatalf("Expected insert success, but got error => %s", err)
+	}
+	if !strings.HasPrefix(o.OrderNumber, "ORD-") {
+		t.Errorf("Expected OrderNumber prefix ORD-, but got %s", o.OrderNumber)
+	}
+
+	// second insert can't get unused order number
+	_, err = r.Insert(ctx, getTestingOrder(1, 10))
+	if err != model.ErrOrderNumberExhausted {
+		t.Errorf("Expected error %v, but got %v",
+			model.ErrOrderNumberExhausted, err)
+	}
+}
+
+// TestMemoryOrderRepositoryGet test MemoryOrderRepository Get
+func TestMemoryOrderRepositoryGet(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemoryOrderRepository()
+
+	o, err := r.Insert(ctx, getTestingOrder(1, 10))
+	if err != nil {
+		t.Fatalf("There's an error when inserting order => %s", err)
+	}
+
+	result, err := r.Get(ctx, OrderFilter{OrderNumber: o.OrderNumber})
+	if err != nil {
+		t.Errorf("Expected get success, but got error => %s", err)
+	}
+	if o.ID != result.ID || o.OrderNumber != result.OrderNumber ||
+		o.BuyerID != result.BuyerID || len(o.Items) != len(result.Items) {
+		t.Errorf("Expected order %v, but got %v", o, result)
+	}
+
+	_, err = r.Get(ctx, OrderFilter{OrderNumber: "not exist"})
+	if err != ErrOrderNotFound {
+		t.Errorf("Expected error ErrOrderNotFound, but got %v", err)
+	}
+}
+
+// TestMemoryOrderRepositoryList test MemoryOrderRepository List
+func TestMemoryOrderRepositoryList(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemoryOrderRepository()
+
+	orders := []model.Order{
+		getTestingOrder(1, 10),
+		getTestingOrder(1, 20),
+		getTestingOrder(2, 20),
+	}
+	orders[1].Status = model.OrderStatusDone
+	for i := range orders {
+		var err error
+		orders[i], err = r.Insert(ctx, orders[i])
+		if err != nil {
+			t.Fatalf("There's an error when inserting order => %s", err)
+		}
+	}
+
+	// create testing table
+	testTable := []struct {
+		TestName        string
+		Filter          OrderFilter
+		ExpectedResults []model.Order
+	}{
+		{
+			TestName:        "Test List All Order",
+			Filter:          OrderFilter{},
+			ExpectedResults: orders,
+		},
+		{
+			TestName:        "Test List Order By Buyer ID <1>",
+			Filter:          OrderFilter{BuyerID: 1},
+			ExpectedResults: []model.Order{orders[0], orders[1]},
+		},
+		{
+			TestName:        "Test List Order By Buyer ID <1> and Status <done>",
+			Filter:          OrderFilter{BuyerID: 1, Status: model.OrderStatusDone},
+			ExpectedResults: []model.Order{orders[1]},
+		},
+		{
+			TestName:        "Test List Order By Product User ID <20>",
+			Filter:          OrderFilter{ProductUserID: 20},
+			ExpectedResults: []model.Order{orders[1], orders[2]},
+		},
+	}
+
+	// do test in test table
+	for _, test := range testTable {
+		page, err := r.List(ctx, test.Filter, ListOptions{})
+		results := page.Items
+		if err != nil {
+			t.Errorf("[%s] Expected list success, but got error => %s",
+				test.TestName, err)
+		}
+
+		if len(test.ExpectedResults) != len(results) {
+			t.Errorf("[%s] Expected total order %d, but got %d", test.TestName,
+				len(test.ExpectedResults), len(results))
+			continue
+		}
+
+		for i := range test.ExpectedResults {
+			if test.ExpectedResults[i].OrderNumber != results[i].OrderNumber {
+				t.Errorf("[%s] Expected order number %s, but got %s",
+					test.TestName, test.ExpectedResults[i].OrderNumber,
+					results[i].OrderNumber)
+			}
+		}
+	}
+}
+
+// TestMemoryOrderRepositoryListPagination test MemoryOrderRepository List
+// with sorting and pagination
+func TestMemoryOrderRepositoryListPagination(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemoryOrderRepository()
+
+	// insert orders with total price 3000, 1000, 2000, 1000, 3000
+	orders := []model.Order{}
+	for _, price := range []string{"3000", "1000", "2000", "1000", "3000"} {
+		o := getTestingOrder(1, 10)
+		o.Items[0].ProductPrice = money.MustParse(price)
+		o.Items[0].Qty = 1
+
+		o, err := r.Insert(ctx, o)
+		if err != nil {
+			t.Fatalf("There's an error when inserting order => %s", err)
+		}
+		orders = append(orders, o)
+	}
+
+	// create testing table
+	testTable := []struct {
+		TestName           string
+		Options            ListOptions
+		ExpectedResults    []model.Order
+		ExpectedNextCursor string
+	}{
+		{
+			TestName:           "Test List First Page",
+			Options:            ListOptions{Limit: 2},
+			ExpectedResults:    []model.Order{orders[0], orders[1]},
+			ExpectedNextCursor: orders[1].ID.Hex(),
+		},
+		{
+			TestName:           "Test List With Offset",
+			Options:            ListOptions{Limit: 2, Offset: 2},
+			ExpectedResults:    []model.Order{orders[2], orders[3]},
+			ExpectedNextCursor: orders[3].ID.Hex(),
+		},
+		{
+			TestName:        "Test List Last Page With Cursor",
+			Options:         ListOptions{Limit: 2, After: orders[3].ID},
+			ExpectedResults: []model.Order{orders[4]},
+		},
+		{
+			TestName:        "Test List Sort By Creation Time Descending",
+			Options:         ListOptions{Sort: "-created_at"},
+			ExpectedResults: []model.Order{orders[4], orders[3], orders[2], orders[1], orders[0]},
+		},
+		{
+			TestName:        "Test List Sort By Total Price",
+			Options:         ListOptions{Sort: "total_price"},
+			ExpectedResults: []model.Order{orders[1], orders[3], orders[2], orders[0], orders[4]},
+		},
+		{
+			TestName:           "Test List Sort By Total Price Descending With Cursor",
+			Options:            ListOptions{Sort: "-total_price", Limit: 2, After: orders[0].ID},
+			ExpectedResults:    []model.Order{orders[2], orders[3]},
+			ExpectedNextCursor: orders[3].ID.Hex(),
+		},
+	}
+
+	// do test in test table
+	for _, test := range testTable {
+		page, err := r.List(ctx, OrderFilter{}, test.Options)
+		if err != nil {
+			t.Errorf("[%s] Expected list success, but got error => %s",
+				test.TestName, err)
+			continue
+		}
+
+		if page.Total != int64(len(orders)) {
+			t.Errorf("[%s] Expected total %d, but got %d", test.TestName,
+				len(orders), page.Total)
+		}
+		if page.NextCursor != test.ExpectedNextCursor {
+			t.Errorf("[%s] Expected next cursor '%s', but got '%s'",
+				test.TestName, test.ExpectedNextCursor, page.NextCursor)
+		}
+		if len(test.ExpectedResults) != len(page.Items) {
+			t.Errorf("[%s] Expected total order %d, but got %d", test.TestName,
+				len(test.ExpectedResults), len(page.Items))
+			continue
+		}
+
+		for i := range test.ExpectedResults {
+			if test.ExpectedResults[i].ID != page.Items[i].ID {
+				t.Errorf("[%s] Expected order %d ID %s, but got %s",
+					test.TestName, i, test.ExpectedResults[i].ID.Hex(),
+					page.Items[i].ID.Hex())
+			}
+		}
+	}
+
+	// test cursor not exist
+	_, err := r.List(ctx, OrderFilter{}, ListOptions{After: primitive.NewObjectID()})
+	if err != ErrInvalidCursor {
+		t.Errorf("Expected error ErrInvalidCursor, but got %v", err)
+	}
+}
+
+// TestMemoryOrderRepositoryUpdate test MemoryOrderRepository Update
+func TestMemoryOrderRepositoryUpdate(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemoryOrderRepository()
+
+	o, err := r.Insert(ctx, getTestingOrder(1, 10))
+	if err != nil {
+		t.Fatalf("There's an error when inserting order => %s", err)
+	}
+
+	// test update only non zero value fields
+	filter := OrderFilter{OrderNumber: o.OrderNumber}
+	err = r.Update(ctx, filter, model.Order{
+		Status:       model.OrderStatusCheckedOut,
+		BuyerAddress: "Buyer Street 2",
+	})
+	if err != nil {
+		t.Errorf("Expected update success, but got error => %s", err)
+	}
+
+	result, err := r.Get(ctx, filter)
+	if err != nil {
+		t.Fatalf("There's an error when getting order => %s", err)
+	}
+	if result.Status != model.OrderStatusCheckedOut {
+		t.Errorf("Expected Status %s, but got %s",
+			model.OrderStatusCheckedOut, result.Status)
+	}
+	if result.BuyerAddress != "Buyer Street 2" {
+		t.Errorf("Expected BuyerAddress Buyer Street 2, but got %s",
+			result.BuyerAddress)
+	}
+	if result.BuyerFullName != o.BuyerFullName {
+		t.Errorf("Expected BuyerFullName %s, but got %s",
+			o.BuyerFullName, result.BuyerFullName)
+	}
+	if len(result.Items) != 1 || result.TotalPrice != o.TotalPrice {
+		t.Errorf("Expected items not changed, but got %v", result.Items)
+	}
+
+	// test update with filter not match
+	err = r.Update(ctx, OrderFilter{
+		OrderNumber: o.OrderNumber,
+		Status:      model.OrderStatusInCart,
+	}, model.Order{Status: model.OrderStatusCancelled})
+	if err != ErrNoDataUpdated {
+		t.Errorf("Expected error ErrNoDataUpdated, but got %v", err)
+	}
+}
+
+// TestMemoryOrderRepositoryPatch test MemoryOrderRepository Patch
+func TestMemoryOrderRepositoryPatch(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemoryOrderRepository()
+
+	o, err := r.Insert(ctx, getTestingOrder(1, 10))
+	if err != nil {
+		t.Fatalf("There's an error when inserting order => %s", err)
+	}
+
+	// test patch fields into zero value
+	oPatch := o
+	oPatch.BuyerAddress = ""
+	oPatch.BuyerFullName = "changed but not patched"
+	oPatch.Items = []model.OrderItem{o.Items[0]}
+	oPatch.Items[0].Qty = 0
+	oPatch.Items[0].ProductImagesPath = []string{}
+
+	filter := OrderFilter{OrderNumber: o.OrderNumber}
+	err = r.Patch(ctx, filter, oPatch, []string{"buyer_address", "items"})
+	if err != nil {
+		t.Fatalf("Expected patch success, but got error => %s", err)
+	}
+
+	result, err := r.Get(ctx, filter)
+	if err != nil {
+		t.Fatalf("There's an error when getting order => %s", err)
+	}
+	if result.BuyerAddress != "" {
+		t.Errorf("Expected BuyerAddress empty, but got %s", result.BuyerAddress)
+	}
+	if result.BuyerFullName != o.BuyerFullName {
+		t.Errorf("Expected BuyerFullName %s, but got %s", o.BuyerFullName,
+			result.BuyerFullName)
+	}
+	if len(result.Items) != 1 || result.Items[0].Qty != 0 ||
+		len(result.Items[0].ProductImagesPath) != 0 ||
+		result.Qty != 0 || result.TotalPrice != money.MustParse("0") {
+		t.Errorf("Expected item qty 0 without images and total 0, but got %+v",
+			result)
+	}
+
+	// test patch unknown field
+	err = r.Patch(ctx, filter, oPatch, []string{"unknown"})
+	if err == nil {
+		t.Errorf("Expected error field invalid, but got nil")
+	}
+
+	// test patch with stale version
+	filter.Version = o.Version
+	err = r.Patch(ctx, filter, oPatch, []string{"buyer_address"})
+	if err != ErrVersionConflict {
+		t.Errorf("Expected error ErrVersionConflict, but got %v", err)
+	}
+}
+
+// TestMemoryOrderRepositoryVersion test MemoryOrderRepository
+// timestamps and version of order
+func TestMemoryOrderRepositoryVersion(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemoryOrderRepository()
+
+	o, err := r.Insert(ctx, getTestingOrder(1, 10))
+	if err != nil {
+		t.Fatalf("There's an error when inserting order => %s", err)
+	}
+	if o.Version != 1 || o.CreatedAt.IsZero() ||
+		!o.UpdatedAt.Equal(o.CreatedAt) {
+		t.Errorf("Expected version 1 and creation time set, but got "+
+			"version %d created at %v updated at %v", o.Version, o.CreatedAt,
+			o.UpdatedAt)
+	}
+
+	// test update with the current version
+	filter := OrderFilter{OrderNumber: o.OrderNumber, Version: 1}
+	err = r.Update(ctx, filter, model.Order{BuyerAddress: "Buyer Street 2"})
+	if err != nil {
+		t.Errorf("Expected update success, but got error => %s", err)
+	}
+
+	// test update with stale version
+	err = r.Update(ctx, filter, model.Order{BuyerAddress: "Buyer Street 3"})
+	if err != ErrVersionConflict {
+		t.Errorf("Expected error ErrVersionConflict, but got %v", err)
+	}
+
+	// test update not found order with version
+	err = r.Update(ctx, OrderFilter{OrderNumber: "NOTEXIST", Version: 1},
+		model.Order{BuyerAddress: "Buyer Street 3"})
+	if err != ErrNoDataUpdated {
+		t.Errorf("Expected error ErrNoDataUpdated, but got %v", err)
+	}
+
+	// test delete and restore increment version
+	err = r.Delete(ctx, OrderFilter{OrderNumber: o.OrderNumber},
+		model.OrderActor{ID: 1, Role: "buyer"})
+	if err != nil {
+		t.Fatalf("Expected delete success, but got error => %s", err)
+	}
+	err = r.Restore(ctx, OrderFilter{OrderNumber: o.OrderNumber})
+	if err != nil {
+		t.Fatalf("Expected restore success, but got error => %s", err)
+	}
+
+	result, err := r.Get(ctx, OrderFilter{OrderNumber: o.OrderNumber})
+	if err != nil {
+		t.Fatalf("There's an error when getting order => %s", err)
+	}
+	if result.Version != 4 || result.BuyerAddress != "Buyer Street 2" ||
+		!result.CreatedAt.Equal(o.CreatedAt) ||
+		result.UpdatedAt.Before(o.UpdatedAt) {
+		t.Errorf("Expected version 4 and update time changed, but got "+
+			"version %d created at %v updated at %v", result.Version,
+			result.CreatedAt, result.UpdatedAt)
+	}
+}
+
+// TestMemoryOrderRepositoryDelete test MemoryOrderRepository Delete,
+// Restore, and Purge
+func TestMemoryOrderRepositoryDelete(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemoryOrderRepository()
+
+	o, err := r.Insert(ctx, getTestingOrder(1, 10))
+	if err != nil {
+		t.Fatalf("There's an error when inserting order => %s", err)
+	}
+
+	// test delete order
+	filter := OrderFilter{OrderNumber: o.OrderNumber}
+	deletedBy := model.OrderActor{ID: 1, Role: "buyer"}
+	err = r.Delete(ctx, filter, deletedBy)
+	if err != nil {
+		t.Errorf("Expected delete success, but got error => %s", err)
+	}
+
+	_, err = r.Get(ctx, filter)
+	if err != ErrOrderNotFound {
+		t.Errorf("Expected error ErrOrderNotFound, but got %v", err)
+	}
+
+	err = r.Delete(ctx, filter, deletedBy)
+	if err != ErrNoDataDeleted {
+		t.Errorf("Expected error ErrNoDataDeleted, but got %v", err)
+	}
+
+	// test deleted order still can be get with IncludeDeleted
+	result, err := r.Get(ctx, OrderFilter{
+		OrderNumber:    o.OrderNumber,
+		IncludeDeleted: true,
+	})
+	if err != nil {
+		t.Fatalf("Expected deleted order found, but got error => %s", err)
+	}
+	if result.DeletedAt == nil || result.DeletedBy == nil ||
+		*result.DeletedBy != deletedBy {
+		t.Errorf("Expected order deleted by %v, but got deleted at %v by %v",
+			deletedBy, result.DeletedAt, result.DeletedBy)
+	}
+
+	// test restore order
+	err = r.Restore(ctx, filter)
+	if err != nil {
+		t.Errorf("Expected restore success, but got error => %s", err)
+	}
+
+	result, err = r.Get(ctx, filter)
+	if err != nil {
+		t.Fatalf("Expected restored order found, but got error => %s", err)
+	}
+	if result.DeletedAt != nil || result.DeletedBy != nil {
+		t.Errorf("Expected restored order not deleted, but got deleted at %v",
+			result.DeletedAt)
+	}
+
+	err = r.Restore(ctx, filter)
+	if err != ErrNoDataUpdated {
+		t.Errorf("Expected error ErrNoDataUpdated, but got %v", err)
+	}
+
+	// test purge only orders deleted before the time
+	o2, err := r.Insert(ctx, getTestingOrder(2, 10))
+	if err != nil {
+		t.Fatalf("There's an error when inserting order => %s", err)
+	}
+	err = r.Delete(ctx, OrderFilter{OrderNumber: o2.OrderNumber}, deletedBy)
+	if err != nil {
+		t.Fatalf("Expected delete success, but got error => %s", err)
+	}
+
+	total, err := r.Purge(ctx, OrderFilter{
+		DeletedBefore: time.Now().UTC().Add(-time.Hour),
+	})
+	if err != nil || total != 0 {
+		t.Errorf("Expected purge 0 order, but got %d (error %v)", total, err)
+	}
+
+	total, err = r.Purge(ctx, OrderFilter{
+		DeletedBefore: time.Now().UTC().Add(time.Hour),
+	})
+	if err != nil || total != 1 {
+		t.Errorf("Expected purge 1 order, but got %d (error %v)", total, err)
+	}
+
+	page, err := r.List(ctx, OrderFilter{IncludeDeleted: true}, ListOptions{})
+	if err != nil {
+		t.Fatalf("Expected list success, but got error => %s", err)
+	}
+	if page.Total != 1 || page.Items[0].OrderNumber != o.OrderNumber {
+		t.Errorf("Expected only order %s left, but got %v",
+			o.OrderNumber, page.Items)
+	}
+}
+
+// TestMemoryOrderRepositoryEvents test MemoryOrderRepository write
+// order events to outbox when order changed
+func TestMemoryOrderRepositoryEvents(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemoryOrderRepository()
+
+	o, err := r.Insert(ctx, getTestingOrder(1, 10))
+	if err != nil {
+		t.Fatalf("There's an error when inserting order => %s", err)
+	}
+
+	filter := OrderFilter{OrderNumber: o.OrderNumber}
+	updates := []model.Order{
+		{BuyerAddress: "Buyer Street 2"},
+		{Status: model.OrderStatusCheckedOut},
+		{Status: model.OrderStatusCancelled},
+	}
+	for _, oUpdate := range updates {
+		err = r.Update(ctx, filter, oUpdate)
+		if err != nil {
+			t.Fatalf("Expected update success, but got error => %s", err)
+		}
+	}
+
+	err = r.Delete(ctx, filter, model.OrderActor{ID: 1, Role: "buyer"})
+	if err != nil {
+		t.Fatalf("Expected delete success, but got error => %s", err)
+	}
+
+	// check events written in order
+	expectedTypes := []string{
+		model.EventOrderCreated,
+		model.EventOrderUpdated,
+		model.EventOrderStatusChanged,
+		model.EventOrderCancelled,
+		model.EventOrderDeleted,
+	}
+	events := r.Outbox.Events()
+	if len(events) != len(expectedTypes) {
+		t.Fatalf("Expected total events %d, but got %d",
+			len(expectedTypes), len(events))
+	}
+	for i, e := range events {
+		if e.Type != expectedTypes[i] || e.OrderNumber != o.OrderNumber {
+			t.Errorf("Expected event %s of order %s, but got %s of order %s",
+				expectedTypes[i], o.OrderNumber, e.Type, e.OrderNumber)
+		}
+	}
+	if events[2].PreviousStatus != model.OrderStatusInCart ||
+		events[2].Status != model.OrderStatusCheckedOut {
+		t.Errorf("Expected status changed from in-cart to checked-out, "+
+			"but got from %s to %s", events[2].PreviousStatus, events[2].Status)
+	}
+
+	// check failed update not written
+	err = r.Update(ctx, OrderFilter{OrderNumber: "NOTEXIST"},
+		model.Order{Status: model.OrderStatusPaid})
+	if err != ErrNoDataUpdated {
+		t.Errorf("Expected error ErrNoDataUpdated, but got %v", err)
+	}
+	if len(r.Outbox.Events()) != len(expectedTypes) {
+		t.Errorf("Expected no event written for failed update")
+	}
+}
+
+// TestMemoryOrderRepositoryRecorder test MemoryOrderRepository record
+// order change with order change recorder of context,
+// and not change the order if the recorder failed
+func TestMemoryOrderRepositoryRecorder(t *testing.T) {
+	r := NewMemoryOrderRepository()
+	changes := []string{}
+	ctx := model.WithOrderChangeRecorder(context.Background(),
+		func(ctx context.Context, before *model.Order,
+			after *model.Order) error {
+			switch {
+			case before == nil:
+				changes = append(changes, "created")
+			case after == nil:
+				changes = append(changes, "purged")
+			default:
+				changes = append(changes, before.Status+" => "+after.Status)
+			}
+			return nil
+		})
+
+	o, err := r.Insert(ctx, getTestingOrder(1, 10))
+	if err != nil {
+		t.Fatalf("There's an error when inserting order => %s", err)
+	}
+	filter := OrderFilter{OrderNumber: o.OrderNumber}
+	err = r.Update(ctx, filter, model.Order{Status: model.OrderStatusCheckedOut})
+	if err != nil {
+		t.Fatalf("Expected update success, but got error => %s", err)
+	}
+	err = r.Delete(ctx, filter, model.OrderActor{ID: 1, Role: "buyer"})
+	if err != nil {
+		t.Fatalf("Expected delete success, but got error => %s", err)
+	}
+	_, err = r.Purge(ctx, filter)
+	if err != nil {
+		t.Fatalf("Expected purge success, but got error => %s", err)
+	}
+
+	expectedChanges := "created, in-cart => checked-out, " +
+		"checked-out => checked-out, purged"
+	if strings.Join(changes, ", ") != expectedChanges {
+		t.Errorf("Expected changes %s, but got %s", expectedChanges,
+			strings.Join(changes, ", "))
+	}
+
+	// check order not changed when the recorder failed
+	o, err = r.Insert(context.Background(), getTestingOrder(1, 10))
+	if err != nil {
+		t.Fatalf("There's an error when inserting order => %s", err)
+	}
+	failedCtx := model.WithOrderChangeRecorder(context.Background(),
+		func(ctx context.Context, before *model.Order,
+			after *model.Order) error {
+			return errors.New("recorder failed")
+		})
+	filter = OrderFilter{OrderNumber: o.OrderNumber}
+	err = r.Update(failedCtx, filter,
+		model.Order{Status: model.OrderStatusCheckedOut})
+	if err == nil {
+		t.Errorf("Expected update failed, but got error nil")
+	}
+
+	result, err := r.Get(context.Background(), filter)
+	if err != nil {
+		t.Fatalf("Expected get success, but got error => %s", err)
+	}
+	if result.Status != model.OrderStatusInCart || result.Version != 1 {
+		t.Errorf("Expected order not changed, but got status %s version %d",
+			result.Status, result.Version)
+	}
+	if len(r.Outbox.Events()) != 4 {
+		t.Errorf("Expected no event written for failed update, "+
+			"but got total events %d", len(r.Outbox.Events()))
+	}
+}
+
+// TestMemoryOrderRepositoryConcurrency test MemoryOrderRepository
+// used by many goroutine at the same time
+func TestMemoryOrderRepositoryConcurrency(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemoryOrderRepository()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(buyerID int) {
+			defer wg.Done()
+
+			o, err := r.Insert(ctx, getTestingOrder(buyerID, 10))
+			if err != nil {
+				t.Errorf("Expected insert success, but got error => %s", err)
+				return
+			}
+
+			err = r.Update(ctx, OrderFilter{OrderNumber: o.OrderNumber},
+				model.Order{Status: model.OrderStatusCheckedOut})
+			if err != nil {
+				t.Errorf("Expected update success, but got error => %s", err)
+			}
+
+			_, err = r.List(ctx, OrderFilter{BuyerID: buyerID}, ListOptions{})
+			if err != nil {
+				t.Errorf("Expected list success, but got error => %s", err)
+			}
+		}(i + 1)
+	}
+	wg.Wait()
+
+	page, err := r.List(ctx, OrderFilter{Status: model.OrderStatusCheckedOut},
+		ListOptions{})
+	if err != nil {
+		t.Fatalf("Expected list success, but got error => %s", err)
+	}
+	if len(page.Items) != 50 || page.Total != 50 {
+		t.Errorf("Expected total order 50, but got %d (total %d)",
+			len(page.Items), page.Total)
+	}
+}
+
+// getTestingOrder get order for testing
+func getTestingOrder(buyerID int, productUserID int) model.Order {
+	return model.Order{
+		Status:        model.OrderStatusInCart,
+		BuyerID:       buyerID,
+		BuyerFullName: "George Marcus",
+		BuyerAddress:  "Buyer Street",
+		Items: []model.OrderItem{
+			{
+				ProductID:         1,
+				ProductSKU:        "testsku",
+				ProductName:       "product name",
+				ProductPrice:      money.MustParse("1000000.50"),
+				ProductWeight:     1.5,
+				ProductUserID:     productUserID,
+				ProductImagesPath: []string{"product 1.1.jpg"},
+				Qty:               2,
+			},
+		},
+	}
+}
@@ -0,0 +1,181 @@
+/*
+Package repository containing storage-agnostic order repository
+and its implementations
+*/
+package repository
+
+import (
+	"context"
+
+	"github.com/reyhanfikridz/ecom-order-service/internal/model"
+	"github.com/reyhanfikridz/ecom-order-service/internal/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoOrderRepository order repository stored in mongodb collection
+type MongoOrderRepository struct {
+	Collection   *mongo.Collection
+	OrderNumbers utils.OrderNumberGenerator
+}
+
+// NewMongoOrderRepository create order repository
+// using mongodb orders collection and order number generator
+func NewMongoOrderRepository(oc *mongo.Collection,
+	gen utils.OrderNumberGenerator) *MongoOrderRepository {
+	return &MongoOrderRepository{Collection: oc, OrderNumbers: gen}
+}
+
+// Insert insert new order to orders collection
+func (r *MongoOrderRepository) Insert(ctx context.Context,
+	o model.Order) (model.Order, error) {
+	return model.InsertOrder(ctx, r.Collection, o, r.OrderNumbers)
+}
+
+// Get get one order match the filter from orders collection
+func (r *MongoOrderRepository) Get(ctx context.Context,
+	filter OrderFilter) (model.Order, error) {
+	o, err := model.GetOrder(ctx, r.Collection, filter.BSON())
+	if err == mongo.ErrNoDocuments {
+		return o, ErrOrderNotFound
+	}
+
+	return o, err
+}
+
+// List get page of orders match the filter from orders collection
+func (r *MongoOrderRepository) List(ctx context.Context,
+	filter OrderFilter, opts ListOptions) (OrderPage, error) {
+	page := OrderPage{Items: []model.Order{}}
+	bsonFilter := filter.BSON()
+
+	// count all orders match the filter
+	total, err := model.CountOrders(ctx, r.Collection, bsonFilter)
+	if err != nil {
+		return page, err
+	}
+	page.Total = total
+
+	// get sort field and direction
+	field, desc := getSortField(opts.Sort)
+	direction := 1
+	operator := "$gt"
+	if desc {
+		direction = -1
+		operator = "$lt"
+	}
+
+	sort := bson.D{primitive.E{Key: field.BSONKey, Value: direction}}
+	if field.BSONKey != "_id" {
+		sort = append(sort, primitive.E{Key: "_id", Value: direction})
+	}
+
+	// start page right after the cursor order
+	// by comparing sort field value then the ID
+	if opts.After != primitive.NilObjectID {
+		var cursor bson.M
+		err = r.Collection.FindOne(ctx, bson.M{"_id": opts.After}).Decode(&cursor)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				return page, ErrInvalidCursor
+			}
+			return page, err
+		}
+
+		afterFilter := bson.M{"_id": bson.M{operator: opts.After}}
+		if field.BSONKey != "_id" {
+			value := cursor[field.BSONKey]
+			afterFilter = bson.M{"$or": bson.A{
+				bson.M{field.BSONKey: bson.M{operator: value}},
+				bson.M{field.BSONKey: value, "_id": bson.M{operator: opts.After}},
+			}}
+		}
+		bsonFilter = bson.M{"$and": bson.A{bsonFilter, afterFilter}}
+	}
+
+	// get orders, one more than limit to check if there's next page
+	findOpts := options.Find().SetSort(sort).SetSkip(opts.Offset)
+	if opts.Limit > 0 {
+		findOpts.SetLimit(opts.Limit + 1)
+	}
+
+	orders, err := model.GetOrders(ctx, r.Collection, bsonFilter, findOpts)
+	if err != nil {
+		return page, err
+	}
+
+	if opts.Limit > 0 && int64(len(orders)) > opts.Limit {
+		orders = orders[:opts.Limit]
+		page.NextCursor = orders[len(orders)-1].ID.Hex()
+	}
+	page.Items = orders
+
+	return page, nil
+}
+
+// Update update order match the filter in orders collection
+func (r *MongoOrderRepository) Update(ctx context.Context,
+	filter OrderFilter, oUpdate model.Order) error {
+	err := model.UpdateOrder(ctx, r.Collection, filter.BSON(), oUpdate)
+	return r.getUpdateError(ctx, filter, err)
+}
+
+// Patch set fields of order match the filter in orders collection
+func (r *MongoOrderRepository) Patch(ctx context.Context,
+	filter OrderFilter, oPatch model.Order, fields []string) error {
+	err := model.PatchOrder(ctx, r.Collection, filter.BSON(), oPatch, fields)
+	return r.getUpdateError(ctx, filter, err)
+}
+
+// getUpdateError get error of updating order match the filter,
+// ErrVersionConflict if no order updated but the order match
+// except its version
+func (r *MongoOrderRepository) getUpdateError(ctx context.Context,
+	filter OrderFilter, err error) error {
+	if err != ErrNoDataUpdated || filter.Version == 0 {
+		return err
+	}
+
+	// check if the order exist with another version
+	filter.Version = 0
+	_, err = model.GetOrder(ctx, r.Collection, filter.BSON())
+	if err == mongo.ErrNoDocuments {
+		return ErrNoDataUpdated
+	}
+	if err != nil {
+		return err
+	}
+
+	return ErrVersionConflict
+}
+
+// Delete mark order match the filter in orders collection as deleted
+func (r *MongoOrderRepository) Delete(ctx context.Context,
+	filter OrderFilter, deletedBy model.OrderActor) error {
+	return model.DeleteOrder(ctx, r.Collection, filter.BSON(), deletedBy)
+}
+
+// Restore unmark deleted order match the filter in orders collection
+func (r *MongoOrderRepository) Restore(ctx context.Context,
+	filter OrderFilter) error {
+	filter.DeletedOnly = true
+	return model.RestoreOrder(ctx, r.Collection, filter.BSON())
+}
+
+// Purge permanently delete deleted orders match the filter
+// from orders collection
+func (r *MongoOrderRepository) Purge(ctx context.Context,
+	filter OrderFilter) (int64, error) {
+	filter.DeletedOnly = true
+	return model.PurgeOrders(ctx, r.Collection, filter.BSON())
+}
+
+// SetStockReleasePending set whether items stock release of order
+// match the filter in orders collection pending
+func (r *MongoOrderRepository) SetStockReleasePending(ctx context.Context,
+	filter OrderFilter, pending bool) error {
+	return model.SetOrderStockReleasePending(ctx, r.Collection, filter.BSON(),
+		pending)
+}
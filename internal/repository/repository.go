@@ -0,0 +1,344 @@
+/*
+Package repository containing storage-agnostic order repository
+and its implementations
+*/
+package repository
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/reyhanfikridz/ecom-order-service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// ErrOrderNotFound returned when there's no order match the filter
+	ErrOrderNotFound = errors.New("order not found")
+
+	// ErrInvalidCursor returned when list cursor order not exist
+	ErrInvalidCursor = errors.New("cursor invalid")
+
+	// ErrNoDataUpdated returned when there's no order match the filter
+	// when updating order
+	ErrNoDataUpdated = model.ErrNoDataUpdated
+
+	// ErrNoDataDeleted returned when there's no order match the filter
+	// when deleting order
+	ErrNoDataDeleted = model.ErrNoDataDeleted
+
+	// ErrVersionConflict returned when order match the filter
+	// except its version, so the order changed since that version
+	ErrVersionConflict = errors.New("order version conflict")
+)
+
+// OrderRepository storage of orders
+type OrderRepository interface {
+	// Insert insert new order and return it with generated ID
+	// and order number
+	Insert(ctx context.Context, o model.Order) (model.Order, error)
+
+	// Get get one order match the filter,
+	// return ErrOrderNotFound if there's none
+	Get(ctx context.Context, filter OrderFilter) (model.Order, error)
+
+	// List get page of orders match the filter
+	// sorted and paginated by the options
+	List(ctx context.Context, filter OrderFilter,
+		opts ListOptions) (OrderPage, error)
+
+	// Update update non zero value fields of oUpdate in order match the filter,
+	// return ErrVersionConflict if the order match except its version
+	// or ErrNoDataUpdated if there's none
+	Update(ctx context.Context, filter OrderFilter, oUpdate model.Order) error
+
+	// Patch set fields (bson field name) of order match the filter
+	// to their value in oPatch, including zero value,
+	// return ErrVersionConflict if the order match except its version
+	// or ErrNoDataUpdated if there's none
+	Patch(ctx context.Context, filter OrderFilter, oPatch model.Order,
+		fields []string) error
+
+	// Delete mark order match the filter as deleted by actor,
+	// return ErrNoDataDeleted if there's none
+	Delete(ctx context.Context, filter OrderFilter,
+		deletedBy model.OrderActor) error
+
+	// Restore unmark deleted order match the filter,
+	// return ErrNoDataUpdated if there's none
+	Restore(ctx context.Context, filter OrderFilter) error
+
+	// Purge permanently delete deleted orders match the filter,
+	// return total orders purged
+	Purge(ctx context.Context, filter OrderFilter) (int64, error)
+
+	// SetStockReleasePending set whether items stock release of order
+	// match the filter pending, without changing the order version,
+	// return ErrNoDataUpdated if there's none
+	SetStockReleasePending(ctx context.Context, filter OrderFilter,
+		pending bool) error
+}
+
+// OrderFilter filter of orders, field with zero value is ignored
+//
+// deleted orders are ignored unless IncludeDeleted, DeletedOnly,
+// or DeletedBefore is set
+type OrderFilter struct {
+	OrderNumber   string
+	Status        string
+	BuyerID       int
+	ProductUserID int
+
+	// ReservedBefore match order with items stock reserved before this time
+	ReservedBefore time.Time
+
+	// StockReleasePending match order with items stock release pending
+	StockReleasePending bool
+
+	// IncludeDeleted match deleted and not deleted orders
+	IncludeDeleted bool
+
+	// DeletedOnly match only deleted orders
+	DeletedOnly bool
+
+	// DeletedBefore match order deleted before this time
+	DeletedBefore time.Time
+
+	// Version match order with this version
+	Version int
+}
+
+// BSON get filter as mongodb filter document
+func (f OrderFilter) BSON() bson.M {
+	filter := bson.M{}
+	if f.OrderNumber != "" {
+		filter["order_number"] = f.OrderNumber
+	}
+	if f.Status != "" {
+		filter["status"] = f.Status
+	}
+	if f.BuyerID != 0 {
+		filter["buyer_id"] = f.BuyerID
+	}
+	if f.ProductUserID != 0 {
+		filter["items.product_user_id"] = f.ProductUserID
+	}
+	if !f.ReservedBefore.IsZero() {
+		filter["reserved_at"] = bson.M{"$lt": f.ReservedBefore}
+	}
+	if f.StockReleasePending {
+		filter["stock_release_pending"] = true
+	}
+	if f.Version != 0 {
+		filter["version"] = f.Version
+	}
+
+	switch {
+	case !f.DeletedBefore.IsZero():
+		filter["deleted_at"] = bson.M{"$lt": f.DeletedBefore}
+	case f.DeletedOnly:
+		filter["deleted_at"] = bson.M{"$ne": nil}
+	case !f.IncludeDeleted:
+		filter["deleted_at"] = nil
+	}
+
+	return filter
+}
+
+// Match check if order match the filter
+func (f OrderFilter) Match(o model.Order) bool {
+	if f.OrderNumber != "" && f.OrderNumber != o.OrderNumber {
+		return false
+	}
+	if f.Status != "" && f.Status != o.Status {
+		return false
+	}
+	if f.BuyerID != 0 && f.BuyerID != o.BuyerID {
+		return false
+	}
+	if f.ProductUserID != 0 {
+		itemExist := false
+		for _, item := range o.Items {
+			if item.ProductUserID == f.ProductUserID {
+				itemExist = true
+				break
+			}
+		}
+		if !itemExist {
+			return false
+		}
+	}
+	if !f.ReservedBefore.IsZero() &&
+		(o.ReservedAt == nil || !o.ReservedAt.Before(f.ReservedBefore)) {
+		return false
+	}
+	if f.StockReleasePending && !o.StockReleasePending {
+		return false
+	}
+	if f.Version != 0 && f.Version != o.Version {
+		return false
+	}
+
+	switch {
+	case !f.DeletedBefore.IsZero():
+		if o.DeletedAt == nil || !o.DeletedAt.Before(f.DeletedBefore) {
+			return false
+		}
+	case f.DeletedOnly:
+		if o.DeletedAt == nil {
+			return false
+		}
+	case !f.IncludeDeleted:
+		if o.DeletedAt != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ListOptions sorting and pagination option for listing orders
+type ListOptions struct {
+	// Sort name of sort field, prefix with "-" for descending order,
+	// empty means sort by creation time
+	Sort string
+
+	// Limit max total orders in page, 0 means no limit
+	Limit int64
+
+	// Offset total orders skipped
+	Offset int64
+
+	// After ID of the last order in previous page,
+	// the page start right after that order
+	After primitive.ObjectID
+}
+
+// OrderPage page of orders
+type OrderPage struct {
+	Items      []model.Order `json:"items"`
+	Total      int64         `json:"total"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// orderSortField sortable order field
+type orderSortField struct {
+	// BSONKey field key in mongodb document
+	BSONKey string
+
+	// Compare compare the field of two order,
+	// return negative if a < b, positive if a > b, zero if equal
+	Compare func(a model.Order, b model.Order) int
+}
+
+// orderSortFields map of sort name to sortable order field
+var orderSortFields = map[string]orderSortField{
+	"created_at": {
+		BSONKey: "_id",
+		Compare: func(a model.Order, b model.Order) int {
+			return bytes.Compare(a.ID[:], b.ID[:])
+		},
+	},
+	"updated_at": {
+		BSONKey: "updated_at",
+		Compare: func(a model.Order, b model.Order) int {
+			return compareTime(a.UpdatedAt, b.UpdatedAt)
+		},
+	},
+	"total_price": {
+		BSONKey: "total_price",
+		Compare: func(a model.Order, b model.Order) int {
+			return compareInt(int64(a.TotalPrice), int64(b.TotalPrice))
+		},
+	},
+	"qty": {
+		BSONKey: "qty",
+		Compare: func(a model.Order, b model.Order) int {
+			return compareInt(int64(a.Qty), int64(b.Qty))
+		},
+	},
+	"status": {
+		BSONKey: "status",
+		Compare: func(a model.Order, b model.Order) int {
+			return strings.Compare(a.Status, b.Status)
+		},
+	},
+	"order_number": {
+		BSONKey: "order_number",
+		Compare: func(a model.Order, b model.Order) int {
+			return strings.Compare(a.OrderNumber, b.OrderNumber)
+		},
+	},
+}
+
+// IsSortValid check if sort is one of sortable order field,
+// with or without "-" prefix
+func IsSortValid(sort string) bool {
+	if sort == "" {
+		return true
+	}
+
+	_, ok := orderSortFields[strings.TrimPrefix(sort, "-")]
+	return ok
+}
+
+// getSortField get sort field and its direction from sort,
+// default to creation time ascending
+func getSortField(sort string) (orderSortField, bool) {
+	desc := strings.HasPrefix(sort, "-")
+
+	field, ok := orderSortFields[strings.TrimPrefix(sort, "-")]
+	if !ok {
+		field = orderSortFields["created_at"]
+	}
+
+	return field, desc
+}
+
+// compareOrders compare two order by sort field and its direction,
+// tie broken by order ID in the same direction
+//
+// return negative if a placed before b, positive if a placed after b
+func compareOrders(field orderSortField, desc bool,
+	a model.Order, b model.Order) int {
+	result := field.Compare(a, b)
+	if result == 0 {
+		result = bytes.Compare(a.ID[:], b.ID[:])
+	}
+
+	if desc {
+		return -result
+	}
+
+	return result
+}
+
+// compareInt compare two integer,
+// return negative if a < b, positive if a > b, zero if equal
+func compareInt(a int64, b int64) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+
+	return 0
+}
+
+// compareTime compare two time,
+// return negative if a before b, positive if a after b, zero if equal
+func compareTime(a time.Time, b time.Time) int {
+	if a.Before(b) {
+		return -1
+	}
+	if a.After(b) {
+		return 1
+	}
+
+	return 0
+}
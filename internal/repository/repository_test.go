@@ -0,0 +1,75 @@
+/*
+Package repository containing storage-agnostic order repository
+and its implementations
+*/
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// TestOrderFilterBSON test OrderFilter BSON
+func TestOrderFilterBSON(t *testing.T) {
+	reservedBefore := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	filter := OrderFilter{
+		OrderNumber:    "order number",
+		Status:         "in-cart",
+		BuyerID:        1,
+		ProductUserID:  10,
+		ReservedBefore: reservedBefore,
+		Version:        2,
+	}.BSON()
+
+	if len(filter) != 7 {
+		t.Errorf("Expected total filter key 7, but got %d", len(filter))
+	}
+	if filter["order_number"] != "order number" {
+		t.Errorf("Expected order_number 'order number', but got %v",
+			filter["order_number"])
+	}
+	if filter["status"] != "in-cart" {
+		t.Errorf("Expected status 'in-cart', but got %v", filter["status"])
+	}
+	if filter["buyer_id"] != 1 {
+		t.Errorf("Expected buyer_id 1, but got %v", filter["buyer_id"])
+	}
+	if filter["items.product_user_id"] != 10 {
+		t.Errorf("Expected items.product_user_id 10, but got %v",
+			filter["items.product_user_id"])
+	}
+	if filter["version"] != 2 {
+		t.Errorf("Expected version 2, but got %v", filter["version"])
+	}
+	reservedAt, ok := filter["reserved_at"].(bson.M)
+	if !ok || reservedAt["$lt"] != reservedBefore {
+		t.Errorf("Expected reserved_at before %v, but got %v",
+			reservedBefore, filter["reserved_at"])
+	}
+
+	if deletedAt, ok := filter["deleted_at"]; !ok || deletedAt != nil {
+		t.Errorf("Expected deleted_at nil, but got %v", filter["deleted_at"])
+	}
+
+	// test zero value filter ignored except deleted orders
+	filter = OrderFilter{}.BSON()
+	if len(filter) != 1 {
+		t.Errorf("Expected filter only deleted_at, but got %v", filter)
+	}
+
+	// test deleted orders filter
+	filter = OrderFilter{IncludeDeleted: true}.BSON()
+	if len(filter) != 0 {
+		t.Errorf("Expected empty filter, but got %v", filter)
+	}
+
+	deletedBefore := time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC)
+	filter = OrderFilter{DeletedBefore: deletedBefore}.BSON()
+	deletedAt, ok := filter["deleted_at"].(bson.M)
+	if !ok || deletedAt["$lt"] != deletedBefore {
+		t.Errorf("Expected deleted_at before %v, but got %v",
+			deletedBefore, filter["deleted_at"])
+	}
+}
@@ -6,25 +6,150 @@ This package cannot have import from another package except for config package
 package utils
 
 import (
-	"math/rand"
+	"crypto/rand"
+	"fmt"
+	"strings"
 	"time"
 )
 
-// GetRandomOrderNumber get random order number
-func GetRandomOrderNumber() string {
-	// change rand seed so the result is different everytime program running
-	rand.Seed(time.Now().UnixNano())
+// order number formats
+const (
+	OrderNumberFormatRandom   = "random"
+	OrderNumberFormatSortable = "sortable"
+	OrderNumberFormatPrefixed = "prefixed"
+)
+
+const (
+	// base62Chars characters of random order number
+	base62Chars = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+	// crockfordChars characters of sortable and prefixed order number,
+	// Crockford's base32 without ambiguous characters (I, L, O, U)
+	crockfordChars = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+)
+
+// OrderNumberGenerator generator of new order number
+//
+// generated order number is not guaranteed unique,
+// caller must retry when the order number already used
+type OrderNumberGenerator interface {
+	Generate() (string, error)
+}
+
+// NewOrderNumberGenerator get order number generator by format
+// (random, sortable, or prefixed), empty format means random,
+// prefix only used by prefixed format
+func NewOrderNumberGenerator(format string,
+	prefix string) (OrderNumberGenerator, error) {
+	switch format {
+	case "", OrderNumberFormatRandom:
+		return RandomOrderNumberGenerator{Length: 15}, nil
+	case OrderNumberFormatSortable:
+		return SortableOrderNumberGenerator{}, nil
+	case OrderNumberFormatPrefixed:
+		if prefix == "" {
+			prefix = "ORD"
+		}
+		return PrefixedOrderNumberGenerator{Prefix: prefix, Length: 6}, nil
+	}
+
+	return nil, fmt.Errorf("order number format '%s' invalid", format)
+}
+
+// RandomOrderNumberGenerator generate crypto-random alphanumeric order number
+type RandomOrderNumberGenerator struct {
+	Length int
+}
+
+// Generate generate new order number
+func (g RandomOrderNumberGenerator) Generate() (string, error) {
+	return getRandomString(base62Chars, g.Length)
+}
+
+// SortableOrderNumberGenerator generate ULID-like order number,
+// 10 characters of millisecond timestamp followed by 16 random characters,
+// so order number sorted by its creation time
+type SortableOrderNumberGenerator struct {
+	// Now get current time, default to time.Now
+	Now func() time.Time
+}
+
+// Generate generate new order number
+func (g SortableOrderNumberGenerator) Generate() (string, error) {
+	now := time.Now
+	if g.Now != nil {
+		now = g.Now
+	}
+
+	// encode timestamp, 5 bits each character
+	ms := uint64(now().UnixMilli())
+	timestamp := make([]byte, 10)
+	for i := len(timestamp) - 1; i >= 0; i-- {
+		timestamp[i] = crockfordChars[ms&31]
+		ms >>= 5
+	}
+
+	random, err := getRandomString(crockfordChars, 16)
+	if err != nil {
+		return "", err
+	}
+
+	return string(timestamp) + random, nil
+}
+
+// PrefixedOrderNumberGenerator generate human-friendly order number
+// with format PREFIX-YYYYMMDD-XXXXXX (date in UTC)
+type PrefixedOrderNumberGenerator struct {
+	Prefix string
+	Length int
+
+	// Now get current time, default to time.Now
+	Now func() time.Time
+}
+
+// Generate generate new order number
+func (g PrefixedOrderNumberGenerator) Generate() (string, error) {
+	now := time.Now
+	if g.Now != nil {
+		now = g.Now
+	}
+
+	random, err := getRandomString(crockfordChars, g.Length)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join([]string{
+		g.Prefix, now().UTC().Format("20060102"), random,
+	}, "-"), nil
+}
+
+// getRandomString get crypto-random string with length n from chars
+//
+// random bytes outside the biggest multiple of len(chars)
+// are skipped, so every character has the same probability
+func getRandomString(chars string, n int) (string, error) {
+	max := 256 - 256%len(chars)
+	result := make([]byte, 0, n)
+	buf := make([]byte, n)
 
-	// set order number length
-	n := 15
+	for len(result) < n {
+		_, err := rand.Read(buf)
+		if err != nil {
+			return "", err
+		}
 
-	// get random order number
-	base := []rune("0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+		for _, b := range buf {
+			if int(b) >= max {
+				continue
+			}
 
-	runeOfOrderNumber := make([]rune, n)
-	for i := range runeOfOrderNumber {
-		runeOfOrderNumber[i] = base[rand.Intn(len(base))]
+			result = append(result, chars[int(b)%len(chars)])
+			if len(result) == n {
+				break
+			}
+		}
 	}
 
-	return string(runeOfOrderNumber)
+	return string(result), nil
 }
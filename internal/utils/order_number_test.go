@@ -6,15 +6,126 @@ This package cannot have import from another package except for config package
 package utils
 
 import (
+	"regexp"
+	"sort"
 	"testing"
+	"time"
 )
 
-// TestGetRandomOrderNumber test GetRandomOrderNumber
-func TestGetRandomOrderNumber(t *testing.T) {
-	for i := 0; i < 1000; i++ {
-		orderNumber := GetRandomOrderNumber()
-		if len(orderNumber) != 15 {
-			t.Errorf("Expected orderNumber length 15, but got %d", len(orderNumber))
+// TestOrderNumberGenerator test all OrderNumberGenerator format
+func TestOrderNumberGenerator(t *testing.T) {
+	now := func() time.Time {
+		return time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)
+	}
+
+	// initialize testing table
+	testTable := []struct {
+		TestName        string
+		Generator       OrderNumberGenerator
+		ExpectedPattern string
+	}{
+		{
+			TestName:        "Test Random",
+			Generator:       RandomOrderNumberGenerator{Length: 15},
+			ExpectedPattern: `^[0-9a-zA-Z]{15}$`,
+		},
+		{
+			TestName:        "Test Sortable",
+			Generator:       SortableOrderNumberGenerator{Now: now},
+			ExpectedPattern: `^[0-9A-HJKMNP-TV-Z]{26}$`,
+		},
+		{
+			TestName: "Test Prefixed",
+			Generator: PrefixedOrderNumberGenerator{
+				Prefix: "ORD", Length: 6, Now: now,
+			},
+			ExpectedPattern: `^ORD-20261016-[0-9A-HJKMNP-TV-Z]{6}$`,
+		},
+	}
+
+	// test for each testing table
+	for _, test := range testTable {
+		pattern := regexp.MustCompile(test.ExpectedPattern)
+		orderNumbers := make(map[string]bool)
+		for i := 0; i < 1000; i++ {
+			orderNumber, err := test.Generator.Generate()
+			if err != nil {
+				t.Fatalf("[%s] Expected error nil, but got %s", test.TestName, err)
+			}
+
+			if !pattern.MatchString(orderNumber) {
+				t.Errorf("[%s] Expected order number match %s, but got %s",
+					test.TestName, test.ExpectedPattern, orderNumber)
+			}
+			orderNumbers[orderNumber] = true
+		}
+
+		// 1000 order numbers generated in the same time is most likely unique
+		if len(orderNumbers) < 999 {
+			t.Errorf("[%s] Expected unique order numbers, but got %d of 1000",
+				test.TestName, len(orderNumbers))
+		}
+	}
+}
+
+// TestSortableOrderNumberGenerator test order number
+// generated by SortableOrderNumberGenerator sorted by time
+func TestSortableOrderNumberGenerator(t *testing.T) {
+	start := time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)
+
+	orderNumbers := []string{}
+	for i := 0; i < 100; i++ {
+		createdAt := start.Add(time.Duration(i) * time.Millisecond)
+		g := SortableOrderNumberGenerator{
+			Now: func() time.Time { return createdAt },
+		}
+
+		orderNumber, err := g.Generate()
+		if err != nil {
+			t.Fatalf("Expected error nil, but got %s", err)
+		}
+		orderNumbers = append(orderNumbers, orderNumber)
+	}
+
+	if !sort.StringsAreSorted(orderNumbers) {
+		t.Errorf("Expected order numbers sorted by time, but got %v",
+			orderNumbers)
+	}
+}
+
+// TestNewOrderNumberGenerator test NewOrderNumberGenerator
+func TestNewOrderNumberGenerator(t *testing.T) {
+	// initialize testing table
+	testTable := []struct {
+		TestName      string
+		Format        string
+		ExpectedError bool
+	}{
+		{TestName: "Test Default", Format: ""},
+		{TestName: "Test Random", Format: "random"},
+		{TestName: "Test Sortable", Format: "sortable"},
+		{TestName: "Test Prefixed", Format: "prefixed"},
+		{TestName: "Test Invalid", Format: "uuid", ExpectedError: true},
+	}
+
+	// test for each testing table
+	for _, test := range testTable {
+		g, err := NewOrderNumberGenerator(test.Format, "")
+		if test.ExpectedError {
+			if err == nil {
+				t.Errorf("[%s] Expected error, but got nil", test.TestName)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("[%s] Expected error nil, but got %s", test.TestName, err)
+			continue
+		}
+		_, err = g.Generate()
+		if err != nil {
+			t.Errorf("[%s] Expected generate success, but got error %s",
+				test.TestName, err)
 		}
 	}
 }
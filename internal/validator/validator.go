@@ -4,39 +4,329 @@ Package validator containing any validator function
 package validator
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/reyhanfikridz/ecom-order-service/internal/model"
+	"github.com/reyhanfikridz/ecom-order-service/internal/money"
 )
 
-// IsOrderValid check if order data is valid
+// codes of field error, client can rely on these instead of the message
+const (
+	CodeRequired   = "required"
+	CodeInvalid    = "invalid"
+	CodeOutOfRange = "out_of_range"
+	CodeTooLong    = "too_long"
+	CodeTooMany    = "too_many"
+	CodeDuplicate  = "duplicate"
+	CodeMismatch   = "mismatch"
+)
+
+// limits of order field value
+const (
+	MaxBuyerFullNameLength       = 100
+	MaxBuyerAddressLength        = 500
+	MaxItems                     = 100
+	MaxItemQty                   = 1000
+	MaxProductSKULength          = 100
+	MaxProductNameLength         = 200
+	MaxProductDescriptionLength  = 5000
+	MaxProductUserFullNameLength = 100
+	MaxProductPrice              = 1000000000 * money.Unit
+	MaxProductWeight             = 10000
+	MaxProductImages             = 20
+	MaxProductImagePathLength    = 500
+)
+
+// FieldError error of invalid field value
+type FieldError struct {
+	Field   string
+	Code    string
+	Message string
+}
+
+// Error get field error message
+func (e FieldError) Error() string {
+	return e.Field + " " + e.Message
+}
+
+// Errors errors of every invalid field
+type Errors []FieldError
+
+// Error get all field error message
+func (errs Errors) Error() string {
+	messages := make([]string, 0, len(errs))
+	for _, e := range errs {
+		messages = append(messages, e.Error())
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// add add field error
+func (errs *Errors) add(field string, code string, message string) {
+	*errs = append(*errs, FieldError{Field: field, Code: code, Message: message})
+}
+
+// err get errors as error, nil if there's no field error
+func (errs Errors) err() error {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
+
+// ItemError prefix field of field error (or field errors) with items index,
+// other error returned as it is
+func ItemError(i int, err error) error {
+	prefix := fmt.Sprintf("items[%d].", i)
+
+	var fieldErrs Errors
+	if errors.As(err, &fieldErrs) {
+		result := make(Errors, 0, len(fieldErrs))
+		for _, e := range fieldErrs {
+			e.Field = prefix + e.Field
+			result = append(result, e)
+		}
+		return result
+	}
+
+	var fieldErr FieldError
+	if errors.As(err, &fieldErr) {
+		fieldErr.Field = prefix + fieldErr.Field
+		return fieldErr
+	}
+
+	return err
+}
+
+// IsOrderValid check if new order data is valid,
+// every invalid field returned at once
 //
-// return error nil if it's valid
+// return error nil if it's valid, otherwise Errors
 func IsOrderValid(o model.Order) error {
+	var errs Errors
 	if strings.TrimSpace(o.Status) == "" {
-		return fmt.Errorf("status empty/not found")
+		errs.add("status", CodeRequired, "empty/not found")
+	}
+	if len(o.Items) == 0 {
+		errs.add("items", CodeRequired, "empty/not found")
 	}
+	checkOrder(&errs, o)
+
+	return errs.err()
+}
+
+// IsOrderUpdateValid check if order update data is valid,
+// only field with non zero value checked because the others not updated,
+// every invalid field returned at once
+//
+// return error nil if it's valid, otherwise Errors
+func IsOrderUpdateValid(o model.Order) error {
+	var errs Errors
+	checkOrder(&errs, o)
+
+	return errs.err()
+}
+
+// IsOrderItemValid check if order item data is valid,
+// every invalid field returned at once
+//
+// return error nil if it's valid, otherwise Errors
+func IsOrderItemValid(item model.OrderItem) error {
+	var errs Errors
+	checkOrderItem(&errs, "", item)
 
-	if o.Qty == 0 {
-		return fmt.Errorf("qty empty/not found")
+	return errs.err()
+}
+
+// IsOrderRequestValid check if new order data requested by client,
+// before its items filled with product data, is valid,
+// every invalid field returned at once
+//
+// return error nil if it's valid, otherwise Errors
+func IsOrderRequestValid(o model.Order) error {
+	var errs Errors
+	if strings.TrimSpace(o.Status) == "" {
+		errs.add("status", CodeRequired, "empty/not found")
+	}
+	if len(o.Items) == 0 {
+		errs.add("items", CodeRequired, "empty/not found")
 	}
+	checkOrderInfo(&errs, o)
+	checkOrderItems(&errs, o.Items, checkOrderItemRequest)
 
-	if o.TotalPrice == 0 {
-		return fmt.Errorf("total_price empty/not found")
+	return errs.err()
+}
+
+// IsOrderItemsRequestValid check if order items requested by client,
+// before filled with product data, is valid, i.e. total items,
+// product ID and qty of each item, and duplicated product,
+// every invalid field returned at once
+//
+// return error nil if it's valid, otherwise Errors
+func IsOrderItemsRequestValid(items []model.OrderItem) error {
+	var errs Errors
+	checkOrderItems(&errs, items, checkOrderItemRequest)
+
+	return errs.err()
+}
+
+// checkOrder add error of every invalid order field with non zero value
+func checkOrder(errs *Errors, o model.Order) {
+	checkOrderInfo(errs, o)
+	checkOrderItems(errs, o.Items, checkOrderItem)
+
+	qty, totalPrice := 0, money.Amount(0)
+	for _, item := range o.Items {
+		qty += item.Qty
+		totalPrice += item.ProductPrice.Mul(item.Qty)
+	}
+
+	// total only checked if set, otherwise it's calculated from the items
+	if o.Qty != 0 && o.Qty != qty {
+		errs.add("qty", CodeMismatch,
+			fmt.Sprintf("%d must be the same as total items qty %d", o.Qty, qty))
+	}
+	if o.TotalPrice != 0 && o.TotalPrice != totalPrice {
+		errs.add("total_price", CodeMismatch,
+			fmt.Sprintf("%v must be the same as total items subtotal %v",
+				o.TotalPrice, totalPrice))
 	}
+}
+
+// checkOrderInfo add error of every invalid order field with non zero value
+// other than its items and total
+func checkOrderInfo(errs *Errors, o model.Order) {
+	if strings.TrimSpace(o.Status) != "" && !model.IsOrderStatusValid(o.Status) {
+		errs.add("status", CodeInvalid, fmt.Sprintf("'%s' invalid", o.Status))
+	}
+	if o.Currency != "" && !money.IsCurrencyValid(o.Currency) {
+		errs.add("currency", CodeInvalid,
+			fmt.Sprintf("'%s' invalid, must be ISO 4217 code", o.Currency))
+	}
+	if o.BuyerID < 0 {
+		errs.add("buyer_id", CodeOutOfRange, "must be positive")
+	}
+	checkLength(errs, "buyer_full_name", o.BuyerFullName,
+		MaxBuyerFullNameLength)
+	checkLength(errs, "buyer_address", o.BuyerAddress, MaxBuyerAddressLength)
+}
 
-	if strings.TrimSpace(o.ProductName) == "" {
-		return fmt.Errorf("product_name empty/not found")
+// checkOrderItems add error of invalid total items, every invalid item
+// field checked by checkItem, and duplicated product
+func checkOrderItems(errs *Errors, items []model.OrderItem,
+	checkItem func(*Errors, string, model.OrderItem)) {
+	if len(items) > MaxItems {
+		errs.add("items", CodeTooMany,
+			fmt.Sprintf("must not be more than %d items", MaxItems))
 	}
 
-	if o.ProductPrice == 0 {
-		return fmt.Errorf("product_price empty/not found")
+	productExist := map[int]bool{}
+	for i, item := range items {
+		prefix := fmt.Sprintf("items[%d].", i)
+		checkItem(errs, prefix, item)
+
+		if item.ProductID != 0 && productExist[item.ProductID] {
+			errs.add(prefix+"product_id", CodeDuplicate,
+				fmt.Sprintf("%d duplicated", item.ProductID))
+		}
+		productExist[item.ProductID] = true
 	}
+}
+
+// checkOrderItem add error of every invalid order item field,
+// field name prefixed with prefix
+func checkOrderItem(errs *Errors, prefix string, item model.OrderItem) {
+	checkOrderItemRequest(errs, prefix, item)
+	checkOrderItemProduct(errs, prefix, item)
+}
 
-	if o.ProductWeight == 0 {
-		return fmt.Errorf("product_weight empty/not found")
+// checkOrderItemRequest add error of invalid order item field
+// requested by client, i.e. product ID and qty
+func checkOrderItemRequest(errs *Errors, prefix string, item model.OrderItem) {
+	switch {
+	case item.ProductID == 0:
+		errs.add(prefix+"product_id", CodeRequired, "empty/not found")
+	case item.ProductID < 0:
+		errs.add(prefix+"product_id", CodeOutOfRange, "must be positive")
 	}
 
-	return nil
+	switch {
+	case item.Qty == 0:
+		errs.add(prefix+"qty", CodeRequired, "empty/not found")
+	case item.Qty < 0 || item.Qty > MaxItemQty:
+		errs.add(prefix+"qty", CodeOutOfRange,
+			fmt.Sprintf("must be between 1 and %d", MaxItemQty))
+	}
+}
+
+// checkOrderItemProduct add error of every invalid order item field
+// filled with product data
+func checkOrderItemProduct(errs *Errors, prefix string,
+	item model.OrderItem) {
+	if strings.TrimSpace(item.ProductName) == "" {
+		errs.add(prefix+"product_name", CodeRequired, "empty/not found")
+	}
+	checkLength(errs, prefix+"product_name", item.ProductName,
+		MaxProductNameLength)
+	checkLength(errs, prefix+"product_sku", item.ProductSKU,
+		MaxProductSKULength)
+	checkLength(errs, prefix+"product_description", item.ProductDescription,
+		MaxProductDescriptionLength)
+	checkLength(errs, prefix+"product_user_full_name",
+		item.ProductUserFullName, MaxProductUserFullNameLength)
+
+	switch {
+	case item.ProductPrice == 0:
+		errs.add(prefix+"product_price", CodeRequired, "empty/not found")
+	case item.ProductPrice < 0 || item.ProductPrice > MaxProductPrice:
+		errs.add(prefix+"product_price", CodeOutOfRange,
+			fmt.Sprintf("must be more than 0 and not more than %s",
+				MaxProductPrice))
+	}
+
+	switch {
+	case item.ProductWeight == 0:
+		errs.add(prefix+"product_weight", CodeRequired, "empty/not found")
+	case item.ProductWeight < 0 || item.ProductWeight > MaxProductWeight:
+		errs.add(prefix+"product_weight", CodeOutOfRange,
+			fmt.Sprintf("must be more than 0 and not more than %d",
+				MaxProductWeight))
+	}
+
+	if item.ProductStock < 0 {
+		errs.add(prefix+"product_stock", CodeOutOfRange, "must not be negative")
+	}
+	if item.ProductUserID < 0 {
+		errs.add(prefix+"product_user_id", CodeOutOfRange,
+			"must not be negative")
+	}
+
+	if len(item.ProductImagesPath) > MaxProductImages {
+		errs.add(prefix+"product_images_path", CodeTooMany,
+			fmt.Sprintf("must not be more than %d images", MaxProductImages))
+	}
+	for i, path := range item.ProductImagesPath {
+		checkLength(errs, fmt.Sprintf("%sproduct_images_path[%d]", prefix, i),
+			path, MaxProductImagePathLength)
+	}
+
+	// subtotal only checked if set, otherwise it's calculated
+	subtotal := item.ProductPrice.Mul(item.Qty)
+	if item.Subtotal != 0 && item.Subtotal != subtotal {
+		errs.add(prefix+"subtotal", CodeMismatch,
+			fmt.Sprintf("%v must be the same as qty x product_price %v",
+				item.Subtotal, subtotal))
+	}
+}
+
+// checkLength add error if value longer than max characters
+func checkLength(errs *Errors, field string, value string, max int) {
+	if len([]rune(value)) > max {
+		errs.add(field, CodeTooLong,
+			fmt.Sprintf("must not be longer than %d characters", max))
+	}
 }
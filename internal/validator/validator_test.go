@@ -4,10 +4,14 @@ Package validator containing any validator function
 package validator
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/reyhanfikridz/ecom-order-service/internal/model"
+	"github.com/reyhanfikridz/ecom-order-service/internal/money"
 )
 
 // TestIsOrderInfoValid test IsOrderInfoValid
@@ -21,86 +25,145 @@ func TestIsOrderInfoValid(t *testing.T) {
 		{
 			TestName: "Test Form Complete",
 			Order: model.Order{
-				Status:        "in-cart",
-				Qty:           2,
-				TotalPrice:    2000000.50,
-				ProductName:   "Product 1",
-				ProductPrice:  1000000.50,
-				ProductWeight: 1.5,
+				Status: "in-cart",
+				Items: []model.OrderItem{
+					{
+						ProductID:     1,
+						Qty:           2,
+						ProductName:   "Product 1",
+						ProductPrice:  money.MustParse("1000000.50"),
+						ProductWeight: 1.5,
+					},
+				},
 			},
 			ExpectedResult: nil,
 		},
 		{
 			TestName: "Test Form Incomplete 1",
 			Order: model.Order{
-				Status:        "",
-				Qty:           2,
-				TotalPrice:    2000000.50,
-				ProductName:   "Product 1",
-				ProductPrice:  1000000.50,
-				ProductWeight: 1.5,
+				Status: "",
+				Items: []model.OrderItem{
+					{
+						ProductID:     1,
+						Qty:           2,
+						ProductName:   "Product 1",
+						ProductPrice:  money.MustParse("1000000.50"),
+						ProductWeight: 1.5,
+					},
+				},
 			},
 			ExpectedResult: fmt.Errorf("status empty/not found"),
 		},
+		{
+			TestName: "Test Form Invalid Status",
+			Order: model.Order{
+				Status: "waiting-for-payment",
+				Items: []model.OrderItem{
+					{
+						ProductID:     1,
+						Qty:           2,
+						ProductName:   "Product 1",
+						ProductPrice:  money.MustParse("1000000.50"),
+						ProductWeight: 1.5,
+					},
+				},
+			},
+			ExpectedResult: fmt.Errorf("status 'waiting-for-payment' invalid"),
+		},
 		{
 			TestName: "Test Form Incomplete 2",
 			Order: model.Order{
-				Status:        "in-cart",
-				Qty:           0,
-				TotalPrice:    2000000.50,
-				ProductName:   "Product 1",
-				ProductPrice:  1000000.50,
-				ProductWeight: 1.5,
+				Status: "in-cart",
+				Items:  []model.OrderItem{},
 			},
-			ExpectedResult: fmt.Errorf("qty empty/not found"),
+			ExpectedResult: fmt.Errorf("items empty/not found"),
 		},
 		{
 			TestName: "Test Form Incomplete 3",
 			Order: model.Order{
-				Status:        "in-cart",
-				Qty:           2,
-				TotalPrice:    0,
-				ProductName:   "Product 1",
-				ProductPrice:  1000000.50,
-				ProductWeight: 1.5,
+				Status: "in-cart",
+				Items: []model.OrderItem{
+					{
+						ProductID:     1,
+						Qty:           2,
+						ProductName:   "Product 1",
+						ProductPrice:  money.MustParse("1000000.50"),
+						ProductWeight: 1.5,
+					},
+					{
+						ProductID:     2,
+						Qty:           0,
+						ProductName:   "Product 2",
+						ProductPrice:  money.MustParse("1000000.50"),
+						ProductWeight: 1.5,
+					},
+				},
 			},
-			ExpectedResult: fmt.Errorf("total_price empty/not found"),
+			ExpectedResult: fmt.Errorf("items[1].qty empty/not found"),
 		},
 		{
 			TestName: "Test Form Incomplete 4",
 			Order: model.Order{
-				Status:        "in-cart",
-				Qty:           2,
-				TotalPrice:    2000000.50,
-				ProductName:   "",
-				ProductPrice:  1000000.50,
-				ProductWeight: 1.5,
+				Status: "in-cart",
+				Items: []model.OrderItem{
+					{
+						ProductID:     1,
+						Qty:           2,
+						ProductName:   "",
+						ProductPrice:  money.MustParse("1000000.50"),
+						ProductWeight: 1.5,
+					},
+				},
 			},
-			ExpectedResult: fmt.Errorf("product_name empty/not found"),
+			ExpectedResult: fmt.Errorf("items[0].product_name empty/not found"),
 		},
 		{
 			TestName: "Test Form Incomplete 5",
 			Order: model.Order{
-				Status:        "in-cart",
-				Qty:           2,
-				TotalPrice:    2000000.50,
-				ProductName:   "Product 1",
-				ProductPrice:  0,
-				ProductWeight: 1.5,
+				Status: "in-cart",
+				Items: []model.OrderItem{
+					{
+						ProductID:     1,
+						Qty:           2,
+						ProductName:   "Product 1",
+						ProductPrice:  money.MustParse("0"),
+						ProductWeight: 1.5,
+					},
+				},
 			},
-			ExpectedResult: fmt.Errorf("product_price empty/not found"),
+			ExpectedResult: fmt.Errorf("items[0].product_price empty/not found"),
 		},
 		{
 			TestName: "Test Form Incomplete 6",
 			Order: model.Order{
-				Status:        "in-cart",
-				Qty:           2,
-				TotalPrice:    2000000.50,
-				ProductName:   "Product 1",
-				ProductPrice:  1000000.50,
-				ProductWeight: 0,
+				Status: "in-cart",
+				Items: []model.OrderItem{
+					{
+						ProductID:     1,
+						Qty:           2,
+						ProductName:   "Product 1",
+						ProductPrice:  money.MustParse("1000000.50"),
+						ProductWeight: 0,
+					},
+				},
+			},
+			ExpectedResult: fmt.Errorf("items[0].product_weight empty/not found"),
+		},
+		{
+			TestName: "Test Form Incomplete 7",
+			Order: model.Order{
+				Status: "in-cart",
+				Items: []model.OrderItem{
+					{
+						ProductID:     0,
+						Qty:           2,
+						ProductName:   "Product 1",
+						ProductPrice:  money.MustParse("1000000.50"),
+						ProductWeight: 1.5,
+					},
+				},
 			},
-			ExpectedResult: fmt.Errorf("product_weight empty/not found"),
+			ExpectedResult: fmt.Errorf("items[0].product_id empty/not found"),
 		},
 	}
 
@@ -119,3 +182,242 @@ func TestIsOrderInfoValid(t *testing.T) {
 		}
 	}
 }
+
+// TestIsOrderValidErrors test IsOrderValid and IsOrderUpdateValid
+// return every invalid field
+func TestIsOrderValidErrors(t *testing.T) {
+	validItem := model.OrderItem{
+		ProductID:     1,
+		Qty:           2,
+		ProductName:   "Product 1",
+		ProductPrice:  money.MustParse("1000000.50"),
+		ProductWeight: 1.5,
+	}
+
+	// initialize testing table
+	testTable := []struct {
+		TestName       string
+		Order          model.Order
+		Update         bool
+		ExpectedErrors Errors
+	}{
+		{
+			TestName: "Test Create Multiple Fields Invalid",
+			Order: model.Order{
+				Status:       "unknown",
+				BuyerAddress: strings.Repeat("a", MaxBuyerAddressLength+1),
+				Items: []model.OrderItem{
+					{
+						ProductID:         1,
+						Qty:               -1,
+						ProductName:       "Product 1",
+						ProductPrice:      money.MustParse("-10"),
+						ProductWeight:     MaxProductWeight + 1,
+						ProductImagesPath: []string{strings.Repeat("a", 501)},
+					},
+					validItem,
+				},
+			},
+			ExpectedErrors: Errors{
+				{Field: "status", Code: CodeInvalid,
+					Message: "'unknown' invalid"},
+				{Field: "buyer_address", Code: CodeTooLong,
+					Message: "must not be longer than 500 characters"},
+				{Field: "items[0].qty", Code: CodeOutOfRange,
+					Message: "must be between 1 and 1000"},
+				{Field: "items[0].product_price", Code: CodeOutOfRange,
+					Message: "must be more than 0 and not more than 1000000000.00"},
+				{Field: "items[0].product_weight", Code: CodeOutOfRange,
+					Message: "must be more than 0 and not more than 10000"},
+				{Field: "items[0].product_images_path[0]", Code: CodeTooLong,
+					Message: "must not be longer than 500 characters"},
+				{Field: "items[1].product_id", Code: CodeDuplicate,
+					Message: "1 duplicated"},
+			},
+		},
+		{
+			TestName: "Test Create Required Fields",
+			Order:    model.Order{},
+			ExpectedErrors: Errors{
+				{Field: "status", Code: CodeRequired, Message: "empty/not found"},
+				{Field: "items", Code: CodeRequired, Message: "empty/not found"},
+			},
+		},
+		{
+			TestName: "Test Create Total Mismatch",
+			Order: model.Order{
+				Status:     "in-cart",
+				Items:      []model.OrderItem{validItem},
+				Qty:        3,
+				TotalPrice: money.MustParse("2000001"),
+			},
+			ExpectedErrors: Errors{
+				{Field: "qty", Code: CodeMismatch,
+					Message: "3 must be the same as total items qty 2"},
+			},
+		},
+		{
+			TestName: "Test Create Subtotal Mismatch",
+			Order: model.Order{
+				Status: "in-cart",
+				Items: []model.OrderItem{
+					{
+						ProductID:     1,
+						Qty:           2,
+						ProductName:   "Product 1",
+						ProductPrice:  money.MustParse("10"),
+						ProductWeight: 1.5,
+						Subtotal:      money.MustParse("25"),
+					},
+				},
+			},
+			ExpectedErrors: Errors{
+				{Field: "items[0].subtotal", Code: CodeMismatch,
+					Message: "25.00 must be the same as qty x product_price 20.00"},
+			},
+		},
+		{
+			TestName: "Test Update Only Non Zero Fields",
+			Order:    model.Order{BuyerFullName: "Buyer 1"},
+			Update:   true,
+		},
+		{
+			TestName: "Test Update Fields Invalid",
+			Order: model.Order{
+				Status:        "unknown",
+				BuyerID:       -1,
+				BuyerFullName: strings.Repeat("a", MaxBuyerFullNameLength+1),
+			},
+			Update: true,
+			ExpectedErrors: Errors{
+				{Field: "status", Code: CodeInvalid,
+					Message: "'unknown' invalid"},
+				{Field: "buyer_id", Code: CodeOutOfRange,
+					Message: "must be positive"},
+				{Field: "buyer_full_name", Code: CodeTooLong,
+					Message: "must not be longer than 100 characters"},
+			},
+		},
+	}
+
+	// loop test in test table
+	for _, test := range testTable {
+		var err error
+		if test.Update {
+			err = IsOrderUpdateValid(test.Order)
+		} else {
+			err = IsOrderValid(test.Order)
+		}
+
+		if test.ExpectedErrors == nil {
+			if err != nil {
+				t.Errorf("[%s] Expected order valid, but got invalid => %s",
+					test.TestName, err)
+			}
+			continue
+		}
+
+		var errs Errors
+		if !errors.As(err, &errs) {
+			t.Errorf("[%s] Expected field errors, but got %v",
+				test.TestName, err)
+			continue
+		}
+		if !reflect.DeepEqual(errs, test.ExpectedErrors) {
+			t.Errorf("[%s] Expected field errors %v, but got %v",
+				test.TestName, test.ExpectedErrors, errs)
+		}
+	}
+}
+
+// TestIsOrderRequestValid test IsOrderRequestValid and IsOrderItemsRequestValid
+func TestIsOrderRequestValid(t *testing.T) {
+	// initialize testing table
+	testTable := []struct {
+		TestName       string
+		Order          model.Order
+		ItemsOnly      bool
+		ExpectedErrors Errors
+	}{
+		{
+			TestName: "Test Request Without Product Data",
+			Order: model.Order{
+				Status: "in-cart",
+				Items:  []model.OrderItem{{ProductID: 1, Qty: 1}},
+			},
+		},
+		{
+			TestName: "Test Request Fields Invalid",
+			Order: model.Order{
+				BuyerFullName: strings.Repeat("a", MaxBuyerFullNameLength+1),
+				Items: []model.OrderItem{
+					{ProductID: 1, Qty: 0},
+					{ProductID: 1, Qty: 1},
+				},
+			},
+			ExpectedErrors: Errors{
+				{Field: "status", Code: CodeRequired,
+					Message: "empty/not found"},
+				{Field: "buyer_full_name", Code: CodeTooLong,
+					Message: "must not be longer than 100 characters"},
+				{Field: "items[0].qty", Code: CodeRequired,
+					Message: "empty/not found"},
+				{Field: "items[1].product_id", Code: CodeDuplicate,
+					Message: "1 duplicated"},
+			},
+		},
+		{
+			TestName:  "Test Request Items Empty",
+			Order:     model.Order{},
+			ItemsOnly: true,
+		},
+		{
+			TestName: "Test Request Items Invalid",
+			Order: model.Order{
+				Items: []model.OrderItem{
+					{Qty: 1},
+					{ProductID: 2, Qty: MaxItemQty + 1},
+					{ProductID: 2, Qty: 1},
+				},
+			},
+			ItemsOnly: true,
+			ExpectedErrors: Errors{
+				{Field: "items[0].product_id", Code: CodeRequired,
+					Message: "empty/not found"},
+				{Field: "items[1].qty", Code: CodeOutOfRange,
+					Message: "must be between 1 and 1000"},
+				{Field: "items[2].product_id", Code: CodeDuplicate,
+					Message: "2 duplicated"},
+			},
+		},
+	}
+
+	// loop test in test table
+	for _, test := range testTable {
+		var err error
+		if test.ItemsOnly {
+			err = IsOrderItemsRequestValid(test.Order.Items)
+		} else {
+			err = IsOrderRequestValid(test.Order)
+		}
+
+		if test.ExpectedErrors == nil {
+			if err != nil {
+				t.Errorf("[%s] Expected order valid, but got invalid => %s",
+					test.TestName, err)
+			}
+			continue
+		}
+
+		var errs Errors
+		if !errors.As(err, &errs) {
+			t.Errorf("[%s] Expected field errors, but got %v",
+				test.TestName, err)
+			continue
+		}
+		if !reflect.DeepEqual(errs, test.ExpectedErrors) {
+			t.Errorf("[%s] Expected field errors %v, but got %v",
+				test.TestName, test.ExpectedErrors, errs)
+		}
+	}
+}
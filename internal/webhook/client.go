@@ -0,0 +1,76 @@
+/*
+Package webhook containing seller webhook subscriptions
+and signed delivery of order events to them
+*/
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// ErrAddressNotAllowed returned when webhook URL resolved to address
+// not allowed to be delivered to, e.g. loopback or private network address
+var ErrAddressNotAllowed = errors.New("webhook address not allowed")
+
+// sharedAddressSpace carrier-grade NAT address range (RFC 6598),
+// not routable on the internet
+var sharedAddressSpace = &net.IPNet{
+	IP:   net.IPv4(100, 64, 0, 0),
+	Mask: net.CIDRMask(10, 32),
+}
+
+// NewClient create HTTP client of webhook delivery with the timeout
+//
+// the client only connect to public address, so seller can't make
+// the service send request to its internal network, and redirect
+// not followed, so 3xx response is a failed delivery
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: checkDialAddress,
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// checkDialAddress check address about to be connected,
+// after host name resolved, is public address
+func checkDialAddress(network string, address string,
+	c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !isPublicIP(ip) {
+		return fmt.Errorf("%w => %s", ErrAddressNotAllowed, host)
+	}
+
+	return nil
+}
+
+// isPublicIP check if ip is not loopback, private, link-local,
+// unspecified, or multicast address
+func isPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() &&
+		!ip.IsUnspecified() && !sharedAddressSpace.Contains(ip)
+}
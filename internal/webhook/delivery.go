@@ -0,0 +1,377 @@
+/*
+Package webhook containing seller webhook subscriptions
+and signed delivery of order events to them
+*/
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/reyhanfikridz/ecom-order-service/internal/model"
+)
+
+const (
+	// defaultMaxAttempts default max attempts of delivery
+	// before it's moved to dead letters
+	defaultMaxAttempts = 8
+
+	// defaultBaseBackoff default wait time before the second attempt,
+	// doubled for each next attempt
+	defaultBaseBackoff = 30 * time.Second
+
+	// defaultMaxBackoff default max wait time between attempts
+	defaultMaxBackoff = time.Hour
+
+	// defaultTimeout default time limit of one attempt
+	defaultTimeout = 10 * time.Second
+
+	// defaultLockTimeout default time claimed delivery locked
+	// for one deliverer, longer than defaultTimeout
+	defaultLockTimeout = time.Minute
+
+	// defaultBatchSize default max total deliveries attempted at once
+	defaultBatchSize = 100
+
+	// defaultWorkers default max total deliveries attempted concurrently
+	defaultWorkers = 10
+)
+
+// Dispatcher publisher of order events creating delivery of the event
+// for each subscription of the sellers of the order items
+type Dispatcher struct {
+	Repository Repository
+}
+
+// NewDispatcher create dispatcher storing deliveries in repository
+func NewDispatcher(r Repository) *Dispatcher {
+	return &Dispatcher{Repository: r}
+}
+
+// Publish create pending delivery of event for each subscription
+// of the order sellers subscribed to the event type,
+// payload of the delivery only contain the seller items
+//
+// event already delivered to subscription is ignored,
+// so the same event can be published more than once
+func (d *Dispatcher) Publish(ctx context.Context, e model.OrderEvent) error {
+	// get sellers of the order items
+	sellerIDs := []int{}
+	sellerExist := map[int]bool{}
+	for _, item := range e.Order.Items {
+		if item.ProductUserID != 0 && !sellerExist[item.ProductUserID] {
+			sellerIDs = append(sellerIDs, item.ProductUserID)
+			sellerExist[item.ProductUserID] = true
+		}
+	}
+
+	now := time.Now().UTC()
+	for _, sellerID := range sellerIDs {
+		subscriptions, err := d.Repository.ListSubscriptions(ctx, sellerID)
+		if err != nil {
+			return err
+		}
+		if len(subscriptions) == 0 {
+			continue
+		}
+
+		payload, err := json.Marshal(getSellerEvent(e, sellerID))
+		if err != nil {
+			return err
+		}
+
+		for _, s := range subscriptions {
+			if !s.IsSubscribed(e.Type) {
+				continue
+			}
+
+			_, err = d.Repository.InsertDelivery(ctx, Delivery{
+				SubscriptionID: s.ID,
+				EventID:        e.ID,
+				EventType:      e.Type,
+				OrderNumber:    e.OrderNumber,
+				Payload:        payload,
+				Status:         DeliveryStatusPending,
+				Attempts:       []Attempt{},
+				NextAttemptAt:  now,
+				CreatedAt:      now,
+			})
+			if err != nil && err != ErrDeliveryExists {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// getSellerEvent get event with order only containing the seller items,
+// its qty and total price of the seller items, and without buyer
+// personal data, so seller only get their part of the order
+func getSellerEvent(e model.OrderEvent, sellerID int) model.OrderEvent {
+	items := []model.OrderItem{}
+	for _, item := range e.Order.Items {
+		if item.ProductUserID == sellerID {
+			items = append(items, item)
+		}
+	}
+
+	e.Order.Items = items
+	e.Order.CalculateTotal()
+	e.Order.BuyerFullName = ""
+	e.Order.BuyerAddress = ""
+	e.Order.DeletedBy = nil
+
+	return e
+}
+
+// RetryDelivery move dead delivery back to pending in repository,
+// so it's attempted again by deliverer with the next deliveries
+func RetryDelivery(ctx context.Context, r Repository,
+	d Delivery) (Delivery, error) {
+	d.Status = DeliveryStatusPending
+	d.Attempts = []Attempt{}
+	d.NextAttemptAt = time.Now().UTC()
+
+	return d, r.UpdateDelivery(ctx, d)
+}
+
+// Deliverer sender of pending deliveries to subscription URL
+//
+// failed delivery retried with exponential backoff,
+// then moved to dead letters after max attempts
+//
+// each delivery claimed before attempted, so deliverers of many instances
+// sharing the repository don't send the same delivery
+//
+// Client must only connect to public address, as client from NewClient
+type Deliverer struct {
+	Repository Repository
+	Client     *http.Client
+
+	// MaxAttempts max attempts of delivery before moved to dead letters
+	MaxAttempts int
+
+	// BaseBackoff wait time before the second attempt,
+	// doubled for each next attempt up to MaxBackoff
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+
+	// BatchSize max total deliveries attempted at once
+	BatchSize int64
+
+	// Workers max total deliveries attempted concurrently
+	Workers int
+
+	// LockTimeout time claimed delivery locked for the deliverer,
+	// must be longer than Client timeout, after that the delivery
+	// can be claimed again in case the deliverer stopped
+	LockTimeout time.Duration
+
+	// Now get current time, default to time.Now
+	Now func() time.Time
+}
+
+// NewDeliverer create deliverer of deliveries in repository
+// with default retry policy
+func NewDeliverer(r Repository) *Deliverer {
+	return &Deliverer{
+		Repository:  r,
+		Client:      NewClient(defaultTimeout),
+		MaxAttempts: defaultMaxAttempts,
+		BaseBackoff: defaultBaseBackoff,
+		MaxBackoff:  defaultMaxBackoff,
+		BatchSize:   defaultBatchSize,
+		Workers:     defaultWorkers,
+		LockTimeout: defaultLockTimeout,
+	}
+}
+
+// DeliverDue claim and attempt pending deliveries which next attempt time
+// passed, max BatchSize deliveries by at most Workers deliveries at once,
+// return total deliveries succeeded
+//
+// every claimed delivery attempted even if one of them failed to be saved,
+// the first error is returned
+func (d *Deliverer) DeliverDue(ctx context.Context) (int, error) {
+	workers := d.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		total  int
+		result error
+	)
+	sem := make(chan struct{}, workers)
+	for i := int64(0); d.BatchSize <= 0 || i < d.BatchSize; i++ {
+		// claim delivery only when there's free worker,
+		// so its lock doesn't expire while waiting
+		sem <- struct{}{}
+		now := d.now()
+		delivery, err := d.Repository.ClaimDueDelivery(ctx, now,
+			now.Add(d.LockTimeout))
+		if err != nil {
+			<-sem
+			if err != ErrDeliveryNotFound {
+				mu.Lock()
+				if result == nil {
+					result = err
+				}
+				mu.Unlock()
+			}
+			break
+		}
+
+		wg.Add(1)
+		go func(delivery Delivery) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			delivery, err := d.Deliver(ctx, delivery)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil && result == nil {
+				result = err
+			}
+			if err == nil && delivery.Status == DeliveryStatusSucceeded {
+				total++
+			}
+		}(delivery)
+	}
+	wg.Wait()
+
+	return total, result
+}
+
+// Deliver attempt delivery claimed by ClaimDueDelivery once
+// and save the result, which release the claim
+//
+// the claim is also released if the delivery couldn't be attempted,
+// so it's attempted again without waiting for the lock to expire
+func (d *Deliverer) Deliver(ctx context.Context,
+	delivery Delivery) (Delivery, error) {
+	now := d.now()
+	attempt := Attempt{At: now}
+	delivery.LockedUntil = time.Time{}
+
+	// send delivery to subscription URL
+	s, err := d.Repository.GetSubscription(ctx, delivery.SubscriptionID)
+	if err == ErrSubscriptionNotFound {
+		// delivery can't be retried after subscription deleted
+		attempt.Error = err.Error()
+		delivery.Attempts = append(delivery.Attempts, attempt)
+		delivery.Status = DeliveryStatusDead
+
+		return delivery, d.Repository.UpdateDelivery(ctx, delivery)
+	}
+	if err != nil {
+		delivery.Status = DeliveryStatusPending
+		releaseErr := d.Repository.UpdateDelivery(ctx, delivery)
+		if releaseErr != nil {
+			log.Printf("There's an error when releasing webhook delivery "+
+				"%s => %s", delivery.ID.Hex(), releaseErr)
+		}
+		return delivery, err
+	}
+
+	attempt.StatusCode, err = d.send(ctx, s, delivery, now)
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	delivery.Attempts = append(delivery.Attempts, attempt)
+
+	// set delivery status by the result
+	switch {
+	case err == nil:
+		delivery.Status = DeliveryStatusSucceeded
+	case len(delivery.Attempts) >= d.MaxAttempts:
+		delivery.Status = DeliveryStatusDead
+	default:
+		delivery.Status = DeliveryStatusPending
+		delivery.NextAttemptAt = now.Add(d.backoff(len(delivery.Attempts)))
+	}
+
+	return delivery, d.Repository.UpdateDelivery(ctx, delivery)
+}
+
+// Run periodically attempt due deliveries until ctx done
+func (d *Deliverer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := d.DeliverDue(ctx)
+			if err != nil {
+				log.Printf("There's an error when delivering webhooks "+
+					"=> %s", err)
+			}
+		}
+	}
+}
+
+// send send signed delivery payload to subscription URL,
+// return response status code, non 2xx status is an error
+func (d *Deliverer) send(ctx context.Context, s Subscription,
+	delivery Delivery, now time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL,
+		bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, delivery.ID.Hex())
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(s.Secret, now, delivery.Payload))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("response status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// backoff get wait time after total attempts failed
+func (d *Deliverer) backoff(attempts int) time.Duration {
+	wait := d.BaseBackoff
+	for i := 1; i < attempts && wait < d.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > d.MaxBackoff {
+		wait = d.MaxBackoff
+	}
+
+	return wait
+}
+
+// now get current time
+func (d *Deliverer) now() time.Time {
+	if d.Now != nil {
+		return d.Now().UTC()
+	}
+
+	return time.Now().UTC()
+}
@@ -0,0 +1,233 @@
+/*
+Package webhook containing seller webhook subscriptions
+and signed delivery of order events to them
+*/
+package webhook
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryRepository thread-safe webhook subscription and delivery
+// repository stored in memory, mostly used for testing
+type MemoryRepository struct {
+	mu            sync.RWMutex
+	subscriptions []Subscription
+	deliveries    []Delivery
+}
+
+// NewMemoryRepository create empty in-memory webhook repository
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{}
+}
+
+// InsertSubscription insert new subscription to memory
+func (r *MemoryRepository) InsertSubscription(ctx context.Context,
+	s Subscription) (Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s.ID = primitive.NewObjectID()
+	stored := Subscription{}
+	err := copyValue(s, &stored)
+	if err != nil {
+		return s, err
+	}
+	r.subscriptions = append(r.subscriptions, stored)
+
+	return s, nil
+}
+
+// GetSubscription get subscription by ID from memory
+func (r *MemoryRepository) GetSubscription(ctx context.Context,
+	id primitive.ObjectID) (Subscription, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := Subscription{}
+	for _, s := range r.subscriptions {
+		if s.ID == id {
+			return result, copyValue(s, &result)
+		}
+	}
+
+	return result, ErrSubscriptionNotFound
+}
+
+// ListSubscriptions get subscriptions of seller from memory
+func (r *MemoryRepository) ListSubscriptions(ctx context.Context,
+	sellerID int) ([]Subscription, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	subscriptions := []Subscription{}
+	for _, s := range r.subscriptions {
+		if sellerID != 0 && s.SellerID != sellerID {
+			continue
+		}
+
+		result := Subscription{}
+		err := copyValue(s, &result)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, result)
+	}
+
+	return subscriptions, nil
+}
+
+// DeleteSubscription delete subscription by ID from memory
+func (r *MemoryRepository) DeleteSubscription(ctx context.Context,
+	id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, s := range r.subscriptions {
+		if s.ID == id {
+			r.subscriptions = append(r.subscriptions[:i], r.subscriptions[i+1:]...)
+			return nil
+		}
+	}
+
+	return ErrSubscriptionNotFound
+}
+
+// InsertDelivery insert new delivery to memory
+func (r *MemoryRepository) InsertDelivery(ctx context.Context,
+	d Delivery) (Delivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, stored := range r.deliveries {
+		if stored.SubscriptionID == d.SubscriptionID &&
+			stored.EventID == d.EventID {
+			return d, ErrDeliveryExists
+		}
+	}
+
+	d.ID = primitive.NewObjectID()
+	stored := Delivery{}
+	err := copyValue(d, &stored)
+	if err != nil {
+		return d, err
+	}
+	r.deliveries = append(r.deliveries, stored)
+
+	return d, nil
+}
+
+// GetDelivery get delivery by ID from memory
+func (r *MemoryRepository) GetDelivery(ctx context.Context,
+	id primitive.ObjectID) (Delivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := Delivery{}
+	for _, d := range r.deliveries {
+		if d.ID == id {
+			return result, copyValue(d, &result)
+		}
+	}
+
+	return result, ErrDeliveryNotFound
+}
+
+// UpdateDelivery replace delivery with the same ID in memory
+func (r *MemoryRepository) UpdateDelivery(ctx context.Context,
+	d Delivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.deliveries {
+		if r.deliveries[i].ID == d.ID {
+			stored := Delivery{}
+			err := copyValue(d, &stored)
+			if err != nil {
+				return err
+			}
+			r.deliveries[i] = stored
+
+			return nil
+		}
+	}
+
+	return ErrDeliveryNotFound
+}
+
+// ListDeliveries get deliveries of subscription from memory
+func (r *MemoryRepository) ListDeliveries(ctx context.Context,
+	subscriptionID primitive.ObjectID, status string) ([]Delivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.filterDeliveries(func(d Delivery) bool {
+		return d.SubscriptionID == subscriptionID &&
+			(status == "" || d.Status == status)
+	}, 0)
+}
+
+// ClaimDueDelivery claim due delivery in memory
+func (r *MemoryRepository) ClaimDueDelivery(ctx context.Context,
+	now time.Time, lockedUntil time.Time) (Delivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := Delivery{}
+	for i, d := range r.deliveries {
+		due := d.Status == DeliveryStatusPending && !d.NextAttemptAt.After(now)
+		expired := d.Status == DeliveryStatusSending && !d.LockedUntil.After(now)
+		if !due && !expired {
+			continue
+		}
+
+		r.deliveries[i].Status = DeliveryStatusSending
+		r.deliveries[i].LockedUntil = lockedUntil
+
+		return result, copyValue(r.deliveries[i], &result)
+	}
+
+	return result, ErrDeliveryNotFound
+}
+
+// filterDeliveries get copy of deliveries match, max limit deliveries,
+// limit 0 means no limit
+//
+// caller must hold the lock
+func (r *MemoryRepository) filterDeliveries(match func(d Delivery) bool,
+	limit int64) ([]Delivery, error) {
+	deliveries := []Delivery{}
+	for _, d := range r.deliveries {
+		if limit > 0 && int64(len(deliveries)) >= limit {
+			break
+		}
+		if !match(d) {
+			continue
+		}
+
+		result := Delivery{}
+		err := copyValue(d, &result)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, result)
+	}
+
+	return deliveries, nil
+}
+
+// copyValue deep copy value into result so stored value
+// can't be changed from outside the repository
+func copyValue(value interface{}, result interface{}) error {
+	doc, err := bson.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return bson.Unmarshal(doc, result)
+}
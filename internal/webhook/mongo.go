@@ -0,0 +1,228 @@
+/*
+Package webhook containing seller webhook subscriptions
+and signed delivery of order events to them
+*/
+package webhook
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoRepository webhook subscription and delivery repository
+// stored in mongodb collections
+type MongoRepository struct {
+	Subscriptions *mongo.Collection
+	Deliveries    *mongo.Collection
+}
+
+// NewMongoRepository create webhook repository using mongodb
+// webhook subscriptions and webhook deliveries collection
+func NewMongoRepository(sc *mongo.Collection,
+	dc *mongo.Collection) *MongoRepository {
+	return &MongoRepository{Subscriptions: sc, Deliveries: dc}
+}
+
+// CreateIndexes create indexes of webhook subscriptions and deliveries
+// collection, including unique index of event delivered to subscription
+func (r *MongoRepository) CreateIndexes(ctx context.Context) error {
+	_, err := r.Subscriptions.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{primitive.E{Key: "seller_id", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Deliveries.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				primitive.E{Key: "subscription_id", Value: 1},
+				primitive.E{Key: "event_id", Value: 1},
+			},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{
+				primitive.E{Key: "status", Value: 1},
+				primitive.E{Key: "next_attempt_at", Value: 1},
+			},
+		},
+		{
+			Keys: bson.D{
+				primitive.E{Key: "status", Value: 1},
+				primitive.E{Key: "locked_until", Value: 1},
+			},
+		},
+	})
+
+	return err
+}
+
+// InsertSubscription insert new subscription
+// to webhook subscriptions collection
+func (r *MongoRepository) InsertSubscription(ctx context.Context,
+	s Subscription) (Subscription, error) {
+	s.ID = primitive.NewObjectID()
+	_, err := r.Subscriptions.InsertOne(ctx, s)
+
+	return s, err
+}
+
+// GetSubscription get subscription by ID
+// from webhook subscriptions collection
+func (r *MongoRepository) GetSubscription(ctx context.Context,
+	id primitive.ObjectID) (Subscription, error) {
+	s := Subscription{}
+
+	err := r.Subscriptions.FindOne(ctx, bson.M{"_id": id}).Decode(&s)
+	if err == mongo.ErrNoDocuments {
+		return s, ErrSubscriptionNotFound
+	}
+
+	return s, err
+}
+
+// ListSubscriptions get subscriptions of seller
+// from webhook subscriptions collection
+func (r *MongoRepository) ListSubscriptions(ctx context.Context,
+	sellerID int) ([]Subscription, error) {
+	subscriptions := []Subscription{}
+
+	filter := bson.M{}
+	if sellerID != 0 {
+		filter["seller_id"] = sellerID
+	}
+
+	cursor, err := r.Subscriptions.Find(ctx, filter,
+		options.Find().SetSort(bson.D{primitive.E{Key: "_id", Value: 1}}))
+	if err != nil {
+		return subscriptions, err
+	}
+
+	err = cursor.All(ctx, &subscriptions)
+	return subscriptions, err
+}
+
+// DeleteSubscription delete subscription by ID
+// from webhook subscriptions collection
+func (r *MongoRepository) DeleteSubscription(ctx context.Context,
+	id primitive.ObjectID) error {
+	result, err := r.Subscriptions.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrSubscriptionNotFound
+	}
+
+	return nil
+}
+
+// InsertDelivery insert new delivery to webhook deliveries collection
+func (r *MongoRepository) InsertDelivery(ctx context.Context,
+	d Delivery) (Delivery, error) {
+	d.ID = primitive.NewObjectID()
+	_, err := r.Deliveries.InsertOne(ctx, d)
+	if mongo.IsDuplicateKeyError(err) {
+		return d, ErrDeliveryExists
+	}
+
+	return d, err
+}
+
+// GetDelivery get delivery by ID from webhook deliveries collection
+func (r *MongoRepository) GetDelivery(ctx context.Context,
+	id primitive.ObjectID) (Delivery, error) {
+	d := Delivery{}
+
+	err := r.Deliveries.FindOne(ctx, bson.M{"_id": id}).Decode(&d)
+	if err == mongo.ErrNoDocuments {
+		return d, ErrDeliveryNotFound
+	}
+
+	return d, err
+}
+
+// UpdateDelivery replace delivery with the same ID
+// in webhook deliveries collection
+func (r *MongoRepository) UpdateDelivery(ctx context.Context,
+	d Delivery) error {
+	result, err := r.Deliveries.ReplaceOne(ctx, bson.M{"_id": d.ID}, d)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrDeliveryNotFound
+	}
+
+	return nil
+}
+
+// ListDeliveries get deliveries of subscription
+// from webhook deliveries collection
+func (r *MongoRepository) ListDeliveries(ctx context.Context,
+	subscriptionID primitive.ObjectID, status string) ([]Delivery, error) {
+	filter := bson.M{"subscription_id": subscriptionID}
+	if status != "" {
+		filter["status"] = status
+	}
+
+	return r.findDeliveries(ctx, filter, 0)
+}
+
+// ClaimDueDelivery claim due delivery in webhook deliveries collection
+func (r *MongoRepository) ClaimDueDelivery(ctx context.Context,
+	now time.Time, lockedUntil time.Time) (Delivery, error) {
+	d := Delivery{}
+
+	filter := bson.M{"$or": bson.A{
+		bson.M{
+			"status":          DeliveryStatusPending,
+			"next_attempt_at": bson.M{"$lte": now},
+		},
+		bson.M{
+			"status":       DeliveryStatusSending,
+			"locked_until": bson.M{"$lte": now},
+		},
+	}}
+	update := bson.M{"$set": bson.M{
+		"status":       DeliveryStatusSending,
+		"locked_until": lockedUntil,
+	}}
+	updateOpts := options.FindOneAndUpdate().
+		SetSort(bson.D{primitive.E{Key: "_id", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	err := r.Deliveries.FindOneAndUpdate(ctx, filter, update, updateOpts).
+		Decode(&d)
+	if err == mongo.ErrNoDocuments {
+		return d, ErrDeliveryNotFound
+	}
+
+	return d, err
+}
+
+// findDeliveries get deliveries match the filter sorted by creation time,
+// max limit deliveries, limit 0 means no limit
+func (r *MongoRepository) findDeliveries(ctx context.Context,
+	filter bson.M, limit int64) ([]Delivery, error) {
+	deliveries := []Delivery{}
+
+	findOpts := options.Find().SetSort(bson.D{primitive.E{Key: "_id", Value: 1}})
+	if limit > 0 {
+		findOpts.SetLimit(limit)
+	}
+
+	cursor, err := r.Deliveries.Find(ctx, filter, findOpts)
+	if err != nil {
+		return deliveries, err
+	}
+
+	err = cursor.All(ctx, &deliveries)
+	return deliveries, err
+}
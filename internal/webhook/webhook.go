@@ -0,0 +1,220 @@
+/*
+Package webhook containing seller webhook subscriptions
+and signed delivery of order events to them
+*/
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/reyhanfikridz/ecom-order-service/internal/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// delivery statuses
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusSending   = "sending"
+	DeliveryStatusSucceeded = "succeeded"
+	DeliveryStatusDead      = "dead"
+)
+
+// headers of webhook delivery request
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+var (
+	// ErrSubscriptionNotFound returned when there's no subscription
+	// with the ID
+	ErrSubscriptionNotFound = errors.New("webhook subscription not found")
+
+	// ErrDeliveryNotFound returned when there's no delivery with the ID
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+
+	// ErrDeliveryExists returned when event already delivered
+	// to the subscription
+	ErrDeliveryExists = errors.New("webhook delivery already exist")
+)
+
+// EventTypes order event types that can be subscribed
+var EventTypes = []string{
+	model.EventOrderCreated,
+	model.EventOrderUpdated,
+	model.EventOrderStatusChanged,
+	model.EventOrderCancelled,
+	model.EventOrderDeleted,
+	model.EventOrderRestored,
+}
+
+// Subscription seller subscription of order events to webhook URL,
+// empty EventTypes means all event types
+//
+// Secret used to sign deliveries, only shown when subscription created
+type Subscription struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	SellerID   int                `bson:"seller_id" json:"seller_id"`
+	URL        string             `bson:"url" json:"url" form:"url"`
+	Secret     string             `bson:"secret" json:"secret,omitempty" form:"secret"`
+	EventTypes []string           `bson:"event_types" json:"event_types" form:"event_types"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// IsSubscribed check if subscription subscribe to event type
+func (s Subscription) IsSubscribed(eventType string) bool {
+	if len(s.EventTypes) == 0 {
+		return true
+	}
+
+	for _, t := range s.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Delivery delivery of one order event to one subscription
+//
+// sending delivery is claimed by one deliverer until LockedUntil,
+// after that it can be claimed again if the deliverer stopped
+type Delivery struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	SubscriptionID primitive.ObjectID `bson:"subscription_id" json:"subscription_id"`
+	EventID        primitive.ObjectID `bson:"event_id" json:"event_id"`
+	EventType      string             `bson:"event_type" json:"event_type"`
+	OrderNumber    string             `bson:"order_number" json:"order_number"`
+	Payload        json.RawMessage    `bson:"payload" json:"payload"`
+	Status         string             `bson:"status" json:"status"`
+	Attempts       []Attempt          `bson:"attempts" json:"attempts"`
+	NextAttemptAt  time.Time          `bson:"next_attempt_at" json:"next_attempt_at"`
+	LockedUntil    time.Time          `bson:"locked_until,omitempty" json:"-"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// Attempt result of one attempt of delivery,
+// zero StatusCode means there's no response
+type Attempt struct {
+	At         time.Time `bson:"at" json:"at"`
+	StatusCode int       `bson:"status_code" json:"status_code"`
+	Error      string    `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+// Repository storage of webhook subscriptions and deliveries
+type Repository interface {
+	// InsertSubscription insert new subscription
+	// and return it with generated ID
+	InsertSubscription(ctx context.Context, s Subscription) (Subscription, error)
+
+	// GetSubscription get subscription by ID,
+	// return ErrSubscriptionNotFound if there's none
+	GetSubscription(ctx context.Context, id primitive.ObjectID) (Subscription, error)
+
+	// ListSubscriptions get subscriptions of seller sorted by creation time,
+	// seller ID 0 means all sellers
+	ListSubscriptions(ctx context.Context, sellerID int) ([]Subscription, error)
+
+	// DeleteSubscription delete subscription by ID,
+	// return ErrSubscriptionNotFound if there's none
+	DeleteSubscription(ctx context.Context, id primitive.ObjectID) error
+
+	// InsertDelivery insert new delivery and return it with generated ID,
+	// return ErrDeliveryExists if the event already delivered
+	// to the subscription
+	InsertDelivery(ctx context.Context, d Delivery) (Delivery, error)
+
+	// GetDelivery get delivery by ID,
+	// return ErrDeliveryNotFound if there's none
+	GetDelivery(ctx context.Context, id primitive.ObjectID) (Delivery, error)
+
+	// UpdateDelivery replace delivery with the same ID,
+	// return ErrDeliveryNotFound if there's none
+	UpdateDelivery(ctx context.Context, d Delivery) error
+
+	// ListDeliveries get deliveries of subscription sorted by creation time,
+	// filtered by status if not empty
+	ListDeliveries(ctx context.Context, subscriptionID primitive.ObjectID,
+		status string) ([]Delivery, error)
+
+	// ClaimDueDelivery atomically claim the first created pending delivery
+	// with next attempt time not after now, or sending delivery with
+	// expired claim, by making it sending until lockedUntil,
+	// return ErrDeliveryNotFound if there's none
+	ClaimDueDelivery(ctx context.Context, now time.Time,
+		lockedUntil time.Time) (Delivery, error)
+}
+
+// ValidateSubscription check subscription URL and event types valid,
+// URL must be https
+func ValidateSubscription(s Subscription) error {
+	u, err := url.Parse(s.URL)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		return fmt.Errorf("url '%s' invalid, must be https URL", s.URL)
+	}
+
+	for _, eventType := range s.EventTypes {
+		valid := false
+		for _, t := range EventTypes {
+			if t == eventType {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("event type '%s' invalid", eventType)
+		}
+	}
+
+	return nil
+}
+
+// NewSecret generate random secret of subscription
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// Sign get signature of delivery payload sent at the time,
+// which is "sha256=" followed by hex of HMAC-SHA256 of
+// "<unix timestamp>.<payload>" using subscription secret
+func Sign(secret string, timestamp time.Time, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify check if signature is signature of payload sent at timestamp
+// (unix timestamp string) using the secret,
+// used by receiver of the webhook
+func Verify(secret string, timestamp string, payload []byte,
+	signature string) bool {
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	expected := Sign(secret, time.Unix(unix, 0), payload)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
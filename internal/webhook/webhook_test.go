@@ -0,0 +1,492 @@
+/*
+Package webhook containing seller webhook subscriptions
+and signed delivery of order events to them
+*/
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/reyhanfikridz/ecom-order-service/internal/model"
+	"github.com/reyhanfikridz/ecom-order-service/internal/money"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// testReceiver webhook receiver for testing, verifying each request
+// signature and responding with Status
+type testReceiver struct {
+	Secret string
+	Status int
+
+	mu       sync.Mutex
+	received []model.OrderEvent
+	invalid  int
+}
+
+// ServeHTTP receive webhook request
+func (rc *testReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	if !Verify(rc.Secret, r.Header.Get(HeaderTimestamp), body,
+		r.Header.Get(HeaderSignature)) {
+		rc.invalid++
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if rc.Status != http.StatusOK {
+		w.WriteHeader(rc.Status)
+		return
+	}
+
+	var e model.OrderEvent
+	_ = json.Unmarshal(body, &e)
+	rc.received = append(rc.received, e)
+	w.WriteHeader(http.StatusOK)
+}
+
+// getTestingEvent get event of order with item of seller for testing
+func getTestingEvent(eventType string, sellerID int) model.OrderEvent {
+	return model.NewOrderEvent(eventType, nil, model.Order{
+		OrderNumber: "ORDER1",
+		Status:      model.OrderStatusCheckedOut,
+		BuyerID:     1,
+		Items: []model.OrderItem{
+			{ProductID: 1,
+				ProductPrice: money.MustParse("1000"), ProductUserID: sellerID, Qty: 1},
+		},
+	})
+}
+
+// TestSignAndVerify test Sign and Verify
+func TestSignAndVerify(t *testing.T) {
+	now := time.Unix(1660000000, 0)
+	payload := []byte(`{"type":"OrderCreated"}`)
+
+	signature := Sign("secret", now, payload)
+	if !Verify("secret", "1660000000", payload, signature) {
+		t.Errorf("Expected signature %s valid", signature)
+	}
+	if Verify("other secret", "1660000000", payload, signature) {
+		t.Errorf("Expected signature invalid with other secret")
+	}
+	if Verify("secret", "1660000001", payload, signature) {
+		t.Errorf("Expected signature invalid with other timestamp")
+	}
+	if Verify("secret", "1660000000", []byte(`{}`), signature) {
+		t.Errorf("Expected signature invalid with other payload")
+	}
+}
+
+// TestValidateSubscription test ValidateSubscription
+func TestValidateSubscription(t *testing.T) {
+	// initialize testing table
+	testTable := []struct {
+		TestName      string
+		Subscription  Subscription
+		ExpectedValid bool
+	}{
+		{
+			TestName:      "Test Valid",
+			Subscription:  Subscription{URL: "https://seller.example.com/hook"},
+			ExpectedValid: true,
+		},
+		{
+			TestName: "Test Valid Event Types",
+			Subscription: Subscription{
+				URL:        "https://seller.example.com/hook",
+				EventTypes: []string{model.EventOrderCreated},
+			},
+			ExpectedValid: true,
+		},
+		{
+			TestName:      "Test Invalid URL HTTP",
+			Subscription:  Subscription{URL: "http://seller.example.com/hook"},
+			ExpectedValid: false,
+		},
+		{
+			TestName:      "Test Invalid URL Scheme",
+			Subscription:  Subscription{URL: "ftp://seller.example.com/hook"},
+			ExpectedValid: false,
+		},
+		{
+			TestName:      "Test Invalid URL Empty",
+			Subscription:  Subscription{},
+			ExpectedValid: false,
+		},
+		{
+			TestName: "Test Invalid Event Type",
+			Subscription: Subscription{
+				URL:        "https://seller.example.com/hook",
+				EventTypes: []string{"OrderShipped"},
+			},
+			ExpectedValid: false,
+		},
+	}
+
+	// test for each testing table
+	for _, test := range testTable {
+		err := ValidateSubscription(test.Subscription)
+		if (err == nil) != test.ExpectedValid {
+			t.Errorf("[%s] Expected valid %t, but got error %v",
+				test.TestName, test.ExpectedValid, err)
+		}
+	}
+}
+
+// TestDelivery test Dispatcher and Deliverer deliver event
+// to subscribed seller webhook, with retries and dead letter
+func TestDelivery(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemoryRepository()
+
+	receiver := &testReceiver{Secret: "secret", Status: http.StatusOK}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	// subscribe seller 10 to status change, seller 20 to all events
+	sub, err := r.InsertSubscription(ctx, Subscription{
+		SellerID:   10,
+		URL:        server.URL,
+		Secret:     "secret",
+		EventTypes: []string{model.EventOrderStatusChanged},
+	})
+	if err != nil {
+		t.Fatalf("There's an error when inserting subscription => %s", err)
+	}
+	_, err = r.InsertSubscription(ctx, Subscription{
+		SellerID: 20,
+		URL:      server.URL,
+		Secret:   "secret",
+	})
+	if err != nil {
+		t.Fatalf("There's an error when inserting subscription => %s", err)
+	}
+
+	// publish events, status change published twice
+	dispatcher := NewDispatcher(r)
+	statusChanged := getTestingEvent(model.EventOrderStatusChanged, 10)
+	for _, e := range []model.OrderEvent{
+		getTestingEvent(model.EventOrderCreated, 10),
+		statusChanged,
+		statusChanged,
+	} {
+		err = dispatcher.Publish(ctx, e)
+		if err != nil {
+			t.Fatalf("Expected publish success, but got error => %s", err)
+		}
+	}
+
+	deliveries, err := r.ListDeliveries(ctx, sub.ID, "")
+	if err != nil {
+		t.Fatalf("There's an error when getting deliveries => %s", err)
+	}
+	if len(deliveries) != 1 || deliveries[0].EventID != statusChanged.ID {
+		t.Fatalf("Expected only one delivery of status change, but got %+v",
+			deliveries)
+	}
+
+	// test delivery succeeded and signed,
+	// time stored in milliseconds so it's truncated
+	now := time.Now().Truncate(time.Millisecond)
+	deliverer := NewDeliverer(r)
+	deliverer.Client = server.Client()
+	deliverer.MaxAttempts = 3
+	deliverer.Now = func() time.Time { return now }
+
+	total, err := deliverer.DeliverDue(ctx)
+	if err != nil || total != 1 {
+		t.Fatalf("Expected 1 delivery succeeded, but got %d (error %v)",
+			total, err)
+	}
+	if len(receiver.received) != 1 || receiver.invalid != 0 ||
+		receiver.received[0].ID != statusChanged.ID {
+		t.Errorf("Expected event %s received with valid signature, "+
+			"but got %+v (%d invalid)", statusChanged.ID.Hex(),
+			receiver.received, receiver.invalid)
+	}
+
+	// test failed delivery retried with backoff then moved to dead letters
+	receiver.Status = http.StatusInternalServerError
+	e := getTestingEvent(model.EventOrderStatusChanged, 10)
+	err = dispatcher.Publish(ctx, e)
+	if err != nil {
+		t.Fatalf("Expected publish success, but got error => %s", err)
+	}
+	now = time.Now().Truncate(time.Millisecond)
+
+	expectedWaits := []time.Duration{30 * time.Second, time.Minute}
+	for i, wait := range expectedWaits {
+		_, err = deliverer.DeliverDue(ctx)
+		if err != nil {
+			t.Fatalf("Expected error nil, but got %s", err)
+		}
+
+		deliveries, _ = r.ListDeliveries(ctx, sub.ID, DeliveryStatusPending)
+		if len(deliveries) != 1 || len(deliveries[0].Attempts) != i+1 ||
+			!deliveries[0].NextAttemptAt.Equal(now.UTC().Add(wait)) {
+			t.Fatalf("Expected attempt %d retried after %s, but got %+v",
+				i+1, wait, deliveries)
+		}
+		if deliveries[0].Attempts[i].StatusCode != http.StatusInternalServerError {
+			t.Errorf("Expected attempt status code 500, but got %d",
+				deliveries[0].Attempts[i].StatusCode)
+		}
+
+		// test delivery not attempted before next attempt time
+		_, err = deliverer.DeliverDue(ctx)
+		if err != nil {
+			t.Fatalf("Expected error nil, but got %s", err)
+		}
+		deliveries, _ = r.ListDeliveries(ctx, sub.ID, DeliveryStatusPending)
+		if len(deliveries[0].Attempts) != i+1 {
+			t.Errorf("Expected delivery not attempted before backoff passed")
+		}
+
+		now = now.Add(wait)
+	}
+
+	_, err = deliverer.DeliverDue(ctx)
+	if err != nil {
+		t.Fatalf("Expected error nil, but got %s", err)
+	}
+	deadLetters, err := r.ListDeliveries(ctx, sub.ID, DeliveryStatusDead)
+	if err != nil {
+		t.Fatalf("There's an error when getting dead letters => %s", err)
+	}
+	if len(deadLetters) != 1 || len(deadLetters[0].Attempts) != 3 {
+		t.Fatalf("Expected delivery dead after 3 attempts, but got %+v",
+			deadLetters)
+	}
+
+	// test dead letter retried
+	receiver.Status = http.StatusOK
+	_, err = RetryDelivery(ctx, r, deadLetters[0])
+	now = time.Now()
+	if err != nil {
+		t.Fatalf("Expected retry success, but got error => %s", err)
+	}
+	total, err = deliverer.DeliverDue(ctx)
+	if err != nil || total != 1 {
+		t.Errorf("Expected dead letter delivered, but got %d (error %v)",
+			total, err)
+	}
+}
+
+// subscriptionFailingRepository repository failed getting subscription
+type subscriptionFailingRepository struct {
+	*MemoryRepository
+	Err error
+}
+
+// GetSubscription return Err
+func (r subscriptionFailingRepository) GetSubscription(ctx context.Context,
+	id primitive.ObjectID) (Subscription, error) {
+	return Subscription{}, r.Err
+}
+
+// TestDeliverDueClaim test Deliverer DeliverDue claim each delivery
+// so it's only sent once by many deliverers, and release the claim
+func TestDeliverDueClaim(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemoryRepository()
+
+	receiver := &testReceiver{Secret: "secret", Status: http.StatusOK}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	_, err := r.InsertSubscription(ctx, Subscription{
+		SellerID: 10,
+		URL:      server.URL,
+		Secret:   "secret",
+	})
+	if err != nil {
+		t.Fatalf("There's an error when inserting subscription => %s", err)
+	}
+
+	dispatcher := NewDispatcher(r)
+	for i := 0; i < 20; i++ {
+		err = dispatcher.Publish(ctx,
+			getTestingEvent(model.EventOrderCreated, 10))
+		if err != nil {
+			t.Fatalf("Expected publish success, but got error => %s", err)
+		}
+	}
+
+	// test deliveries sent once by deliverers of many instances
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		total int
+	)
+	for i := 0; i < 3; i++ {
+		deliverer := NewDeliverer(r)
+		deliverer.Client = server.Client()
+		deliverer.Workers = 4
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			succeeded, err := deliverer.DeliverDue(ctx)
+			if err != nil {
+				t.Errorf("Expected error nil, but got %s", err)
+			}
+
+			mu.Lock()
+			total += succeeded
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if total != 20 || len(receiver.received) != 20 {
+		t.Errorf("Expected 20 deliveries sent once, but got %d succeeded "+
+			"and %d received", total, len(receiver.received))
+	}
+
+	// test claim released when delivery couldn't be attempted
+	err = dispatcher.Publish(ctx, getTestingEvent(model.EventOrderCreated, 10))
+	if err != nil {
+		t.Fatalf("Expected publish success, but got error => %s", err)
+	}
+
+	deliverer := NewDeliverer(subscriptionFailingRepository{
+		MemoryRepository: r,
+		Err:              errors.New("database down"),
+	})
+	_, err = deliverer.DeliverDue(ctx)
+	if err == nil {
+		t.Errorf("Expected error database down, but got nil")
+	}
+
+	now := time.Now()
+	pending, err := r.ClaimDueDelivery(ctx, now, now.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("Expected released delivery claimed again, "+
+			"but got error %s", err)
+	}
+	if len(pending.Attempts) != 0 {
+		t.Errorf("Expected released delivery not attempted, but got %+v",
+			pending.Attempts)
+	}
+
+	// test claimed delivery not claimed again until the lock expired
+	_, err = r.ClaimDueDelivery(ctx, now, now.Add(time.Minute))
+	if err != ErrDeliveryNotFound {
+		t.Errorf("Expected error %s, but got %v", ErrDeliveryNotFound, err)
+	}
+
+	expired, err := r.ClaimDueDelivery(ctx, now.Add(time.Minute),
+		now.Add(2*time.Minute))
+	if err != nil || expired.ID != pending.ID {
+		t.Errorf("Expected delivery %s claimed after lock expired, "+
+			"but got %s (error %v)", pending.ID.Hex(), expired.ID.Hex(), err)
+	}
+}
+
+// TestDispatcherSellerPayload test Dispatcher deliver event
+// of multi-seller order with only the seller items and without
+// buyer personal data
+func TestDispatcherSellerPayload(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemoryRepository()
+
+	sub, err := r.InsertSubscription(ctx, Subscription{
+		SellerID: 10,
+		URL:      "https://seller.example.com/hook",
+		Secret:   "secret",
+	})
+	if err != nil {
+		t.Fatalf("There's an error when inserting subscription => %s", err)
+	}
+
+	e := getTestingEvent(model.EventOrderCreated, 10)
+	e.Order.BuyerFullName = "George Marcus"
+	e.Order.BuyerAddress = "Buyer Street"
+	e.Order.Items = append(e.Order.Items, model.OrderItem{ProductID: 2,
+		ProductPrice: money.MustParse("500"), ProductUserID: 20, Qty: 2})
+	e.Order.CalculateTotal()
+
+	err = NewDispatcher(r).Publish(ctx, e)
+	if err != nil {
+		t.Fatalf("Expected publish success, but got error => %s", err)
+	}
+
+	deliveries, err := r.ListDeliveries(ctx, sub.ID, "")
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("Expected one delivery, but got %+v (error %v)",
+			deliveries, err)
+	}
+
+	var result model.OrderEvent
+	err = json.Unmarshal(deliveries[0].Payload, &result)
+	if err != nil {
+		t.Fatalf("There's an error when unmarshal payload => %s", err)
+	}
+	if len(result.Order.Items) != 1 || result.Order.Items[0].ProductID != 1 ||
+		result.Order.TotalPrice != money.MustParse("1000") ||
+		result.Order.BuyerFullName != "" || result.Order.BuyerAddress != "" {
+		t.Errorf("Expected payload with only seller item and without "+
+			"buyer personal data, but got %s", deliveries[0].Payload)
+	}
+
+	// check published event not changed
+	if len(e.Order.Items) != 2 || e.Order.BuyerAddress != "Buyer Street" {
+		t.Errorf("Expected published event not changed, but got %+v", e)
+	}
+}
+
+// TestNewClient test client from NewClient not connect to loopback address
+// and not follow redirect
+func TestNewClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "http://169.254.169.254/", http.StatusFound)
+		}))
+	defer server.Close()
+
+	// test loopback address not allowed
+	_, err := NewClient(time.Second).Post(server.URL, "application/json", nil)
+	if !errors.Is(err, ErrAddressNotAllowed) {
+		t.Errorf("Expected error %v, but got %v", ErrAddressNotAllowed, err)
+	}
+
+	// test redirect not followed
+	client := server.Client()
+	client.CheckRedirect = NewClient(time.Second).CheckRedirect
+	resp, err := client.Post(server.URL, "application/json", nil)
+	if err != nil {
+		t.Fatalf("Expected error nil, but got %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Errorf("Expected status %d, but got %d", http.StatusFound,
+			resp.StatusCode)
+	}
+
+	// test public address allowed
+	for _, address := range []string{"93.184.216.34", "2606:2800:220:1::"} {
+		if !isPublicIP(net.ParseIP(address)) {
+			t.Errorf("Expected %s public address", address)
+		}
+	}
+	for _, address := range []string{"127.0.0.1", "10.0.0.1", "192.168.1.1",
+		"169.254.169.254", "100.64.0.1", "0.0.0.0", "::1", "fe80::1",
+		"fd00::1", "::ffff:127.0.0.1"} {
+		if isPublicIP(net.ParseIP(address)) {
+			t.Errorf("Expected %s not public address", address)
+		}
+	}
+}